@@ -0,0 +1,155 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "text/template"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// newAbuseReporter builds the abuse sink from its settings, remembering
+// the domains it reported in history.
+func newAbuseReporter(cfg *Config, history *store.History) (*notify.AbuseReporter, error) {
+    a := cfg.Sinks.Abuse
+    var ledger notify.TicketLedger
+    if history.Enabled() {
+        ledger = history
+    }
+    reporter := notify.NewAbuseReporter(ledger)
+    reporter.RDAPServer, reporter.CC, reporter.Drafts, reporter.Interval = a.RDAP, a.CC, a.Drafts, a.Interval
+    reporter.From = a.From
+    resolver, err := analysis.NewResolver(cfg.Analyzers.DNS)
+    if err != nil {
+        return nil, err
+    }
+    reporter.Resolver = resolver
+    if reporter.From == "" {
+        reporter.From = cfg.Sinks.Email.From
+    }
+    if a.Template != "" {
+        t, err := template.ParseFiles(a.Template)
+        if err != nil {
+            return nil, fmt.Errorf("sinks.abuse.template: %v", err)
+        }
+        reporter.Template = t
+    }
+    if a.Send {
+        e := cfg.Sinks.Email
+        reporter.Mail = &notify.EmailNotifier{Addr: e.Addr, Username: e.Username, Password: e.Password, From: e.From}
+    }
+    if w := a.WebRisk; w.Project != "" {
+        submitter, err := notify.NewWebRiskSubmitter(w.Project, w.Credentials)
+        if err != nil {
+            return nil, fmt.Errorf("sinks.abuse.web_risk: %v", err)
+        }
+        reporter.WebRisk = submitter
+    }
+    return reporter, nil
+}
+
+// abuseCommand reports a link from the history, or any link, to its
+// registrar and host by hand, whatever its verdict and however recently
+// its domain was reported.
+func abuseCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("abuse")
+    send := fs.Bool("send", false, "email the report even if sinks.abuse.send is off")
+    asJSON := fs.Bool("json", false, "print who the link was reported to as JSON")
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        return fmt.Errorf("usage: %s abuse [--send] [--json] <history ID or url>", os.Args[0])
+    }
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if *send {
+        if cfg.Sinks.Email.Addr == "" {
+            return fmt.Errorf("--send: needs sinks.email's server to send reports through")
+        }
+        cfg.Sinks.Abuse.Send = true
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    alert, seen, err := abuseTarget(history, fs.Arg(0))
+    if err != nil {
+        return err
+    }
+    reporter, err := newAbuseReporter(cfg, history)
+    if err != nil {
+        return err
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, submitTimeout)
+    defer cancel()
+    report, err := reporter.Report(ctx, alert, seen)
+    if report != nil && (report.Sent || report.Draft != "" || report.WebRisk) {
+        auditConfigured(cfg, store.AuditEvent{Actor: cliActor(), Action: "abuse", Target: alert.URL})
+    }
+    if report == nil {
+        return err
+    }
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetEscapeHTML(false)
+        enc.SetIndent("", "  ")
+        if jerr := enc.Encode(report); jerr != nil {
+            return jerr
+        }
+        return err
+    }
+    for _, c := range report.Contacts {
+        fmt.Printf("%s\t%s\t%s\n", c.Role, c.Email, c.Name)
+    }
+    switch {
+    case report.Sent:
+        fmt.Fprintf(os.Stderr, "sent the report on %s\n", notify.Defang(alert.URL))
+    case report.Draft != "":
+        fmt.Fprintf(os.Stderr, "wrote the report on %s to %s\n", notify.Defang(alert.URL), report.Draft)
+    }
+    if report.WebRisk {
+        fmt.Fprintln(os.Stderr, "submitted the link to Google Safe Browsing")
+    }
+    return err
+}
+
+// abuseTarget returns the alert to report for arg: the history entry with
+// that ID, the latest one for that link, or a bare alert for a link that
+// isn't in the history.
+func abuseTarget(history *store.History, arg string) (notify.Alert, time.Time, error) {
+    if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+        entry, err := history.Get(id)
+        if err != nil {
+            return notify.Alert{}, time.Time{}, err
+        }
+        if entry == nil {
+            return notify.Alert{}, time.Time{}, fmt.Errorf("no history entry %d", id)
+        }
+        return entry.Alert, entry.Time, nil
+    }
+    u, err := url.Parse(arg)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return notify.Alert{}, time.Time{}, fmt.Errorf("want a history ID or an http(s) URL, got %q", arg)
+    }
+    alert, seen := notify.Alert{URL: arg}, time.Now()
+    if history.Enabled() {
+        err = history.Each(store.HistoryFilter{Domains: []string{strings.ToLower(u.Hostname())}}, func(e store.HistoryEntry) error {
+            if e.Alert.URL == arg {
+                alert, seen = e.Alert, e.Time
+            }
+            return nil
+        })
+    }
+    return alert, seen, err
+}
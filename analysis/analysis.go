@@ -0,0 +1,466 @@
+// Package analysis scores links for phishing: a Scanner runs a link and the
+// message it came in through a set of analyzers and combines their
+// findings into a verdict.
+package analysis
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/capture"
+)
+
+// Severity ranks how dangerous a link looks.
+type Severity int
+
+const (
+    SeverityClean Severity = iota
+    SeverityInfo
+    SeveritySuspicious
+    SeverityMalicious
+)
+
+var severityNames = []string{"clean", "info", "suspicious", "malicious"}
+
+func (s Severity) String() string {
+    if s < 0 || int(s) >= len(severityNames) {
+        return fmt.Sprintf("severity(%d)", int(s))
+    }
+    return severityNames[s]
+}
+
+// MarshalText encodes the severity by name, e.g. in JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+    return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name.
+func (s *Severity) UnmarshalText(text []byte) error {
+    parsed, err := ParseSeverity(string(text))
+    if err != nil {
+        return err
+    }
+    *s = parsed
+    return nil
+}
+
+// ParseSeverity parses a severity name such as "suspicious".
+func ParseSeverity(name string) (Severity, error) {
+    for i, n := range severityNames {
+        if strings.EqualFold(name, n) {
+            return Severity(i), nil
+        }
+    }
+    return 0, fmt.Errorf("unknown severity %q (want one of %s)", name, strings.Join(severityNames, ", "))
+}
+
+// Target is what gets scanned: a link and the message it arrived in.
+type Target struct {
+    URL  string
+    Text string
+
+    // Language is the ISO 639-1 code of the language to assume when Text
+    // is too short for DetectLanguage, such as the chat's.
+    Language string
+}
+
+// Finding is a single observation made by an analyzer.
+type Finding struct {
+    Analyzer    string   `json:"analyzer"`
+    Severity    Severity `json:"severity"`
+    Description string   `json:"description"`
+
+    // Facts are named values for rules, e.g. has_login_form or, from a
+    // plugin, domain_age_days.
+    Facts map[string]interface{} `json:"facts,omitempty"`
+}
+
+// Verdict is the combined result of scanning a target.
+type Verdict struct {
+    URL      string    `json:"url"`
+    Severity Severity  `json:"severity"`
+    Findings []Finding `json:"findings"`
+
+    // Duration and Runs say how long the scan took and how each analyzer
+    // went; the history keeps them for `telephish stats`.
+    Duration time.Duration `json:"-"`
+    Runs     []Run         `json:"-"`
+}
+
+// Run is how one analyzer went in a scan.
+type Run struct {
+    Analyzer string
+    Duration time.Duration
+    Failed   bool
+    Cached   bool // Answered from the reputation cache, without calling the service
+}
+
+// runKey is the context key of the Run an analyzer is making.
+type runKey struct{}
+
+// markCached notes in ctx's Run that the analyzer answered from cache.
+func markCached(ctx context.Context) {
+    if run, ok := ctx.Value(runKey{}).(*Run); ok {
+        run.Cached = true
+    }
+}
+
+// Analyzer inspects a target and reports findings. Analyzers doing I/O
+// stop when ctx is done, which happens when the scan times out.
+type Analyzer interface {
+    Name() string
+    Analyze(ctx context.Context, target Target) ([]Finding, error)
+}
+
+// SlowAnalyzer is implemented by analyzers that take long enough (page
+// fetches, detonation, screenshots) to warrant a progress toast.
+type SlowAnalyzer interface {
+    Slow() bool
+}
+
+// Scanner runs a target through a list of analyzers.
+type Scanner struct {
+    Analyzers []Analyzer
+    Lists     *Lists // Optional; listed domains skip the analyzers
+
+    // MaliciousCount is how many suspicious findings make a target
+    // malicious.
+    MaliciousCount int
+
+    // Timeout bounds a whole scan; zero means no limit.
+    Timeout time.Duration
+
+    // Capture, if set, records what each analyzer was asked and answered,
+    // and the page fetches behind them.
+    Capture *capture.Recorder
+}
+
+// analyzerCapture is the record of one analyzer run.
+type analyzerCapture struct {
+    Analyzer string    `json:"analyzer"`
+    Target   Target    `json:"target"`
+    Findings []Finding `json:"findings"`
+    Error    string    `json:"error,omitempty"`
+    Duration string    `json:"duration"`
+}
+
+// AnalyzerNames lists the built-in analyzers in the order they run.
+var AnalyzerNames = []string{"url", "text", "page"}
+
+// NewScanner returns a scanner running the enabled built-in analyzers.
+func NewScanner(cfg Config, thresholds Thresholds) (*Scanner, error) {
+    client, err := NewFetcher(cfg)
+    if err != nil {
+        return nil, err
+    }
+
+    s := &Scanner{MaliciousCount: thresholds.MaliciousCount, Timeout: cfg.ScanTimeout}
+    for _, name := range cfg.Enabled {
+        switch name {
+        case "url":
+            s.Analyzers = append(s.Analyzers, URLAnalyzer{})
+        case "text":
+            s.Analyzers = append(s.Analyzers, TextAnalyzer{})
+        case "page":
+            s.Analyzers = append(s.Analyzers, PageAnalyzer{Client: client})
+        default:
+            return nil, fmt.Errorf("unknown analyzer %q", name)
+        }
+    }
+    return s, nil
+}
+
+// Slow reports whether any analyzer in the scan is slow.
+func (s *Scanner) Slow() bool {
+    for _, a := range s.Analyzers {
+        if slow, ok := a.(SlowAnalyzer); ok && slow.Slow() {
+            return true
+        }
+    }
+    return false
+}
+
+// Scan runs every analyzer against target. progress, if not nil, is called
+// before each analyzer starts and once more when the scan is done. Once
+// ctx is done or the scan times out, the remaining analyzers are skipped.
+func (s *Scanner) Scan(ctx context.Context, target Target, progress func(done, total int, stage string)) Verdict {
+    verdict := Verdict{URL: target.URL}
+    if s.Lists != nil {
+        switch list, domain := s.Lists.Match(target.URL); list {
+        case ListAllow:
+            verdict.Findings = []Finding{{Analyzer: "lists", Severity: SeverityClean, Description: domain + " is allowlisted",
+                Facts: map[string]interface{}{"allowlisted": true}}}
+            return verdict
+        case ListBlock:
+            verdict.Severity = SeverityMalicious
+            verdict.Findings = []Finding{{Analyzer: "lists", Severity: SeverityMalicious, Description: domain + " is blocked",
+                Facts: map[string]interface{}{"blocklisted": true}}}
+            return verdict
+        }
+    }
+    started := time.Now()
+    if s.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+        defer cancel()
+    }
+    ctx = capture.NewContext(ctx, s.Capture)
+    total := len(s.Analyzers)
+    for i, a := range s.Analyzers {
+        if ctx.Err() != nil {
+            verdict.Findings = append(verdict.Findings, Finding{
+                Analyzer:    "scan",
+                Severity:    SeverityInfo,
+                Description: fmt.Sprintf("scan stopped before %d of %d analyzers: %v", total-i, total, context.Cause(ctx)),
+            })
+            break
+        }
+        if progress != nil {
+            progress(i, total, a.Name())
+        }
+        start := time.Now()
+        run := &Run{Analyzer: a.Name()}
+        findings, err := analyze(context.WithValue(ctx, runKey{}, run), a, target)
+        run.Duration, run.Failed = time.Since(start), err != nil
+        verdict.Runs = append(verdict.Runs, *run)
+        if s.Capture != nil {
+            exchange := analyzerCapture{Analyzer: a.Name(), Target: target, Findings: findings,
+                Duration: time.Since(start).String()}
+            if err != nil {
+                exchange.Error = err.Error()
+            }
+            s.Capture.Record("analyzer", exchange)
+        }
+        if err != nil {
+            findings = append(findings, Finding{
+                Analyzer:    a.Name(),
+                Severity:    SeverityInfo,
+                Description: fmt.Sprintf("analyzer failed: %v", err),
+            })
+        }
+        verdict.Findings = append(verdict.Findings, findings...)
+    }
+    if progress != nil {
+        progress(total, total, "done")
+    }
+    verdict.Duration = time.Since(started)
+    verdict.Severity = Score(verdict.Findings, s.MaliciousCount)
+    return verdict
+}
+
+// analyze runs a, turning a panic in it into an error so one broken
+// analyzer fails like any other instead of taking the scan down.
+func analyze(ctx context.Context, a Analyzer, target Target) (findings []Finding, err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            findings, err = nil, fmt.Errorf("panic: %v", v)
+        }
+    }()
+    return a.Analyze(ctx, target)
+}
+
+// Score combines findings into a severity: the worst finding wins, and
+// maliciousCount or more suspicious findings together count as malicious.
+func Score(findings []Finding, maliciousCount int) Severity {
+    severity := SeverityClean
+    suspicious := 0
+    for _, f := range findings {
+        if f.Severity > severity {
+            severity = f.Severity
+        }
+        if f.Severity >= SeveritySuspicious {
+            suspicious++
+        }
+    }
+    if maliciousCount > 0 && suspicious >= maliciousCount {
+        severity = SeverityMalicious
+    }
+    return severity
+}
+
+// suspiciousTLDs are top-level domains heavily used by throwaway phishing
+// domains.
+var suspiciousTLDs = map[string]bool{
+    "zip": true, "mov": true, "top": true, "xyz": true, "tk": true,
+    "ml": true, "ga": true, "cf": true, "gq": true, "icu": true,
+}
+
+// shorteners hide the real destination of a link.
+var shorteners = map[string]bool{
+    "bit.ly": true, "tinyurl.com": true, "t.co": true, "goo.gl": true,
+    "is.gd": true, "cutt.ly": true, "rebrand.ly": true, "ow.ly": true,
+}
+
+// URLAnalyzer checks the shape of the link itself.
+type URLAnalyzer struct{}
+
+// Name identifies the analyzer.
+func (URLAnalyzer) Name() string { return "url" }
+
+// Analyze flags structural red flags in the URL.
+func (a URLAnalyzer) Analyze(ctx context.Context, target Target) ([]Finding, error) {
+    u, err := url.Parse(target.URL)
+    if err != nil {
+        return nil, err
+    }
+    host := strings.ToLower(u.Hostname())
+
+    var findings []Finding
+    add := func(severity Severity, format string, args ...interface{}) {
+        findings = append(findings, Finding{Analyzer: a.Name(), Severity: severity, Description: fmt.Sprintf(format, args...)})
+    }
+
+    if net.ParseIP(host) != nil {
+        add(SeveritySuspicious, "link points at a bare IP address %s", host)
+    }
+    if strings.HasPrefix(host, "xn--") || strings.Contains(host, ".xn--") {
+        add(SeveritySuspicious, "domain %s uses punycode", host)
+    }
+    if u.User != nil {
+        add(SeveritySuspicious, "link hides its destination behind %q@", u.User.Username())
+    }
+    if u.Scheme == "http" {
+        add(SeverityInfo, "link is not HTTPS")
+    }
+    if i := strings.LastIndex(host, "."); i >= 0 && suspiciousTLDs[host[i+1:]] {
+        add(SeveritySuspicious, "domain uses high-risk TLD .%s", host[i+1:])
+    }
+    if shorteners[host] {
+        add(SeverityInfo, "link uses URL shortener %s", host)
+    }
+    if strings.Count(host, ".") >= 4 {
+        add(SeverityInfo, "domain %s has unusually many subdomains", host)
+    }
+    return findings, nil
+}
+
+// urgencyPatterns match the pressure tactics common in phishing lures.
+var urgencyPatterns = []*regexp.Regexp{
+    regexp.MustCompile(`(?i)\burgent(ly)?\b`),
+    regexp.MustCompile(`(?i)\bverify your (account|identity)\b`),
+    regexp.MustCompile(`(?i)\b(account|card) (has been |will be )?(suspended|locked|blocked)\b`),
+    regexp.MustCompile(`(?i)\bconfirm your (password|details|payment)\b`),
+    regexp.MustCompile(`(?i)\b(act|respond) now\b`),
+    regexp.MustCompile(`(?i)\bwithin 24 hours\b`),
+    regexp.MustCompile(`(?i)\bsecurity alert\b`),
+    regexp.MustCompile(`(?i)\byou have won\b`),
+}
+
+// TextAnalyzer looks for urgency and credential-harvesting language in the
+// message carrying the link.
+type TextAnalyzer struct{}
+
+// Name identifies the analyzer.
+func (TextAnalyzer) Name() string { return "text" }
+
+// Analyze flags urgency phrases in the message text: English ones in any
+// message, since lures are often sent in English whatever the chat speaks,
+// and those of the message's language: the one DetectLanguage tells, or
+// else target.Language, as lures are often only a few words.
+func (a TextAnalyzer) Analyze(ctx context.Context, target Target) ([]Finding, error) {
+    var matches []string
+    for _, p := range urgencyPatterns {
+        if m := p.FindString(target.Text); m != "" {
+            matches = append(matches, m)
+        }
+    }
+    lang := DetectLanguage(target.Text)
+    assumed := lang == ""
+    if assumed {
+        lang = target.Language
+    }
+    english := len(matches)
+    for _, p := range urgencyLexicons[lang] {
+        if m := p.FindStringSubmatch(target.Text); m != nil {
+            matches = append(matches, m[1])
+        }
+    }
+    // An assumed language is only named if the text bears it out
+    if assumed && len(matches) == english {
+        lang = ""
+    }
+    if len(matches) == 0 {
+        return nil, nil
+    }
+    severity := SeverityInfo
+    if len(matches) >= 2 {
+        severity = SeveritySuspicious
+    }
+    finding := Finding{
+        Analyzer:    a.Name(),
+        Severity:    severity,
+        Description: fmt.Sprintf("message uses urgency language: %s", strings.Join(matches, ", ")),
+    }
+    if lang != "" {
+        finding.Facts = map[string]interface{}{"language": lang}
+        if lang != "en" {
+            finding.Description = fmt.Sprintf("message uses urgency language (%s): %s", lang, strings.Join(matches, ", "))
+        }
+    }
+    return []Finding{finding}, nil
+}
+
+var passwordField = regexp.MustCompile(`(?i)<input[^>]+type\s*=\s*["']?password`)
+
+// PageAnalyzer fetches the page behind the link and inspects its content.
+// Client should come from NewFetcher, which guards against fetching
+// internal addresses and caps the response size.
+type PageAnalyzer struct {
+    Client *http.Client
+}
+
+// Name identifies the analyzer.
+func (PageAnalyzer) Name() string { return "page" }
+
+// Slow reports that fetching the page can take a while.
+func (PageAnalyzer) Slow() bool { return true }
+
+// Analyze fetches the page and flags login forms and cross-domain redirects.
+func (a PageAnalyzer) Analyze(ctx context.Context, target Target) ([]Finding, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := a.Client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return nil, err
+    }
+
+    var findings []Finding
+    if location, err := resp.Location(); err == nil && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+        // Not followed; see analyzers.follow_redirects
+        if orig, err := url.Parse(target.URL); err == nil && location.Hostname() != orig.Hostname() {
+            findings = append(findings, Finding{
+                Analyzer:    a.Name(),
+                Severity:    SeverityInfo,
+                Description: fmt.Sprintf("link redirects to %s", location.Hostname()),
+            })
+        }
+        return findings, nil
+    }
+    if passwordField.Match(body) {
+        findings = append(findings, Finding{Analyzer: a.Name(), Severity: SeveritySuspicious, Description: "page asks for a password",
+            Facts: map[string]interface{}{"has_login_form": true}})
+    }
+    if orig, err := url.Parse(target.URL); err == nil && resp.Request.URL.Hostname() != orig.Hostname() {
+        findings = append(findings, Finding{
+            Analyzer:    a.Name(),
+            Severity:    SeverityInfo,
+            Description: fmt.Sprintf("link redirects to %s", resp.Request.URL.Hostname()),
+        })
+    }
+    return findings, nil
+}
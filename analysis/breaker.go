@@ -0,0 +1,172 @@
+package analysis
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// BreakerConfig tunes the retries and circuit breaker around an analyzer
+// that calls a remote service, such as a reputation API.
+type BreakerConfig struct {
+    Retries  int           `yaml:"retries"`  // Attempts after the first failure of a scan
+    Backoff  time.Duration `yaml:"backoff"`  // Before the first retry, doubling for each one after
+    Failures int           `yaml:"failures"` // Scans failing in a row that open the breaker; 0 never does
+    Cooldown time.Duration `yaml:"cooldown"` // How long an open breaker skips the analyzer
+}
+
+// Breaker states.
+const (
+    BreakerClosed   = "closed"    // The analyzer runs as usual
+    BreakerOpen     = "open"      // The analyzer is skipped until the cooldown is over
+    BreakerHalfOpen = "half-open" // One scan is trying the analyzer again
+)
+
+// BreakerStatus is a breaker's health, for the health checks.
+type BreakerStatus struct {
+    Analyzer  string    `json:"analyzer"`
+    State     string    `json:"state"`
+    Failures  int       `json:"failures"` // Failed scans in a row
+    LastError string    `json:"last_error,omitempty"`
+    Until     time.Time `json:"until,omitempty"` // When an open breaker lets a scan try again
+}
+
+// Breaker wraps an analyzer calling a remote service. A failed call is
+// retried a few times with backoff, and once cfg.Failures scans in a row
+// have failed the breaker opens: for cfg.Cooldown the analyzer is skipped,
+// so an outage costs each scan nothing rather than minutes of retries and
+// the other analyzers decide the verdict. Then one scan tries it again,
+// closing the breaker if it works and opening it anew if not.
+type Breaker struct {
+    Analyzer
+    cfg BreakerConfig
+
+    mu       sync.Mutex
+    state    string
+    failures int
+    lastErr  error
+    until    time.Time
+}
+
+// NewBreaker wraps a in a breaker configured by cfg.
+func NewBreaker(a Analyzer, cfg BreakerConfig) *Breaker {
+    return &Breaker{Analyzer: a, cfg: cfg, state: BreakerClosed}
+}
+
+// Slow reports whether the wrapped analyzer is slow.
+func (b *Breaker) Slow() bool {
+    slow, ok := b.Analyzer.(SlowAnalyzer)
+    return ok && slow.Slow()
+}
+
+// Analyze runs the wrapped analyzer unless the breaker is open, retrying
+// it on failure while ctx allows.
+func (b *Breaker) Analyze(ctx context.Context, target Target) ([]Finding, error) {
+    if err := b.allow(time.Now()); err != nil {
+        return nil, err
+    }
+    backoff := b.cfg.Backoff
+    var err error
+    for attempt := 0; ; attempt++ {
+        var findings []Finding
+        if findings, err = analyze(ctx, b.Analyzer, target); err == nil {
+            b.record(nil, time.Now())
+            return findings, nil
+        }
+        if attempt >= b.cfg.Retries || ctx.Err() != nil {
+            break
+        }
+        select {
+        case <-ctx.Done():
+        case <-time.After(backoff):
+        }
+        backoff *= 2
+    }
+    if ctx.Err() != nil {
+        // Out of time for the whole scan, which says nothing of the service
+        b.release()
+        return nil, err
+    }
+    b.record(err, time.Now())
+    return nil, err
+}
+
+// allow reports an error if the breaker is open, and otherwise lets the
+// scan through, as the trial if the cooldown is over.
+func (b *Breaker) allow(now time.Time) error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    switch b.state {
+    case BreakerHalfOpen:
+        return fmt.Errorf("skipped while another scan tries it again after %d failures: %v", b.failures, b.lastErr)
+    case BreakerOpen:
+        if now.Before(b.until) {
+            return fmt.Errorf("skipped until %s after %d failures: %v", b.until.Local().Format("15:04:05"), b.failures, b.lastErr)
+        }
+        b.state = BreakerHalfOpen
+    }
+    return nil
+}
+
+// release ends a trial that ran out of time without an answer either way.
+func (b *Breaker) release() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.state == BreakerHalfOpen {
+        b.state = BreakerOpen
+    }
+}
+
+// record counts how a scan went, opening or closing the breaker.
+func (b *Breaker) record(err error, now time.Time) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if err == nil {
+        b.state, b.failures, b.lastErr = BreakerClosed, 0, nil
+        return
+    }
+    b.failures++
+    b.lastErr = err
+    if b.state == BreakerHalfOpen || (b.cfg.Failures > 0 && b.failures >= b.cfg.Failures) {
+        b.state, b.until = BreakerOpen, now.Add(b.cfg.Cooldown)
+    }
+}
+
+// Status returns the breaker's health.
+func (b *Breaker) Status() BreakerStatus {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    s := BreakerStatus{Analyzer: b.Name(), State: b.state, Failures: b.failures}
+    if b.lastErr != nil {
+        s.LastError = b.lastErr.Error()
+    }
+    if b.state != BreakerClosed {
+        s.Until = b.until
+    }
+    return s
+}
+
+// Breakers returns the health of the scanner's breakers.
+func (s *Scanner) Breakers() []BreakerStatus {
+    var status []BreakerStatus
+    for _, a := range s.Analyzers {
+        if shared, ok := a.(*SharedReputation); ok {
+            status = append(status, shared.Breakers()...)
+        } else if st, ok := breakerStatus(a); ok {
+            status = append(status, st)
+        }
+    }
+    return status
+}
+
+// breakerStatus returns the health of a's breaker, if it has one.
+func breakerStatus(a Analyzer) (BreakerStatus, bool) {
+    switch a := a.(type) {
+    case *Breaker:
+        return a.Status(), true
+    case Cached:
+        return a.Status()
+    }
+    return BreakerStatus{}, false
+}
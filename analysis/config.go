@@ -0,0 +1,48 @@
+package analysis
+
+import "time"
+
+// Config selects and tunes the analyzers.
+type Config struct {
+    Enabled     []string      `yaml:"enabled"`
+    PageTimeout time.Duration `yaml:"page_timeout"`
+    ScanTimeout time.Duration `yaml:"scan_timeout"` // For all analyzers together
+    Proxy       string        `yaml:"proxy"`        // Used when fetching suspicious pages
+
+    // Fetching links is limited to public addresses, MaxPageKB of each
+    // response, and the first response unless FollowRedirects is set.
+    FollowRedirects bool `yaml:"follow_redirects"`
+    MaxRedirects    int  `yaml:"max_redirects"`
+    MaxPageKB       int  `yaml:"max_page_kb"`
+    AllowPrivate    bool `yaml:"allow_private"`
+
+    // Only responses of AllowTypes are read, type/* matching any subtype;
+    // empty, any type is. At most MaxFetches fetches run at once, holding
+    // at most FetchQuotaMB between them. Both limits are shared by every
+    // scanner, so profiles should leave them alone; zero is no limit.
+    AllowTypes   []string `yaml:"allow_types"`
+    MaxFetches   int      `yaml:"max_fetches"`
+    FetchQuotaMB int      `yaml:"fetch_quota_mb"`
+
+    // Fetches pass for the browser named by Browser, with UserAgent,
+    // AcceptLanguage and Headers overriding what it sends, because many
+    // phishing kits show a harmless page to clients that don't look like
+    // one. Empty, they keep Go's default User-Agent.
+    Browser        string            `yaml:"browser"`
+    UserAgent      string            `yaml:"user_agent"`
+    AcceptLanguage string            `yaml:"accept_language"`
+    Headers        map[string]string `yaml:"headers"`
+
+    // DNS, if set, resolves the names of fetched links privately.
+    DNS DNSConfig `yaml:"dns"`
+
+    // Breaker guards the analyzers that call remote services, such as
+    // reputation lookups in plugins.
+    Breaker BreakerConfig `yaml:"breaker"`
+}
+
+// Thresholds tune how findings combine into a verdict.
+type Thresholds struct {
+    // MaliciousCount is how many suspicious findings make a link malicious.
+    MaliciousCount int `yaml:"malicious_count"`
+}
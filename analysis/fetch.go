@@ -0,0 +1,246 @@
+package analysis
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/netip"
+    "net/url"
+    "strings"
+    "syscall"
+
+    "github.com/hacker1337itme/telephish/capture"
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+// NewFetcher returns the client for fetching untrusted links. It only
+// speaks HTTP and HTTPS, reads at most cfg.MaxPageKB of each response, and
+// doesn't follow redirects unless cfg.FollowRedirects is set. Responses
+// whose type cfg.AllowTypes doesn't list aren't read, and compressed ones
+// that grow too much are cut off. Every fetcher shares cfg.MaxFetches and
+// cfg.FetchQuotaMB, however many scanners there are. Names are resolved
+// by the resolver cfg.DNS describes, unless a proxy resolves them. Unless
+// cfg.AllowPrivate is set it refuses to connect to loopback, private,
+// link-local and other non-public addresses, so a link can't be used to
+// probe the network the monitor runs in. Through a proxy only literal
+// addresses in links can be checked; the proxy has to enforce the rest.
+// Every request carries the headers cfg.FetchHeaders describes.
+func NewFetcher(cfg Config) (*http.Client, error) {
+    headers, err := cfg.FetchHeaders()
+    if err != nil {
+        return nil, err
+    }
+    resolver, err := NewResolver(cfg.DNS)
+    if err != nil {
+        return nil, err
+    }
+    var dial func(network, address string, c syscall.RawConn) error
+    if !cfg.AllowPrivate && cfg.Proxy == "" {
+        dial = guardDial
+    }
+    transport := netutil.NewTransport(dial)
+    if resolver != nil {
+        transport.DialContext = netutil.NewDialer(dial, resolver).DialContext
+    }
+    transport.Proxy = nil // Untrusted fetches only use analyzers.proxy
+    // Responses are decoded by fetchTransport, which can tell a
+    // decompression bomb from a page
+    transport.DisableCompression = true
+    if cfg.Proxy != "" {
+        proxy, err := url.Parse(cfg.Proxy)
+        if err != nil {
+            return nil, fmt.Errorf("invalid analyzer proxy: %v", err)
+        }
+        transport.Proxy = http.ProxyURL(proxy)
+    }
+
+    client := &http.Client{
+        Timeout: cfg.PageTimeout,
+        Transport: fetchTransport{
+            next:         transport,
+            maxBytes:     int64(cfg.MaxPageKB) << 10,
+            allowPrivate: cfg.AllowPrivate,
+            allowTypes:   cfg.AllowTypes,
+            headers:      headers,
+            limits:       sharedLimits(cfg),
+        },
+        CheckRedirect: func(req *http.Request, via []*http.Request) error {
+            if !cfg.FollowRedirects {
+                return http.ErrUseLastResponse
+            }
+            if len(via) > cfg.MaxRedirects {
+                return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+            }
+            return nil
+        },
+    }
+    return client, nil
+}
+
+// fetchTransport checks each request's URL before sending it, adds the
+// configured headers the request doesn't set itself, waits for a free
+// fetch, and caps the size of the response body. With a capture.Recorder in the request's context,
+// each exchange is recorded once its body is closed.
+type fetchTransport struct {
+    next         http.RoundTripper
+    maxBytes     int64
+    allowPrivate bool
+    allowTypes   []string
+    headers      http.Header
+    limits       *downloadLimits
+}
+
+func (t fetchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    // A RoundTripper mustn't change the request it was given
+    req = req.Clone(req.Context())
+    for name, values := range t.headers {
+        if _, ok := req.Header[name]; !ok {
+            req.Header[name] = append([]string(nil), values...)
+        }
+    }
+    if req.Header.Get("Accept-Encoding") == "" {
+        req.Header.Set("Accept-Encoding", "gzip, deflate")
+    }
+    rec := capture.FromContext(req.Context())
+    resp, err := t.roundTrip(req)
+    if rec == nil {
+        return resp, err
+    }
+    exchange := &fetchCapture{Method: req.Method, URL: req.URL.String(), RequestHeader: req.Header}
+    if err != nil {
+        exchange.Error = err.Error()
+        rec.Record("fetch", exchange)
+        return nil, err
+    }
+    exchange.Status, exchange.ResponseHeader = resp.StatusCode, resp.Header
+    resp.Body = &capturedBody{ReadCloser: resp.Body, rec: rec, exchange: exchange}
+    return resp, nil
+}
+
+func (t fetchTransport) roundTrip(req *http.Request) (*http.Response, error) {
+    if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+        return nil, fmt.Errorf("refusing to fetch %s links", req.URL.Scheme)
+    }
+    if !t.allowPrivate {
+        host := strings.ToLower(strings.TrimSuffix(req.URL.Hostname(), "."))
+        if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+            return nil, fmt.Errorf("refusing to fetch %s: not a public address", host)
+        }
+        if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+            return nil, fmt.Errorf("refusing to fetch %s: not a public address", host)
+        }
+    }
+    if err := t.limits.acquire(req.Context()); err != nil {
+        return nil, err
+    }
+    resp, err := t.next.RoundTrip(req)
+    if err != nil {
+        t.limits.release()
+        return nil, err
+    }
+    // A link to a download is only worth its headers
+    if resp.StatusCode >= 200 && resp.StatusCode <= 299 && !allowedType(resp.Header.Get("Content-Type"), t.allowTypes) {
+        resp.Body.Close()
+        t.limits.release()
+        contentType := resp.Header.Get("Content-Type")
+        if contentType == "" {
+            contentType = "untyped"
+        }
+        return nil, fmt.Errorf("refusing to read %s response (see analyzers.allow_types)", contentType)
+    }
+    if err := decodeBody(resp); err != nil {
+        resp.Body.Close()
+        t.limits.release()
+        return nil, err
+    }
+    body := io.Reader(resp.Body)
+    if t.maxBytes > 0 {
+        body = io.LimitReader(body, t.maxBytes)
+    }
+    resp.Body = &limitedBody{Reader: body, closer: resp.Body, limits: t.limits}
+    return resp, nil
+}
+
+// fetchCapture is the record of one fetch.
+type fetchCapture struct {
+    Method         string      `json:"method"`
+    URL            string      `json:"url"`
+    RequestHeader  http.Header `json:"request_header,omitempty"`
+    Status         int         `json:"status,omitempty"`
+    ResponseHeader http.Header `json:"response_header,omitempty"`
+    Body           string      `json:"body,omitempty"` // As much as was read
+    Error          string      `json:"error,omitempty"`
+}
+
+// capturedBody keeps what is read from a response body and records the
+// exchange when it is closed.
+type capturedBody struct {
+    io.ReadCloser
+    rec      *capture.Recorder
+    exchange *fetchCapture
+    body     bytes.Buffer
+    closed   bool
+}
+
+func (b *capturedBody) Read(p []byte) (int, error) {
+    n, err := b.ReadCloser.Read(p)
+    b.body.Write(p[:n])
+    if err != nil && err != io.EOF {
+        b.exchange.Error = err.Error()
+    }
+    return n, err
+}
+
+func (b *capturedBody) Close() error {
+    if !b.closed {
+        b.closed = true
+        b.exchange.Body = b.body.String()
+        b.rec.Record("fetch", b.exchange)
+    }
+    return b.ReadCloser.Close()
+}
+
+// guardDial is a net.Dialer Control function that refuses non-public
+// addresses, checked after DNS resolution so a public name pointing at a
+// private address is caught too.
+func guardDial(network, address string, _ syscall.RawConn) error {
+    host, _, err := net.SplitHostPort(address)
+    if err != nil {
+        return err
+    }
+    addr, err := netip.ParseAddr(host)
+    if err != nil {
+        return err
+    }
+    if !publicAddr(addr) {
+        return fmt.Errorf("refusing to connect to %s: not a public address", host)
+    }
+    return nil
+}
+
+// nonPublic are the special-purpose ranges netip's predicates don't cover.
+var nonPublic = []netip.Prefix{
+    netip.MustParsePrefix("0.0.0.0/8"),
+    netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+    netip.MustParsePrefix("192.0.0.0/24"),
+    netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+    netip.MustParsePrefix("240.0.0.0/4"),
+    netip.MustParsePrefix("64:ff9b::/96"), // NAT64, can reach private IPv4
+    netip.MustParsePrefix("fec0::/10"),    // Deprecated site-local
+}
+
+// publicAddr reports whether addr is an ordinary public unicast address.
+func publicAddr(addr netip.Addr) bool {
+    addr = addr.Unmap()
+    if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+        return false
+    }
+    for _, prefix := range nonPublic {
+        if prefix.Contains(addr) {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,248 @@
+package analysis
+
+import (
+    "context"
+    "encoding/binary"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "net/netip"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "syscall"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+func testFetchConfig() Config {
+    return Config{PageTimeout: 5 * time.Second, FollowRedirects: true, MaxRedirects: 5, MaxPageKB: 512}
+}
+
+func TestPublicAddr(t *testing.T) {
+    tests := []struct {
+        addr   string
+        public bool
+    }{
+        {"8.8.8.8", true},
+        {"2606:4700:4700::1111", true},
+        {"127.0.0.1", false},
+        {"127.8.8.8", false},
+        {"::1", false},
+        {"10.1.2.3", false},
+        {"172.16.0.1", false},
+        {"172.31.255.255", false},
+        {"192.168.1.1", false},
+        {"169.254.169.254", false},
+        {"fe80::1", false},
+        {"fc00::1", false},
+        {"fec0::1", false},
+        {"100.64.0.1", false},
+        {"0.0.0.0", false},
+        {"::", false},
+        {"255.255.255.255", false},
+        {"224.0.0.1", false},
+        {"ff02::1", false},
+        {"::ffff:127.0.0.1", false},
+        {"::ffff:10.0.0.1", false},
+        {"::ffff:169.254.169.254", false},
+        {"::ffff:8.8.8.8", true},
+        {"64:ff9b::a00:1", false},
+    }
+    for _, tt := range tests {
+        if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+            t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+        }
+    }
+}
+
+// privateServer is a server on loopback counting the requests that reach
+// it, which none should.
+func privateServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+    var hits atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        hits.Add(1)
+        w.Write([]byte("internal"))
+    }))
+    t.Cleanup(srv.Close)
+    return srv, &hits
+}
+
+func TestFetcherRefusesPrivateLiterals(t *testing.T) {
+    srv, hits := privateServer(t)
+    port := srv.Listener.Addr().(*net.TCPAddr).Port
+    client, err := NewFetcher(testFetchConfig())
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, link := range []string{
+        srv.URL,
+        "http://localhost:" + strconv.Itoa(port),
+        "http://LOCALHOST.:" + strconv.Itoa(port),
+        "http://app.localhost:" + strconv.Itoa(port),
+        "http://[::1]:" + strconv.Itoa(port),
+        "http://[::ffff:127.0.0.1]:" + strconv.Itoa(port),
+        "http://[::ffff:7f00:1]:" + strconv.Itoa(port),
+        "http://10.0.0.1/",
+        "http://192.168.0.1/",
+        "http://169.254.169.254/latest/meta-data/",
+        "http://[fe80::1]/",
+        "http://0.0.0.0:" + strconv.Itoa(port),
+    } {
+        resp, err := client.Get(link)
+        if err == nil {
+            resp.Body.Close()
+            t.Errorf("fetched %s", link)
+            continue
+        }
+        if !strings.Contains(err.Error(), "not a public address") {
+            t.Errorf("fetching %s: %v, want a refusal", link, err)
+        }
+    }
+    if n := hits.Load(); n != 0 {
+        t.Errorf("%d requests reached the private server", n)
+    }
+}
+
+// stubTransport answers every request with a redirect to location, and
+// remembers the hosts asked for.
+type stubTransport struct {
+    location string
+    hosts    []string
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    s.hosts = append(s.hosts, req.URL.Host)
+    return &http.Response{
+        StatusCode: http.StatusFound,
+        Header:     http.Header{"Location": {s.location}},
+        Body:       http.NoBody,
+        Request:    req,
+    }, nil
+}
+
+func TestFetcherRefusesRedirectToPrivate(t *testing.T) {
+    for _, location := range []string{
+        "http://127.0.0.1/admin",
+        "http://[::ffff:10.0.0.1]/",
+        "http://169.254.169.254/latest/meta-data/",
+        "http://localhost/",
+    } {
+        client, err := NewFetcher(testFetchConfig())
+        if err != nil {
+            t.Fatal(err)
+        }
+        // The public site is stubbed out; the guard is still in front
+        stub := &stubTransport{location: location}
+        ft := client.Transport.(fetchTransport)
+        ft.next = stub
+        client.Transport = ft
+
+        resp, err := client.Get("http://public.example/")
+        if err == nil {
+            resp.Body.Close()
+            t.Errorf("followed the redirect to %s", location)
+            continue
+        }
+        if !strings.Contains(err.Error(), "not a public address") {
+            t.Errorf("redirect to %s: %v, want a refusal", location, err)
+        }
+        if len(stub.hosts) != 1 || stub.hosts[0] != "public.example" {
+            t.Errorf("redirect to %s: requests sent for %v, want only public.example", location, stub.hosts)
+        }
+    }
+}
+
+// fakeDNS serves A queries on loopback UDP, answering every name with
+// addr, and returns a resolver that asks it.
+func fakeDNS(t *testing.T, addr netip.Addr) *net.Resolver {
+    conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    go func() {
+        buf := make([]byte, 512)
+        for {
+            n, from, err := conn.ReadFrom(buf)
+            if err != nil {
+                return
+            }
+            if n < 12 {
+                continue
+            }
+            // The question ends 4 bytes after its name
+            end := 12
+            for end < n && buf[end] != 0 {
+                end += int(buf[end]) + 1
+            }
+            end += 5
+            if end > n {
+                continue
+            }
+            qtype := binary.BigEndian.Uint16(buf[end-4:])
+            answer := append([]byte(nil), buf[:end]...)
+            binary.BigEndian.PutUint16(answer[2:], 0x8180) // Response, recursion available
+            binary.BigEndian.PutUint16(answer[8:], 0)
+            binary.BigEndian.PutUint16(answer[10:], 0)
+            if qtype == 1 { // A
+                binary.BigEndian.PutUint16(answer[6:], 1)
+                ip := addr.As4()
+                answer = append(answer, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+                answer = append(answer, ip[:]...)
+            } else {
+                binary.BigEndian.PutUint16(answer[6:], 0)
+            }
+            conn.WriteTo(answer, from)
+        }
+    }()
+    server := conn.LocalAddr().String()
+    return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+        var d net.Dialer
+        return d.DialContext(ctx, "udp", server)
+    }}
+}
+
+func TestFetcherRefusesNamesResolvingToPrivate(t *testing.T) {
+    srv, hits := privateServer(t)
+    port := srv.Listener.Addr().(*net.TCPAddr).Port
+    resolver := fakeDNS(t, netip.MustParseAddr("127.0.0.1"))
+    link := "http://intranet.example:" + strconv.Itoa(port) + "/"
+
+    // NewFetcher's dialer, with the fake resolver in place of cfg.DNS's
+    fetcher := func(dial func(network, address string, c syscall.RawConn) error) *http.Client {
+        cfg := testFetchConfig()
+        cfg.AllowPrivate = dial == nil
+        client, err := NewFetcher(cfg)
+        if err != nil {
+            t.Fatal(err)
+        }
+        ft := client.Transport.(fetchTransport)
+        ft.next.(*http.Transport).DialContext = netutil.NewDialer(dial, resolver).DialContext
+        return client
+    }
+
+    // The name does lead to the server
+    resp, err := fetcher(nil).Get(link)
+    if err != nil {
+        t.Fatalf("without the guard: %v", err)
+    }
+    resp.Body.Close()
+    if hits.Load() != 1 {
+        t.Fatalf("without the guard the server got %d requests, want 1", hits.Load())
+    }
+
+    resp, err = fetcher(guardDial).Get(link)
+    if err == nil {
+        resp.Body.Close()
+        t.Fatalf("fetched %s, which resolves to loopback", link)
+    }
+    if !strings.Contains(err.Error(), "not a public address") {
+        t.Errorf("fetching %s: %v, want a refusal", link, err)
+    }
+    if hits.Load() != 1 {
+        t.Errorf("the guarded fetch reached the server")
+    }
+}
@@ -0,0 +1,125 @@
+package analysis
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+)
+
+// BrowserProfile is what a browser sends when it opens a page: its
+// User-Agent and the headers that go with it.
+type BrowserProfile struct {
+    UserAgent string
+    Headers   map[string]string
+}
+
+// Header values shared by the Chromium-based profiles.
+const (
+    chromiumAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+    chromeVersion  = "141"
+)
+
+// BrowserProfiles are the browsers fetches can pass for, by name. Accept-
+// Encoding is left to the fetcher, which only asks for the encodings it
+// decodes.
+var BrowserProfiles = map[string]BrowserProfile{
+    "chrome-windows": chromium("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/"+chromeVersion+".0.0.0 Safari/537.36",
+        `"Google Chrome";v="`+chromeVersion+`", "Not?A_Brand";v="8", "Chromium";v="`+chromeVersion+`"`, "Windows", false),
+    "edge-windows": chromium("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/"+chromeVersion+".0.0.0 Safari/537.36 Edg/"+chromeVersion+".0.0.0",
+        `"Microsoft Edge";v="`+chromeVersion+`", "Not?A_Brand";v="8", "Chromium";v="`+chromeVersion+`"`, "Windows", false),
+    "chrome-android": chromium("Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/"+chromeVersion+".0.0.0 Mobile Safari/537.36",
+        `"Google Chrome";v="`+chromeVersion+`", "Not?A_Brand";v="8", "Chromium";v="`+chromeVersion+`"`, "Android", true),
+    "firefox-windows": {
+        UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:143.0) Gecko/20100101 Firefox/143.0",
+        Headers: map[string]string{
+            "Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+            "Accept-Language":           "en-US,en;q=0.5",
+            "Upgrade-Insecure-Requests": "1",
+            "Sec-Fetch-Dest":            "document",
+            "Sec-Fetch-Mode":            "navigate",
+            "Sec-Fetch-Site":            "none",
+            "Sec-Fetch-User":            "?1",
+        },
+    },
+    "safari-macos": safari("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/26.0 Safari/605.1.15"),
+    "safari-iphone": safari("Mozilla/5.0 (iPhone; CPU iPhone OS 18_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/26.0 Mobile/15E148 Safari/604.1"),
+}
+
+func chromium(userAgent, brands, platform string, mobile bool) BrowserProfile {
+    m := "?0"
+    if mobile {
+        m = "?1"
+    }
+    return BrowserProfile{
+        UserAgent: userAgent,
+        Headers: map[string]string{
+            "Accept":                    chromiumAccept,
+            "Accept-Language":           "en-US,en;q=0.9",
+            "Sec-Ch-Ua":                 brands,
+            "Sec-Ch-Ua-Mobile":          m,
+            "Sec-Ch-Ua-Platform":        `"` + platform + `"`,
+            "Upgrade-Insecure-Requests": "1",
+            "Sec-Fetch-Dest":            "document",
+            "Sec-Fetch-Mode":            "navigate",
+            "Sec-Fetch-Site":            "none",
+            "Sec-Fetch-User":            "?1",
+        },
+    }
+}
+
+func safari(userAgent string) BrowserProfile {
+    return BrowserProfile{
+        UserAgent: userAgent,
+        Headers: map[string]string{
+            "Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+            "Accept-Language": "en-US,en;q=0.9",
+            "Sec-Fetch-Dest":  "document",
+            "Sec-Fetch-Mode":  "navigate",
+            "Sec-Fetch-Site":  "none",
+        },
+    }
+}
+
+// BrowserNames lists the names of BrowserProfiles, sorted.
+func BrowserNames() []string {
+    names := make([]string, 0, len(BrowserProfiles))
+    for name := range BrowserProfiles {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// FetchHeaders returns the headers fetches send as cfg describes them: the
+// browser profile's, overridden by UserAgent, AcceptLanguage and Headers.
+// Without any of them it returns nil, and fetches look like Go's client.
+func (c Config) FetchHeaders() (http.Header, error) {
+    h := http.Header{}
+    if c.Browser != "" {
+        profile, ok := BrowserProfiles[c.Browser]
+        if !ok {
+            return nil, fmt.Errorf("analyzers.browser: unknown browser %q (available: %s)", c.Browser, strings.Join(BrowserNames(), ", "))
+        }
+        for name, value := range profile.Headers {
+            h.Set(name, value)
+        }
+        h.Set("User-Agent", profile.UserAgent)
+    }
+    if c.UserAgent != "" {
+        h.Set("User-Agent", c.UserAgent)
+    }
+    if c.AcceptLanguage != "" {
+        h.Set("Accept-Language", c.AcceptLanguage)
+    }
+    for name, value := range c.Headers {
+        if strings.EqualFold(name, "Host") || strings.EqualFold(name, "Accept-Encoding") {
+            return nil, fmt.Errorf("analyzers.headers: %s can't be set", name)
+        }
+        h.Set(name, value)
+    }
+    if len(h) == 0 {
+        return nil, nil
+    }
+    return h, nil
+}
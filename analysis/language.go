@@ -0,0 +1,199 @@
+package analysis
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+// urgencyLexicons are the urgency patterns of lures in other languages,
+// by ISO 639-1 code, which the text analyzer applies along with the
+// English urgencyPatterns to messages detected as in that language.
+var urgencyLexicons = map[string][]*regexp.Regexp{
+    "ru": lexicon(
+        `срочн(о|ая|ое|ый)`,
+        `немедленно`,
+        `(аккаунт|учётная запись|учетная запись|счёт|счет|карта) (был[аио]? |будет )?(заблокирован[аоы]?|приостановлен[аоы]?)`,
+        `подтвердите (свой |свои |ваш |ваши )?(аккаунт|пароль|данные|личность|платёж|платеж)`,
+        `в течение 24 часов`,
+        `вы выиграли`,
+        `служб[аы] безопасности`,
+    ),
+    "uk": lexicon(
+        `терміново`,
+        `негайно`,
+        `(акаунт|обліковий запис|рахунок|картк[ау]) (було |буде )?(заблоковано|заблокован[аи]?|призупинено)`,
+        `підтвердіть (свій |свої |ваш |ваші )?(акаунт|пароль|дані|особу|платіж)`,
+        `протягом 24 годин`,
+        `ви виграли`,
+        `служб[аи] безпеки`,
+    ),
+    "es": lexicon(
+        `urgente`,
+        `verifique su (cuenta|identidad)`,
+        `verifica tu (cuenta|identidad)`,
+        `(cuenta|tarjeta) (ha sido |será |sera )?(suspendida|bloqueada)`,
+        `confirm[ae] (su|tu) (contraseña|datos|pago)`,
+        `(en las próximas|dentro de|en) 24 horas`,
+        `(ha|has) ganado`,
+        `alerta de seguridad`,
+    ),
+    "pt": lexicon(
+        `urgente`,
+        `verifique (sua|a sua) (conta|identidade)`,
+        `(conta|cartão) (foi |será |sera )?(suspens[ao]|bloquead[ao])`,
+        `confirme (sua senha|seus dados|seu pagamento)`,
+        `(nas próximas|em) 24 horas`,
+        `você ganhou`,
+        `alerta de segurança`,
+    ),
+    "fr": lexicon(
+        `urgent(e)?`,
+        `vérifiez votre (compte|identité)`,
+        `(compte|carte) (a été |sera )?(suspendu|bloqué|verrouillé)e?`,
+        `confirmez (votre|vos) (mot de passe|paiement|identité|coordonnées)`,
+        `(sous|dans les) 24 heures`,
+        `vous avez gagné`,
+        `alerte de sécurité`,
+    ),
+    "de": lexicon(
+        `dringend`,
+        `(bestätigen|verifizieren) sie ihr(e|en)? (konto|identität|passwort|zahlung|daten)`,
+        `(konto|karte) (wurde |wird )?(gesperrt|eingeschränkt)`,
+        `innerhalb von 24 stunden`,
+        `sie haben gewonnen`,
+        `sicherheitswarnung`,
+    ),
+    "it": lexicon(
+        `urgente`,
+        `verifica (il tuo|il suo) (account|conto|identità)`,
+        `(account|conto|carta) (è stat[oa] |sarà )?(sospes[oa]|bloccat[oa])`,
+        `conferma (la tua password|i tuoi dati|il pagamento)`,
+        `entro 24 ore`,
+        `hai vinto`,
+        `avviso di sicurezza`,
+    ),
+    "tr": lexicon(
+        `acil`,
+        `hesabınızı doğrulayın`,
+        `hesabınız (askıya alındı|askıya alınacak|bloke edildi|kilitlendi)`,
+        `(şifrenizi|bilgilerinizi|ödemenizi) onaylayın`,
+        `24 saat içinde`,
+        `kazandınız`,
+        `güvenlik uyarısı`,
+    ),
+}
+
+// HasLexicon reports whether the text analyzer knows the urgency phrases
+// of lang, an ISO 639-1 code.
+func HasLexicon(lang string) bool {
+    _, ok := urgencyLexicons[lang]
+    return ok || lang == "en"
+}
+
+// lexicon compiles case-insensitive phrases that match as whole words. Go's
+// \b only knows ASCII letters, so the phrases are bounded by anything but a
+// letter or digit instead, and their first group is the phrase itself.
+func lexicon(phrases ...string) []*regexp.Regexp {
+    patterns := make([]*regexp.Regexp, len(phrases))
+    for i, phrase := range phrases {
+        patterns[i] = regexp.MustCompile(`(?i)(?:^|[^\pL\pN])(` + phrase + `)(?:$|[^\pL\pN])`)
+    }
+    return patterns
+}
+
+// stopwords are common short words of each language written in the Latin
+// script, which tell them apart in a sentence or two.
+var stopwords = map[string][]string{
+    "en": {"the", "and", "your", "you", "is", "to", "of", "for", "this", "with", "are", "please", "has", "been", "will"},
+    "es": {"el", "la", "los", "las", "que", "y", "su", "tu", "para", "con", "por", "es", "una", "cuenta", "ha", "sido", "usted"},
+    "pt": {"o", "os", "que", "e", "sua", "seu", "para", "com", "não", "uma", "você", "conta", "foi", "em", "é"},
+    "fr": {"le", "la", "les", "et", "votre", "vous", "pour", "est", "une", "avec", "des", "compte", "été", "sera"},
+    "de": {"der", "die", "das", "und", "ist", "sie", "ihr", "ihre", "für", "mit", "nicht", "ein", "eine", "konto", "wurde"},
+    "it": {"il", "di", "che", "e", "per", "con", "tuo", "una", "non", "sono", "conto", "è", "stato", "stata"},
+    "tr": {"ve", "bir", "bu", "için", "ile", "lütfen", "değil", "hesabınız", "olarak", "size"},
+}
+
+// DetectLanguage guesses the language text is written in, as an ISO 639-1
+// code, or returns "" if it can't tell. Scripts other than Latin name
+// their language, telling Ukrainian from Russian by its own letters; in
+// the Latin script the language whose common words text uses most wins.
+// Links and short texts don't say much, so the guess is only for picking
+// lexicons, not for showing.
+func DetectLanguage(text string) string {
+    // Links are mostly Latin letters whatever the message is written in
+    text = linkPattern.ReplaceAllString(text, " ")
+    var latin, cyrillic, ukrainian, greek, arabic, hebrew, cjk, kana, hangul int
+    for _, r := range text {
+        switch {
+        case unicode.Is(unicode.Latin, r):
+            latin++
+        case unicode.Is(unicode.Cyrillic, r):
+            cyrillic++
+            if strings.ContainsRune("іїєґІЇЄҐ", r) {
+                ukrainian++
+            }
+        case unicode.Is(unicode.Greek, r):
+            greek++
+        case unicode.Is(unicode.Arabic, r):
+            arabic++
+        case unicode.Is(unicode.Hebrew, r):
+            hebrew++
+        case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+            kana++
+        case unicode.Is(unicode.Han, r):
+            cjk++
+        case unicode.Is(unicode.Hangul, r):
+            hangul++
+        }
+    }
+    switch {
+    case cyrillic > latin:
+        if ukrainian > 0 {
+            return "uk"
+        }
+        return "ru"
+    case kana > 0 && kana+cjk > latin:
+        return "ja"
+    case cjk > latin:
+        return "zh"
+    case hangul > latin:
+        return "ko"
+    case arabic > latin:
+        return "ar"
+    case hebrew > latin:
+        return "he"
+    case greek > latin:
+        return "el"
+    case latin == 0:
+        return ""
+    }
+
+    counts := map[string]int{}
+    for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+        for lang, words := range stopwords {
+            for _, w := range words {
+                if w == word {
+                    counts[lang]++
+                }
+            }
+        }
+    }
+    best, bestCount, tied := "", 0, false
+    for _, lang := range []string{"en", "es", "pt", "fr", "de", "it", "tr"} {
+        switch n := counts[lang]; {
+        case n > bestCount:
+            best, bestCount, tied = lang, n, false
+        case n == bestCount && n > 0:
+            tied = true
+        }
+    }
+    if bestCount < 2 || tied {
+        return ""
+    }
+    return best
+}
+
+// linkPattern matches the links in a message, which DetectLanguage leaves
+// out.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+    "context"
+    "testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+    tests := []struct {
+        text string
+        want string
+    }{
+        {"Your account has been suspended, please verify it with the link", "en"},
+        {"Su cuenta ha sido suspendida, verifique su identidad para la tarjeta", "es"},
+        {"Sua conta foi suspensa, você precisa confirmar para não perder", "pt"},
+        {"Votre compte a été suspendu, vous devez confirmer pour le garder", "fr"},
+        {"Ihr Konto wurde gesperrt, bitte bestätigen Sie die Zahlung für das Konto", "de"},
+        {"Il tuo conto è stato sospeso, conferma per non perdere i dati", "it"},
+        {"Hesabınız askıya alındı, lütfen bu bağlantı ile doğrulayın", "tr"},
+        {"Ваш аккаунт заблокирован, подтвердите данные", "ru"},
+        {"Ваш рахунок заблоковано, підтвердіть дані", "uk"},
+        {"アカウントが停止されました", "ja"},
+        {"您的账户已被冻结", "zh"},
+        {"계정이 정지되었습니다", "ko"},
+        {"تم تعليق حسابك", "ar"},
+        {"החשבון שלך הושעה", "he"},
+        {"Ο λογαριασμός σας έχει ανασταλεί", "el"},
+        // A Russian message with a long link is still Russian
+        {"Срочно войдите https://secure-login.example.com/account/verify/session", "ru"},
+        {"", ""},
+        {"https://a.example/your/account/is/the/one", ""},
+        {"12345 !!!", ""},
+        {"ok", ""},
+        // One common word isn't enough to tell
+        {"the invoice", ""},
+    }
+    for _, tt := range tests {
+        if got := DetectLanguage(tt.text); got != tt.want {
+            t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+        }
+    }
+}
+
+func TestTextAnalyzerLanguage(t *testing.T) {
+    tests := []struct {
+        text, language string
+        want           string // The finding's description, "" for none
+    }{
+        {"Conta bloqueada! https://a.example/", "", ""},
+        {"Conta bloqueada! https://a.example/", "pt", "message uses urgency language (pt): Conta bloqueada"},
+        {"Verifique sua conta https://a.example/", "", "message uses urgency language (pt): Verifique sua conta"},
+        {"Срочно https://a.example/", "pt", "message uses urgency language (ru): Срочно"},
+        // A language the text does tell wins over the assumed one
+        {"Su cuenta ha sido suspendida, verifique su identidad", "pt", "message uses urgency language (es): verifique su identidad, cuenta ha sido suspendida"},
+        {"Verify your account https://a.example/", "pt", "message uses urgency language: Verify your account"},
+    }
+    for _, tt := range tests {
+        findings, err := TextAnalyzer{}.Analyze(context.Background(), Target{URL: "https://a.example/", Text: tt.text, Language: tt.language})
+        if err != nil {
+            t.Fatal(err)
+        }
+        got := ""
+        if len(findings) > 0 {
+            got = findings[0].Description
+        }
+        if got != tt.want {
+            t.Errorf("%q assuming %q: %q, want %q", tt.text, tt.language, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,195 @@
+package analysis
+
+import (
+    "compress/flate"
+    "compress/gzip"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+// DefaultAllowTypes are the types of response fetches read by default:
+// pages, not the documents and archives a link may also lead to.
+var DefaultAllowTypes = []string{"text/html", "application/xhtml+xml", "text/plain"}
+
+// maxInflation is how many times its size on the wire a compressed
+// response may grow to before it is taken for a decompression bomb.
+// Pages seldom shrink more than tenfold.
+const maxInflation = 100
+
+// inflationSlack is how much a response may decompress to before
+// maxInflation applies, so short, highly repetitive pages are read.
+const inflationSlack = 64 << 10
+
+// errFetchQuota is returned by reads once the fetches in flight hold
+// fetch_quota_mb between them.
+var errFetchQuota = errors.New("fetches in flight are over analyzers.fetch_quota_mb")
+
+// downloadLimits bound the fetches in flight across every scanner: how
+// many run at once, and how many bytes they have read between them.
+//
+// Nothing bounds temporary files, as fetches never make any: bodies are
+// only ever read into memory, so the quota on what they hold is the quota
+// on what a download can take up. Only debug.capture_dir gets written to,
+// and debug.capture_days prunes it.
+type downloadLimits struct {
+    maxFetches int
+    quota      int64
+    slots      chan struct{} // nil without a limit
+    used       atomic.Int64
+}
+
+// downloads holds the limits the fetchers share. NewFetcher replaces them
+// when the settings change; fetches already running keep theirs.
+var downloads struct {
+    mu     sync.Mutex
+    limits *downloadLimits
+}
+
+// sharedLimits returns the download limits for cfg, the ones in use if
+// they are the same.
+func sharedLimits(cfg Config) *downloadLimits {
+    quota := int64(cfg.FetchQuotaMB) << 20
+    downloads.mu.Lock()
+    defer downloads.mu.Unlock()
+    if l := downloads.limits; l != nil && l.maxFetches == cfg.MaxFetches && l.quota == quota {
+        return l
+    }
+    l := &downloadLimits{maxFetches: cfg.MaxFetches, quota: quota}
+    if cfg.MaxFetches > 0 {
+        l.slots = make(chan struct{}, cfg.MaxFetches)
+    }
+    downloads.limits = l
+    return l
+}
+
+// acquire waits for a free fetch slot until ctx is done.
+func (l *downloadLimits) acquire(ctx context.Context) error {
+    if l.slots == nil {
+        return nil
+    }
+    select {
+    case l.slots <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return fmt.Errorf("waiting for a free fetch: %w", context.Cause(ctx))
+    }
+}
+
+func (l *downloadLimits) release() {
+    if l.slots != nil {
+        <-l.slots
+    }
+}
+
+// limitedBody is a response body holding a fetch slot and counting what
+// is read from it against the quota, until it is closed.
+type limitedBody struct {
+    io.Reader
+    closer io.Closer
+    limits *downloadLimits
+    read   int64
+    once   sync.Once
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+    n, err := b.Reader.Read(p)
+    b.read += int64(n)
+    if b.limits.quota > 0 && b.limits.used.Add(int64(n)) > b.limits.quota {
+        return n, errFetchQuota
+    }
+    return n, err
+}
+
+func (b *limitedBody) Close() error {
+    b.once.Do(func() {
+        b.limits.used.Add(-b.read)
+        b.limits.release()
+    })
+    return b.closer.Close()
+}
+
+// allowedType reports whether a response of contentType may be read. A
+// response without a type is taken for application/octet-stream.
+func allowedType(contentType string, allow []string) bool {
+    if len(allow) == 0 {
+        return true
+    }
+    media, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        media = "application/octet-stream"
+    }
+    for _, pattern := range allow {
+        pattern = strings.ToLower(pattern)
+        if pattern == media || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(media, strings.TrimSuffix(pattern, "*"))) {
+            return true
+        }
+    }
+    return false
+}
+
+// decodeBody replaces the body of a gzip or deflate encoded response with
+// its decoded content, which fails once it grows past maxInflation times
+// the bytes read from the wire. Other encodings are left as they are.
+func decodeBody(resp *http.Response) error {
+    encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+    if encoding != "gzip" && encoding != "deflate" {
+        return nil
+    }
+    wire := &countingReader{Reader: resp.Body}
+    var decoded io.Reader
+    if encoding == "gzip" {
+        zr, err := gzip.NewReader(wire)
+        if err != nil {
+            return fmt.Errorf("invalid gzip response: %v", err)
+        }
+        decoded = zr
+    } else {
+        decoded = flate.NewReader(wire)
+    }
+    resp.Body = limitedReadCloser{&inflationGuard{Reader: decoded, wire: wire}, resp.Body}
+    resp.Header.Del("Content-Encoding")
+    resp.Header.Del("Content-Length")
+    resp.ContentLength, resp.Uncompressed = -1, true
+    return nil
+}
+
+// limitedReadCloser reads through a wrapper of a body and closes the body.
+type limitedReadCloser struct {
+    io.Reader
+    io.Closer
+}
+
+type countingReader struct {
+    io.Reader
+    n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+    n, err := r.Reader.Read(p)
+    r.n += int64(n)
+    return n, err
+}
+
+// inflationGuard fails reads of a decoded body once it is too much larger
+// than what was read from the wire.
+type inflationGuard struct {
+    io.Reader
+    wire *countingReader
+    n    int64
+}
+
+func (g *inflationGuard) Read(p []byte) (int, error) {
+    n, err := g.Reader.Read(p)
+    g.n += int64(n)
+    if g.n > inflationSlack && g.n > maxInflation*g.wire.n {
+        return n, fmt.Errorf("response decompresses to more than %d times its size", maxInflation)
+    }
+    return n, err
+}
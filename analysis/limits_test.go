@@ -0,0 +1,121 @@
+package analysis
+
+import (
+    "bytes"
+    "compress/gzip"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+func TestAllowedType(t *testing.T) {
+    tests := []struct {
+        contentType string
+        allow       []string
+        want        bool
+    }{
+        {"text/html; charset=utf-8", DefaultAllowTypes, true},
+        {"TEXT/HTML", DefaultAllowTypes, true},
+        {"application/pdf", DefaultAllowTypes, false},
+        {"application/zip", DefaultAllowTypes, false},
+        {"", DefaultAllowTypes, false},
+        {"not a type", DefaultAllowTypes, false},
+        {"", []string{"application/octet-stream"}, true},
+        {"text/csv", []string{"text/*"}, true},
+        {"textual/csv", []string{"text/*"}, false},
+        {"application/zip", nil, true},
+    }
+    for _, tt := range tests {
+        if got := allowedType(tt.contentType, tt.allow); got != tt.want {
+            t.Errorf("allowedType(%q, %v) = %v, want %v", tt.contentType, tt.allow, got, tt.want)
+        }
+    }
+}
+
+func TestFetchLimits(t *testing.T) {
+    page := []byte("<html>" + strings.Repeat("<p>Verify your account</p>", 50000) + "</html>") // ~1.3 MB
+    var bomb bytes.Buffer
+    zw := gzip.NewWriter(&bomb)
+    zw.Write(make([]byte, 64<<20))
+    zw.Close()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/page":
+            w.Header().Set("Content-Type", "text/html")
+            w.Write(page)
+        case "/bomb":
+            w.Header().Set("Content-Type", "text/html")
+            w.Header().Set("Content-Encoding", "gzip")
+            w.Write(bomb.Bytes())
+        case "/document":
+            w.Header().Set("Content-Type", "application/pdf")
+            w.Write(page)
+        }
+    }))
+    defer srv.Close()
+
+    // Fetched bodies stay in memory
+    tmp := t.TempDir()
+    if runtime.GOOS == "windows" {
+        t.Setenv("TMP", tmp)
+    } else {
+        t.Setenv("TMPDIR", tmp)
+    }
+
+    cfg := testFetchConfig()
+    cfg.AllowPrivate = true
+    cfg.MaxPageKB = 256
+    cfg.AllowTypes = DefaultAllowTypes
+    client, err := NewFetcher(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    fetch := func(path string) ([]byte, error) {
+        resp, err := client.Get(srv.URL + path)
+        if err != nil {
+            return nil, err
+        }
+        defer resp.Body.Close()
+        return io.ReadAll(resp.Body)
+    }
+
+    if body, err := fetch("/page"); err != nil || len(body) != 256<<10 {
+        t.Errorf("page: read %d bytes, %v; want max_page_kb", len(body), err)
+    }
+    if body, err := fetch("/bomb"); err != nil || len(body) != 256<<10 {
+        t.Errorf("gzip bomb: read %d bytes, %v; want max_page_kb", len(body), err)
+    }
+    if _, err := fetch("/document"); err == nil || !strings.Contains(err.Error(), "refusing to read application/pdf") {
+        t.Errorf("document: %v, want it refused", err)
+    }
+
+    // Without max_page_kb a bomb is stopped by how much it inflates
+    cfg.MaxPageKB = 0
+    if client, err = NewFetcher(cfg); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := fetch("/bomb"); err == nil || !strings.Contains(err.Error(), "decompresses to more than") {
+        t.Errorf("gzip bomb without max_page_kb: %v, want it stopped", err)
+    }
+
+    // Nor can a page outgrow what all fetches may hold between them
+    cfg.FetchQuotaMB = 1
+    if client, err = NewFetcher(cfg); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := fetch("/page"); err != errFetchQuota {
+        t.Errorf("page over fetch_quota_mb: %v, want %v", err, errFetchQuota)
+    }
+
+    entries, err := os.ReadDir(tmp)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(entries) != 0 {
+        t.Errorf("fetches left %d files in the temporary directory", len(entries))
+    }
+}
@@ -0,0 +1,238 @@
+package analysis
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/fsutil"
+)
+
+// List names.
+const (
+    ListAllow = "allow"
+    ListBlock = "block"
+)
+
+// ListEntry is a domain on the allowlist or blocklist.
+type ListEntry struct {
+    Added   time.Time `json:"added"`
+    Note    string    `json:"note,omitempty"`
+    Source  string    `json:"source,omitempty"`  // The indicator feed it was imported from, if any
+    Expires time.Time `json:"expires,omitzero"` // When it stops counting; zero for never
+}
+
+// expired reports whether the entry no longer counts at now.
+func (e ListEntry) expired(now time.Time) bool {
+    return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// Lists are the allowlisted and blocked domains, saved as JSON. A domain
+// also covers its subdomains. Links on either list skip the analyzers:
+// allowlisted ones are clean and blocked ones malicious.
+type Lists struct {
+    Allow map[string]ListEntry `json:"allow"`
+    Block map[string]ListEntry `json:"block"`
+
+    path string
+    mu   sync.Mutex
+}
+
+// LoadLists reads the lists from path. A missing file starts both lists
+// empty, and an empty path keeps them in memory only.
+func LoadLists(path string) (*Lists, error) {
+    l := &Lists{path: path}
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil && !os.IsNotExist(err) {
+            return nil, fmt.Errorf("failed to read lists: %v", err)
+        }
+        if err == nil {
+            if err := json.Unmarshal(data, l); err != nil {
+                return nil, fmt.Errorf("failed to parse lists %s: %v", path, err)
+            }
+        }
+    }
+    if l.Allow == nil {
+        l.Allow = map[string]ListEntry{}
+    }
+    if l.Block == nil {
+        l.Block = map[string]ListEntry{}
+    }
+    return l, nil
+}
+
+// Replace takes over the entries and file of fresh, for a reload.
+func (l *Lists) Replace(fresh *Lists) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.Allow, l.Block, l.path = fresh.Allow, fresh.Block, fresh.path
+}
+
+// Match returns the list covering link's host, the blocklist winning, and
+// the listed domain that matched. list is empty if neither does.
+func (l *Lists) Match(link string) (list, domain string) {
+    u, err := url.Parse(link)
+    if err != nil || u.Hostname() == "" {
+        return "", ""
+    }
+    host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+    now := time.Now()
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    for _, name := range []string{ListBlock, ListAllow} {
+        entries := l.list(name)
+        for d := host; d != ""; {
+            if e, ok := entries[d]; ok && !e.expired(now) {
+                return name, d
+            }
+            _, parent, ok := strings.Cut(d, ".")
+            if !ok {
+                break
+            }
+            d = parent
+        }
+    }
+    return "", ""
+}
+
+// Add puts domain on a list, taking it off the other one, and saves.
+func (l *Lists) Add(list, domain, note string) error {
+    domain, err := normalizeDomain(domain)
+    if err != nil {
+        return err
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    entries := l.list(list)
+    if entries == nil {
+        return fmt.Errorf("unknown list %q", list)
+    }
+    delete(l.Allow, domain)
+    delete(l.Block, domain)
+    entries[domain] = ListEntry{Added: time.Now().UTC(), Note: note}
+    return l.save()
+}
+
+// Remove takes domain off a list and saves.
+func (l *Lists) Remove(list, domain string) error {
+    domain, err := normalizeDomain(domain)
+    if err != nil {
+        return err
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    entries := l.list(list)
+    if entries == nil {
+        return fmt.Errorf("unknown list %q", list)
+    }
+    delete(entries, domain)
+    return l.save()
+}
+
+// Import puts the domains of entries on a list, tagged with source, and
+// saves once. With replace, what source put on the list before is taken
+// off first. Domains on the other list, or put on this one by hand, are
+// skipped, so a feed never overrides a person's decision. It returns how
+// many domains were added and skipped.
+func (l *Lists) Import(list, source string, entries map[string]ListEntry, replace bool) (added, skipped int, err error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    target := l.list(list)
+    if target == nil {
+        return 0, 0, fmt.Errorf("unknown list %q", list)
+    }
+    other := l.Allow
+    if list == ListAllow {
+        other = l.Block
+    }
+    now := time.Now().UTC()
+    for domain, e := range target {
+        if e.Source == source && (replace || e.expired(now)) {
+            delete(target, domain)
+        }
+    }
+    for domain, e := range entries {
+        domain, err := normalizeDomain(domain)
+        if err != nil {
+            skipped++
+            continue
+        }
+        if _, ok := other[domain]; ok {
+            skipped++
+            continue
+        }
+        if old, ok := target[domain]; ok && old.Source != source {
+            skipped++
+            continue
+        }
+        e.Added, e.Source = now, source
+        target[domain] = e
+        added++
+    }
+    return added, skipped, l.save()
+}
+
+// Domains returns the domains on a list that haven't expired, sorted.
+func (l *Lists) Domains(list string) []string {
+    now := time.Now()
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    var domains []string
+    for d, e := range l.list(list) {
+        if e.expired(now) {
+            continue
+        }
+        domains = append(domains, d)
+    }
+    sort.Strings(domains)
+    return domains
+}
+
+func (l *Lists) list(name string) map[string]ListEntry {
+    switch name {
+    case ListAllow:
+        return l.Allow
+    case ListBlock:
+        return l.Block
+    }
+    return nil
+}
+
+func (l *Lists) save() error {
+    if l.path == "" {
+        return nil
+    }
+    data, err := json.MarshalIndent(l, "", "    ")
+    if err != nil {
+        return err
+    }
+    if err := fsutil.WriteFile(l.path, data, 0o600); err != nil {
+        return fmt.Errorf("failed to save lists: %v", err)
+    }
+    return nil
+}
+
+// normalizeDomain accepts a bare domain or a URL and returns the lowercase
+// host.
+func normalizeDomain(s string) (string, error) {
+    s = strings.TrimSpace(s)
+    if strings.Contains(s, "://") {
+        u, err := url.Parse(s)
+        if err != nil {
+            return "", fmt.Errorf("invalid URL %q: %v", s, err)
+        }
+        s = u.Hostname()
+    }
+    s = strings.TrimSuffix(strings.ToLower(s), ".")
+    if s == "" || strings.ContainsAny(s, "/ :@") {
+        return "", fmt.Errorf("invalid domain %q", s)
+    }
+    return s, nil
+}
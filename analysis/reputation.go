@@ -0,0 +1,209 @@
+package analysis
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+// ReputationCache keeps what reputation analyzers found for links, so a
+// link seen again, here or by another instance asking this one, doesn't
+// use up an API's quota again. Only successful lookups are kept.
+type ReputationCache struct {
+    size int // Links kept at most
+
+    mu      sync.Mutex
+    entries map[string]cachedFindings
+}
+
+type cachedFindings struct {
+    findings []Finding
+    expires  time.Time
+}
+
+// NewReputationCache returns a cache keeping up to size lookups.
+func NewReputationCache(size int) *ReputationCache {
+    return &ReputationCache{size: size, entries: map[string]cachedFindings{}}
+}
+
+func (c *ReputationCache) get(key string, now time.Time) ([]Finding, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    e, ok := c.entries[key]
+    if !ok || !now.Before(e.expires) {
+        return nil, false
+    }
+    return append([]Finding(nil), e.findings...), true
+}
+
+func (c *ReputationCache) put(key string, findings []Finding, expires time.Time) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+        // Make room by dropping what has expired, or else what expires
+        // soonest
+        var soonest string
+        for k, e := range c.entries {
+            if !e.expires.After(time.Now()) {
+                delete(c.entries, k)
+            } else if soonest == "" || e.expires.Before(c.entries[soonest].expires) {
+                soonest = k
+            }
+        }
+        if len(c.entries) >= c.size {
+            delete(c.entries, soonest)
+        }
+    }
+    c.entries[key] = cachedFindings{findings: append([]Finding(nil), findings...), expires: expires}
+}
+
+// Len returns how many lookups are cached, expired or not.
+func (c *ReputationCache) Len() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return len(c.entries)
+}
+
+// Cached answers from cache what its analyzer found for a link in the
+// last TTL, and otherwise runs it and remembers the findings. The message
+// text isn't part of the key, so it should only wrap analyzers that look
+// at the link alone, as reputation lookups do.
+type Cached struct {
+    Analyzer
+    Cache *ReputationCache
+    TTL   time.Duration
+}
+
+// Slow reports whether the wrapped analyzer is slow.
+func (c Cached) Slow() bool {
+    slow, ok := c.Analyzer.(SlowAnalyzer)
+    return ok && slow.Slow()
+}
+
+// Analyze returns the cached findings for target's link, or looks it up.
+func (c Cached) Analyze(ctx context.Context, target Target) ([]Finding, error) {
+    key := c.Name() + "\x00" + target.URL
+    if findings, ok := c.Cache.get(key, time.Now()); ok {
+        markCached(ctx)
+        return findings, nil
+    }
+    findings, err := analyze(ctx, c.Analyzer, target)
+    if err != nil {
+        return nil, err
+    }
+    c.Cache.put(key, findings, time.Now().Add(c.TTL))
+    return findings, nil
+}
+
+// Status returns the health of the breaker the analyzer is wrapped in, if
+// it is.
+func (c Cached) Status() (BreakerStatus, bool) {
+    if b, ok := c.Analyzer.(*Breaker); ok {
+        return b.Status(), true
+    }
+    return BreakerStatus{}, false
+}
+
+// ReputationRequest is what a SharedReputation sends the instance serving
+// lookups.
+type ReputationRequest struct {
+    URL string `json:"url"`
+}
+
+// ReputationResponse is the answer to a ReputationRequest.
+type ReputationResponse struct {
+    Findings []Finding `json:"findings"`
+}
+
+// SharedReputation asks another instance serving reputation lookups what
+// its reputation analyzers make of a link, so a team's instances share
+// one set of API keys, their quota and a cache. Only the link is sent,
+// never the message. If that instance can't be reached, the Local
+// analyzers are run instead.
+type SharedReputation struct {
+    Server string // Admin server URL of the instance serving lookups
+    Token  string // Its admin token
+    Local  []Analyzer
+
+    client *http.Client
+}
+
+// NewSharedReputation returns an analyzer asking server, with timeout for
+// each lookup.
+func NewSharedReputation(server, token string, timeout time.Duration, local []Analyzer) *SharedReputation {
+    return &SharedReputation{Server: strings.TrimSuffix(server, "/"), Token: token, Local: local,
+        client: &http.Client{Transport: netutil.NewTransport(nil), Timeout: timeout}}
+}
+
+// Name identifies the analyzer.
+func (*SharedReputation) Name() string { return "reputation" }
+
+// Slow reports that lookups go over the network.
+func (*SharedReputation) Slow() bool { return true }
+
+// Analyze asks the server about target's link, falling back to the local
+// analyzers.
+func (s *SharedReputation) Analyze(ctx context.Context, target Target) ([]Finding, error) {
+    findings, err := s.lookup(ctx, target.URL)
+    if err == nil {
+        return findings, nil
+    }
+    if len(s.Local) == 0 {
+        return nil, err
+    }
+    findings = []Finding{{Analyzer: s.Name(), Severity: SeverityInfo, Description: fmt.Sprintf("shared lookup failed, so looked up here: %v", err)}}
+    for _, a := range s.Local {
+        local, err := analyze(ctx, a, target)
+        if err != nil {
+            local = append(local, Finding{Analyzer: a.Name(), Severity: SeverityInfo, Description: fmt.Sprintf("analyzer failed: %v", err)})
+        }
+        findings = append(findings, local...)
+    }
+    return findings, nil
+}
+
+func (s *SharedReputation) lookup(ctx context.Context, link string) ([]Finding, error) {
+    body, err := json.Marshal(ReputationRequest{URL: link})
+    if err != nil {
+        return nil, err
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Server+"/api/v1/reputation", bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Authorization", "Bearer "+s.Token)
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return nil, fmt.Errorf("%s returned %s: %s", s.Server, resp.Status, strings.TrimSpace(string(msg)))
+    }
+    var res ReputationResponse
+    if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&res); err != nil {
+        return nil, fmt.Errorf("%s returned invalid JSON: %v", s.Server, err)
+    }
+    return res.Findings, nil
+}
+
+// Breakers returns the health of the local analyzers' breakers.
+func (s *SharedReputation) Breakers() []BreakerStatus {
+    var status []BreakerStatus
+    for _, a := range s.Local {
+        if st, ok := breakerStatus(a); ok {
+            status = append(status, st)
+        }
+    }
+    return status
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+// DNSConfig sends the lookups made while analyzing links to an encrypted
+// resolver instead of the system's.
+type DNSConfig struct {
+    // Server is a DNS-over-HTTPS URL, such as
+    // https://cloudflare-dns.com/dns-query, or a DNS-over-TLS server as
+    // tls://host[:port]. Empty, the system resolver is used.
+    Server string `yaml:"server"`
+    // Bootstrap is the plain DNS server, as ip[:port], that resolves
+    // Server's own name. Empty, the system resolver does.
+    Bootstrap string `yaml:"bootstrap"`
+}
+
+// dnsTimeout bounds one query to the encrypted resolver.
+const dnsTimeout = 10 * time.Second
+
+// NewResolver returns the resolver cfg describes, or nil if lookups should
+// use the system's.
+func NewResolver(cfg DNSConfig) (*net.Resolver, error) {
+    if cfg.Server == "" {
+        return nil, nil
+    }
+    u, err := url.Parse(cfg.Server)
+    if err != nil || u.Host == "" {
+        return nil, fmt.Errorf("analyzers.dns.server: want https://host/path or tls://host[:port], got %q", cfg.Server)
+    }
+
+    var bootstrap *net.Resolver
+    if cfg.Bootstrap != "" {
+        addr := cfg.Bootstrap
+        if _, _, err := net.SplitHostPort(addr); err != nil {
+            addr = net.JoinHostPort(addr, "53")
+        }
+        host, _, _ := net.SplitHostPort(addr)
+        if net.ParseIP(host) == nil {
+            return nil, fmt.Errorf("analyzers.dns.bootstrap: want an IP address, got %q", cfg.Bootstrap)
+        }
+        bootstrap = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+            var d net.Dialer
+            return d.DialContext(ctx, network, addr)
+        }}
+    }
+    dialer := netutil.NewDialer(nil, bootstrap)
+
+    // The Go resolver speaks DNS over TCP to any connection that isn't a
+    // PacketConn, which is all DNS over TLS is, and what dohConn turns
+    // into HTTP requests
+    var dial func(ctx context.Context) (net.Conn, error)
+    switch u.Scheme {
+    case "tls":
+        port := u.Port()
+        if port == "" {
+            port = "853"
+        }
+        addr := net.JoinHostPort(u.Hostname(), port)
+        tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}
+        dial = func(ctx context.Context) (net.Conn, error) {
+            return tlsDialer.DialContext(ctx, "tcp", addr)
+        }
+    case "https":
+        transport := netutil.NewTransport(nil)
+        transport.Proxy = nil
+        transport.DialContext = dialer.DialContext
+        client := &http.Client{Transport: transport, Timeout: dnsTimeout}
+        dial = func(ctx context.Context) (net.Conn, error) {
+            return &dohConn{ctx: ctx, client: client, url: u.String()}, nil
+        }
+    default:
+        return nil, fmt.Errorf("analyzers.dns.server: want https://host/path or tls://host[:port], got %q", cfg.Server)
+    }
+    return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+        return dial(ctx)
+    }}, nil
+}
+
+// dohConn is a DNS-over-TCP connection made of DNS-over-HTTPS requests:
+// each length-prefixed query written to it is posted to the server, and
+// the answer read back with its length prefixed.
+type dohConn struct {
+    ctx      context.Context
+    client   *http.Client
+    url      string
+    deadline time.Time
+    query    bytes.Buffer
+    answer   bytes.Buffer
+}
+
+func (c *dohConn) Write(p []byte) (int, error) {
+    c.query.Write(p)
+    for c.query.Len() >= 2 {
+        size := int(binary.BigEndian.Uint16(c.query.Bytes()))
+        if c.query.Len() < 2+size {
+            break
+        }
+        msg := make([]byte, size)
+        c.query.Next(2)
+        c.query.Read(msg)
+        answer, err := c.exchange(msg)
+        if err != nil {
+            return 0, err
+        }
+        binary.Write(&c.answer, binary.BigEndian, uint16(len(answer)))
+        c.answer.Write(answer)
+    }
+    return len(p), nil
+}
+
+// exchange posts msg to the server as RFC 8484 describes, and returns its
+// answer.
+func (c *dohConn) exchange(msg []byte) ([]byte, error) {
+    ctx := c.ctx
+    if !c.deadline.IsZero() {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithDeadline(ctx, c.deadline)
+        defer cancel()
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(msg))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/dns-message")
+    req.Header.Set("Accept", "application/dns-message")
+    resp, err := c.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("DNS over HTTPS server returned %s", resp.Status)
+    }
+    answer, err := io.ReadAll(io.LimitReader(resp.Body, 65535+1))
+    if err != nil {
+        return nil, err
+    }
+    if len(answer) > 65535 {
+        return nil, fmt.Errorf("DNS over HTTPS answer is too large")
+    }
+    return answer, nil
+}
+
+func (c *dohConn) Read(p []byte) (int, error) {
+    if c.answer.Len() == 0 {
+        return 0, io.EOF
+    }
+    return c.answer.Read(p)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr{} }
+func (c *dohConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { c.deadline = t; return nil }
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string { return "https" }
+func (dohAddr) String() string  { return "dns-over-https" }
@@ -0,0 +1,188 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "sync/atomic"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Limits on API requests.
+const (
+    maxScanRequestBytes = 64 << 10
+    defaultAlertsLimit  = 100
+    maxAlertsLimit      = 1000
+)
+
+// ScanRequest is the body of POST /api/v1/scan.
+type ScanRequest struct {
+    URL    string `json:"url"`
+    Text   string `json:"text,omitempty"`   // Message the link arrived with, for the text analyzer
+    Notify bool   `json:"notify,omitempty"` // Also deliver the alert along the configured routes
+}
+
+// submitted numbers the alerts submitted through the API and gRPC, and
+// copied to the clipboard.
+var submitted atomic.Int64
+
+// API serves the JSON API for other tools:
+//
+//    POST /api/v1/scan            scan a URL (ScanRequest) and return its HistoryEntry
+//    GET  /api/v1/verdicts/{id}   a recorded HistoryEntry
+//    GET  /api/v1/alerts?since=   entries since an RFC 3339 time or a duration ago, oldest first
+//    POST /api/v1/reload          reload the config file, as SIGHUP does
+//    POST /api/v1/reputation      look up a URL (ReputationRequest) with the reputation plugins, if reputation.serve is set
+//
+// Requests need the admin token as a Bearer token.
+func (a *App) API() http.Handler {
+    mux := http.NewServeMux()
+    mux.HandleFunc("POST /api/v1/scan", a.apiScan)
+    mux.HandleFunc("GET /api/v1/verdicts/{id}", a.apiVerdict)
+    mux.HandleFunc("GET /api/v1/alerts", a.apiAlerts)
+    mux.HandleFunc("POST /api/v1/reload", a.apiReload)
+    mux.HandleFunc("POST /api/v1/reputation", a.apiReputation)
+    return requireToken(a.Config.Admin.Token, http.NewCrossOriginProtection().Handler(mux))
+}
+
+func (a *App) apiScan(w http.ResponseWriter, r *http.Request) {
+    var req ScanRequest
+    dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxScanRequestBytes))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&req); err != nil {
+        apiError(w, http.StatusBadRequest, "invalid request: %v", err)
+        return
+    }
+    if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        apiError(w, http.StatusBadRequest, "url: want an absolute http or https URL, got %q", req.URL)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, a.ScanSubmitted(r.Context(), req.URL, req.Text, req.Notify, "api"))
+}
+
+// apiReputation answers another instance's reputation lookup from the
+// cache, or else with the plugins here. Only the link is looked up; no
+// alert is made or recorded.
+func (a *App) apiReputation(w http.ResponseWriter, r *http.Request) {
+    var req analysis.ReputationRequest
+    dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxScanRequestBytes))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&req); err != nil {
+        apiError(w, http.StatusBadRequest, "invalid request: %v", err)
+        return
+    }
+    if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        apiError(w, http.StatusBadRequest, "url: want an absolute http or https URL, got %q", req.URL)
+        return
+    }
+
+    a.inFlight.Add(1)
+    defer a.inFlight.Add(-1)
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if !a.Config.Reputation.Serve {
+        apiError(w, http.StatusNotFound, "reputation.serve is not set")
+        return
+    }
+    res := analysis.ReputationResponse{Findings: []analysis.Finding{}}
+    for _, plugin := range a.local {
+        findings, err := plugin.Analyze(r.Context(), analysis.Target{URL: req.URL})
+        if err != nil {
+            appLog.Warn("reputation lookup failed", "analyzer", plugin.Name(), "url", req.URL, "err", err)
+            findings = []analysis.Finding{{Analyzer: plugin.Name(), Severity: analysis.SeverityInfo, Description: fmt.Sprintf("analyzer failed: %v", err)}}
+        }
+        res.Findings = append(res.Findings, findings...)
+    }
+    writeJSON(w, http.StatusOK, res)
+}
+
+// ScanSubmitted processes a link submitted by another tool through the API
+// or gRPC, named by source.
+func (a *App) ScanSubmitted(ctx context.Context, link, text string, notify bool, source string) store.HistoryEntry {
+    a.inFlight.Add(1)
+    defer a.inFlight.Add(-1)
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    alert := a.NewAlert(link, text)
+    alert.ID = fmt.Sprintf("%s-%d-%d", source, time.Now().Unix(), submitted.Add(1))
+    logger := appLog.With("alert", alert.ID, "url", link, "source", source)
+    return a.Process(ctx, logger, store.HistoryEntry{Text: text, Alert: alert}, false, notify)
+}
+
+func (a *App) apiVerdict(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        apiError(w, http.StatusBadRequest, "invalid id %q", r.PathValue("id"))
+        return
+    }
+    entry, err := a.History.Get(id)
+    if err != nil {
+        apiError(w, http.StatusInternalServerError, "%v", err)
+        return
+    }
+    if entry == nil {
+        apiError(w, http.StatusNotFound, "no verdict %d", id)
+        return
+    }
+    writeJSON(w, http.StatusOK, entry)
+}
+
+func (a *App) apiAlerts(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    since := time.Now().Add(-24 * time.Hour)
+    if s := query.Get("since"); s != "" {
+        if t, err := time.Parse(time.RFC3339, s); err == nil {
+            since = t
+        } else if d, err := time.ParseDuration(s); err == nil {
+            since = time.Now().Add(-d)
+        } else {
+            apiError(w, http.StatusBadRequest, "since: want an RFC 3339 time or a duration such as 24h, got %q", s)
+            return
+        }
+    }
+    limit := defaultAlertsLimit
+    if s := query.Get("limit"); s != "" {
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 1 || n > maxAlertsLimit {
+            apiError(w, http.StatusBadRequest, "limit: want 1 to %d, got %q", maxAlertsLimit, s)
+            return
+        }
+        limit = n
+    }
+    entries, err := a.History.Since(since, limit)
+    if err != nil {
+        apiError(w, http.StatusInternalServerError, "%v", err)
+        return
+    }
+    if entries == nil {
+        entries = []store.HistoryEntry{}
+    }
+    writeJSON(w, http.StatusOK, entries)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func apiError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+    writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+func (a *App) apiReload(w http.ResponseWriter, r *http.Request) {
+    if err := a.Reload(); err != nil {
+        apiError(w, http.StatusUnprocessableEntity, "%v", err)
+        return
+    }
+    a.audit(ActorAPI, "reload", "config", "")
+    w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,672 @@
+// Telephish analysis pipeline, for running the monitor as a sidecar.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: api/telephish/v1/telephish.proto
+
+package telephishv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Severity int32
+
+const (
+	Severity_SEVERITY_UNSPECIFIED Severity = 0
+	Severity_SEVERITY_CLEAN       Severity = 1
+	Severity_SEVERITY_INFO        Severity = 2
+	Severity_SEVERITY_SUSPICIOUS  Severity = 3
+	Severity_SEVERITY_MALICIOUS   Severity = 4
+)
+
+// Enum value maps for Severity.
+var (
+	Severity_name = map[int32]string{
+		0: "SEVERITY_UNSPECIFIED",
+		1: "SEVERITY_CLEAN",
+		2: "SEVERITY_INFO",
+		3: "SEVERITY_SUSPICIOUS",
+		4: "SEVERITY_MALICIOUS",
+	}
+	Severity_value = map[string]int32{
+		"SEVERITY_UNSPECIFIED": 0,
+		"SEVERITY_CLEAN":       1,
+		"SEVERITY_INFO":        2,
+		"SEVERITY_SUSPICIOUS":  3,
+		"SEVERITY_MALICIOUS":   4,
+	}
+)
+
+func (x Severity) Enum() *Severity {
+	p := new(Severity)
+	*p = x
+	return p
+}
+
+func (x Severity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Severity) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_telephish_v1_telephish_proto_enumTypes[0].Descriptor()
+}
+
+func (Severity) Type() protoreflect.EnumType {
+	return &file_api_telephish_v1_telephish_proto_enumTypes[0]
+}
+
+func (x Severity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Severity.Descriptor instead.
+func (Severity) EnumDescriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{0}
+}
+
+type Finding struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Analyzer      string                 `protobuf:"bytes,1,opt,name=analyzer,proto3" json:"analyzer,omitempty"`
+	Severity      Severity               `protobuf:"varint,2,opt,name=severity,proto3,enum=telephish.v1.Severity" json:"severity,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Finding) Reset() {
+	*x = Finding{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Finding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Finding) ProtoMessage() {}
+
+func (x *Finding) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Finding.ProtoReflect.Descriptor instead.
+func (*Finding) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Finding) GetAnalyzer() string {
+	if x != nil {
+		return x.Analyzer
+	}
+	return ""
+}
+
+func (x *Finding) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+func (x *Finding) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type Verdict struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Severity      Severity               `protobuf:"varint,2,opt,name=severity,proto3,enum=telephish.v1.Severity" json:"severity,omitempty"`
+	Findings      []*Finding             `protobuf:"bytes,3,rep,name=findings,proto3" json:"findings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Verdict) Reset() {
+	*x = Verdict{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Verdict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Verdict) ProtoMessage() {}
+
+func (x *Verdict) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Verdict.ProtoReflect.Descriptor instead.
+func (*Verdict) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Verdict) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Verdict) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+func (x *Verdict) GetFindings() []*Finding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+// Action is what happened to an alert at one sink.
+type Action struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Sink          string                 `protobuf:"bytes,2,opt,name=sink,proto3" json:"sink,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"` // sent, failed or suppressed
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Action) Reset() {
+	*x = Action{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Action) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Action) ProtoMessage() {}
+
+func (x *Action) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Action.ProtoReflect.Descriptor instead.
+func (*Action) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Action) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Action) GetSink() string {
+	if x != nil {
+		return x.Sink
+	}
+	return ""
+}
+
+func (x *Action) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Action) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Alert struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"` // History ID, 0 if history is disabled
+	Time          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	AlertId       string                 `protobuf:"bytes,3,opt,name=alert_id,json=alertId,proto3" json:"alert_id,omitempty"`
+	Url           string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	Title         string                 `protobuf:"bytes,5,opt,name=title,proto3" json:"title,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	ChatId        int64                  `protobuf:"varint,7,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,8,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	Text          string                 `protobuf:"bytes,9,opt,name=text,proto3" json:"text,omitempty"`
+	Verdict       *Verdict               `protobuf:"bytes,10,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	Actions       []*Action              `protobuf:"bytes,11,rep,name=actions,proto3" json:"actions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Alert) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Alert) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Alert) GetAlertId() string {
+	if x != nil {
+		return x.AlertId
+	}
+	return ""
+}
+
+func (x *Alert) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Alert) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Alert) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Alert) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *Alert) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+func (x *Alert) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Alert) GetVerdict() *Verdict {
+	if x != nil {
+		return x.Verdict
+	}
+	return nil
+}
+
+func (x *Alert) GetActions() []*Action {
+	if x != nil {
+		return x.Actions
+	}
+	return nil
+}
+
+type ScanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"` // Message the link arrived with, for the text analyzer
+	Notify        bool                   `protobuf:"varint,3,opt,name=notify,proto3" json:"notify,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanRequest) Reset() {
+	*x = ScanRequest{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanRequest) ProtoMessage() {}
+
+func (x *ScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanRequest.ProtoReflect.Descriptor instead.
+func (*ScanRequest) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ScanRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *ScanRequest) GetNotify() bool {
+	if x != nil {
+		return x.Notify
+	}
+	return false
+}
+
+type ScanResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Alert         *Alert                 `protobuf:"bytes,1,opt,name=alert,proto3" json:"alert,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScanResponse) Reset() {
+	*x = ScanResponse{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanResponse) ProtoMessage() {}
+
+func (x *ScanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanResponse.ProtoReflect.Descriptor instead.
+func (*ScanResponse) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ScanResponse) GetAlert() *Alert {
+	if x != nil {
+		return x.Alert
+	}
+	return nil
+}
+
+type StreamAlertsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only send alerts at or above this severity; unspecified sends all.
+	MinSeverity Severity `protobuf:"varint,1,opt,name=min_severity,json=minSeverity,proto3,enum=telephish.v1.Severity" json:"min_severity,omitempty"`
+	// Only send alerts from these chats; empty sends all.
+	Chats         []int64 `protobuf:"varint,2,rep,packed,name=chats,proto3" json:"chats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamAlertsRequest) Reset() {
+	*x = StreamAlertsRequest{}
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamAlertsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamAlertsRequest) ProtoMessage() {}
+
+func (x *StreamAlertsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telephish_v1_telephish_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamAlertsRequest.ProtoReflect.Descriptor instead.
+func (*StreamAlertsRequest) Descriptor() ([]byte, []int) {
+	return file_api_telephish_v1_telephish_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamAlertsRequest) GetMinSeverity() Severity {
+	if x != nil {
+		return x.MinSeverity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+func (x *StreamAlertsRequest) GetChats() []int64 {
+	if x != nil {
+		return x.Chats
+	}
+	return nil
+}
+
+var File_api_telephish_v1_telephish_proto protoreflect.FileDescriptor
+
+const file_api_telephish_v1_telephish_proto_rawDesc = "" +
+	"\n" +
+	" api/telephish/v1/telephish.proto\x12\ftelephish.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"{\n" +
+	"\aFinding\x12\x1a\n" +
+	"\banalyzer\x18\x01 \x01(\tR\banalyzer\x122\n" +
+	"\bseverity\x18\x02 \x01(\x0e2\x16.telephish.v1.SeverityR\bseverity\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\"\x82\x01\n" +
+	"\aVerdict\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x122\n" +
+	"\bseverity\x18\x02 \x01(\x0e2\x16.telephish.v1.SeverityR\bseverity\x121\n" +
+	"\bfindings\x18\x03 \x03(\v2\x15.telephish.v1.FindingR\bfindings\"z\n" +
+	"\x06Action\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x12\n" +
+	"\x04sink\x18\x02 \x01(\tR\x04sink\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xcf\x02\n" +
+	"\x05Alert\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x19\n" +
+	"\balert_id\x18\x03 \x01(\tR\aalertId\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12\x14\n" +
+	"\x05title\x18\x05 \x01(\tR\x05title\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12\x17\n" +
+	"\achat_id\x18\a \x01(\x03R\x06chatId\x12\x1b\n" +
+	"\tchat_type\x18\b \x01(\tR\bchatType\x12\x12\n" +
+	"\x04text\x18\t \x01(\tR\x04text\x12/\n" +
+	"\averdict\x18\n" +
+	" \x01(\v2\x15.telephish.v1.VerdictR\averdict\x12.\n" +
+	"\aactions\x18\v \x03(\v2\x14.telephish.v1.ActionR\aactions\"K\n" +
+	"\vScanRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x16\n" +
+	"\x06notify\x18\x03 \x01(\bR\x06notify\"9\n" +
+	"\fScanResponse\x12)\n" +
+	"\x05alert\x18\x01 \x01(\v2\x13.telephish.v1.AlertR\x05alert\"f\n" +
+	"\x13StreamAlertsRequest\x129\n" +
+	"\fmin_severity\x18\x01 \x01(\x0e2\x16.telephish.v1.SeverityR\vminSeverity\x12\x14\n" +
+	"\x05chats\x18\x02 \x03(\x03R\x05chats*|\n" +
+	"\bSeverity\x12\x18\n" +
+	"\x14SEVERITY_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSEVERITY_CLEAN\x10\x01\x12\x11\n" +
+	"\rSEVERITY_INFO\x10\x02\x12\x17\n" +
+	"\x13SEVERITY_SUSPICIOUS\x10\x03\x12\x16\n" +
+	"\x12SEVERITY_MALICIOUS\x10\x042\x94\x01\n" +
+	"\tTelephish\x12=\n" +
+	"\x04Scan\x12\x19.telephish.v1.ScanRequest\x1a\x1a.telephish.v1.ScanResponse\x12H\n" +
+	"\fStreamAlerts\x12!.telephish.v1.StreamAlertsRequest\x1a\x13.telephish.v1.Alert0\x01BBZ@github.com/hacker1337itme/telephish/api/telephish/v1;telephishv1b\x06proto3"
+
+var (
+	file_api_telephish_v1_telephish_proto_rawDescOnce sync.Once
+	file_api_telephish_v1_telephish_proto_rawDescData []byte
+)
+
+func file_api_telephish_v1_telephish_proto_rawDescGZIP() []byte {
+	file_api_telephish_v1_telephish_proto_rawDescOnce.Do(func() {
+		file_api_telephish_v1_telephish_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_telephish_v1_telephish_proto_rawDesc), len(file_api_telephish_v1_telephish_proto_rawDesc)))
+	})
+	return file_api_telephish_v1_telephish_proto_rawDescData
+}
+
+var file_api_telephish_v1_telephish_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_telephish_v1_telephish_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_api_telephish_v1_telephish_proto_goTypes = []any{
+	(Severity)(0),                 // 0: telephish.v1.Severity
+	(*Finding)(nil),               // 1: telephish.v1.Finding
+	(*Verdict)(nil),               // 2: telephish.v1.Verdict
+	(*Action)(nil),                // 3: telephish.v1.Action
+	(*Alert)(nil),                 // 4: telephish.v1.Alert
+	(*ScanRequest)(nil),           // 5: telephish.v1.ScanRequest
+	(*ScanResponse)(nil),          // 6: telephish.v1.ScanResponse
+	(*StreamAlertsRequest)(nil),   // 7: telephish.v1.StreamAlertsRequest
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_api_telephish_v1_telephish_proto_depIdxs = []int32{
+	0,  // 0: telephish.v1.Finding.severity:type_name -> telephish.v1.Severity
+	0,  // 1: telephish.v1.Verdict.severity:type_name -> telephish.v1.Severity
+	1,  // 2: telephish.v1.Verdict.findings:type_name -> telephish.v1.Finding
+	8,  // 3: telephish.v1.Action.time:type_name -> google.protobuf.Timestamp
+	8,  // 4: telephish.v1.Alert.time:type_name -> google.protobuf.Timestamp
+	2,  // 5: telephish.v1.Alert.verdict:type_name -> telephish.v1.Verdict
+	3,  // 6: telephish.v1.Alert.actions:type_name -> telephish.v1.Action
+	4,  // 7: telephish.v1.ScanResponse.alert:type_name -> telephish.v1.Alert
+	0,  // 8: telephish.v1.StreamAlertsRequest.min_severity:type_name -> telephish.v1.Severity
+	5,  // 9: telephish.v1.Telephish.Scan:input_type -> telephish.v1.ScanRequest
+	7,  // 10: telephish.v1.Telephish.StreamAlerts:input_type -> telephish.v1.StreamAlertsRequest
+	6,  // 11: telephish.v1.Telephish.Scan:output_type -> telephish.v1.ScanResponse
+	4,  // 12: telephish.v1.Telephish.StreamAlerts:output_type -> telephish.v1.Alert
+	11, // [11:13] is the sub-list for method output_type
+	9,  // [9:11] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_api_telephish_v1_telephish_proto_init() }
+func file_api_telephish_v1_telephish_proto_init() {
+	if File_api_telephish_v1_telephish_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_telephish_v1_telephish_proto_rawDesc), len(file_api_telephish_v1_telephish_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_telephish_v1_telephish_proto_goTypes,
+		DependencyIndexes: file_api_telephish_v1_telephish_proto_depIdxs,
+		EnumInfos:         file_api_telephish_v1_telephish_proto_enumTypes,
+		MessageInfos:      file_api_telephish_v1_telephish_proto_msgTypes,
+	}.Build()
+	File_api_telephish_v1_telephish_proto = out.File
+	file_api_telephish_v1_telephish_proto_goTypes = nil
+	file_api_telephish_v1_telephish_proto_depIdxs = nil
+}
@@ -0,0 +1,179 @@
+// Telephish analysis pipeline, for running the monitor as a sidecar.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.29.3
+// source: api/telephish/v1/telephish.proto
+
+package telephishv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Telephish_Scan_FullMethodName         = "/telephish.v1.Telephish/Scan"
+	Telephish_StreamAlerts_FullMethodName = "/telephish.v1.Telephish/StreamAlerts"
+)
+
+// TelephishClient is the client API for Telephish service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Telephish scans links and streams the alerts it raises. Calls need the
+// admin token as "authorization: Bearer <token>" metadata.
+type TelephishClient interface {
+	// Scan runs a URL through the analyzers, records it in the history and
+	// optionally delivers the alert along the configured routes.
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+	// StreamAlerts sends every alert processed from now on until the client
+	// disconnects.
+	StreamAlerts(ctx context.Context, in *StreamAlertsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Alert], error)
+}
+
+type telephishClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTelephishClient(cc grpc.ClientConnInterface) TelephishClient {
+	return &telephishClient{cc}
+}
+
+func (c *telephishClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, Telephish_Scan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *telephishClient) StreamAlerts(ctx context.Context, in *StreamAlertsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Alert], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Telephish_ServiceDesc.Streams[0], Telephish_StreamAlerts_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamAlertsRequest, Alert]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Telephish_StreamAlertsClient = grpc.ServerStreamingClient[Alert]
+
+// TelephishServer is the server API for Telephish service.
+// All implementations must embed UnimplementedTelephishServer
+// for forward compatibility.
+//
+// Telephish scans links and streams the alerts it raises. Calls need the
+// admin token as "authorization: Bearer <token>" metadata.
+type TelephishServer interface {
+	// Scan runs a URL through the analyzers, records it in the history and
+	// optionally delivers the alert along the configured routes.
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
+	// StreamAlerts sends every alert processed from now on until the client
+	// disconnects.
+	StreamAlerts(*StreamAlertsRequest, grpc.ServerStreamingServer[Alert]) error
+	mustEmbedUnimplementedTelephishServer()
+}
+
+// UnimplementedTelephishServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTelephishServer struct{}
+
+func (UnimplementedTelephishServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedTelephishServer) StreamAlerts(*StreamAlertsRequest, grpc.ServerStreamingServer[Alert]) error {
+	return status.Error(codes.Unimplemented, "method StreamAlerts not implemented")
+}
+func (UnimplementedTelephishServer) mustEmbedUnimplementedTelephishServer() {}
+func (UnimplementedTelephishServer) testEmbeddedByValue()                   {}
+
+// UnsafeTelephishServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TelephishServer will
+// result in compilation errors.
+type UnsafeTelephishServer interface {
+	mustEmbedUnimplementedTelephishServer()
+}
+
+func RegisterTelephishServer(s grpc.ServiceRegistrar, srv TelephishServer) {
+	// If the following call panics, it indicates UnimplementedTelephishServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Telephish_ServiceDesc, srv)
+}
+
+func _Telephish_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TelephishServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Telephish_Scan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TelephishServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Telephish_StreamAlerts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamAlertsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TelephishServer).StreamAlerts(m, &grpc.GenericServerStream[StreamAlertsRequest, Alert]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Telephish_StreamAlertsServer = grpc.ServerStreamingServer[Alert]
+
+// Telephish_ServiceDesc is the grpc.ServiceDesc for Telephish service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Telephish_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "telephish.v1.Telephish",
+	HandlerType: (*TelephishServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Scan",
+			Handler:    _Telephish_Scan_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamAlerts",
+			Handler:       _Telephish_StreamAlerts_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/telephish/v1/telephish.proto",
+}
@@ -0,0 +1,721 @@
+// Package telephish is the phishing link monitor: it receives Telegram
+// updates, scans their links with the analysis package, and sends alerts
+// through notify sinks and the desktop. cmd/telephish is its command.
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/capture"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// App is the monitoring pipeline: it turns Telegram updates into scanned
+// alerts and delivers them along the configured routes.
+type App struct {
+    Config *Config
+    pipeline
+    Prefs   *store.ChatPreferences
+    History *store.History
+    State   *store.StateStore
+    Lists   *analysis.Lists
+    Alerts  *Broker // Publishes every processed alert
+
+    // mu is held for reading while an update or submitted link is
+    // processed, and for writing while Reload swaps in a new pipeline.
+    mu       sync.RWMutex
+    reloadMu sync.Mutex
+    closers  []func() error
+    queue    *workQueue
+    lanes    *chatLanes // nil unless workers.per_chat
+    domains  domainLimiter
+    health   health
+    capture  *capture.Recorder // nil unless debug.capture_dir is set
+    cache    *analysis.ReputationCache
+    sup      supervisor
+    inFlight atomic.Int64
+    peers    []*App // The other profiles' apps, on the first one
+
+    deliveries *deliveryQueue      // nil without history
+    results    *notify.ResultWriter // nil unless output is jsonl
+}
+
+// stdoutResults writes the results of every profile's app to stdout.
+var stdoutResults = notify.NewResultWriter(os.Stdout)
+
+// NewApp wires up the pipeline described by cfg, ignoring any profiles;
+// NewApps runs them.
+func NewApp(cfg *Config) (*App, error) {
+    return newApp(cfg, nil)
+}
+
+// newApp wires up an App, sharing the stores and broker of shared if it's
+// not nil.
+func newApp(cfg *Config, shared *App) (*App, error) {
+    if cfg.Telegram.Proxy != "" {
+        if err := telegram.SetProxy(cfg.Telegram.Proxy); err != nil {
+            return nil, err
+        }
+    }
+
+    app := &App{Config: cfg}
+    if cfg.Output == "jsonl" {
+        app.results = stdoutResults
+    }
+    var err error
+    if app.State, err = store.OpenStateStore(cfg.State); err != nil {
+        return nil, err
+    }
+    if shared != nil {
+        app.Prefs, app.Lists, app.History, app.Alerts, app.capture = shared.Prefs, shared.Lists, shared.History, shared.Alerts, shared.capture
+        app.cache = shared.cache
+    } else {
+        if app.Prefs, err = store.LoadChatPreferences(cfg.ChatPrefs); err != nil {
+            return nil, err
+        }
+        if app.Lists, err = analysis.LoadLists(cfg.Lists); err != nil {
+            return nil, err
+        }
+        if app.History, err = store.OpenHistory(cfg.History); err != nil {
+            return nil, err
+        }
+        app.closers = append(app.closers, app.History.Close)
+        app.Alerts = &Broker{}
+        app.cache = analysis.NewReputationCache(cfg.Reputation.CacheSize)
+        if cfg.Debug.CaptureDir != "" {
+            if app.capture, err = capture.Open(cfg.Debug.CaptureDir); err != nil {
+                app.Close()
+                return nil, err
+            }
+            appLog.Warn("capturing raw updates and analyzer exchanges; captures hold message contents", "dir", cfg.Debug.CaptureDir)
+        }
+    }
+    app.queue = newWorkQueue(cfg.Workers, &app.sup)
+    if cfg.Workers.PerChat {
+        app.lanes = newChatLanes(cfg.Workers, &app.sup)
+    }
+    app.domains.limit = cfg.Workers.PerDomain
+    if app.pipeline, err = buildPipeline(cfg, app.Prefs, app.Lists, app.History, app.capture, app.cache, &app.sup); err != nil {
+        app.Close()
+        return nil, err
+    }
+    if app.History.Enabled() {
+        app.deliveries = newDeliveryQueue(app)
+    }
+    return app, nil
+}
+
+// pipeline is the part of the App built from settings that can be
+// reloaded while running.
+type pipeline struct {
+    Loc       *i18n.Localizer
+    Templates *notify.Templates
+    Toast     ToastNotifier
+    Notifier  notify.Notifier
+    Scanner   *analysis.Scanner
+    Rules     *Rules
+
+    desktop   notify.Notifier            // The desktop sink, behind the digest if there is one
+    digest    *notify.DigestNotifier     // nil without digests
+    connected map[string]notify.Notifier // Sinks holding connections, closed with the pipeline
+    sinks     map[string]notify.Notifier // Every sink by name, for the delivery queue
+    direct    *Router                    // Routes to sinks, not the queue or digest, for alerts kept out of the history
+    local     []analysis.Analyzer        // The reputation plugins, for lookups served to other instances
+}
+
+// buildPipeline creates the localizer, sinks, routes, analyzers and rules
+// described by cfg. Remote sinks are routed through the delivery queue in
+// history if it is enabled. rec, if not nil, captures the analyzer
+// exchanges, cache keeps reputation lookups across reloads, and sup
+// recovers panics in the sinks.
+func buildPipeline(cfg *Config, prefs *store.ChatPreferences, lists *analysis.Lists, history *store.History, rec *capture.Recorder, cache *analysis.ReputationCache, sup *supervisor) (p pipeline, err error) {
+    loc, err := i18n.NewLocalizer(cfg.Locale)
+    if err != nil {
+        return p, fmt.Errorf("failed to load locale: %v", err)
+    }
+    links := notify.ToastLinks{Open: OpenURI, Sandbox: SandboxURI}
+    if cfg.Sinks.Defender.TenantID != "" {
+        links.Submit = SubmitURI
+    }
+    templates, err := notify.LoadTemplates(cfg.Templates.Toast, cfg.Templates.Telegram, cfg.Templates.Warning, loc, links)
+    if err != nil {
+        return p, fmt.Errorf("failed to load templates: %v", err)
+    }
+    p.Loc, p.Templates, p.Toast = loc, templates, ToastNotifier{Templates: templates}
+
+    plugins, err := DiscoverPlugins(cfg.Plugins)
+    if err != nil {
+        return p, err
+    }
+    sinks, err := BuildSinks(cfg, p.Toast, templates, loc, history)
+    if err != nil {
+        return p, err
+    }
+    p.connected = map[string]notify.Notifier{}
+    for name, sink := range sinks {
+        if _, ok := sink.(io.Closer); ok {
+            p.connected[name] = sink
+        }
+    }
+    defer func() {
+        if err != nil {
+            closeSinks(p.connected)
+        }
+    }()
+    for name, sink := range plugins.Sinks {
+        if _, ok := sinks[name]; ok {
+            return p, fmt.Errorf("sink plugin %q has the same name as a built-in sink", name)
+        }
+        sinks[name] = sink
+    }
+    p.sinks = map[string]notify.Notifier{}
+    for name, sink := range sinks {
+        p.sinks[name] = supervisedSink{name: name, next: sink, sup: sup}
+        sinks[name] = p.sinks[name]
+        if cfg.Delivery.Queue && history.Enabled() && !inlineSinks[name] {
+            sinks[name] = queuedSink{name: name, history: history, maxPending: cfg.Delivery.MaxPending}
+        }
+    }
+
+    if p.Scanner, err = analysis.NewScanner(cfg.Analyzers, cfg.Thresholds); err != nil {
+        return p, fmt.Errorf("failed to configure analyzers: %v", err)
+    }
+    p.Scanner.Lists, p.Scanner.Capture = lists, rec
+    // Plugins are how reputation services are looked up, so an outage of
+    // one shouldn't slow every scan down, and a link seen again shouldn't
+    // use up their quota
+    for _, a := range plugins.Analyzers {
+        a = analysis.NewBreaker(a, cfg.Analyzers.Breaker)
+        if r := cfg.Reputation; r.CacheTTL > 0 {
+            a = analysis.Cached{Analyzer: a, Cache: cache, TTL: r.CacheTTL}
+        }
+        p.local = append(p.local, a)
+    }
+    if r := cfg.Reputation; r.Server != "" {
+        p.Scanner.Analyzers = append(p.Scanner.Analyzers, analysis.NewSharedReputation(r.Server, r.Token, r.Timeout, p.local))
+    } else {
+        p.Scanner.Analyzers = append(p.Scanner.Analyzers, p.local...)
+    }
+    if p.Rules, err = CompileRules(cfg.Rules); err != nil {
+        return p, fmt.Errorf("failed to compile rules: %v", err)
+    }
+
+    routes := cfg.Routes
+    if len(routes) == 0 {
+        routes = DefaultRoutes(sinks)
+    }
+    if cfg.Digest.Minutes > 0 {
+        p.digest = notify.NewDigestNotifier(sinks["desktop"], cfg.Digest.Severity, time.Duration(cfg.Digest.Minutes)*time.Minute, loc)
+        sinks["desktop"] = p.digest
+    }
+    p.desktop = sinks["desktop"]
+    router, err := NewRouter(routes, sinks)
+    if err != nil {
+        if p.digest != nil {
+            p.digest.Close()
+        }
+        return p, fmt.Errorf("failed to configure routes: %v", err)
+    }
+    p.Notifier = ChatFilter{Prefs: prefs, Next: router}
+    if p.direct, err = NewRouter(routes, p.sinks); err != nil {
+        if p.digest != nil {
+            p.digest.Close()
+        }
+        return p, fmt.Errorf("failed to configure routes: %v", err)
+    }
+    return p, nil
+}
+
+// Close flushes anything the pipeline is still holding, such as a pending
+// digest.
+func (a *App) Close() error {
+    a.reloadMu.Lock()
+    defer a.reloadMu.Unlock()
+    if a.queue != nil {
+        a.closeQueues()
+    }
+    if a.deliveries != nil {
+        a.deliveries.Close()
+    }
+    var first error
+    if a.digest != nil {
+        first = a.digest.Close()
+    }
+    closeSinks(a.connected)
+    for _, closer := range a.closers {
+        if err := closer(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
+
+// HandleUpdate dispatches update and waits until it is finished with. It
+// reports false if the update was dropped because the work queue was full.
+func (a *App) HandleUpdate(ctx context.Context, update telegram.Update) bool {
+    done := make(chan struct{})
+    if !a.Dispatch(ctx, update, func() { close(done) }) {
+        return false
+    }
+    <-done
+    return true
+}
+
+// Dispatch handles one Telegram update: bot commands are executed at once,
+// so they apply in the order they were sent, and a message carrying a link
+// is queued for a worker to scan and alert on. done is called when the
+// update is finished with. Dispatch reports false, without calling done,
+// if the queue was full and overflow is set to drop. The scan and
+// deliveries give up when ctx is done.
+func (a *App) Dispatch(ctx context.Context, update telegram.Update, done func()) bool {
+    return a.dispatch(ctx, update, false, func(*store.HistoryEntry) { done() })
+}
+
+// dispatch is Dispatch, passing done the recorded entry if the update's
+// link was scanned, or nil. resumed is set for an update a previous run
+// took from Telegram but didn't finish.
+func (a *App) dispatch(ctx context.Context, update telegram.Update, resumed bool, done func(*store.HistoryEntry)) bool {
+    var (
+        logger *slog.Logger
+        entry  store.HistoryEntry
+        ok     bool
+    )
+    // An update that panics is skipped rather than taking the poller down
+    // with it.
+    if a.sup.protect("dispatch", func() { logger, entry, ok = a.prepare(ctx, update, resumed) }) != nil || !ok {
+        done(nil)
+        return true
+    }
+    job := func() {
+        var recorded *store.HistoryEntry
+        defer func() { done(recorded) }()
+        a.inFlight.Add(1)
+        defer a.inFlight.Add(-1)
+        a.mu.RLock()
+        defer a.mu.RUnlock()
+        e := a.Process(ctx, logger, entry, !a.Config.Headless, true)
+        recorded = &e
+    }
+    if a.lanes != nil && entry.Alert.ChatID != 0 {
+        return a.lanes.Submit(entry.Alert.ChatID, job)
+    }
+    return a.queue.Submit(job)
+}
+
+// waiting returns how many jobs are queued for a worker, in the shared
+// pool and the chats' lanes.
+func (a *App) waiting() int {
+    n := a.queue.Waiting()
+    if a.lanes != nil {
+        n += a.lanes.Waiting()
+    }
+    return n
+}
+
+// closeQueues runs the jobs still queued, in the shared pool and the
+// chats' lanes, and waits for them to finish.
+func (a *App) closeQueues() {
+    if a.lanes != nil {
+        a.lanes.Close()
+    }
+    a.queue.Close()
+}
+
+// prepare filters the update, runs any bot command in it, and returns the
+// unscanned entry for the link it carries. ok is false if there is nothing
+// to scan.
+func (a *App) prepare(ctx context.Context, update telegram.Update, resumed bool) (logger *slog.Logger, entry store.HistoryEntry, ok bool) {
+    a.health.received()
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    logger = appLog.With("update_id", update.UpdateID)
+    if a.Config.Profile != "" {
+        logger = logger.With("profile", a.Config.Profile)
+    }
+    logUpdateProblems(ctx, logger, update)
+    message := update.Message
+    if message == nil {
+        logger.Debug("update has no message")
+        return logger, entry, false
+    }
+    if message.Chat != nil {
+        logger = logger.With("chat_id", message.Chat.ID)
+        if !a.Config.WatchesChat(message.Chat.ID) {
+            logger.Debug("ignoring message from unwatched chat")
+            return logger, entry, false
+        }
+    }
+    // A restart, a webhook retry or a reset offset can hand over a message
+    // again; it was handled the first time. A resumed update was claimed
+    // by the run that didn't finish it.
+    if message.Chat != nil && !resumed && !a.claim(logger, store.SeenMessage, fmt.Sprintf("%s/%d/%d", a.Config.Profile, message.Chat.ID, message.MessageID), a.Config.Dedup.Messages) {
+        logger.Debug("skipping message already handled", "message_id", message.MessageID)
+        return logger, entry, false
+    }
+    if a.HandleCommand(ctx, logger, message) {
+        return logger, entry, false
+    }
+
+    link := extract.URL(message)
+    if link == "" {
+        logger.Debug("no URL in message")
+        return logger, entry, false
+    }
+    logger = logger.With("url", link)
+
+    alert := a.messageAlert(link, message)
+    entry = store.HistoryEntry{UpdateID: update.UpdateID, MessageID: message.MessageID, Text: message.Text, Alert: alert}
+    return logger, entry, true
+}
+
+// messageAlert returns an unscanned alert for a link sent in message.
+func (a *App) messageAlert(link string, message *telegram.Message) notify.Alert {
+    alert := a.NewAlert(link, message.Text)
+    alert.ID = fmt.Sprintf("msg-%d", message.MessageID)
+    if message.Chat != nil {
+        alert.ChatID = message.Chat.ID
+        alert.ChatType = message.Chat.Type
+        alert.ID = fmt.Sprintf("msg-%d-%d", message.Chat.ID, message.MessageID)
+    }
+    if message.From != nil {
+        alert.Sender = message.From.Handle()
+    }
+    if alert.Profile != "" {
+        // Bots of different profiles can see the same message
+        alert.ID = alert.Profile + "-" + alert.ID
+    }
+    return alert
+}
+
+// Process scans the link in entry's alert, delivers the alert if deliver is
+// set, and records the result in the history. It returns the recorded
+// entry, with its ID if history is enabled.
+func (a *App) Process(ctx context.Context, logger *slog.Logger, entry store.HistoryEntry, showProgress, deliver bool) store.HistoryEntry {
+    release := a.domains.acquire(entry.Alert.URL)
+    a.Scan(ctx, &entry.Alert, entry.Text, showProgress)
+    release()
+    return a.finish(ctx, logger, entry, deliver)
+}
+
+// finish applies the rules to the scanned alert in entry, delivers it if
+// deliver is set, and records and publishes the result. Delivering a
+// malicious alert also blocks its domain, if block.mode is set, whether or
+// not a rule suppressed the alert, and one that wasn't suppressed is warned
+// about in its group if the group has warnings on.
+func (a *App) finish(ctx context.Context, logger *slog.Logger, entry store.HistoryEntry, deliver bool) store.HistoryEntry {
+    suppressedBy := a.Rules.Apply(logger, &entry.Alert)
+    logger = logger.With("verdict", entry.Alert.Verdict.Severity)
+    logger.Info("link scanned", "findings", len(entry.Alert.Verdict.Findings))
+    // A rescan is meant to alert again, when the verdict has changed
+    duplicate := deliver && suppressedBy == "" && entry.Alert.ChatID != 0 && entry.RescanOf == 0 &&
+        !a.claim(logger, store.SeenLink, fmt.Sprintf("%s/%d/%s", a.Config.Profile, entry.Alert.ChatID, extract.Canonical(entry.Alert.URL)), a.Config.Dedup.Links)
+    if deliver && suppressedBy != "" {
+        entry.Actions = []notify.Action{suppressedAction(suppressedBy)}
+    } else if duplicate {
+        logger.Info("not alerting on a link repeated in the chat", "window", a.Config.Dedup.Links)
+        entry.Actions = []notify.Action{{Time: time.Now().UTC(), Sink: "dedup", Status: notify.ActionSuppressed}}
+    } else if deliver {
+        entry.Actions = notify.Deliver(ctx, a.Notifier, "notifier", entry.Alert)
+        if err := notify.ActionsError(entry.Actions); err != nil {
+            logger.Error("failed to deliver notification", "err", err)
+        }
+    }
+    if deliver && !duplicate && entry.Alert.Verdict.Severity >= analysis.SeverityMalicious {
+        if act, ok := a.block(ctx, logger, entry.Alert); ok {
+            entry.Actions = append(entry.Actions, act)
+        }
+    }
+    if deliver && !duplicate && suppressedBy == "" {
+        if act, ok := a.warn(ctx, logger, entry); ok {
+            entry.Actions = append(entry.Actions, act)
+        }
+    }
+    entry.Time = time.Now().UTC()
+    id, err := a.History.Record(entry)
+    if err != nil {
+        logger.Error("failed to record history", "err", err)
+    }
+    entry.ID = id
+    if a.deliveries != nil {
+        a.deliveries.Wake()
+    }
+    if a.results != nil {
+        if err := a.results.Write(entryResult(entry)); err != nil {
+            logger.Error("failed to write result", "err", err)
+        }
+    }
+    a.Alerts.Publish(entry)
+    return entry
+}
+
+// entryResult returns the result of the history entry.
+func entryResult(entry store.HistoryEntry) notify.Result {
+    r := notify.NewResult(entry.Alert, entry.Time, entry.Actions)
+    r.HistoryID, r.RescanOf = entry.ID, entry.RescanOf
+    return r
+}
+
+// claim reports whether key of kind is new within window, recording it
+// in the history so it stays taken across restarts. A window of 0 turns
+// the check off, and if the history can't be read the key counts as new:
+// a duplicate alert is better than a missed one.
+func (a *App) claim(logger *slog.Logger, kind, key string, window time.Duration) bool {
+    if window <= 0 {
+        return true
+    }
+    fresh, err := a.History.Claim(kind, key, time.Now(), window)
+    if err != nil {
+        logger.Warn("failed to check for duplicates", "err", err)
+        return true
+    }
+    return fresh
+}
+
+// StartServers starts the admin and gRPC servers that are configured.
+// They stop when ctx is cancelled.
+func (a *App) StartServers(ctx context.Context) error {
+    if err := a.ServeAdmin(ctx); err != nil {
+        return err
+    }
+    return a.ServeGRPC(ctx)
+}
+
+// NewAlert returns an unscanned alert for a link found in text.
+func (a *App) NewAlert(link, text string) notify.Alert {
+    return notify.Alert{
+        Title:   a.Loc.T("alert.title"),
+        Message: a.Loc.T("alert.message", text),
+        URL:     link,
+        Profile: a.Config.Profile,
+    }
+}
+
+// Scan runs the alert's link through the analyzers and stores the verdict
+// on it, showing a progress toast for slow scans if showProgress is set.
+func (a *App) Scan(ctx context.Context, alert *notify.Alert, text string, showProgress bool) {
+    var progress func(done, total int, stage string)
+    if showProgress && a.Scanner.Slow() {
+        progress = func(done, total int, stage string) {
+            if err := a.Toast.Progress(ctx, *alert, done, total, stage); err != nil {
+                appLog.Warn("failed to show scan progress", "url", alert.URL, "err", err)
+            }
+        }
+    }
+    alert.Verdict = a.Scanner.Scan(ctx, analysis.Target{URL: alert.URL, Text: text, Language: a.textLanguage(*alert)}, progress)
+}
+
+// textLanguage is the language assumed for the text of alert when it is too
+// short to tell: the one set for its chat, or else the locale's.
+func (a *App) textLanguage(alert notify.Alert) string {
+    if alert.ChatType != "" {
+        if lang := a.Prefs.For(alert.ChatID, alert.ChatType).Language; lang != "" {
+            return lang
+        }
+    }
+    return a.Loc.Locale
+}
+
+// Poll processes updates from getUpdates, resuming after the last update
+// handled by a previous run. Everything that arrived while the monitor was
+// stopped is caught up on first, skipping messages older than
+// catch_up.max_age, and summed up in an alert. With once set it returns
+// after catching up, otherwise it long-polls until ctx is cancelled.
+// Updates are scanned by the worker pool. The ones still queued or being
+// scanned when polling stops are finished, and then confirmed to Telegram
+// before returning.
+func (a *App) Poll(ctx context.Context, once bool) error {
+    token := a.Config.Telegram.Token
+    ctx = capture.NewContext(ctx, a.capture)
+    // Updates already queued are finished after ctx is cancelled
+    work := context.WithoutCancel(ctx)
+
+    a.health.started("poll")
+    saved := a.State.Offset()
+    offset := saved
+    pollLog.Info("resuming", "offset", offset)
+    a.resume(work)
+    caught := &catchUp{maxAge: a.Config.CatchUp.MaxAge, now: time.Now()}
+    for {
+        next, n, err := a.pollPage(ctx, work, token, offset, 0, caught)
+        a.health.polled(err)
+        offset = next
+        if err != nil {
+            logParseError(pollLog, err)
+            return fmt.Errorf("failed to fetch updates: %v", err)
+        }
+        if n < telegram.UpdatesPage {
+            break
+        }
+        pollLog.Info("catching up", "offset", offset, "updates", caught.updates)
+    }
+    a.caughtUp(ctx, caught, saved)
+    if once {
+        a.closeQueues()
+        return confirmUpdates(token, offset)
+    }
+
+    for ctx.Err() == nil {
+        next, _, err := a.pollPage(ctx, work, token, offset, 30, nil)
+        offset = next
+        if ctx.Err() != nil {
+            break
+        }
+        a.health.polled(err)
+        if err != nil {
+            logParseError(pollLog, err)
+            pollLog.Error("failed to fetch updates, retrying", "offset", offset, "err", err)
+            select {
+            case <-ctx.Done():
+            case <-time.After(5 * time.Second):
+            }
+            continue
+        }
+    }
+    pollLog.Info("shutting down", "offset", offset, "waiting", a.waiting())
+    a.closeQueues()
+    return confirmUpdates(token, offset)
+}
+
+// reportedProblems holds the kind and path of each problem with updates
+// that has been logged as a warning.
+var reportedProblems sync.Map
+
+// logUpdateProblems logs what ParseUpdate found in update that doesn't
+// match the Bot API as the monitor knows it: as a warning the first time
+// a kind of problem turns up at a path, and at debug level after that, so
+// a new Bot API version is noticed without every update repeating it.
+func logUpdateProblems(ctx context.Context, logger *slog.Logger, update telegram.Update) {
+    for _, p := range update.Problems {
+        level := slog.LevelDebug
+        if _, seen := reportedProblems.LoadOrStore(p.Kind+" "+p.Path, true); !seen {
+            level = slog.LevelWarn
+        }
+        logger.Log(ctx, level, "update doesn't match the Bot API this version knows", p.LogAttrs()...)
+    }
+}
+
+// logParseError logs err with the payload it was found in, if it is a
+// *telegram.ParseError.
+func logParseError(logger *slog.Logger, err error) {
+    var perr *telegram.ParseError
+    if errors.As(err, &perr) {
+        logger.Error("update doesn't parse", perr.LogAttrs()...)
+    }
+}
+
+// pollPage fetches a page of updates from offset, dispatching each as it
+// is decoded, and returns the offset after the last one and how many there
+// were. A full page means a backlog, so pollPage waits for its updates to
+// be handled before returning: only one page is held at a time. With
+// per-chat lanes it isn't waited for, so a busy chat can't hold up polling
+// for the others; the lanes' queues hold the backlog instead, and the
+// state file the updates the next getUpdates confirms before they finish. While catching up,
+// caught counts the page, and it is always waited for. Updates that don't
+// parse are logged and confirmed without being dispatched.
+func (a *App) pollPage(ctx, work context.Context, token string, offset int64, timeout int, caught *catchUp) (int64, int, error) {
+    var page sync.WaitGroup
+    n, err := telegram.StreamUpdates(ctx, token, offset, telegram.UpdatesPage, timeout, func(update telegram.Update) error {
+        skip := false
+        if update.Err != nil {
+            logParseError(pollLog.With("update_id", update.UpdateID), update.Err)
+            if update.UpdateID == 0 {
+                // Nothing to confirm it by but a later update
+                return nil
+            }
+            skip = true
+        }
+        page.Add(1)
+        skip = caught.received(update) || skip
+        a.dispatchPolled(work, update, skip, false, func(entry *store.HistoryEntry) {
+            caught.handled(entry)
+            page.Done()
+        })
+        offset = update.UpdateID + 1
+        return nil
+    })
+    if (n == telegram.UpdatesPage && a.lanes == nil) || caught != nil {
+        page.Wait()
+    }
+    return offset, n, err
+}
+
+// dispatchPolled dispatches an update from getUpdates, or with skip set
+// just moves the saved offset past it, calling done once it is finished
+// with. The next getUpdates confirms the update to Telegram, which won't
+// send it again, so until it is finished it is held in the state file,
+// for a restart to resume.
+func (a *App) dispatchPolled(ctx context.Context, update telegram.Update, skip, resumed bool, done func(*store.HistoryEntry)) {
+    if skip {
+        if err := a.State.SetOffset(update.UpdateID + 1); err != nil {
+            pollLog.Error("failed to save offset", "offset", update.UpdateID+1, "err", err)
+        }
+        done(nil)
+        return
+    }
+    raw, err := json.Marshal(update)
+    if err == nil {
+        err = a.State.Hold(update.UpdateID, raw)
+    }
+    if err != nil {
+        pollLog.Error("failed to save pending update", "update_id", update.UpdateID, "err", err)
+    }
+    finish := func(entry *store.HistoryEntry) {
+        if err := a.State.Release(update.UpdateID); err != nil {
+            pollLog.Error("failed to save state", "update_id", update.UpdateID, "err", err)
+        }
+        done(entry)
+    }
+    if !a.dispatch(ctx, update, resumed, finish) {
+        pollLog.Warn("work queue is full; dropping update", "update_id", update.UpdateID)
+        finish(nil)
+    }
+}
+
+// resume dispatches the updates a previous run took from Telegram but
+// didn't finish, before polling for more.
+func (a *App) resume(ctx context.Context) {
+    pending := a.State.Pending()
+    if len(pending) == 0 {
+        return
+    }
+    pollLog.Info("resuming unfinished updates", "updates", len(pending))
+    for _, p := range pending {
+        var update telegram.Update
+        if err := json.Unmarshal(p.Update, &update); err != nil {
+            pollLog.Error("failed to read pending update", "update_id", p.ID, "err", err)
+            a.State.Release(p.ID)
+            continue
+        }
+        update.UpdateID = p.ID
+        a.dispatchPolled(ctx, update, false, true, func(*store.HistoryEntry) {})
+    }
+}
+
+// confirmUpdates tells Telegram every update before offset was handled, so
+// a restart doesn't see them again.
+func confirmUpdates(token string, offset int64) error {
+    if offset == 0 {
+        return nil
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if _, err := telegram.GetUpdates(ctx, token, offset, 0); err != nil {
+        return fmt.Errorf("failed to confirm updates: %v", err)
+    }
+    return nil
+}
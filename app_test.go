@@ -0,0 +1,159 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telephishtest"
+)
+
+// newTestApp returns an App polling api, with its files in a temporary
+// directory, that alerts on malicious links in the chat they were sent to
+// and nowhere else.
+func newTestApp(t *testing.T, api *telephishtest.BotAPI) *App {
+    t.Helper()
+    dir := t.TempDir()
+    cfg := DefaultConfig()
+    cfg.Telegram.Token = api.Token
+    cfg.Headless = true
+    cfg.Keystore = false
+    cfg.Analyzers.Enabled = []string{"url", "text"}
+    cfg.History = filepath.Join(dir, "history.db")
+    cfg.State = filepath.Join(dir, "state.json")
+    cfg.ChatPrefs = filepath.Join(dir, "chats.json")
+    cfg.Lists = filepath.Join(dir, "lists.json")
+    cfg.Routes = []Route{{Severity: analysis.SeverityMalicious, Sinks: []string{"telegram"}}}
+    cfg.Delivery.Queue = false
+    cfg.CatchUp.Summary = false
+    app, err := NewApp(&cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { app.Close() })
+    return app
+}
+
+func TestPollAlertsOnPhishing(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+
+    phishing := telephishtest.Phishing()
+    api.Push(telephishtest.Clean(), phishing, telephishtest.NoLink(), telephishtest.NonMessage())
+    // Telegram handing the same message over again
+    api.Push(phishing)
+    if err := app.Poll(context.Background(), true); err != nil {
+        t.Fatal(err)
+    }
+
+    sent := api.Sent()
+    if len(sent) != 1 {
+        t.Fatalf("sent %d messages, want 1 alert: %+v", len(sent), sent)
+    }
+    if sent[0].ChatID != telephishtest.PrivateChatID || sent[0].ParseMode != "MarkdownV2" {
+        t.Errorf("alert sent to chat %d as %q, want %d as MarkdownV2", sent[0].ChatID, sent[0].ParseMode, telephishtest.PrivateChatID)
+    }
+    if got := api.Confirmed(); got != 6 {
+        t.Errorf("confirmed up to %d, want 6", got)
+    }
+    if got := app.State.Offset(); got != 6 {
+        t.Errorf("saved offset %d, want 6", got)
+    }
+    if pending := app.State.Pending(); len(pending) != 0 {
+        t.Errorf("%d updates still pending after polling", len(pending))
+    }
+}
+
+func TestPollResumesPendingUpdates(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+
+    // A previous run took update 7 from Telegram, which has been told of
+    // it, and stopped before scanning it
+    update := telephishtest.Phishing()
+    update.UpdateID = 7
+    raw, err := json.Marshal(update)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := app.State.Hold(7, raw); err != nil {
+        t.Fatal(err)
+    }
+    // The message was claimed when it was taken
+    if _, err := app.History.Claim(store.SeenMessage, fmt.Sprintf("/%d/%d", update.Message.Chat.ID, update.Message.MessageID), time.Now(), time.Hour); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := app.Poll(context.Background(), true); err != nil {
+        t.Fatal(err)
+    }
+    if sent := api.Sent(); len(sent) != 1 {
+        t.Fatalf("sent %d messages, want the alert for the resumed update", len(sent))
+    }
+    if pending := app.State.Pending(); len(pending) != 0 {
+        t.Errorf("%d updates still pending after resuming", len(pending))
+    }
+    if got := app.State.Offset(); got != 8 {
+        t.Errorf("saved offset %d, want 8", got)
+    }
+}
+
+func TestScanAssumesChatLanguage(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+    ctx := context.Background()
+
+    // Too short to tell it is Portuguese
+    message := telephishtest.PrivateMessage("Conta bloqueada! http://conta.example/login").Message
+    textFinding := func() string {
+        alert := app.messageAlert("http://conta.example/login", message)
+        app.Scan(ctx, &alert, message.Text, false)
+        for _, f := range alert.Verdict.Findings {
+            if f.Analyzer == "text" {
+                return f.Description
+            }
+        }
+        return ""
+    }
+    want := "message uses urgency language (pt): Conta bloqueada"
+
+    if got := textFinding(); got != "" {
+        t.Errorf("in English: text finding %q, want none", got)
+    }
+
+    api.Push(telephishtest.Command("/language pt"))
+    if err := app.Poll(ctx, true); err != nil {
+        t.Fatal(err)
+    }
+    if sent, err := api.WaitSent(1, 5*time.Second); err != nil || sent[0].Text != app.Loc.T("prefs.language", "pt") {
+        t.Fatalf("replies %+v, %v; want the language set", sent, err)
+    }
+    if got := textFinding(); got != want {
+        t.Errorf("with the chat in Portuguese: text finding %q, want %q", got, want)
+    }
+
+    // Without a language of its own, a chat has the locale's
+    if err := app.Prefs.Set(telephishtest.PrivateChatID, store.ChatPreference{}); err != nil {
+        t.Fatal(err)
+    }
+    loc, err := i18n.NewLocalizer("pt-BR")
+    if err != nil {
+        t.Fatal(err)
+    }
+    app.Loc = loc
+    if got := textFinding(); got != want {
+        t.Errorf("with a Portuguese locale: text finding %q, want %q", got, want)
+    }
+}
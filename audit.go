@@ -0,0 +1,116 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/user"
+    "strconv"
+    "text/tabwriter"
+    "time"
+
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Actors in the audit log, besides Telegram users and people at the
+// command line.
+const (
+    ActorMonitor   = "telephish" // The monitor itself, e.g. blocking a malicious link's domain
+    ActorDashboard = "dashboard"
+    ActorAPI       = "api"
+    ActorToast     = "toast" // A toast button
+)
+
+// telegramActor names a Telegram user in the audit log.
+func telegramActor(userID int64) string {
+    return "telegram:" + strconv.FormatInt(userID, 10)
+}
+
+// cliActor names the person running a command in the audit log.
+func cliActor() string {
+    if u, err := user.Current(); err == nil {
+        return "cli:" + u.Username
+    }
+    return "cli"
+}
+
+// audit appends e to the audit log in history. A failure is logged rather
+// than returned, since the action has already been taken.
+func audit(history *store.History, e store.AuditEvent) {
+    if err := history.Audit(e); err != nil {
+        appLog.Error("failed to write audit log", "action", e.Action, "target", e.Target, "actor", e.Actor, "err", err)
+    }
+}
+
+// audit records an action taken through the app in the audit log.
+func (a *App) audit(actor, action, target, reason string) {
+    audit(a.History, store.AuditEvent{Actor: actor, Action: action, Target: target, Reason: reason, Profile: a.Config.Profile})
+}
+
+// auditConfigured records an action taken by a command that doesn't keep
+// the history open, opening it just for that.
+func auditConfigured(cfg *Config, e store.AuditEvent) {
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        appLog.Error("failed to write audit log", "action", e.Action, "target", e.Target, "err", err)
+        return
+    }
+    defer history.Close()
+    audit(history, e)
+}
+
+// auditCommand prints the audit log, newest first.
+func auditCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("audit")
+    since := fs.Duration("since", 0, "only actions in this long before now (default all)")
+    actor := fs.String("actor", "", "only actions by this actor, e.g. telephish, dashboard or telegram:12345")
+    action := fs.String("action", "", "only this action, e.g. block or allowlist.add")
+    limit := fs.Int("n", 100, "show at most this many actions; 0 for all")
+    asJSON := fs.Bool("json", false, "print a JSON array of actions instead of a table")
+    fs.Parse(args)
+    if fs.NArg() > 0 {
+        return fmt.Errorf("usage: %s audit [--since duration] [--actor a] [--action a] [-n count] [--json]", os.Args[0])
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    if !history.Enabled() {
+        return fmt.Errorf("history: the audit log is kept in the history, which is turned off")
+    }
+    f := store.AuditFilter{Actor: *actor, Action: *action, Limit: *limit}
+    if *since > 0 {
+        f.Since = time.Now().Add(-*since)
+    }
+    events, err := history.AuditLog(f)
+    if err != nil {
+        return err
+    }
+
+    if *asJSON {
+        if events == nil {
+            events = []store.AuditEvent{}
+        }
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetEscapeHTML(false)
+        enc.SetIndent("", "  ")
+        return enc.Encode(events)
+    }
+    if len(events) == 0 {
+        fmt.Fprintln(os.Stderr, "no actions match")
+        return nil
+    }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "TIME\tACTOR\tACTION\tTARGET\tREASON")
+    for _, e := range events {
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime), e.Actor, e.Action, tableCell(e.Target), tableCell(e.Reason))
+    }
+    return tw.Flush()
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package telephish
+
+import (
+    "fmt"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// ToastsSupported reports whether Windows toasts are available.
+func ToastsSupported() bool {
+    return false
+}
+
+// ShowBalloon is only available on Windows.
+func ShowBalloon(title, message string, severity analysis.Severity) error {
+    return fmt.Errorf("balloon notifications are only supported on Windows")
+}
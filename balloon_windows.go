@@ -0,0 +1,119 @@
+//go:build windows
+
+package telephish
+
+import (
+    "fmt"
+    "time"
+    "unsafe"
+
+    "golang.org/x/sys/windows"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+var (
+    shell32              = windows.NewLazySystemDLL("shell32.dll")
+    user32               = windows.NewLazySystemDLL("user32.dll")
+    procShellNotifyIconW = shell32.NewProc("Shell_NotifyIconW")
+    procCreateWindowExW  = user32.NewProc("CreateWindowExW")
+    procDestroyWindow    = user32.NewProc("DestroyWindow")
+    procLoadIconW        = user32.NewProc("LoadIconW")
+)
+
+const (
+    nimAdd    = 0
+    nimDelete = 2
+
+    nifIcon = 0x2
+    nifTip  = 0x4
+    nifInfo = 0x10
+
+    niifInfo    = 0x1
+    niifWarning = 0x2
+    niifError   = 0x3
+
+    idiInformation = 32516
+    idiWarning     = 32515
+    idiError       = 32513
+
+    hwndMessage = ^uintptr(2) // HWND_MESSAGE, (HWND)-3
+)
+
+// balloonDuration is how long the tray icon stays up to show the balloon.
+const balloonDuration = 10 * time.Second
+
+// notifyIconData mirrors NOTIFYICONDATAW.
+type notifyIconData struct {
+    cbSize           uint32
+    hWnd             uintptr
+    uID              uint32
+    uFlags           uint32
+    uCallbackMessage uint32
+    hIcon            uintptr
+    szTip            [128]uint16
+    dwState          uint32
+    dwStateMask      uint32
+    szInfo           [256]uint16
+    uTimeout         uint32
+    szInfoTitle      [64]uint16
+    dwInfoFlags      uint32
+    guidItem         windows.GUID
+    hBalloonIcon     uintptr
+}
+
+// ToastsSupported reports whether this Windows version has the WinRT toast
+// APIs for desktop apps (Windows 10 / Server 2016 and later).
+func ToastsSupported() bool {
+    return windows.RtlGetVersion().MajorVersion >= 10
+}
+
+// ShowBalloon shows a Shell_NotifyIcon balloon tip, the notification style
+// available before WinRT toasts. It blocks while the balloon is visible.
+func ShowBalloon(title, message string, severity analysis.Severity) error {
+    className, _ := windows.UTF16PtrFromString("STATIC")
+    hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(className)), 0, 0, 0, 0, 0, 0, hwndMessage, 0, 0, 0)
+    if hwnd == 0 {
+        return fmt.Errorf("failed to create notification window: %v", err)
+    }
+    defer procDestroyWindow.Call(hwnd)
+
+    infoFlags, iconID := uint32(niifInfo), uintptr(idiInformation)
+    switch {
+    case severity >= analysis.SeverityMalicious:
+        infoFlags, iconID = niifError, idiError
+    case severity >= analysis.SeveritySuspicious:
+        infoFlags, iconID = niifWarning, idiWarning
+    }
+    icon, _, _ := procLoadIconW.Call(0, iconID)
+
+    nid := notifyIconData{
+        hWnd:        hwnd,
+        uID:         1,
+        uFlags:      nifIcon | nifTip | nifInfo,
+        hIcon:       icon,
+        dwInfoFlags: infoFlags,
+    }
+    nid.cbSize = uint32(unsafe.Sizeof(nid))
+    copyUTF16(nid.szTip[:], AppName)
+    copyUTF16(nid.szInfoTitle[:], title)
+    copyUTF16(nid.szInfo[:], message)
+
+    if ok, _, err := procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&nid))); ok == 0 {
+        return fmt.Errorf("failed to show balloon notification: %v", err)
+    }
+    time.Sleep(balloonDuration)
+    procShellNotifyIconW.Call(nimDelete, uintptr(unsafe.Pointer(&nid)))
+    return nil
+}
+
+// copyUTF16 copies s into a fixed-size, NUL-terminated UTF-16 buffer,
+// truncating if needed.
+func copyUTF16(dst []uint16, s string) {
+    src := windows.StringToUTF16(s)
+    if len(src) > len(dst) {
+        src = src[:len(dst)]
+        src[len(src)-1] = 0
+    }
+    copy(dst, src)
+}
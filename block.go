@@ -0,0 +1,426 @@
+package telephish
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/url"
+    "os"
+    "strings"
+    "sync"
+    "text/tabwriter"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/internal/fsutil"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "golang.org/x/net/idna"
+)
+
+// Ways of blocking a domain on this machine.
+const (
+    BlockHosts    = "hosts"    // Point it at 0.0.0.0 in the hosts file
+    BlockFirewall = "firewall" // Refuse outbound connections to its addresses
+)
+
+// hostsMarker ends the hosts file lines the monitor adds, so unblocking
+// leaves everyone else's alone.
+const hostsMarker = "# added by telephish"
+
+// blockExpiryInterval is how often expired blocks are looked for.
+const blockExpiryInterval = time.Minute
+
+// hostsMu serializes edits to the hosts file by the workers.
+var hostsMu sync.Mutex
+
+// blockedDomain returns the host of link to block, as blockName gives
+// it, or "" if link has none that can be blocked.
+func blockedDomain(link string) string {
+    u, err := url.Parse(link)
+    if err != nil {
+        return ""
+    }
+    domain, err := blockName(u.Hostname())
+    if err != nil {
+        return ""
+    }
+    return domain
+}
+
+// blockName returns domain as blocks name it: an IP address as it is, and
+// a domain lowercased, without a trailing dot and with its Unicode labels
+// in their xn-- form, which is what resolvers and the hosts file know it
+// by. Anything else is refused, as is a name of one label such as
+// localhost, so nothing but a domain can be written into the hosts file or
+// a firewall rule.
+func blockName(domain string) (string, error) {
+    domain = strings.TrimSuffix(domain, ".")
+    if ip := net.ParseIP(domain); ip != nil {
+        return ip.String(), nil
+    }
+    name, err := idna.Lookup.ToASCII(domain)
+    if err != nil || !validHostname(name) {
+        return "", fmt.Errorf("%q is not a domain name", domain)
+    }
+    return name, nil
+}
+
+// validHostname reports whether name is an ASCII hostname of at least two
+// labels.
+func validHostname(name string) bool {
+    labels := strings.Split(name, ".")
+    if len(name) > 253 || len(labels) < 2 {
+        return false
+    }
+    for _, label := range labels {
+        if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+            return false
+        }
+        for _, c := range label {
+            if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// BlockDomain blocks domain on this machine as cfg says, and records the
+// block in history so it can be listed and lifted when it expires. link is
+// what got it blocked, if anything. Blocking a domain again renews it. The
+// firewall mode looks domain up with resolver; nil is the system's.
+func BlockDomain(ctx context.Context, history *store.History, cfg BlockConfig, resolver *net.Resolver, domain, link string) error {
+    now := time.Now().UTC()
+    b := store.Block{Domain: domain, Mode: cfg.Mode, URL: link, Time: now}
+    if cfg.Expiry > 0 {
+        b.Expires = now.Add(cfg.Expiry)
+    }
+    switch cfg.Mode {
+    case BlockHosts:
+        if net.ParseIP(domain) != nil {
+            return fmt.Errorf("%s is an IP address, which the hosts file can't block", domain)
+        }
+        if err := addHostsEntry(cfg.hostsFile(), domain); err != nil {
+            return err
+        }
+    case BlockFirewall:
+        addrs, err := blockedAddrs(ctx, resolver, domain)
+        if err != nil {
+            return err
+        }
+        if err := addFirewallRule(domain, addrs); err != nil {
+            return err
+        }
+        b.Addrs = addrs
+    default:
+        return fmt.Errorf("block.mode: want hosts or firewall, got %q", cfg.Mode)
+    }
+    return history.AddBlock(b)
+}
+
+// Unblock lifts b, the way it was put in place, and forgets it.
+func Unblock(history *store.History, cfg BlockConfig, b store.Block) error {
+    switch b.Mode {
+    case BlockHosts:
+        if err := removeHostsEntry(cfg.hostsFile(), b.Domain); err != nil {
+            return err
+        }
+    case BlockFirewall:
+        if err := deleteFirewallRule(b.Domain); err != nil {
+            return err
+        }
+    }
+    return history.RemoveBlock(b.Domain)
+}
+
+// blockedAddrs resolves domain, or parses it if it is an address, for a
+// firewall rule. Private and loopback addresses are left out, since
+// blocking them could cut the machine off from its own network.
+func blockedAddrs(ctx context.Context, resolver *net.Resolver, domain string) ([]string, error) {
+    var ips []net.IP
+    if ip := net.ParseIP(domain); ip != nil {
+        ips = []net.IP{ip}
+    } else {
+        if resolver == nil {
+            resolver = net.DefaultResolver
+        }
+        addrs, err := resolver.LookupIPAddr(ctx, domain)
+        if err != nil {
+            return nil, fmt.Errorf("failed to resolve %s: %v", domain, err)
+        }
+        for _, a := range addrs {
+            ips = append(ips, a.IP)
+        }
+    }
+    var blocked []string
+    for _, ip := range ips {
+        if ip.IsGlobalUnicast() && !ip.IsPrivate() {
+            blocked = append(blocked, ip.String())
+        }
+    }
+    if len(blocked) == 0 {
+        return nil, fmt.Errorf("%s has no public addresses to block", domain)
+    }
+    return blocked, nil
+}
+
+// addHostsEntry points domain at the unspecified addresses in the hosts
+// file at path, unless it is there already.
+func addHostsEntry(path, domain string) error {
+    hostsMu.Lock()
+    defer hostsMu.Unlock()
+    data, info, err := readHosts(path)
+    if err != nil {
+        return err
+    }
+    for _, line := range strings.Split(string(data), "\n") {
+        if hostsEntryFor(line) == domain {
+            return nil
+        }
+    }
+    eol := "\n"
+    if bytes.Contains(data, []byte("\r\n")) {
+        eol = "\r\n"
+    }
+    if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+        data = append(data, eol...)
+    }
+    for _, addr := range []string{"0.0.0.0", "::"} {
+        data = append(data, fmt.Sprintf("%s %s %s%s", addr, domain, hostsMarker, eol)...)
+    }
+    return writeHosts(path, data, info)
+}
+
+// removeHostsEntry removes the lines addHostsEntry added for domain.
+func removeHostsEntry(path, domain string) error {
+    hostsMu.Lock()
+    defer hostsMu.Unlock()
+    data, info, err := readHosts(path)
+    if err != nil {
+        return err
+    }
+    lines := strings.SplitAfter(string(data), "\n")
+    kept := lines[:0]
+    for _, line := range lines {
+        if hostsEntryFor(line) != domain {
+            kept = append(kept, line)
+        }
+    }
+    if len(kept) == len(lines) {
+        return nil
+    }
+    return writeHosts(path, []byte(strings.Join(kept, "")), info)
+}
+
+// hostsEntryFor returns the domain a hosts file line added by
+// addHostsEntry blocks, or "" for any other line.
+func hostsEntryFor(line string) string {
+    line = strings.TrimRight(line, "\r\n")
+    if !strings.HasSuffix(line, hostsMarker) {
+        return ""
+    }
+    fields := strings.Fields(strings.TrimSuffix(line, hostsMarker))
+    if len(fields) != 2 {
+        return ""
+    }
+    return fields[1]
+}
+
+func readHosts(path string) ([]byte, os.FileInfo, error) {
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to read hosts file: %v", err)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to read hosts file: %v", err)
+    }
+    return data, info, nil
+}
+
+func writeHosts(path string, data []byte, info os.FileInfo) error {
+    if err := fsutil.WriteFile(path, data, info.Mode().Perm()); err != nil {
+        return fmt.Errorf("failed to write hosts file (blocking needs administrator rights): %v", err)
+    }
+    return nil
+}
+
+// block blocks the domain of a malicious alert's link, if block.mode is
+// set, and returns what happened as an action for the history.
+func (a *App) block(ctx context.Context, logger *slog.Logger, alert notify.Alert) (notify.Action, bool) {
+    cfg := a.Config.Block
+    domain := blockedDomain(alert.URL)
+    if cfg.Mode == "" || domain == "" {
+        return notify.Action{}, false
+    }
+    if list, _ := a.Lists.Match(alert.URL); list == analysis.ListAllow {
+        return notify.Action{}, false
+    }
+    // Blocking again would replace the firewall rule and audit the same
+    // block twice; the block runs from the first time
+    if b, ok, err := a.History.Block(domain); err == nil && ok && b.Mode == cfg.Mode && (b.Expires.IsZero() || b.Expires.After(time.Now())) {
+        logger.Debug("domain already blocked", "domain", domain, "mode", cfg.Mode)
+        return notify.Action{}, false
+    }
+    resolver, err := analysis.NewResolver(a.Config.Analyzers.DNS)
+    if err == nil {
+        err = BlockDomain(ctx, a.History, cfg, resolver, domain, alert.URL)
+    }
+    if err != nil {
+        logger.Error("failed to block domain", "domain", domain, "mode", cfg.Mode, "err", err)
+    } else {
+        logger.Info("blocked domain", "domain", domain, "mode", cfg.Mode, "expiry", cfg.Expiry)
+        a.audit(ActorMonitor, "block", domain, "malicious verdict on "+alert.ID)
+    }
+    return notify.NewAction("block:"+cfg.Mode, err), true
+}
+
+// StartBlockExpiry lifts blocks as they expire until ctx is cancelled.
+// Blocks are shared by every profile, so one app's covers them all. It
+// does nothing unless blocking is on.
+func (a *App) StartBlockExpiry(ctx context.Context) {
+    if a.Config.Block.Mode == "" {
+        return
+    }
+    run := func() {
+        if err := a.sup.protect("block expiry", func() { expireBlocks(a.History, a.Config.Block, time.Now()) }); err != nil {
+            appLog.Error("failed to lift expired blocks", "err", err)
+        }
+    }
+    go func() {
+        run()
+        ticker := time.NewTicker(blockExpiryInterval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                run()
+            }
+        }
+    }()
+}
+
+// expireBlocks lifts the blocks expired by now.
+func expireBlocks(history *store.History, cfg BlockConfig, now time.Time) {
+    blocks, err := history.Blocks(now)
+    if err != nil {
+        appLog.Error("failed to read blocks", "err", err)
+        return
+    }
+    for _, b := range blocks {
+        if err := Unblock(history, cfg, b); err != nil {
+            appLog.Error("failed to lift expired block", "domain", b.Domain, "err", err)
+            continue
+        }
+        appLog.Info("block expired", "domain", b.Domain, "mode", b.Mode)
+        audit(history, store.AuditEvent{Actor: ActorMonitor, Action: "unblock", Target: b.Domain, Reason: "expired"})
+    }
+}
+
+// blockCommand lists the domains blocked on this machine, or blocks one
+// by hand.
+func blockCommand(ctx context.Context, args []string) error {
+    if len(args) == 0 {
+        return fmt.Errorf("usage: %s block list | add [--for duration] [--reason text] <domain>", os.Args[0])
+    }
+    action := args[0]
+    fs, configPath := newFlagSet("block " + action)
+    expiry := fs.Duration("for", -1, "how long the block lasts, overriding block.expiry; 0 for ever")
+    reason := fs.String("reason", "", "why, for the audit log")
+    fs.Parse(args[1:])
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+
+    switch action {
+    case "list":
+        blocks, err := history.Blocks(time.Time{})
+        if err != nil {
+            return err
+        }
+        w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(w, "DOMAIN\tMODE\tSINCE\tEXPIRES\tLINK")
+        for _, b := range blocks {
+            expires := "never"
+            if !b.Expires.IsZero() {
+                expires = b.Expires.Local().Format("2006-01-02 15:04")
+            }
+            fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", b.Domain, b.Mode, b.Time.Local().Format("2006-01-02 15:04"), expires, notify.Defang(b.URL))
+        }
+        return w.Flush()
+    case "add":
+        if fs.NArg() != 1 {
+            return fmt.Errorf("usage: %s block add [--for duration] [--reason text] <domain>", os.Args[0])
+        }
+        b := cfg.Block
+        if b.Mode == "" {
+            return fmt.Errorf("block.mode: set hosts or firewall to block domains")
+        }
+        if *expiry >= 0 {
+            b.Expiry = *expiry
+        }
+        resolver, err := analysis.NewResolver(cfg.Analyzers.DNS)
+        if err != nil {
+            return err
+        }
+        domain, err := blockName(fs.Arg(0))
+        if err != nil {
+            return err
+        }
+        if err := BlockDomain(ctx, history, b, resolver, domain, ""); err != nil {
+            return err
+        }
+        audit(history, store.AuditEvent{Actor: cliActor(), Action: "block", Target: domain, Reason: *reason})
+        fmt.Printf("blocked %s (%s)\n", domain, b.Mode)
+        return nil
+    }
+    return fmt.Errorf("unknown block action %q", action)
+}
+
+// unblockCommand lifts the blocks of the given domains before they expire.
+func unblockCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("unblock")
+    reason := fs.String("reason", "", "why, for the audit log")
+    fs.Parse(args)
+    if fs.NArg() == 0 {
+        return fmt.Errorf("usage: %s unblock [--reason text] <domain>...", os.Args[0])
+    }
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+
+    for _, domain := range fs.Args() {
+        domain = strings.ToLower(domain)
+        b, ok, err := history.Block(domain)
+        if err != nil {
+            return err
+        }
+        if !ok {
+            return fmt.Errorf("%s is not blocked", domain)
+        }
+        if err := Unblock(history, cfg.Block, b); err != nil {
+            return err
+        }
+        audit(history, store.AuditEvent{Actor: cliActor(), Action: "unblock", Target: domain, Reason: *reason})
+        fmt.Printf("unblocked %s\n", domain)
+    }
+    return nil
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package telephish
+
+import "fmt"
+
+// defaultHostsFile is where the system resolver reads static names.
+const defaultHostsFile = "/etc/hosts"
+
+// addFirewallRule is only implemented with Windows Firewall.
+func addFirewallRule(domain string, addrs []string) error {
+    return fmt.Errorf("block.mode firewall is only supported on Windows; use hosts")
+}
+
+// deleteFirewallRule is only implemented with Windows Firewall.
+func deleteFirewallRule(domain string) error {
+    return fmt.Errorf("block.mode firewall is only supported on Windows")
+}
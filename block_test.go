@@ -0,0 +1,112 @@
+package telephish
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/telephishtest"
+)
+
+func TestBlockName(t *testing.T) {
+    tests := []struct {
+        domain string
+        want   string // "" if refused
+    }{
+        {"login-paypa1.example", "login-paypa1.example"},
+        {"Login-PayPa1.Example.", "login-paypa1.example"},
+        {"аpple.com", "xn--pple-43d.com"},
+        {"xn--pple-43d.com", "xn--pple-43d.com"},
+        {"bücher.example", "xn--bcher-kva.example"},
+        {"203.0.113.7", "203.0.113.7"},
+        {"2001:db8::1", "2001:db8::1"},
+        {"localhost", ""},
+        {"", ""},
+        {".", ""},
+        {"a..example", ""},
+        {"-a.example", ""},
+        {"a-.example", ""},
+        {"a_b.example", ""},
+        {"a b.example", ""},
+        {"evil.example\n127.0.0.1", ""},
+        {"evil.example#comment", ""},
+        {"*.example", ""},
+        {"../etc/passwd", ""},
+        {strings.Repeat("a", 64) + ".example", ""},
+        {strings.Repeat("a.", 127) + "example", ""},
+    }
+    for _, tt := range tests {
+        got, err := blockName(tt.domain)
+        if tt.want == "" {
+            if err == nil {
+                t.Errorf("blockName(%q) = %q, want it refused", tt.domain, got)
+            }
+        } else if err != nil || got != tt.want {
+            t.Errorf("blockName(%q) = %q, %v; want %q", tt.domain, got, err, tt.want)
+        }
+    }
+}
+
+func TestBlockedDomain(t *testing.T) {
+    tests := map[string]string{
+        "https://Login-PayPa1.Example./verify": "login-paypa1.example",
+        "https://аpple.com/id":                 "xn--pple-43d.com",
+        "http://203.0.113.7:8080/login":        "203.0.113.7",
+        "http://[2001:db8::1]/":                "2001:db8::1",
+        "http://localhost/":                    "",
+        "http://a_b.example/":                  "",
+        "mailto:someone@example.com":           "",
+        "not a link":                           "",
+    }
+    for link, want := range tests {
+        if got := blockedDomain(link); got != want {
+            t.Errorf("blockedDomain(%q) = %q, want %q", link, got, want)
+        }
+    }
+}
+
+func TestBlockCommand(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+    hosts := filepath.Join(t.TempDir(), "hosts")
+    if err := os.WriteFile(hosts, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    app.Config.Block = BlockConfig{Mode: BlockHosts, HostsFile: hosts}
+    loc, err := i18n.NewLocalizer("en")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    api.Push(telephishtest.Command("/block a_b!.example"), telephishtest.Command("/block localhost"), telephishtest.Command("/block аpple.com"))
+    if err := app.Poll(context.Background(), true); err != nil {
+        t.Fatal(err)
+    }
+    sent, err := api.WaitSent(3, 5*time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    replies := map[string]bool{}
+    for _, m := range sent {
+        replies[m.Text] = true
+    }
+    for _, want := range []string{loc.T("block.invalid", "a_b!.example"), loc.T("block.invalid", "localhost"), loc.T("block.done", "xn--pple-43d.com")} {
+        if !replies[want] {
+            t.Errorf("no reply %q among %+v", want, sent)
+        }
+    }
+
+    data, err := os.ReadFile(hosts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := string(data); got != "127.0.0.1 localhost\n0.0.0.0 xn--pple-43d.com "+hostsMarker+"\n:: xn--pple-43d.com "+hostsMarker+"\n" {
+        t.Errorf("hosts file is now:\n%s", got)
+    }
+}
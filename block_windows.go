@@ -0,0 +1,45 @@
+//go:build windows
+
+package telephish
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// defaultHostsFile is where the system resolver reads static names.
+var defaultHostsFile = filepath.Join(os.Getenv("SystemRoot"), `System32\drivers\etc\hosts`)
+
+// firewallRuleName names the rule blocking domain, so it can be found
+// again to delete.
+func firewallRuleName(domain string) string {
+    return "telephish-block-" + domain
+}
+
+// addFirewallRule adds a Windows Firewall rule refusing outbound
+// connections to addrs, replacing any earlier rule for domain.
+func addFirewallRule(domain string, addrs []string) error {
+    deleteFirewallRule(domain)
+    return netsh("add", "rule", "name="+firewallRuleName(domain), "dir=out", "action=block", "remoteip="+strings.Join(addrs, ","))
+}
+
+// deleteFirewallRule deletes the rule addFirewallRule added for domain,
+// if it is still there.
+func deleteFirewallRule(domain string) error {
+    // show fails when no rule matches, in whatever language netsh speaks
+    if netsh("show", "rule", "name="+firewallRuleName(domain)) != nil {
+        return nil
+    }
+    return netsh("delete", "rule", "name="+firewallRuleName(domain))
+}
+
+func netsh(args ...string) error {
+    out, err := exec.Command("netsh", append([]string{"advfirewall", "firewall"}, args...)...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("netsh %s failed (blocking needs administrator rights): %v: %s", args[0], err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
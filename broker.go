@@ -0,0 +1,54 @@
+package telephish
+
+import (
+    "sync"
+
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// subscriberBuffer is how many alerts a slow subscriber may fall behind
+// before alerts are dropped for it.
+const subscriberBuffer = 64
+
+// Broker fans processed alerts out to live subscribers such as gRPC
+// StreamAlerts calls.
+type Broker struct {
+    mu   sync.Mutex
+    subs map[chan store.HistoryEntry]struct{}
+}
+
+// Subscribe returns a channel of entries published from now on, and a
+// function that unsubscribes and closes it.
+func (b *Broker) Subscribe() (<-chan store.HistoryEntry, func()) {
+    ch := make(chan store.HistoryEntry, subscriberBuffer)
+    b.mu.Lock()
+    if b.subs == nil {
+        b.subs = map[chan store.HistoryEntry]struct{}{}
+    }
+    b.subs[ch] = struct{}{}
+    b.mu.Unlock()
+
+    var once sync.Once
+    return ch, func() {
+        once.Do(func() {
+            b.mu.Lock()
+            delete(b.subs, ch)
+            b.mu.Unlock()
+            close(ch)
+        })
+    }
+}
+
+// Publish sends entry to every subscriber, skipping any whose buffer is
+// full rather than holding up the pipeline.
+func (b *Broker) Publish(entry store.HistoryEntry) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for ch := range b.subs {
+        select {
+        case ch <- entry:
+        default:
+            appLog.Warn("subscriber is falling behind; dropping alert", "alert", entry.Alert.ID)
+        }
+    }
+}
@@ -0,0 +1,84 @@
+package telephish
+
+import (
+    "runtime"
+    "runtime/debug"
+    "strings"
+)
+
+// Build metadata, set at build time with -ldflags, e.g.
+//
+//    -X github.com/hacker1337itme/telephish.version=v1.4.0
+//    -X github.com/hacker1337itme/telephish.commit=$(git rev-parse HEAD)
+//    -X github.com/hacker1337itme/telephish.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+//    -X github.com/hacker1337itme/telephish.features=toast,headless
+//
+// Without them, commit and buildDate come from the VCS stamp go build
+// embeds, if there is one.
+var (
+    version   = "dev"
+    commit    = ""
+    buildDate = ""
+    features  = "" // Comma separated feature flags the build was made with
+)
+
+// BuildInfo describes the running binary, for support requests.
+type BuildInfo struct {
+    Version   string   `json:"version"`
+    Commit    string   `json:"commit,omitempty"`
+    Date      string   `json:"date,omitempty"`
+    Modified  bool     `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+    Features  []string `json:"features,omitempty"`
+    GoVersion string   `json:"go_version"`
+    Platform  string   `json:"platform"` // GOOS/GOARCH
+}
+
+// Build returns the running binary's build metadata.
+func Build() BuildInfo {
+    b := BuildInfo{
+        Version:   version,
+        Commit:    commit,
+        Date:      buildDate,
+        Features:  splitList(features),
+        GoVersion: runtime.Version(),
+        Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+    }
+    if info, ok := debug.ReadBuildInfo(); ok {
+        for _, s := range info.Settings {
+            switch s.Key {
+            case "vcs.revision":
+                if b.Commit == "" {
+                    b.Commit = s.Value
+                }
+            case "vcs.time":
+                if b.Date == "" {
+                    b.Date = s.Value
+                }
+            case "vcs.modified":
+                b.Modified = s.Value == "true"
+            }
+        }
+    }
+    return b
+}
+
+// ShortCommit is the commit abbreviated as git does, or "unknown".
+func (b BuildInfo) ShortCommit() string {
+    switch {
+    case b.Commit == "":
+        return "unknown"
+    case len(b.Commit) > 12:
+        return b.Commit[:12]
+    }
+    return b.Commit
+}
+
+// LogAttrs returns the metadata as log attributes, for the line a
+// monitor logs when it starts.
+func (b BuildInfo) LogAttrs() []interface{} {
+    attrs := []interface{}{"version", b.Version, "commit", b.ShortCommit(), "built", b.Date, "go", b.GoVersion, "platform", b.Platform}
+    if len(b.Features) > 0 {
+        attrs = append(attrs, "features", strings.Join(b.Features, ","))
+    }
+    return attrs
+}
@@ -0,0 +1,212 @@
+// Package capture records raw payloads for debugging: Telegram updates as
+// they arrived, and what each analyzer was asked and answered. Secrets are
+// redacted before anything is written, but captures still hold message
+// contents and should be treated as sensitive.
+package capture
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sync/atomic"
+    "time"
+
+    "github.com/hacker1337itme/telephish/logging"
+)
+
+// captureLog logs failed captures.
+var captureLog = logging.Module("capture")
+
+// Redacted replaces every secret in a capture.
+const Redacted = "REDACTED"
+
+// Recorder writes payloads to a directory, one JSON file each. A nil
+// Recorder records nothing, so callers needn't check whether capture is on.
+type Recorder struct {
+    dir string
+    seq atomic.Uint64
+}
+
+// Open returns a recorder writing to dir, creating it if needed.
+func Open(dir string) (*Recorder, error) {
+    if err := os.MkdirAll(dir, 0o700); err != nil {
+        return nil, fmt.Errorf("failed to create capture directory: %v", err)
+    }
+    return &Recorder{dir: dir}, nil
+}
+
+// Dir is the directory captures are written to.
+func (r *Recorder) Dir() string {
+    return r.dir
+}
+
+// Record writes v as JSON under kind, e.g. "analyzer". Failing to write a
+// capture never affects the caller; it is only logged.
+func (r *Recorder) Record(kind string, v interface{}) {
+    if r == nil {
+        return
+    }
+    data, err := json.Marshal(v)
+    if err != nil {
+        captureLog.Warn("failed to encode capture", "kind", kind, "err", err)
+        return
+    }
+    r.RecordRaw(kind, data)
+}
+
+// RecordRaw writes a JSON payload exactly as received, unless it has
+// secrets to redact, in which case it is re-encoded without them.
+func (r *Recorder) RecordRaw(kind string, data []byte) {
+    if r == nil {
+        return
+    }
+    data = Redact(data)
+    name := fmt.Sprintf("%s-%06d-%s.json", time.Now().UTC().Format("20060102T150405.000"), r.seq.Add(1), kind)
+    if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o600); err != nil {
+        captureLog.Warn("failed to write capture", "kind", kind, "err", err)
+    }
+}
+
+// Prune deletes the captures in dir written before before, and returns
+// how many there were. Other files are left alone.
+func Prune(dir string, before time.Time) (int, error) {
+    entries, err := os.ReadDir(dir)
+    if os.IsNotExist(err) {
+        return 0, nil
+    } else if err != nil {
+        return 0, fmt.Errorf("failed to read capture directory: %v", err)
+    }
+    pruned := 0
+    for _, entry := range entries {
+        if !entry.Type().IsRegular() || filepath.Ext(entry.Name()) != ".json" {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil || !info.ModTime().Before(before) {
+            continue
+        }
+        if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+            return pruned, fmt.Errorf("failed to delete capture: %v", err)
+        }
+        pruned++
+    }
+    return pruned, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying r, for code deep in a call such as
+// an HTTP transport to record what it sees.
+func NewContext(ctx context.Context, r *Recorder) context.Context {
+    if r == nil {
+        return ctx
+    }
+    return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the recorder in ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Recorder {
+    r, _ := ctx.Value(contextKey{}).(*Recorder)
+    return r
+}
+
+var (
+    // secretKey matches object keys and URL parameters holding secrets.
+    secretKey = regexp.MustCompile(`(?i)token|secret|passw|authorization|cookie|api[_-]?key|credential`)
+    // botToken matches a Telegram bot token anywhere in a string, such as
+    // in a Bot API URL.
+    botToken = regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`)
+    // embeddedURL finds URLs inside longer strings.
+    embeddedURL = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+)
+
+// Redact returns data, a JSON document, with secrets replaced by Redacted:
+// the values of keys named like tokens, passwords, cookies or API keys,
+// bot tokens, and passwords and secret parameters in URLs. data is
+// returned unchanged if there is nothing to redact or it isn't JSON.
+func Redact(data []byte) []byte {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    var v interface{}
+    if err := dec.Decode(&v); err != nil {
+        return data
+    }
+    v, changed := redactValue(v)
+    if !changed {
+        return data
+    }
+    out, err := json.Marshal(v)
+    if err != nil {
+        return data
+    }
+    return out
+}
+
+func redactValue(v interface{}) (interface{}, bool) {
+    switch v := v.(type) {
+    case map[string]interface{}:
+        changed := false
+        for key, value := range v {
+            if secretKey.MatchString(key) && value != nil && value != "" {
+                if _, nested := value.(map[string]interface{}); !nested {
+                    v[key], changed = Redacted, true
+                    continue
+                }
+            }
+            var c bool
+            if v[key], c = redactValue(value); c {
+                changed = true
+            }
+        }
+        return v, changed
+    case []interface{}:
+        changed := false
+        for i, value := range v {
+            var c bool
+            if v[i], c = redactValue(value); c {
+                changed = true
+            }
+        }
+        return v, changed
+    case string:
+        s := RedactString(v)
+        return s, s != v
+    }
+    return v, false
+}
+
+// RedactString replaces bot tokens, and passwords and secret parameters in
+// any URLs, found in s.
+func RedactString(s string) string {
+    s = botToken.ReplaceAllString(s, Redacted)
+    return embeddedURL.ReplaceAllStringFunc(s, redactURL)
+}
+
+func redactURL(raw string) string {
+    u, err := url.Parse(raw)
+    if err != nil {
+        return raw
+    }
+    changed := false
+    if _, ok := u.User.Password(); ok {
+        u.User = url.UserPassword(u.User.Username(), Redacted)
+        changed = true
+    }
+    query := u.Query()
+    for key := range query {
+        if secretKey.MatchString(key) {
+            query.Set(key, Redacted)
+            changed = true
+        }
+    }
+    if !changed {
+        return raw
+    }
+    u.RawQuery = query.Encode()
+    return u.String()
+}
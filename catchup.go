@@ -0,0 +1,114 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// catchUp tallies the updates that arrived while the monitor was stopped,
+// as Poll works through them. A nil catchUp tallies nothing, so the steady
+// poll loop needn't check.
+type catchUp struct {
+    maxAge time.Duration // Skip messages older than this, if set
+    now    time.Time
+
+    mu         sync.Mutex
+    first      int64 // The first update fetched
+    updates    int
+    skipped    int // Too old to scan
+    scanned    int
+    severities map[analysis.Severity]int
+}
+
+// received counts update, and reports whether it is too old to handle.
+func (c *catchUp) received(update telegram.Update) bool {
+    if c == nil {
+        return false
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.first == 0 {
+        c.first = update.UpdateID
+    }
+    c.updates++
+    m := update.Message
+    if c.maxAge > 0 && m != nil && m.Date > 0 && c.now.Sub(time.Unix(m.Date, 0)) > c.maxAge {
+        c.skipped++
+        return true
+    }
+    return false
+}
+
+// handled counts the entry recorded for an update, if its link was
+// scanned.
+func (c *catchUp) handled(entry *store.HistoryEntry) {
+    if c == nil || entry == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.severities == nil {
+        c.severities = map[analysis.Severity]int{}
+    }
+    c.scanned++
+    c.severities[entry.Alert.Verdict.Severity]++
+}
+
+// caughtUp logs what Poll caught up on since the saved offset and, if
+// catch_up.summary is set, delivers an alert summing it up. Updates
+// Telegram no longer had, since they were more than a day old or fetched
+// by something else, are reported as lost.
+func (a *App) caughtUp(ctx context.Context, c *catchUp, saved int64) {
+    var lost int64
+    if saved > 0 && c.first > saved {
+        lost = c.first - saved
+    }
+    logger := pollLog
+    if a.Config.Profile != "" {
+        logger = logger.With("profile", a.Config.Profile)
+    }
+    if c.updates == 0 && lost == 0 {
+        logger.Info("no new messages")
+        return
+    }
+    logger.Info("caught up", "updates", c.updates, "scanned", c.scanned, "skipped", c.skipped, "lost", lost,
+        "malicious", c.severities[analysis.SeverityMalicious], "suspicious", c.severities[analysis.SeveritySuspicious])
+    if lost > 0 {
+        logger.Warn("updates were lost while the monitor was stopped", "lost", lost, "saved_offset", saved, "first_update", c.first)
+    }
+
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if !a.Config.CatchUp.Summary {
+        return
+    }
+    alert := notify.Alert{
+        ID:      fmt.Sprintf("catchup-%d", c.now.Unix()),
+        Title:   a.Loc.T("catchup.title", c.updates),
+        Message: a.Loc.T("catchup.message", c.scanned, c.severities[analysis.SeverityMalicious], c.severities[analysis.SeveritySuspicious]),
+        Profile: a.Config.Profile,
+    }
+    if c.skipped > 0 {
+        alert.Message += " " + a.Loc.T("catchup.skipped", c.skipped)
+    }
+    if lost > 0 {
+        alert.Message += " " + a.Loc.T("catchup.lost", lost)
+    }
+    alert.Verdict.Severity = analysis.SeverityInfo
+    for severity, n := range c.severities {
+        if n > 0 && severity > alert.Verdict.Severity {
+            alert.Verdict.Severity = severity
+        }
+    }
+    // The summary isn't in the history for the delivery queue to find
+    if err := notify.ActionsError(a.direct.Deliver(ctx, alert)); err != nil {
+        logger.Error("failed to deliver catch-up summary", "err", err)
+    }
+}
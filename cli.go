@@ -0,0 +1,465 @@
+package telephish
+
+import (
+    "context"
+    "crypto/subtle"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/hacker1337itme/telephish/capture"
+    "github.com/hacker1337itme/telephish/logging"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// command is a CLI subcommand.
+type command struct {
+    name    string
+    args    string
+    summary string
+    run     func(ctx context.Context, args []string) error
+}
+
+var commands []command
+
+func init() {
+    commands = []command{
+        {"run", "[--once] [--output text|jsonl] [--tui]", "watch the bot for links and alert on them (default)", runCommand},
+        {"scan", "[--text message] [--profile name] [--json] <url>", "scan a single URL and print the verdict", scanCommand},
+        {"history", "[-n count]", "show recent alerts", historyCommand},
+        {"query", "[--domain d] [--sender s] [--json]", "search the history by domain, sender, chat, verdict or date", queryCommand},
+        {"stats", "[--since time] [--json]", "show scans per day, verdicts, scan times and analyzer calls", statsCommand},
+        {"export", "[--format csv|jsonl] [-o file]", "export the alert history for spreadsheets or notebooks", exportCommand},
+        {"deliveries", "list|retry|purge [--sink name] [--dead]", "show, retry or purge alerts waiting in the delivery queue", deliveriesCommand},
+        {"prune", "--now", "delete alerts, screenshots and captures older than the retention settings", pruneCommand},
+        {"block", "list | add [--for duration] [--reason text] <domain>", "show the domains blocked on this machine, or block one", blockCommand},
+        {"unblock", "[--reason text] <domain>...", "lift blocks before they expire", unblockCommand},
+        {"report", "[--period weekly|monthly] [--format html|pdf] [-o file] [--send]", "write or send a summary report of the last week or month", reportCommand},
+        {"import", "[--format f] [--list block|allow] [--source name] [--expire duration] <file|url>", "put the domains of an indicator feed on the blocklist", importCommand},
+        {"audit", "[--since duration] [--actor a] [--action a] [--json]", "show what the monitor and its users have done, newest first", auditCommand},
+        {"rescan", "", "scan recent clean links again and alert on changed verdicts", rescanCommand},
+        {"webhook", "[--listen addr] [--url public-url]", "receive updates by Telegram webhook instead of polling", webhookCommand},
+        {"install", "[--config file]", "register the app for Windows toasts and their buttons", installCommand},
+        {"uninstall", "", "remove the Windows toast registration", func(context.Context, []string) error { return runInstall(false, "") }},
+        {"open", "<url>", "open a URL in a throwaway browser profile", openCommand},
+        {"sandbox", "<url>", "open a URL in Windows Sandbox", sandboxCommand},
+        {"submit", "<url>", "report a link to Microsoft Defender and SmartScreen", submitCommand},
+        {"abuse", "<history ID or url>", "report a link to its registrar and host's abuse contacts", abuseCommand},
+        {"protocol", "<telephish: link>", "run the action behind a toast button", protocolCommand},
+        {"service", "install|uninstall|start|stop|reload", "manage the Windows service", serviceCommand},
+        {"secrets", "set|delete <name> | list", "keep the bot token and API keys in the OS credential store", secretsCommand},
+        {"systemd-unit", "[--webhook] [--user name]", "print a systemd unit file for this binary", systemdUnitCommand},
+        {"update", "[--check] [--version tag]", "install the latest release after verifying it", updateCommand},
+        {"version", "[--json]", "print the version, commit, build date and features", versionCommand},
+    }
+}
+
+// Run dispatches to the subcommand named in args, defaulting to run. The
+// command's context is cancelled on SIGINT or SIGTERM.
+func Run(args []string) error {
+    name := "run"
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        name, args = args[0], args[1:]
+    }
+    for _, cmd := range commands {
+        if cmd.name == name {
+            ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+            defer stop()
+            return cmd.run(ctx, args)
+        }
+    }
+    if name == "help" {
+        usage(os.Stdout)
+        return nil
+    }
+    usage(os.Stderr)
+    return fmt.Errorf("unknown command %q", name)
+}
+
+func usage(w io.Writer) {
+    fmt.Fprintf(w, "Usage: %s <command> [--config file] [flags]\n\nCommands:\n", os.Args[0])
+    for _, cmd := range commands {
+        fmt.Fprintf(w, "  %-13s %-40s %s\n", cmd.name, cmd.args, cmd.summary)
+    }
+}
+
+// loadConfig reads the config and sends logs where it says.
+func loadConfig(path string) (*Config, error) {
+    cfg, err := LoadConfig(path)
+    if err != nil {
+        return nil, err
+    }
+    w, err := logging.Writer(cfg.Logging, os.Stderr)
+    if err != nil {
+        return nil, err
+    }
+    return cfg, logging.Setup(cfg.Logging, w)
+}
+
+// addCaptureFlag adds --capture, which overrides debug.capture_dir.
+func addCaptureFlag(fs *flag.FlagSet) *string {
+    return fs.String("capture", "", "directory to record raw updates and analyzer exchanges in, for debugging (overrides debug.capture_dir)")
+}
+
+// newFlagSet returns the flags for a command, including the shared --config.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+    fs := flag.NewFlagSet(name, flag.ExitOnError)
+    configPath := fs.String("config", "", "path to the YAML config file (default "+DefaultConfigPath+" if present)")
+    return fs, configPath
+}
+
+func runCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("run")
+    once := fs.Bool("once", false, "handle the waiting messages and exit")
+    output := fs.String("output", "", "text, or jsonl to write one JSON result per alert to stdout (overrides output)")
+    useTUI := fs.Bool("tui", false, "show a live alert feed with per-chat counters in the terminal, with keys to mark domains safe or block them")
+    captureDir := addCaptureFlag(fs)
+    fs.Parse(args)
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if *captureDir != "" {
+        cfg.Debug.CaptureDir = *captureDir
+    }
+    if *useTUI {
+        switch {
+        case *once:
+            return fmt.Errorf("--tui: can't be used with --once")
+        case *output == "jsonl" || (*output == "" && cfg.Output == "jsonl"):
+            return fmt.Errorf("--tui: can't be used with JSON output, which takes stdout")
+        case !notify.IsTerminal(os.Stdin) || !notify.IsTerminal(os.Stdout):
+            return fmt.Errorf("--tui: stdin and stdout must be a terminal")
+        }
+        for _, pc := range append(cfg.ProfileConfigs(), cfg) {
+            pc.TUI = true
+            pc.applyOutput()
+        }
+    }
+    if *output != "" {
+        if *output != "text" && *output != "jsonl" {
+            return fmt.Errorf("--output: want text or jsonl, got %q", *output)
+        }
+        for _, pc := range append(cfg.ProfileConfigs(), cfg) {
+            pc.Output = *output
+            pc.applyOutput()
+        }
+    }
+    if err := cfg.RequireTokens(); err != nil {
+        return err
+    }
+    unlock, err := LockInstances(cfg)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    apps, err := NewApps(cfg)
+    if err != nil {
+        return err
+    }
+    defer closeApps(apps)
+
+    // The first app's servers and health cover every profile
+    app := apps[0]
+    appLog.Info("starting", Build().LogAttrs()...)
+    if !*once {
+        if err := app.StartServers(ctx); err != nil {
+            return err
+        }
+        app.ReloadOnSignal(ctx)
+        app.StartPruning(ctx)
+        app.StartBlockExpiry(ctx)
+        app.StartClipboard(ctx)
+        app.StartReports(ctx)
+        app.StartHeartbeat(ctx)
+        for _, app := range apps {
+            app.StartRescans(ctx)
+            app.StartDeliveries(ctx)
+        }
+        StartWatchdog(func() bool { return app.Live().OK })
+        SdNotify("READY=1")
+        defer SdNotify("STOPPING=1")
+    }
+    if *useTUI {
+        return runTUI(ctx, cfg, apps)
+    }
+    return pollApps(ctx, apps, *once)
+}
+
+// runTUI polls like run does, drawing the terminal UI until it is quit or
+// polling stops. Logs show in its status bar unless they go to a file.
+func runTUI(ctx context.Context, cfg *Config, apps []*App) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    t := newTUI(apps[0], os.Stdin, os.Stdout)
+    w, err := logging.Writer(cfg.Logging, t)
+    if err != nil {
+        return err
+    }
+    logging.Setup(cfg.Logging, w)
+    defer func() {
+        if w, err := logging.Writer(cfg.Logging, os.Stderr); err == nil {
+            logging.Setup(cfg.Logging, w)
+        }
+    }()
+
+    polled := make(chan error, 1)
+    go func() {
+        polled <- pollApps(ctx, apps, false)
+        cancel()
+    }()
+    if err := t.run(ctx, cancel); err != nil {
+        cancel()
+        <-polled
+        return err
+    }
+    return <-polled
+}
+
+func scanCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("scan")
+    text := fs.String("text", "", "message text the link arrived with, for the text analyzer")
+    profile := fs.String("profile", "", "scan with this profile's analyzers, rules and thresholds (default the first)")
+    asJSON := fs.Bool("json", false, "print the result as JSON, in the schema run --output=jsonl uses")
+    captureDir := addCaptureFlag(fs)
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        return fmt.Errorf("usage: %s scan [--text message] [--profile name] [--json] <url>", os.Args[0])
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if cfg, err = cfg.ForProfile(*profile); err != nil {
+        return err
+    }
+    if *captureDir != "" {
+        cfg.Debug.CaptureDir = *captureDir
+    }
+    app, err := NewApp(cfg)
+    if err != nil {
+        return err
+    }
+    defer app.Close()
+
+    alert := app.NewAlert(fs.Arg(0), *text)
+    app.Scan(ctx, &alert, *text, false)
+    if *asJSON {
+        return notify.NewResultWriter(os.Stdout).Write(notify.NewResult(alert, time.Now(), nil))
+    }
+    return notify.NewHeadlessNotifier().Notify(ctx, alert)
+}
+
+func historyCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("history")
+    limit := fs.Int("n", 20, "number of alerts to show")
+    fs.Parse(args)
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    entries, err := history.Recent(*limit)
+    if err != nil {
+        return err
+    }
+    color := notify.IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+    for _, entry := range entries {
+        fmt.Println(notify.FormatAlertLine(entry.Time.Local(), entry.Alert, color))
+    }
+    return nil
+}
+
+func webhookCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("webhook")
+    listen := fs.String("listen", "", "local address to serve the webhook on (overrides webhook.listen)")
+    publicURL := fs.String("url", "", "public HTTPS URL Telegram should call (overrides webhook.url)")
+    captureDir := addCaptureFlag(fs)
+    fs.Parse(args)
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if *captureDir != "" {
+        cfg.Debug.CaptureDir = *captureDir
+    }
+    if len(cfg.Profiles) > 0 {
+        return fmt.Errorf("profiles: the webhook receives updates for a single bot; use run to watch several profiles")
+    }
+    if err := cfg.RequireToken(); err != nil {
+        return err
+    }
+    unlock, err := LockInstances(cfg)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    if *listen != "" {
+        cfg.Webhook.Listen = *listen
+    }
+    if *publicURL != "" {
+        cfg.Webhook.URL = *publicURL
+    }
+    if cfg.Webhook.URL == "" {
+        return fmt.Errorf("webhook.url: a public HTTPS URL is required (or pass --url)")
+    }
+
+    app, err := NewApp(cfg)
+    if err != nil {
+        return err
+    }
+    defer app.Close()
+    appLog.Info("starting", Build().LogAttrs()...)
+
+    if err := telegram.SetWebhook(ctx, cfg.Telegram.Token, cfg.Webhook.URL, cfg.Webhook.Secret); err != nil {
+        return err
+    }
+    app.health.started("webhook")
+    if err := app.StartServers(ctx); err != nil {
+        return err
+    }
+    listener, err := net.Listen("tcp", cfg.Webhook.Listen)
+    if err != nil {
+        return err
+    }
+    webhookLog.Info("receiving updates", "url", cfg.Webhook.URL, "listen", cfg.Webhook.Listen)
+    app.ReloadOnSignal(ctx)
+    app.StartRescans(ctx)
+    app.StartDeliveries(ctx)
+    app.StartPruning(ctx)
+    app.StartBlockExpiry(ctx)
+    app.StartClipboard(ctx)
+    app.StartReports(ctx)
+    app.StartHeartbeat(ctx)
+    StartWatchdog(func() bool { return app.Live().OK })
+    SdNotify("READY=1")
+
+    // Shutdown waits for handlers, so updates being scanned are finished
+    // and acknowledged before the digest is flushed by app.Close.
+    server := &http.Server{Handler: app.WebhookHandler()}
+    go func() {
+        <-ctx.Done()
+        SdNotify("STOPPING=1")
+        webhookLog.Info("shutting down")
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+        defer cancel()
+        server.Shutdown(shutdownCtx)
+    }()
+    if err := server.Serve(listener); err != http.ErrServerClosed {
+        return err
+    }
+    return nil
+}
+
+// WebhookHandler accepts updates pushed by Telegram.
+func (a *App) WebhookHandler() http.Handler {
+    want := a.Config.Webhook.Secret
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+        if want != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(want)) != 1 {
+            http.Error(w, "forbidden", http.StatusForbidden)
+            return
+        }
+        body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+        if err != nil {
+            http.Error(w, "bad update", http.StatusBadRequest)
+            return
+        }
+        a.capture.RecordRaw("update", body)
+        update, err := telegram.ParseUpdate(body)
+        if err != nil {
+            logParseError(webhookLog, err)
+            http.Error(w, "bad update", http.StatusBadRequest)
+            return
+        }
+        if !a.HandleUpdate(capture.NewContext(r.Context(), a.capture), update) {
+            // Telegram delivers it again later
+            webhookLog.Warn("work queue is full; refusing update", "update_id", update.UpdateID)
+            http.Error(w, "busy", http.StatusServiceUnavailable)
+        }
+    })
+}
+
+func installCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("install")
+    fs.Parse(args)
+    return runInstall(true, *configPath)
+}
+
+// runInstall installs or uninstalls the toast registration. Toast buttons
+// that need the config, such as "Report to Microsoft", load configPath.
+func runInstall(install bool, configPath string) error {
+    if !install {
+        if err := Uninstall(); err != nil {
+            return err
+        }
+        installLog.Info("removed Start Menu shortcut, AppUserModelID and telephish: protocol")
+        return nil
+    }
+    if err := Install(configPath); err != nil {
+        return err
+    }
+    installLog.Info("installed Start Menu shortcut, AppUserModelID and telephish: protocol", "app_id", AppID)
+    return nil
+}
+
+func sandboxCommand(ctx context.Context, args []string) error {
+    if len(args) != 1 {
+        return fmt.Errorf("usage: %s sandbox <url>", os.Args[0])
+    }
+    // Installs that predate the protocol command send every telephish:
+    // link here
+    if strings.HasPrefix(args[0], ProtocolScheme+":") {
+        return protocolCommand(ctx, args)
+    }
+    return OpenInSandbox(args[0])
+}
+
+func versionCommand(ctx context.Context, args []string) error {
+    fs := flag.NewFlagSet("version", flag.ExitOnError)
+    asJSON := fs.Bool("json", false, "print the build metadata as JSON")
+    fs.Parse(args)
+
+    b := Build()
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        return enc.Encode(b)
+    }
+    commit := b.ShortCommit()
+    if b.Modified {
+        commit += " (modified)"
+    }
+    date := b.Date
+    if date == "" {
+        date = "unknown"
+    }
+    featureList := strings.Join(b.Features, ", ")
+    if featureList == "" {
+        featureList = "none"
+    }
+    fmt.Printf("%s %s\ncommit:   %s\nbuilt:    %s\ngo:       %s %s\nfeatures: %s\n", AppName, b.Version, commit, date, b.GoVersion, b.Platform, featureList)
+    return nil
+}
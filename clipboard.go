@@ -0,0 +1,89 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+const (
+    clipboardMaxText = 64 << 10         // How much of the clipboard is searched for links
+    clipboardRepeat  = 10 * time.Minute // How long a link copied again isn't scanned again
+)
+
+// StartClipboard watches the clipboard for links until ctx is cancelled,
+// if clipboard.enabled is set, and scans each one as it is copied, such as
+// from a secret chat the bot can't see. Only the links are scanned and
+// recorded, never the rest of what was copied.
+func (a *App) StartClipboard(ctx context.Context) {
+    if !a.Config.Clipboard.Enabled {
+        return
+    }
+    interval := a.Config.Clipboard.Interval
+    clipboardLog.Info("watching the clipboard for links", "interval", interval)
+    go func() {
+        // What was copied before the monitor started isn't scanned
+        last := clipboardSequence()
+        seen := map[string]time.Time{}
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+            }
+            seq := clipboardSequence()
+            if seq == last {
+                continue
+            }
+            last = seq
+            text, err := readClipboard()
+            if err != nil {
+                clipboardLog.Debug("failed to read the clipboard", "err", err)
+                continue
+            }
+            if len(text) > clipboardMaxText {
+                text = text[:clipboardMaxText]
+            }
+            now := time.Now()
+            for link, at := range seen {
+                if now.Sub(at) > clipboardRepeat {
+                    delete(seen, link)
+                }
+            }
+            for _, link := range extract.Links(text) {
+                if _, ok := seen[link]; ok {
+                    continue
+                }
+                seen[link] = now
+                if !a.queue.Submit(func() { a.scanCopied(ctx, link) }) {
+                    clipboardLog.Warn("work queue is full; not scanning copied link", "url", link)
+                }
+            }
+        }
+    }()
+}
+
+// scanCopied scans a link copied to the clipboard, and alerts on it if
+// its verdict is clipboard.severity or worse. Cleaner verdicts are only
+// recorded, so copying links doesn't raise a toast every time.
+func (a *App) scanCopied(ctx context.Context, link string) {
+    a.inFlight.Add(1)
+    defer a.inFlight.Add(-1)
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    alert := a.NewAlert(link, "")
+    alert.Title, alert.Message = a.Loc.T("clipboard.title"), a.Loc.T("clipboard.message")
+    alert.ID = fmt.Sprintf("clipboard-%d-%d", time.Now().Unix(), submitted.Add(1))
+    logger := clipboardLog.With("alert", alert.ID, "url", link)
+    entry := store.HistoryEntry{Alert: alert}
+
+    release := a.domains.acquire(link)
+    a.Scan(ctx, &entry.Alert, "", false)
+    release()
+    a.finish(ctx, logger, entry, entry.Alert.Verdict.Severity >= a.Config.Clipboard.Severity)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package telephish
+
+import "fmt"
+
+// clipboardSequence is only implemented on Windows.
+func clipboardSequence() uint32 {
+    return 0
+}
+
+// readClipboard is only implemented on Windows.
+func readClipboard() (string, error) {
+    return "", fmt.Errorf("clipboard monitoring is only supported on Windows")
+}
@@ -0,0 +1,80 @@
+//go:build windows
+
+package telephish
+
+import (
+    "fmt"
+    "runtime"
+    "time"
+    "unsafe"
+
+    "golang.org/x/sys/windows"
+)
+
+var (
+    kernel32                       = windows.NewLazySystemDLL("kernel32.dll")
+    procGetClipboardSequenceNumber = user32.NewProc("GetClipboardSequenceNumber")
+    procIsClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
+    procRegisterClipboardFormatW   = user32.NewProc("RegisterClipboardFormatW")
+    procOpenClipboard              = user32.NewProc("OpenClipboard")
+    procCloseClipboard             = user32.NewProc("CloseClipboard")
+    procGetClipboardData           = user32.NewProc("GetClipboardData")
+    procGlobalLock                 = kernel32.NewProc("GlobalLock")
+    procGlobalUnlock               = kernel32.NewProc("GlobalUnlock")
+)
+
+const cfUnicodeText = 13
+
+// clipboardSequence returns a number that changes whenever the clipboard
+// does, cheaply enough to check often.
+func clipboardSequence() uint32 {
+    n, _, _ := procGetClipboardSequenceNumber.Call()
+    return uint32(n)
+}
+
+// readClipboard returns the text on the clipboard, or "" if there is none
+// or the program that put it there, such as a password manager, asked for
+// it to be kept from clipboard monitors.
+func readClipboard() (string, error) {
+    if available, _, _ := procIsClipboardFormatAvailable.Call(cfUnicodeText); available == 0 {
+        return "", nil
+    }
+    name, _ := windows.UTF16PtrFromString("ExcludeClipboardContentFromMonitorProcessing")
+    if exclude, _, _ := procRegisterClipboardFormatW.Call(uintptr(unsafe.Pointer(name))); exclude != 0 {
+        if excluded, _, _ := procIsClipboardFormatAvailable.Call(exclude); excluded != 0 {
+            return "", nil
+        }
+    }
+
+    // The clipboard is opened and closed by a thread, not a goroutine
+    runtime.LockOSThread()
+    defer runtime.UnlockOSThread()
+
+    // Whoever is writing to the clipboard holds it open for a moment
+    var opened uintptr
+    var err error
+    for i := 0; i < 5 && opened == 0; i++ {
+        if i > 0 {
+            time.Sleep(50 * time.Millisecond)
+        }
+        opened, _, err = procOpenClipboard.Call(0)
+    }
+    if opened == 0 {
+        return "", fmt.Errorf("failed to open the clipboard: %v", err)
+    }
+    defer procCloseClipboard.Call()
+
+    handle, _, err := procGetClipboardData.Call(cfUnicodeText)
+    if handle == 0 {
+        return "", fmt.Errorf("failed to read the clipboard: %v", err)
+    }
+    text, _, err := procGlobalLock.Call(handle)
+    if text == 0 {
+        return "", fmt.Errorf("failed to read the clipboard: %v", err)
+    }
+    defer procGlobalUnlock.Call(handle)
+    // text points at memory Windows allocated, which the Go heap never
+    // moves, so it is read through the address rather than converted from
+    // a uintptr
+    return windows.UTF16PtrToString(*(**uint16)(unsafe.Pointer(&text))), nil
+}
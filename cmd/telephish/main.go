@@ -0,0 +1,17 @@
+// Command telephish watches a Telegram bot for links and alerts on the
+// ones that look like phishing.
+package main
+
+import (
+    "log/slog"
+    "os"
+
+    "github.com/hacker1337itme/telephish"
+)
+
+func main() {
+    if err := telephish.Run(os.Args[1:]); err != nil {
+        slog.Error(err.Error())
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,277 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// HandleCommand runs a bot command such as /mute sent in a chat, replying
+// there, if the sender's role allows it. It reports false if the message
+// is not a command it knows. Commands that take a while run on the
+// workers, and reply when they are done. Commands that change something
+// are recorded in the audit log.
+//
+//    /scan <link>             scan a link and reply with the verdict (viewer)
+//    /status                  show whether the monitor is working (viewer)
+//    /prefs                   show this chat's settings (viewer)
+//    /mute                    stop alerts from this chat (admin)
+//    /unmute                  resume alerts from this chat (admin)
+//    /alerts <severity>       only alert at this severity or worse (admin)
+//    /warnings on|off         reply to flagged links with a warning (admin)
+//    /language <code>|auto    language of messages too short to tell (admin)
+//    /block <domain> [why]    block a domain on the monitor's machine (admin)
+//    /unblock <domain> [why]  lift a domain's block (admin)
+//    /role <user ID> <role>   grant admin, viewer or none (owner)
+func (a *App) HandleCommand(ctx context.Context, logger *slog.Logger, message *telegram.Message) bool {
+    if message.Chat == nil || !strings.HasPrefix(message.Text, "/") {
+        return false
+    }
+    fields := strings.Fields(message.Text)
+    // Commands in groups may be addressed as /mute@SomeBot
+    command, _, _ := strings.Cut(fields[0], "@")
+    need, ok := commandRoles[command]
+    if !ok {
+        return false
+    }
+    chat := message.Chat
+    var userID int64
+    if message.From != nil {
+        userID = message.From.ID
+    }
+    actor := telegramActor(userID)
+    role := a.Config.RoleOf(userID, a.Prefs)
+    if !roleAllows(role, need) {
+        commandsLog.Warn("command refused", "command", command, "chat_id", chat.ID, "user_id", userID, "role", role)
+        a.reply(ctx, chat.ID, command, a.Loc.T("commands.denied", command))
+        return true
+    }
+    commandsLog.Debug("running command", "command", command, "chat_id", chat.ID, "user_id", userID, "role", role)
+
+    loc := a.Loc
+    pref := a.Prefs.For(chat.ID, chat.Type)
+    var reply string
+    changed := false
+    switch command {
+    case "/scan":
+        links := extract.Links(strings.Join(fields[1:], " "))
+        if len(links) == 0 {
+            reply = loc.T("scan.usage")
+            break
+        }
+        reply = a.later(ctx, chat.ID, command, func() string { return a.scanCommand(ctx, logger, message, links[0]) })
+    case "/status":
+        reply = a.later(ctx, chat.ID, command, a.statusSummary)
+    case "/prefs":
+        reply = prefsSummary(pref, loc)
+    case "/mute":
+        pref.Muted, changed = true, true
+        reply = loc.T("prefs.muted")
+    case "/unmute":
+        pref.Muted, changed = false, true
+        reply = loc.T("prefs.unmuted")
+    case "/alerts":
+        if len(fields) < 2 {
+            reply = loc.T("prefs.usage")
+            break
+        }
+        severity, err := analysis.ParseSeverity(fields[1])
+        if err != nil {
+            reply = loc.T("prefs.usage")
+            break
+        }
+        pref.MinSeverity, changed = severity, true
+        reply = loc.T("prefs.min_severity", loc.T("severity."+severity.String()))
+    case "/warnings":
+        switch {
+        case len(fields) < 2:
+            reply = loc.T("warnings.usage")
+        case strings.EqualFold(fields[1], "on"):
+            pref.Warn, changed = true, true
+            reply = loc.T("prefs.warnings_on")
+        case strings.EqualFold(fields[1], "off"):
+            pref.Warn, changed = false, true
+            reply = loc.T("prefs.warnings_off")
+        default:
+            reply = loc.T("warnings.usage")
+        }
+    case "/language":
+        switch {
+        case len(fields) < 2:
+            reply = loc.T("language.usage")
+        case strings.EqualFold(fields[1], "auto"):
+            pref.Language, changed = "", true
+            reply = loc.T("prefs.language_auto")
+        case analysis.HasLexicon(strings.ToLower(fields[1])):
+            pref.Language, changed = strings.ToLower(fields[1]), true
+            reply = loc.T("prefs.language", pref.Language)
+        default:
+            reply = loc.T("language.usage")
+        }
+    case "/block", "/unblock":
+        if len(fields) < 2 {
+            reply = loc.T("block.usage")
+            break
+        }
+        domain, err := blockName(fields[1])
+        if err != nil {
+            reply = loc.T("block.invalid", fields[1])
+            break
+        }
+        reason := strings.Join(fields[2:], " ")
+        reply = a.later(ctx, chat.ID, command, func() string { return a.blockCommand(ctx, actor, command, domain, reason) })
+    case "/role":
+        reply = a.roleCommand(actor, fields)
+    }
+
+    if changed {
+        if err := a.Prefs.Set(chat.ID, pref); err != nil {
+            commandsLog.Error("failed to save chat preferences", "chat_id", chat.ID, "err", err)
+            reply = err.Error()
+        } else {
+            a.audit(actor, strings.TrimPrefix(command, "/"), "chat:"+strconv.FormatInt(chat.ID, 10), strings.Join(fields[1:], " "))
+        }
+    }
+    a.reply(ctx, chat.ID, command, reply)
+    return true
+}
+
+// later queues run for a worker, so a command that takes a while doesn't
+// hold up the updates behind it, and replies with what it returns. It
+// returns the reply to send now: nothing, or that the queue is full. It
+// never waits for room, whatever overflow is set to: commands run with
+// a.mu held, which a reload waiting for it would keep the workers from
+// taking, and the queue would never drain.
+func (a *App) later(ctx context.Context, chatID int64, command string, run func() string) string {
+    if a.queue.TrySubmit(func() { a.reply(ctx, chatID, command, run()) }) {
+        return ""
+    }
+    return a.Loc.T("commands.busy")
+}
+
+// reply sends the reply to a command, if there is one.
+func (a *App) reply(ctx context.Context, chatID int64, command, text string) {
+    if text == "" {
+        return
+    }
+    if err := telegram.SendMessage(ctx, a.Config.Telegram.Token, chatID, text, ""); err != nil {
+        commandsLog.Error("failed to reply to command", "command", command, "chat_id", chatID, "err", err)
+    }
+}
+
+// scanCommand scans link for /scan and returns the verdict. The scan is
+// recorded in the history, but not delivered: the reply is the alert.
+func (a *App) scanCommand(ctx context.Context, logger *slog.Logger, message *telegram.Message, link string) string {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    alert := a.messageAlert(link, message)
+    alert.ID = "scan-" + alert.ID
+    entry := store.HistoryEntry{MessageID: message.MessageID, Text: message.Text, Alert: alert}
+    entry = a.Process(ctx, logger.With("url", link), entry, false, false)
+
+    verdict := entry.Alert.Verdict
+    lines := []string{a.Loc.T("scan.verdict", notify.Defang(link), a.Loc.T("severity."+verdict.Severity.String()))}
+    if u, err := url.Parse(link); err == nil && extract.IsIDN(u.Hostname()) {
+        lines = append(lines, notify.Defang(extract.DisplayHost(u.Hostname())))
+    }
+    for _, f := range verdict.Findings {
+        lines = append(lines, "• "+f.Description)
+    }
+    return strings.Join(lines, "\n")
+}
+
+// statusSummary describes for /status whether the monitor is working.
+func (a *App) statusSummary() string {
+    s := a.ready()
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    line := a.Loc.T("status.ok")
+    if !s.OK {
+        line = a.Loc.T("status.problems", strings.Join(s.Problems, "; "))
+    }
+    return line + "\n" + a.Loc.T("status.queue", s.InFlight, s.Waiting)
+}
+
+// blockCommand blocks or unblocks domain for /block and /unblock.
+func (a *App) blockCommand(ctx context.Context, actor, command, domain, reason string) string {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    cfg := a.Config.Block
+    if command == "/block" {
+        if cfg.Mode == "" {
+            return a.Loc.T("block.off")
+        }
+        resolver, err := analysis.NewResolver(a.Config.Analyzers.DNS)
+        if err == nil {
+            err = BlockDomain(ctx, a.History, cfg, resolver, domain, "")
+        }
+        if err != nil {
+            return a.Loc.T("commands.failed", command, err)
+        }
+        commandsLog.Info("blocked domain", "domain", domain, "mode", cfg.Mode)
+        a.audit(actor, "block", domain, reason)
+        return a.Loc.T("block.done", domain)
+    }
+    b, ok, err := a.History.Block(domain)
+    if err == nil && !ok {
+        return a.Loc.T("block.not_blocked", domain)
+    }
+    if err == nil {
+        err = Unblock(a.History, cfg, b)
+    }
+    if err != nil {
+        return a.Loc.T("commands.failed", command, err)
+    }
+    commandsLog.Info("unblocked domain", "domain", domain)
+    a.audit(actor, "unblock", domain, reason)
+    return a.Loc.T("block.removed", domain)
+}
+
+// roleCommand grants a role for /role. Roles set in telegram.roles can't
+// be changed this way, and nobody can be made an owner.
+func (a *App) roleCommand(actor string, fields []string) string {
+    if len(fields) != 3 {
+        return a.Loc.T("role.usage")
+    }
+    userID, err := strconv.ParseInt(fields[1], 10, 64)
+    role := strings.ToLower(fields[2])
+    if err != nil || !validRole(role) || role == RoleOwner {
+        return a.Loc.T("role.usage")
+    }
+    if _, ok := a.Config.Telegram.Roles[userID]; ok {
+        return a.Loc.T("role.fixed", userID)
+    }
+    if err := a.Prefs.SetRole(userID, role); err != nil {
+        commandsLog.Error("failed to save role", "user_id", userID, "err", err)
+        return err.Error()
+    }
+    commandsLog.Info("role granted", "user_id", userID, "role", role)
+    a.audit(actor, "role:"+role, telegramActor(userID), "")
+    return a.Loc.T("role.set", userID, role)
+}
+
+func prefsSummary(pref store.ChatPreference, loc *i18n.Localizer) string {
+    state := loc.T("prefs.state_on")
+    if pref.Muted {
+        state = loc.T("prefs.state_muted")
+    }
+    warnings := loc.T("prefs.warnings_off")
+    if pref.Warn {
+        warnings = loc.T("prefs.warnings_on")
+    }
+    summary := fmt.Sprintf("%s\n%s\n%s", state, loc.T("prefs.min_severity", loc.T("severity."+pref.MinSeverity.String())), warnings)
+    if pref.Language != "" {
+        summary += "\n" + loc.T("prefs.language", pref.Language)
+    }
+    return summary
+}
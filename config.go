@@ -0,0 +1,1347 @@
+package telephish
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/url"
+    "os"
+    "runtime"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/keystore"
+    "github.com/hacker1337itme/telephish/logging"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/redact"
+)
+
+// DefaultConfigPath is read when --config is not given, if it exists.
+const DefaultConfigPath = "telephish.yaml"
+
+// Config is the full runtime configuration, read from a YAML file and then
+// overridden by environment variables.
+type Config struct {
+    Telegram   TelegramConfig      `yaml:"telegram"`
+    Locale     string              `yaml:"locale"`
+    Headless   bool                `yaml:"headless"`
+    Output     string              `yaml:"output"` // text, or jsonl for a notify.Result per alert on stdout
+    TUI        bool                `yaml:"-"`      // Set by run --tui, which draws the terminal
+    Keystore   bool                `yaml:"keystore"` // Read secrets left unset from the OS credential store
+    Templates  TemplatesConfig     `yaml:"templates"`
+    Analyzers  analysis.Config     `yaml:"analyzers"`
+    Thresholds analysis.Thresholds `yaml:"thresholds"`
+    Plugins    PluginsConfig       `yaml:"plugins"`
+    Reputation ReputationConfig    `yaml:"reputation"`
+    Workers    WorkersConfig       `yaml:"workers"`
+    Digest     DigestConfig        `yaml:"digest"`
+    Rescan     RescanConfig        `yaml:"rescan"`
+    Retention  RetentionConfig     `yaml:"retention"`
+    Reports    ReportsConfig       `yaml:"reports"`
+    Heartbeat  HeartbeatConfig     `yaml:"heartbeat"`
+    Dedup      DedupConfig         `yaml:"dedup"`
+    CatchUp    CatchUpConfig       `yaml:"catch_up"`
+    Block      BlockConfig         `yaml:"block"`
+    SafeOpen   SafeOpenConfig      `yaml:"safe_open"`
+    Clipboard  ClipboardConfig     `yaml:"clipboard"`
+    Warnings   WarningsConfig      `yaml:"warnings"`
+    Rules      []Rule              `yaml:"rules"`
+    Routes     []Route             `yaml:"routes"`
+    ChatPrefs  string              `yaml:"chat_prefs"`
+    History    string              `yaml:"history"`
+    State      string              `yaml:"state"`
+    Lists      string              `yaml:"lists"`
+    Webhook    WebhookServer       `yaml:"webhook"`
+    Admin      AdminServer         `yaml:"admin"`
+    GRPC       GRPCServer          `yaml:"grpc"`
+    Sinks      SinksConfig         `yaml:"sinks"`
+    Delivery   DeliveryConfig      `yaml:"delivery"`
+    Logging    logging.Config      `yaml:"logging"`
+    Redact     RedactConfig        `yaml:"redact"`
+    Debug      DebugConfig         `yaml:"debug"`
+    Update     UpdateConfig        `yaml:"update"`
+    Profiles   []ProfileConfig     `yaml:"profiles"`
+
+    // Profile names the profile this config was derived for, and is empty
+    // for the top-level config.
+    Profile string `yaml:"-"`
+
+    path     string
+    profiles []*Config // Derived from Profiles by LoadConfig
+}
+
+// TelegramConfig configures the bot connection.
+type TelegramConfig struct {
+    Token string  `yaml:"token"`
+    Chats []int64 `yaml:"chats"` // Only process these chats; empty means all
+    Proxy string  `yaml:"proxy"`
+
+    // Roles maps Telegram user IDs to owner, admin or viewer, which decide
+    // the bot commands they may use. DefaultRole is everyone else's; left
+    // empty it is admin without roles, as before they existed, and none
+    // with them.
+    Roles       map[int64]string `yaml:"roles"`
+    DefaultRole string           `yaml:"default_role"`
+}
+
+// WebhookServer configures receiving updates by Telegram webhook instead
+// of polling.
+type WebhookServer struct {
+    Listen string `yaml:"listen"` // Local address, e.g. :8443
+    URL    string `yaml:"url"`    // Public HTTPS URL registered with Telegram
+    Secret string `yaml:"secret"` // Checked against X-Telegram-Bot-Api-Secret-Token
+}
+
+// AdminServer configures the HTTP server for health checks and the
+// dashboard.
+type AdminServer struct {
+    Listen string `yaml:"listen"` // e.g. 127.0.0.1:9090; empty disables
+    Token  string `yaml:"token"`  // Enables the dashboard, which asks for it as the password
+}
+
+// GRPCServer configures the gRPC API. Calls authenticate with the admin
+// token.
+type GRPCServer struct {
+    Listen   string `yaml:"listen"` // e.g. 127.0.0.1:9443; empty disables
+    CertFile string `yaml:"cert_file"`
+    KeyFile  string `yaml:"key_file"`
+}
+
+// TemplatesConfig points at custom notification templates.
+type TemplatesConfig struct {
+    Toast    string `yaml:"toast"`
+    Telegram string `yaml:"telegram"`
+    Warning  string `yaml:"warning"`
+}
+
+// PluginsConfig locates external analyzer and sink executables.
+type PluginsConfig struct {
+    Dir     string        `yaml:"dir"`     // Empty disables plugins
+    Timeout time.Duration `yaml:"timeout"` // Per run
+}
+
+// WorkersConfig sizes the pool that scans links from Telegram updates.
+type WorkersConfig struct {
+    Count     int    `yaml:"count"`      // Scans run at once
+    PerDomain int    `yaml:"per_domain"` // Scans of one host run at once; 0 means no limit
+    Queue     int    `yaml:"queue"`      // Updates waiting for a worker
+    Overflow  string `yaml:"overflow"`   // block or drop, when the queue is full
+
+    // PerChat gives each chat its own workers and queue, sized as above
+    // unless Chats says otherwise, so a flood in one chat can't hold up
+    // the others.
+    PerChat bool                        `yaml:"per_chat"`
+    Chats   map[int64]ChatWorkersConfig `yaml:"chats"` // By chat ID, with per_chat
+}
+
+// ChatWorkersConfig overrides the workers settings for one chat's lane.
+// Zero values keep the workers ones.
+type ChatWorkersConfig struct {
+    Count    int    `yaml:"count"`
+    Queue    int    `yaml:"queue"`
+    Overflow string `yaml:"overflow"`
+}
+
+// forChat returns the settings of chatID's lane.
+func (w WorkersConfig) forChat(chatID int64) WorkersConfig {
+    c, ok := w.Chats[chatID]
+    if !ok {
+        return w
+    }
+    if c.Count > 0 {
+        w.Count = c.Count
+    }
+    if c.Queue > 0 {
+        w.Queue = c.Queue
+    }
+    if c.Overflow != "" {
+        w.Overflow = c.Overflow
+    }
+    return w
+}
+
+// DeliveryConfig configures the queue alerts for remote sinks wait in, in
+// the history database, until they are delivered or run out of attempts.
+type DeliveryConfig struct {
+    Queue      bool          `yaml:"queue"`       // False delivers inline, without retries
+    Attempts   int           `yaml:"attempts"`    // Before a delivery is dead-lettered
+    Backoff    time.Duration `yaml:"backoff"`     // After the first failed attempt, doubling after each
+    MaxBackoff time.Duration `yaml:"max_backoff"` // Cap on the wait between attempts
+    MaxPending int           `yaml:"max_pending"` // Deliveries one sink may have waiting before new alerts fail
+}
+
+// RedactConfig masks personal data in what the monitor writes out, for
+// environments with privacy requirements. The history itself keeps
+// everything, so alerts can still be investigated.
+type RedactConfig struct {
+    redact.Policy `yaml:",inline"`
+    Logs          bool `yaml:"logs"`    // Apply the policy to log records
+    Exports       bool `yaml:"exports"` // And to the export and query commands
+}
+
+// DebugConfig helps reproduce parsing and analyzer bugs from real traffic.
+type DebugConfig struct {
+    // CaptureDir, if set, receives every raw update and analyzer exchange,
+    // with secrets redacted; empty disables capture.
+    CaptureDir string `yaml:"capture_dir"`
+}
+
+// UpdateConfig says where `update` looks for releases and whose signature
+// it trusts.
+type UpdateConfig struct {
+    Repo      string `yaml:"repo"`       // GitHub owner/name publishing the releases
+    API       string `yaml:"api"`        // GitHub API base URL, for GitHub Enterprise
+    PublicKey string `yaml:"public_key"` // Base64 Ed25519 key, if not built in
+}
+
+// ReputationConfig shares reputation lookups, made by analyzer plugins,
+// between instances: one serves them from a cache over its admin API, and
+// the others ask it before their own plugins.
+type ReputationConfig struct {
+    CacheTTL  time.Duration `yaml:"cache_ttl"`  // How long a lookup is reused; 0 disables the cache
+    CacheSize int           `yaml:"cache_size"` // Links cached at most
+    Serve     bool          `yaml:"serve"`      // Answer other instances' lookups on the admin API
+    Server    string        `yaml:"server"`     // Admin URL of the instance to ask; empty looks up here
+    Token     string        `yaml:"token"`      // That instance's admin token
+    Timeout   time.Duration `yaml:"timeout"`    // Before falling back to the plugins here
+}
+
+// RescanConfig schedules scanning recent links that looked clean again.
+type RescanConfig struct {
+    Interval time.Duration `yaml:"interval"` // How often to rescan; 0 disables rescans
+    Days     int           `yaml:"days"`     // Rescan links received this many days back
+    Limit    int           `yaml:"limit"`    // Rescan at most this many links each time
+}
+
+// CatchUpConfig controls how the updates that arrived while the monitor
+// was stopped are handled when it starts.
+type CatchUpConfig struct {
+    MaxAge  time.Duration `yaml:"max_age"` // Skip messages older than this; 0 handles all Telegram still has
+    Summary bool          `yaml:"summary"` // Alert with a summary once caught up
+}
+
+// RetentionConfig limits how long alerts and the files they refer to are
+// kept. Zero days keeps them for good.
+type RetentionConfig struct {
+    AlertDays      int           `yaml:"alert_days"`      // Alerts, with their findings and deliveries
+    ScreenshotDays int           `yaml:"screenshot_days"` // Page screenshots, usually sooner than their alerts
+    CaptureDays    int           `yaml:"capture_days"`    // Files in debug.capture_dir
+    Interval       time.Duration `yaml:"interval"`        // How often the monitor prunes
+}
+
+// ReportsConfig schedules summary reports of the alert history.
+type ReportsConfig struct {
+    Schedule string   `yaml:"schedule"` // weekly or monthly; empty sends none
+    Format   string   `yaml:"format"`   // html or pdf
+    Sinks    []string `yaml:"sinks"`    // email and telegram
+    Chats    []int64  `yaml:"chats"`    // Telegram chats the report is sent to
+    Top      int      `yaml:"top"`      // Domains and chats listed
+}
+
+// HeartbeatConfig has the monitor say it is alive, so that when it stops
+// someone notices: an outside service expecting a ping every interval,
+// and a Telegram chat told when it stops working and, every
+// chat_interval, that it still is.
+type HeartbeatConfig struct {
+    URL          string        `yaml:"url"`           // Pinged every interval while the monitor is ready, e.g. a healthchecks.io check
+    FailURL      string        `yaml:"fail_url"`      // Pinged instead, with the problems, while it isn't; empty pings nothing
+    Interval     time.Duration `yaml:"interval"`      // How often health is checked and the URL pinged
+    Chat         int64         `yaml:"chat"`          // Told when the monitor stops and starts working; 0 for none
+    ChatInterval time.Duration `yaml:"chat_interval"` // How often the chat is told it is still working; 0 never
+}
+
+// DedupConfig keeps one message, or one link, from being alerted on twice,
+// across restarts too.
+type DedupConfig struct {
+    Messages time.Duration `yaml:"messages"` // How long handled messages are remembered; 0 doesn't
+    Links    time.Duration `yaml:"links"`    // A link repeated in a chat within this isn't alerted on again; 0 always is
+}
+
+// Enabled reports whether anything is ever pruned.
+func (r RetentionConfig) Enabled() bool {
+    return r.AlertDays > 0 || r.ScreenshotDays > 0 || r.CaptureDays > 0
+}
+
+// BlockConfig blocks the domains of malicious links on the monitored
+// machine itself.
+type BlockConfig struct {
+    Mode      string        `yaml:"mode"`       // hosts or firewall; empty disables
+    Expiry    time.Duration `yaml:"expiry"`     // How long a block lasts; 0 for ever
+    HostsFile string        `yaml:"hosts_file"` // Empty for the system's
+}
+
+// WarningsConfig sets which links the bot warns about in reply, in the
+// groups that have warnings on.
+type WarningsConfig struct {
+    Severity analysis.Severity `yaml:"severity"` // Warn at this verdict or worse
+}
+
+// hostsFile returns the hosts file blocks are written to.
+func (b BlockConfig) hostsFile() string {
+    if b.HostsFile != "" {
+        return b.HostsFile
+    }
+    return defaultHostsFile
+}
+
+// SafeOpenConfig configures the browser the toast's "Open browser" button
+// opens links in, with a throwaway profile.
+type SafeOpenConfig struct {
+    Browser string   `yaml:"browser"` // A Chromium-based browser; empty finds Edge or Chrome
+    Args    []string `yaml:"args"`    // Further command-line flags, e.g. --inprivate
+}
+
+// ClipboardConfig watches the clipboard for links, which covers chats the
+// bot can't see, such as secret chats.
+type ClipboardConfig struct {
+    Enabled  bool              `yaml:"enabled"`
+    Interval time.Duration     `yaml:"interval"` // How often the clipboard is checked for changes
+    Severity analysis.Severity `yaml:"severity"` // Alert at this verdict or worse; the rest are only recorded
+}
+
+// DigestConfig batches low-severity desktop alerts.
+type DigestConfig struct {
+    Minutes  int               `yaml:"minutes"` // 0 disables digests
+    Severity analysis.Severity `yaml:"severity"`
+}
+
+// SinksConfig configures the optional alert sinks. A sink is enabled by
+// setting its address, webhook or token.
+type SinksConfig struct {
+    Email    EmailConfig    `yaml:"email"`
+    Slack    WebhookConfig  `yaml:"slack"`
+    Discord  WebhookConfig  `yaml:"discord"`
+    Teams    WebhookConfig  `yaml:"teams"`
+    Ntfy     NtfyConfig     `yaml:"ntfy"`
+    Pushover PushoverConfig `yaml:"pushover"`
+    Gotify   GotifyConfig   `yaml:"gotify"`
+    Syslog   SyslogConfig   `yaml:"syslog"`
+    Kafka    KafkaConfig    `yaml:"kafka"`
+    NATS     NATSConfig     `yaml:"nats"`
+    Defender DefenderConfig `yaml:"defender"`
+    TheHive  TheHiveConfig  `yaml:"thehive"`
+    Cortex   CortexConfig   `yaml:"cortex"`
+    Tickets  TicketsConfig  `yaml:"tickets"`
+    Abuse    AbuseConfig    `yaml:"abuse"`
+
+    // Webhooks are generic outbound webhooks, each a sink under its
+    // own name.
+    Webhooks []OutboundWebhookConfig `yaml:"webhooks"`
+}
+
+// EmailConfig configures the SMTP sink.
+type EmailConfig struct {
+    Addr     string   `yaml:"addr"`
+    Username string   `yaml:"username"`
+    Password string   `yaml:"password"`
+    From     string   `yaml:"from"`
+    To       []string `yaml:"to"`
+}
+
+// WebhookConfig configures a chat webhook sink.
+type WebhookConfig struct {
+    Webhook string `yaml:"webhook"`
+}
+
+// NtfyConfig configures the ntfy sink.
+type NtfyConfig struct {
+    URL   string `yaml:"url"`
+    Token string `yaml:"token"`
+}
+
+// PushoverConfig configures the Pushover sink.
+type PushoverConfig struct {
+    Token string `yaml:"token"`
+    User  string `yaml:"user"`
+}
+
+// GotifyConfig configures the Gotify sink.
+type GotifyConfig struct {
+    URL   string `yaml:"url"`
+    Token string `yaml:"token"`
+}
+
+// SyslogConfig configures the syslog sink.
+type SyslogConfig struct {
+    Addr     string `yaml:"addr"`     // host:port of the collector; empty disables
+    Network  string `yaml:"network"`  // udp, tcp or tls
+    Facility string `yaml:"facility"` // e.g. user, auth or local0
+    CAFile   string `yaml:"ca_file"`  // CA certificates for tls, instead of the system's
+    Format   string `yaml:"format"`   // rfc5424, cef or leef
+}
+
+// KafkaConfig configures the Kafka sink.
+type KafkaConfig struct {
+    Brokers  []string `yaml:"brokers"` // host:port of the bootstrap brokers; empty disables
+    Topic    string   `yaml:"topic"`
+    Key      string   `yaml:"key"`      // chat, sender, host, profile, id or none
+    Delivery string   `yaml:"delivery"` // at-least-once or at-most-once
+    TLS      bool     `yaml:"tls"`
+    SASL     string   `yaml:"sasl"` // plain, scram-sha-256 or scram-sha-512; empty for none
+    Username string   `yaml:"username"`
+    Password string   `yaml:"password"`
+}
+
+// NATSConfig configures the NATS sink.
+type NATSConfig struct {
+    URL       string `yaml:"url"` // e.g. nats://nats.example.com:4222; empty disables
+    Subject   string `yaml:"subject"`
+    Key       string `yaml:"key"`      // Appended to the subject: chat, sender, host, profile, id or none
+    Delivery  string `yaml:"delivery"` // at-most-once, or at-least-once through JetStream
+    Token     string `yaml:"token"`
+    CredsFile string `yaml:"creds_file"`
+}
+
+// DefenderConfig configures the Microsoft Defender sink, which submits
+// malicious links to Microsoft. It signs in as an Entra ID app
+// registration granted ThreatSubmission.ReadWrite.All on Microsoft Graph,
+// and for indicators Ti.ReadWrite.All on WindowsDefenderATP.
+type DefenderConfig struct {
+    TenantID     string        `yaml:"tenant_id"` // Empty disables
+    ClientID     string        `yaml:"client_id"`
+    ClientSecret string        `yaml:"client_secret"`
+    Category     string        `yaml:"category"`   // phishing or malware
+    Indicators   bool          `yaml:"indicators"` // Also block the link across the tenant in Defender for Endpoint
+    Expiry       time.Duration `yaml:"expiry"`     // How long indicators last; 0 for ever
+}
+
+// TheHiveConfig configures the TheHive sink, which opens an alert for
+// malicious links. The API key's user needs the right to create alerts.
+type TheHiveConfig struct {
+    URL          string   `yaml:"url"` // Empty disables
+    APIKey       string   `yaml:"api_key"`
+    Organisation string   `yaml:"organisation"` // Default the key user's organisation
+    TLP          int      `yaml:"tlp"`          // 0 clear to 4 red
+    PAP          int      `yaml:"pap"`          // 0 clear to 3 red
+    Tags         []string `yaml:"tags"`
+}
+
+// CortexConfig configures the Cortex sink, which runs analyzers on
+// malicious links.
+type CortexConfig struct {
+    URL       string   `yaml:"url"` // Empty disables
+    APIKey    string   `yaml:"api_key"`
+    Analyzers []string `yaml:"analyzers"` // Names of enabled analyzers taking URLs
+    TLP       int      `yaml:"tlp"`
+}
+
+// TicketsConfig configures the tickets sink, which opens an issue in Jira
+// or another tracker for each bad link, and comments on it when the link
+// is seen again.
+type TicketsConfig struct {
+    Tracker  string            `yaml:"tracker"`  // jira or webhook; empty disables
+    Severity analysis.Severity `yaml:"severity"` // Open tickets at this verdict or worse
+    Dedup    string            `yaml:"dedup"`    // One ticket per domain or url
+    Window   time.Duration     `yaml:"window"`   // How long sightings are added to the same ticket
+    Labels   []string          `yaml:"labels"`
+    Jira     JiraConfig        `yaml:"jira"`
+    Webhook  TrackerHookConfig `yaml:"webhook"`
+}
+
+// JiraConfig configures the Jira Cloud tracker. The token is an Atlassian
+// API token of the account with email.
+type JiraConfig struct {
+    URL       string `yaml:"url"` // e.g. https://example.atlassian.net
+    Email     string `yaml:"email"`
+    Token     string `yaml:"token"`
+    Project   string `yaml:"project"`    // Project key
+    IssueType string `yaml:"issue_type"` // Default Task
+    Priority  string `yaml:"priority"`
+}
+
+// TrackerHookConfig configures a tracker reached through its HTTP API.
+type TrackerHookConfig struct {
+    CreateURL  string            `yaml:"create_url"`
+    CommentURL string            `yaml:"comment_url"` // {issue} is replaced by the issue's key
+    Headers    map[string]string `yaml:"headers"`
+    KeyField   string            `yaml:"key_field"` // Response field holding the new issue's key; default id
+}
+
+// AbuseConfig configures the abuse sink, which reports malicious links to
+// the abuse contacts of their registrar and hosting network, found over
+// RDAP, and optionally to Google Safe Browsing.
+type AbuseConfig struct {
+    Enabled  bool          `yaml:"enabled"`
+    Send     bool          `yaml:"send"`     // Email reports through sinks.email's server; otherwise only write drafts
+    Drafts   string        `yaml:"drafts"`   // Directory reports are written to as .eml files; empty for none
+    From     string        `yaml:"from"`     // Reply-To of reports; default sinks.email.from
+    CC       []string      `yaml:"cc"`       // Also sent each report
+    Template string        `yaml:"template"` // File with a text/template for the report's body
+    Interval time.Duration `yaml:"interval"` // Report a domain at most this often
+    RDAP     string        `yaml:"rdap"`     // RDAP server or redirector
+    WebRisk  WebRiskConfig `yaml:"web_risk"`
+}
+
+// WebRiskConfig configures submissions to Google Safe Browsing through
+// the Web Risk Submission API.
+type WebRiskConfig struct {
+    Project     string `yaml:"project"`     // Google Cloud project; empty disables
+    Credentials string `yaml:"credentials"` // Service account key file
+}
+
+// OutboundWebhookConfig configures a generic outbound webhook sink.
+type OutboundWebhookConfig struct {
+    Name     string            `yaml:"name"` // The sink's name in routes
+    URL      string            `yaml:"url"`
+    Template string            `yaml:"template"` // JSON body template; empty posts the bus payload
+    Headers  map[string]string `yaml:"headers"`
+    Secret   string            `yaml:"secret"`  // HMAC-SHA256 key signing each request
+    Retries  int               `yaml:"retries"` // Further attempts after a failed delivery
+    Backoff  time.Duration     `yaml:"backoff"` // Before the first retry, doubling after each; default 1s
+}
+
+// DefaultConfig returns the configuration used for anything the file and
+// environment leave unset.
+func DefaultConfig() Config {
+    return Config{
+        Locale:     i18n.DefaultLocale,
+        Output:     "text",
+        Keystore:   true,
+        Analyzers:  analysis.Config{Enabled: []string{"url", "text", "page"}, PageTimeout: 15 * time.Second, ScanTimeout: time.Minute, MaxRedirects: 5, MaxPageKB: 1024, Browser: "chrome-windows",
+            AllowTypes: append([]string(nil), analysis.DefaultAllowTypes...), MaxFetches: 8, FetchQuotaMB: 64,
+            Breaker: analysis.BreakerConfig{Retries: 1, Backoff: time.Second, Failures: 5, Cooldown: 5 * time.Minute}},
+        Thresholds: analysis.Thresholds{MaliciousCount: 3},
+        Plugins:    PluginsConfig{Timeout: 30 * time.Second},
+        Reputation: ReputationConfig{CacheTTL: 6 * time.Hour, CacheSize: 10000, Timeout: 30 * time.Second},
+        Workers:    WorkersConfig{Count: 4, PerDomain: 2, Queue: 100, Overflow: OverflowBlock},
+        Delivery:   DeliveryConfig{Queue: true, Attempts: 10, Backoff: 30 * time.Second, MaxBackoff: 30 * time.Minute, MaxPending: 10000},
+        Redact:     RedactConfig{Logs: true, Exports: true},
+        Digest:     DigestConfig{Severity: analysis.SeveritySuspicious},
+        Rescan:     RescanConfig{Days: 3, Limit: 100},
+        Retention:  RetentionConfig{Interval: time.Hour},
+        Reports:    ReportsConfig{Format: "html", Sinks: []string{"email"}, Top: 10},
+        Dedup:      DedupConfig{Messages: 7 * 24 * time.Hour, Links: time.Hour},
+        Heartbeat:  HeartbeatConfig{Interval: 5 * time.Minute, ChatInterval: 24 * time.Hour},
+        CatchUp:    CatchUpConfig{Summary: true},
+        Block:      BlockConfig{Expiry: 7 * 24 * time.Hour},
+        Clipboard:  ClipboardConfig{Interval: 500 * time.Millisecond, Severity: analysis.SeveritySuspicious},
+        Warnings:   WarningsConfig{Severity: analysis.SeveritySuspicious},
+        Update:     UpdateConfig{Repo: "hacker1337itme/telephish", API: "https://api.github.com"},
+        ChatPrefs:  "telephish-chats.json",
+        History:    "telephish-history.db",
+        State:      "telephish-state.json",
+        Lists:      "telephish-lists.json",
+        Webhook:    WebhookServer{Listen: ":8443"},
+        Logging:    logging.Config{MaxSizeMB: 100, RotateInterval: 24 * time.Hour, MaxAgeDays: 30, MaxBackups: 10, Compress: true},
+        Sinks: SinksConfig{
+            Syslog:  SyslogConfig{Network: "udp", Facility: "user", Format: notify.FormatRFC5424},
+            Kafka:   KafkaConfig{Topic: "telephish.alerts", Key: "chat", Delivery: notify.AtLeastOnce},
+            NATS:    NATSConfig{Subject: "telephish.alerts", Key: "none", Delivery: notify.AtMostOnce},
+            TheHive: TheHiveConfig{TLP: 2, PAP: 2},
+            Cortex:  CortexConfig{TLP: 2},
+            Abuse:   AbuseConfig{Drafts: "abuse-reports", Interval: 7 * 24 * time.Hour, RDAP: notify.DefaultRDAPServer},
+            Tickets: TicketsConfig{Severity: analysis.SeverityMalicious, Dedup: notify.DedupDomain, Window: 30 * 24 * time.Hour},
+        },
+    }
+}
+
+// LoadConfig reads the config file at path, applies environment overrides
+// and validates the result. An empty path reads DefaultConfigPath if it
+// exists, and otherwise configures from the environment alone.
+func LoadConfig(path string) (*Config, error) {
+    cfg := DefaultConfig()
+    if path == "" {
+        if _, err := os.Stat(DefaultConfigPath); err == nil {
+            path = DefaultConfigPath
+        }
+    }
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read config: %v", err)
+        }
+        dec := yaml.NewDecoder(bytes.NewReader(data))
+        dec.KnownFields(true)
+        if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+            return nil, fmt.Errorf("config %s: %v", path, err)
+        }
+        cfg.path = path
+    }
+
+    if err := cfg.applyEnv(); err != nil {
+        return nil, err
+    }
+    if err := cfg.applyKeystore(); err != nil {
+        return nil, err
+    }
+    if cfg.Redact.Logs {
+        cfg.Logging.Redact = cfg.Redact.Policy
+    }
+    cfg.applyOutput()
+    if err := cfg.resolveProfiles(); err != nil {
+        return nil, err
+    }
+    if err := cfg.Validate(); err != nil {
+        return nil, err
+    }
+    return &cfg, nil
+}
+
+// applyOutput turns toasts off when results or the terminal UI go to
+// stdout, which leaves no room for anything else there.
+func (c *Config) applyOutput() {
+    if c.Output == "jsonl" || c.TUI {
+        c.Headless = true
+    }
+}
+
+// applyEnv overrides settings from TELEGRAM_BOT_TOKEN and TELEPHISH_*
+// environment variables.
+func (c *Config) applyEnv() error {
+    str := func(name string, dst *string) {
+        if v, ok := os.LookupEnv(name); ok {
+            *dst = v
+        }
+    }
+    str("TELEGRAM_BOT_TOKEN", &c.Telegram.Token)
+    str("TELEPHISH_PROXY", &c.Telegram.Proxy)
+    str("TELEPHISH_LOCALE", &c.Locale)
+    str("TELEPHISH_TOAST_TEMPLATE", &c.Templates.Toast)
+    str("TELEPHISH_TELEGRAM_TEMPLATE", &c.Templates.Telegram)
+    str("TELEPHISH_WARNING_TEMPLATE", &c.Templates.Warning)
+    str("TELEPHISH_FETCH_PROXY", &c.Analyzers.Proxy)
+    str("TELEPHISH_FETCH_BROWSER", &c.Analyzers.Browser)
+    str("TELEPHISH_FETCH_USER_AGENT", &c.Analyzers.UserAgent)
+    str("TELEPHISH_FETCH_DNS", &c.Analyzers.DNS.Server)
+    str("TELEPHISH_OUTPUT", &c.Output)
+    str("TELEPHISH_PLUGINS", &c.Plugins.Dir)
+    str("TELEPHISH_REPUTATION_SERVER", &c.Reputation.Server)
+    str("TELEPHISH_REPUTATION_TOKEN", &c.Reputation.Token)
+    str("TELEPHISH_REPORT_SCHEDULE", &c.Reports.Schedule)
+    str("TELEPHISH_REPORT_FORMAT", &c.Reports.Format)
+    str("TELEPHISH_HEARTBEAT_URL", &c.Heartbeat.URL)
+    str("TELEPHISH_HEARTBEAT_FAIL_URL", &c.Heartbeat.FailURL)
+    str("TELEPHISH_CHAT_PREFS", &c.ChatPrefs)
+    str("TELEPHISH_HISTORY", &c.History)
+    str("TELEPHISH_STATE", &c.State)
+    str("TELEPHISH_LISTS", &c.Lists)
+    str("TELEPHISH_WEBHOOK_LISTEN", &c.Webhook.Listen)
+    str("TELEPHISH_WEBHOOK_URL", &c.Webhook.URL)
+    str("TELEPHISH_WEBHOOK_SECRET", &c.Webhook.Secret)
+    str("TELEPHISH_ADMIN_LISTEN", &c.Admin.Listen)
+    str("TELEPHISH_ADMIN_TOKEN", &c.Admin.Token)
+    str("TELEPHISH_GRPC_LISTEN", &c.GRPC.Listen)
+    str("TELEPHISH_GRPC_CERT", &c.GRPC.CertFile)
+    str("TELEPHISH_GRPC_KEY", &c.GRPC.KeyFile)
+    str("TELEPHISH_SMTP_ADDR", &c.Sinks.Email.Addr)
+    str("TELEPHISH_SMTP_USER", &c.Sinks.Email.Username)
+    str("TELEPHISH_SMTP_PASSWORD", &c.Sinks.Email.Password)
+    str("TELEPHISH_SMTP_FROM", &c.Sinks.Email.From)
+    str("TELEPHISH_SLACK_WEBHOOK", &c.Sinks.Slack.Webhook)
+    str("TELEPHISH_DISCORD_WEBHOOK", &c.Sinks.Discord.Webhook)
+    str("TELEPHISH_TEAMS_WEBHOOK", &c.Sinks.Teams.Webhook)
+    str("TELEPHISH_NTFY_URL", &c.Sinks.Ntfy.URL)
+    str("TELEPHISH_NTFY_TOKEN", &c.Sinks.Ntfy.Token)
+    str("TELEPHISH_PUSHOVER_TOKEN", &c.Sinks.Pushover.Token)
+    str("TELEPHISH_PUSHOVER_USER", &c.Sinks.Pushover.User)
+    str("TELEPHISH_GOTIFY_URL", &c.Sinks.Gotify.URL)
+    str("TELEPHISH_GOTIFY_TOKEN", &c.Sinks.Gotify.Token)
+    str("TELEPHISH_SYSLOG_ADDR", &c.Sinks.Syslog.Addr)
+    str("TELEPHISH_SYSLOG_NETWORK", &c.Sinks.Syslog.Network)
+    str("TELEPHISH_SYSLOG_FACILITY", &c.Sinks.Syslog.Facility)
+    str("TELEPHISH_SYSLOG_FORMAT", &c.Sinks.Syslog.Format)
+    str("TELEPHISH_KAFKA_TOPIC", &c.Sinks.Kafka.Topic)
+    str("TELEPHISH_KAFKA_PASSWORD", &c.Sinks.Kafka.Password)
+    str("TELEPHISH_NATS_URL", &c.Sinks.NATS.URL)
+    str("TELEPHISH_NATS_SUBJECT", &c.Sinks.NATS.Subject)
+    str("TELEPHISH_NATS_TOKEN", &c.Sinks.NATS.Token)
+    str("TELEPHISH_DEFENDER_TENANT_ID", &c.Sinks.Defender.TenantID)
+    str("TELEPHISH_DEFENDER_CLIENT_ID", &c.Sinks.Defender.ClientID)
+    str("TELEPHISH_DEFENDER_CLIENT_SECRET", &c.Sinks.Defender.ClientSecret)
+    str("TELEPHISH_THEHIVE_URL", &c.Sinks.TheHive.URL)
+    str("TELEPHISH_THEHIVE_API_KEY", &c.Sinks.TheHive.APIKey)
+    str("TELEPHISH_CORTEX_URL", &c.Sinks.Cortex.URL)
+    str("TELEPHISH_CORTEX_API_KEY", &c.Sinks.Cortex.APIKey)
+    str("TELEPHISH_TICKETS_TRACKER", &c.Sinks.Tickets.Tracker)
+    str("TELEPHISH_JIRA_URL", &c.Sinks.Tickets.Jira.URL)
+    str("TELEPHISH_JIRA_EMAIL", &c.Sinks.Tickets.Jira.Email)
+    str("TELEPHISH_JIRA_TOKEN", &c.Sinks.Tickets.Jira.Token)
+    str("TELEPHISH_JIRA_PROJECT", &c.Sinks.Tickets.Jira.Project)
+    str("TELEPHISH_WEBRISK_PROJECT", &c.Sinks.Abuse.WebRisk.Project)
+    str("TELEPHISH_WEBRISK_CREDENTIALS", &c.Sinks.Abuse.WebRisk.Credentials)
+    str("TELEPHISH_LOG_FORMAT", &c.Logging.Format)
+    str("TELEPHISH_LOG_FILE", &c.Logging.File)
+    str("TELEPHISH_CAPTURE_DIR", &c.Debug.CaptureDir)
+
+    if v, ok := os.LookupEnv("TELEPHISH_SMTP_TO"); ok {
+        c.Sinks.Email.To = splitList(v)
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_KAFKA_BROKERS"); ok {
+        c.Sinks.Kafka.Brokers = splitList(v)
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_ANALYZERS"); ok {
+        c.Analyzers.Enabled = splitList(v)
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_HEADLESS"); ok {
+        c.Headless = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_KEYSTORE"); ok {
+        c.Keystore = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_CLIPBOARD"); ok {
+        c.Clipboard.Enabled = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_ABUSE_SEND"); ok {
+        c.Sinks.Abuse.Send = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_REPUTATION_SERVE"); ok {
+        c.Reputation.Serve = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_DELIVERY_QUEUE"); ok {
+        c.Delivery.Queue = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_CHATS"); ok {
+        c.Telegram.Chats = nil
+        for _, s := range splitList(v) {
+            id, err := strconv.ParseInt(s, 10, 64)
+            if err != nil {
+                return fmt.Errorf("TELEPHISH_CHATS: invalid chat id %q", s)
+            }
+            c.Telegram.Chats = append(c.Telegram.Chats, id)
+        }
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_HEARTBEAT_CHAT"); ok {
+        id, err := strconv.ParseInt(v, 10, 64)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_HEARTBEAT_CHAT: invalid chat id %q", v)
+        }
+        c.Heartbeat.Chat = id
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_ROLES"); ok {
+        c.Telegram.Roles = map[int64]string{}
+        for _, s := range splitList(v) {
+            user, role, _ := strings.Cut(s, ":")
+            id, err := strconv.ParseInt(strings.TrimSpace(user), 10, 64)
+            if err != nil {
+                return fmt.Errorf("TELEPHISH_ROLES: want user:role pairs, got %q", s)
+            }
+            c.Telegram.Roles[id] = strings.TrimSpace(role)
+        }
+    }
+    str("TELEPHISH_DEFAULT_ROLE", &c.Telegram.DefaultRole)
+    num := func(name string, dst *int) error {
+        if v, ok := os.LookupEnv(name); ok {
+            n, err := strconv.Atoi(v)
+            if err != nil {
+                return fmt.Errorf("%s: want a number, got %q", name, v)
+            }
+            *dst = n
+        }
+        return nil
+    }
+    if err := num("TELEPHISH_WORKERS", &c.Workers.Count); err != nil {
+        return err
+    }
+    if err := num("TELEPHISH_WORKERS_PER_DOMAIN", &c.Workers.PerDomain); err != nil {
+        return err
+    }
+    if err := num("TELEPHISH_QUEUE_SIZE", &c.Workers.Queue); err != nil {
+        return err
+    }
+    str("TELEPHISH_QUEUE_OVERFLOW", &c.Workers.Overflow)
+    if v, ok := os.LookupEnv("TELEPHISH_WORKERS_PER_CHAT"); ok {
+        c.Workers.PerChat = v != "" && v != "0" && !strings.EqualFold(v, "false")
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_DIGEST_MINUTES"); ok {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_DIGEST_MINUTES: want a number of minutes, got %q", v)
+        }
+        c.Digest.Minutes = n
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_CATCHUP_MAX_AGE"); ok {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_CATCHUP_MAX_AGE: want a duration such as 12h, got %q", v)
+        }
+        c.CatchUp.MaxAge = d
+    }
+    str("TELEPHISH_BLOCK_MODE", &c.Block.Mode)
+    str("TELEPHISH_SAFE_OPEN_BROWSER", &c.SafeOpen.Browser)
+    if v, ok := os.LookupEnv("TELEPHISH_RETENTION_DAYS"); ok {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_RETENTION_DAYS: want a number of days, got %q", v)
+        }
+        c.Retention.AlertDays = n
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_DIGEST_SEVERITY"); ok {
+        if err := c.Digest.Severity.UnmarshalText([]byte(v)); err != nil {
+            return fmt.Errorf("TELEPHISH_DIGEST_SEVERITY: %v", err)
+        }
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_RESCAN_INTERVAL"); ok {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_RESCAN_INTERVAL: want a duration such as 6h, got %q", v)
+        }
+        c.Rescan.Interval = d
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_LOG_LEVEL"); ok {
+        if err := c.Logging.Level.UnmarshalText([]byte(v)); err != nil {
+            return fmt.Errorf("TELEPHISH_LOG_LEVEL: %v", err)
+        }
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_LOG_LEVELS"); ok {
+        levels, err := logging.ParseLevels(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_LOG_LEVELS: %v", err)
+        }
+        c.Logging.Levels = levels
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_REDACT"); ok {
+        p, err := redact.Parse(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_REDACT: %v", err)
+        }
+        c.Redact.Policy = p
+    }
+    if v, ok := os.LookupEnv("TELEPHISH_ROUTES"); ok {
+        routes, err := ParseRoutes(v)
+        if err != nil {
+            return fmt.Errorf("TELEPHISH_ROUTES: %v", err)
+        }
+        c.Routes = routes
+    }
+    return nil
+}
+
+// Validate reports every invalid setting at once, including those of each
+// profile.
+func (c *Config) Validate() error {
+    problems := c.problems()
+    problems = append(problems, c.profileProblems(problems)...)
+    if len(problems) == 0 {
+        return nil
+    }
+    source := "configuration"
+    if c.path != "" {
+        source = "config " + c.path
+    }
+    return errors.New(source + " is invalid:\n  " + strings.Join(problems, "\n  "))
+}
+
+// problems lists the invalid settings of c, leaving out its profiles.
+func (c *Config) problems() []string {
+    var problems []string
+    bad := func(format string, args ...interface{}) {
+        problems = append(problems, fmt.Sprintf(format, args...))
+    }
+
+    if _, err := i18n.NewLocalizer(c.Locale); err != nil {
+        bad("locale: %v", err)
+    }
+    if c.Output != "text" && c.Output != "jsonl" {
+        bad("output: want text or jsonl, got %q", c.Output)
+    }
+    checkURL := func(field, value string) {
+        if value == "" {
+            return
+        }
+        if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+            bad("%s: %q is not an absolute URL", field, value)
+        }
+    }
+    checkURL("telegram.proxy", c.Telegram.Proxy)
+    checkURL("webhook.url", c.Webhook.URL)
+    checkURL("analyzers.proxy", c.Analyzers.Proxy)
+
+    known := map[string]bool{}
+    for _, name := range analysis.AnalyzerNames {
+        known[name] = true
+    }
+    for _, name := range c.Analyzers.Enabled {
+        if !known[name] {
+            bad("analyzers.enabled: unknown analyzer %q (available: %s)", name, strings.Join(analysis.AnalyzerNames, ", "))
+        }
+    }
+    if _, err := c.Analyzers.FetchHeaders(); err != nil {
+        bad("%v", err)
+    }
+    if _, err := analysis.NewResolver(c.Analyzers.DNS); err != nil {
+        bad("%v", err)
+    }
+    if b := c.Analyzers.Breaker; b.Retries < 0 || b.Failures < 0 || b.Backoff < 0 || b.Cooldown < 0 {
+        bad("analyzers.breaker: retries, failures, backoff and cooldown must not be negative")
+    }
+    if c.Analyzers.PageTimeout <= 0 {
+        bad("analyzers.page_timeout: must be positive, got %s", c.Analyzers.PageTimeout)
+    }
+    if c.Plugins.Timeout < 0 {
+        bad("plugins.timeout: must not be negative, got %s", c.Plugins.Timeout)
+    }
+    if d := c.Delivery; d.Queue && (d.Attempts < 1 || d.MaxPending < 1) {
+        bad("delivery: attempts and max_pending must be at least 1")
+    }
+    if d := c.Delivery; d.Queue && (d.Backoff <= 0 || d.MaxBackoff < d.Backoff) {
+        bad("delivery: backoff must be positive and max_backoff at least backoff")
+    }
+    if c.Workers.Count < 1 {
+        bad("workers.count: must be at least 1, got %d", c.Workers.Count)
+    }
+    if c.Workers.PerDomain < 0 {
+        bad("workers.per_domain: must not be negative, got %d", c.Workers.PerDomain)
+    }
+    if c.Workers.Queue < 0 {
+        bad("workers.queue: must not be negative, got %d", c.Workers.Queue)
+    }
+    for id, role := range c.Telegram.Roles {
+        if !validRole(role) || role == RoleNone {
+            bad("telegram.roles.%d: want owner, admin or viewer, got %q", id, role)
+        }
+    }
+    if r := c.Telegram.DefaultRole; r != "" && (!validRole(r) || r == RoleOwner) {
+        bad("telegram.default_role: want admin, viewer or none, got %q", r)
+    }
+    if o := c.Workers.Overflow; o != OverflowBlock && o != OverflowDrop {
+        bad("workers.overflow: want %s or %s, got %q", OverflowBlock, OverflowDrop, o)
+    }
+    for id, w := range c.Workers.Chats {
+        if w.Count < 0 || w.Queue < 0 {
+            bad("workers.chats.%d: count and queue must not be negative", id)
+        }
+        if o := w.Overflow; o != "" && o != OverflowBlock && o != OverflowDrop {
+            bad("workers.chats.%d.overflow: want %s or %s, got %q", id, OverflowBlock, OverflowDrop, o)
+        }
+    }
+    if len(c.Workers.Chats) > 0 && !c.Workers.PerChat {
+        bad("workers.chats: only applies with workers.per_chat")
+    }
+    if c.Analyzers.ScanTimeout <= 0 {
+        bad("analyzers.scan_timeout: must be positive, got %s", c.Analyzers.ScanTimeout)
+    }
+    if c.Analyzers.MaxRedirects < 0 {
+        bad("analyzers.max_redirects: must not be negative, got %d", c.Analyzers.MaxRedirects)
+    }
+    if c.Analyzers.MaxPageKB < 1 {
+        bad("analyzers.max_page_kb: must be at least 1, got %d", c.Analyzers.MaxPageKB)
+    }
+    if c.Analyzers.MaxFetches < 0 {
+        bad("analyzers.max_fetches: must not be negative, got %d", c.Analyzers.MaxFetches)
+    }
+    if q := c.Analyzers.FetchQuotaMB; q < 0 || (q > 0 && q<<10 < c.Analyzers.MaxPageKB) {
+        bad("analyzers.fetch_quota_mb: must be 0 or hold at least one page of max_page_kb, got %d", q)
+    }
+    for _, t := range c.Analyzers.AllowTypes {
+        if _, _, err := mime.ParseMediaType(t); err != nil || !strings.Contains(t, "/") {
+            bad("analyzers.allow_types: %q is not a type such as text/html or text/*", t)
+        }
+    }
+    if c.Thresholds.MaliciousCount < 1 {
+        bad("thresholds.malicious_count: must be at least 1, got %d", c.Thresholds.MaliciousCount)
+    }
+    if c.Digest.Minutes < 0 {
+        bad("digest.minutes: must not be negative, got %d", c.Digest.Minutes)
+    }
+    if c.CatchUp.MaxAge < 0 {
+        bad("catch_up.max_age: must not be negative, got %s", c.CatchUp.MaxAge)
+    }
+    if r := c.Retention; r.AlertDays < 0 || r.ScreenshotDays < 0 || r.CaptureDays < 0 {
+        bad("retention: alert_days, screenshot_days and capture_days must not be negative")
+    }
+    if c.Retention.Enabled() && c.Retention.Interval <= 0 {
+        bad("retention.interval: must be positive, got %s", c.Retention.Interval)
+    }
+    switch c.Block.Mode {
+    case "", BlockHosts:
+    case BlockFirewall:
+        if runtime.GOOS != "windows" {
+            bad("block.mode: firewall is only supported on Windows; use hosts")
+        }
+    default:
+        bad("block.mode: want hosts or firewall, got %q", c.Block.Mode)
+    }
+    if c.Block.Mode != "" && c.History == "" {
+        bad("block.mode: history is required to keep track of blocks")
+    }
+    if c.Clipboard.Enabled {
+        if runtime.GOOS != "windows" {
+            bad("clipboard.enabled: clipboard monitoring is only supported on Windows")
+        }
+        if c.Clipboard.Interval <= 0 {
+            bad("clipboard.interval: must be positive, got %s", c.Clipboard.Interval)
+        }
+    }
+    if c.Warnings.Severity == analysis.SeverityClean {
+        bad("warnings.severity: must be info or worse, not clean")
+    }
+    if c.Block.Expiry < 0 {
+        bad("block.expiry: must not be negative, got %s", c.Block.Expiry)
+    }
+    switch c.Reports.Schedule {
+    case "", "weekly", "monthly":
+    default:
+        bad("reports.schedule: want weekly or monthly, got %q", c.Reports.Schedule)
+    }
+    if f := c.Reports.Format; f != "html" && f != "pdf" {
+        bad("reports.format: want html or pdf, got %q", f)
+    }
+    if c.Reports.Top < 1 {
+        bad("reports.top: must be at least 1, got %d", c.Reports.Top)
+    }
+    if c.Reports.Schedule != "" {
+        if c.History == "" {
+            bad("reports.schedule: history is required to report on")
+        }
+        if len(c.Reports.Sinks) == 0 {
+            bad("reports.sinks: name email or telegram to send reports to")
+        }
+    }
+    for _, sink := range c.Reports.Sinks {
+        switch sink {
+        case "email":
+            if c.Reports.Schedule != "" && c.Sinks.Email.Addr == "" {
+                bad("reports.sinks: email needs sinks.email")
+            }
+        case "telegram":
+            if len(c.Reports.Chats) == 0 {
+                bad("reports.chats: telegram needs the chats to send reports to")
+            }
+        default:
+            bad("reports.sinks: want email or telegram, got %q", sink)
+        }
+    }
+    if h := c.Heartbeat; h.URL != "" || h.FailURL != "" || h.Chat != 0 {
+        checkURL("heartbeat.url", h.URL)
+        checkURL("heartbeat.fail_url", h.FailURL)
+        if h.Interval < 10*time.Second {
+            bad("heartbeat.interval: want at least 10s, got %s", h.Interval)
+        }
+        if h.ChatInterval < 0 {
+            bad("heartbeat.chat_interval: must not be negative")
+        }
+    }
+    if c.Dedup.Messages < 0 || c.Dedup.Links < 0 {
+        bad("dedup: messages and links must not be negative")
+    }
+    if c.Rescan.Interval < 0 {
+        bad("rescan.interval: must not be negative, got %s", c.Rescan.Interval)
+    }
+    if c.Rescan.Interval > 0 && (c.Rescan.Days < 1 || c.Rescan.Limit < 1) {
+        bad("rescan: days and limit must be at least 1")
+    }
+    if strings.Count(c.Update.Repo, "/") != 1 {
+        bad("update.repo: want owner/name, got %q", c.Update.Repo)
+    }
+    if c.Update.PublicKey != "" {
+        if _, err := parsePublicKey(c.Update.PublicKey); err != nil {
+            bad("update.public_key: %v", err)
+        }
+    }
+    for i, rule := range c.Rules {
+        if _, err := compileCondition(rule.When); err != nil {
+            bad("rules[%d].when: %v", i, err)
+        }
+        if rule.Severity == nil && !rule.Suppress {
+            bad("rules[%d]: sets neither severity nor suppress", i)
+        }
+    }
+    for i, route := range c.Routes {
+        if len(route.Sinks) == 0 {
+            bad("routes[%d]: no sinks listed", i)
+        }
+        if route.When != "" {
+            if _, err := compileCondition(route.When); err != nil {
+                bad("routes[%d].when: %v", i, err)
+            }
+        }
+    }
+
+    if e := c.Sinks.Email; e.Addr != "" {
+        if !strings.Contains(e.Addr, ":") {
+            bad("sinks.email.addr: want host:port, got %q", e.Addr)
+        }
+        if e.From == "" {
+            bad("sinks.email.from: a sender address is required")
+        }
+        if len(e.To) == 0 {
+            bad("sinks.email.to: at least one recipient is required")
+        }
+    }
+    checkURL("sinks.slack.webhook", c.Sinks.Slack.Webhook)
+    checkURL("sinks.discord.webhook", c.Sinks.Discord.Webhook)
+    checkURL("sinks.teams.webhook", c.Sinks.Teams.Webhook)
+    checkURL("sinks.ntfy.url", c.Sinks.Ntfy.URL)
+    checkURL("sinks.gotify.url", c.Sinks.Gotify.URL)
+    if c.Sinks.Pushover.Token != "" && c.Sinks.Pushover.User == "" {
+        bad("sinks.pushover.user: a user key is required with a Pushover token")
+    }
+    if c.Sinks.Gotify.URL != "" && c.Sinks.Gotify.Token == "" {
+        bad("sinks.gotify.token: an app token is required with a Gotify URL")
+    }
+    if s := c.Sinks.Syslog; s.Addr != "" {
+        if !strings.Contains(s.Addr, ":") {
+            bad("sinks.syslog.addr: want host:port, got %q", s.Addr)
+        }
+        if s.Network != "udp" && s.Network != "tcp" && s.Network != "tls" {
+            bad("sinks.syslog.network: want udp, tcp or tls, got %q", s.Network)
+        }
+        if _, ok := notify.SyslogFacilities[s.Facility]; !ok {
+            bad("sinks.syslog.facility: want user, daemon, auth, authpriv or local0 to local7, got %q", s.Facility)
+        }
+        if s.Format != notify.FormatRFC5424 && s.Format != notify.FormatCEF && s.Format != notify.FormatLEEF {
+            bad("sinks.syslog.format: want rfc5424, cef or leef, got %q", s.Format)
+        }
+        if s.CAFile != "" && s.Network != "tls" {
+            bad("sinks.syslog.ca_file: only used with network tls")
+        }
+    }
+    checkBus := func(name, key, delivery string) {
+        if !slices.Contains(notify.BusKeys, key) {
+            bad("sinks.%s.key: want one of %s, got %q", name, strings.Join(notify.BusKeys, ", "), key)
+        }
+        if delivery != notify.AtLeastOnce && delivery != notify.AtMostOnce {
+            bad("sinks.%s.delivery: want at-least-once or at-most-once, got %q", name, delivery)
+        }
+    }
+    if k := c.Sinks.Kafka; len(k.Brokers) > 0 {
+        if k.Topic == "" {
+            bad("sinks.kafka.topic: a topic is required with brokers")
+        }
+        checkBus("kafka", k.Key, k.Delivery)
+        switch k.SASL {
+        case "":
+        case "plain", "scram-sha-256", "scram-sha-512":
+            if k.Username == "" {
+                bad("sinks.kafka.username: required with sasl %s", k.SASL)
+            }
+        default:
+            bad("sinks.kafka.sasl: want plain, scram-sha-256 or scram-sha-512, got %q", k.SASL)
+        }
+    }
+    if n := c.Sinks.NATS; n.URL != "" {
+        if n.Subject == "" || strings.ContainsAny(n.Subject, " *>") {
+            bad("sinks.nats.subject: want a subject without wildcards, got %q", n.Subject)
+        }
+        checkBus("nats", n.Key, n.Delivery)
+    }
+    if d := c.Sinks.Defender; d.TenantID != "" {
+        if d.ClientID == "" || d.ClientSecret == "" {
+            bad("sinks.defender: client_id and client_secret are required with a tenant_id")
+        }
+        if d.Category != "" && !slices.Contains(notify.DefenderCategories, d.Category) {
+            bad("sinks.defender.category: want %s, got %q", strings.Join(notify.DefenderCategories, " or "), d.Category)
+        }
+        if d.Expiry < 0 {
+            bad("sinks.defender.expiry: must not be negative")
+        }
+    }
+    checkURL("sinks.thehive.url", c.Sinks.TheHive.URL)
+    if t := c.Sinks.TheHive; t.URL != "" {
+        if t.APIKey == "" {
+            bad("sinks.thehive.api_key: required with a url")
+        }
+        if t.TLP < 0 || t.TLP > 4 {
+            bad("sinks.thehive.tlp: want 0 to 4, got %d", t.TLP)
+        }
+        if t.PAP < 0 || t.PAP > 3 {
+            bad("sinks.thehive.pap: want 0 to 3, got %d", t.PAP)
+        }
+    }
+    checkURL("sinks.cortex.url", c.Sinks.Cortex.URL)
+    if x := c.Sinks.Cortex; x.URL != "" {
+        if x.APIKey == "" {
+            bad("sinks.cortex.api_key: required with a url")
+        }
+        if len(x.Analyzers) == 0 {
+            bad("sinks.cortex.analyzers: name at least one analyzer to run")
+        }
+        if x.TLP < 0 || x.TLP > 4 {
+            bad("sinks.cortex.tlp: want 0 to 4, got %d", x.TLP)
+        }
+    }
+    switch t := c.Sinks.Tickets; t.Tracker {
+    case "":
+    case "jira":
+        checkURL("sinks.tickets.jira.url", t.Jira.URL)
+        if t.Jira.URL == "" || t.Jira.Email == "" || t.Jira.Token == "" || t.Jira.Project == "" {
+            bad("sinks.tickets.jira: url, email, token and project are required")
+        }
+    case "webhook":
+        checkURL("sinks.tickets.webhook.create_url", t.Webhook.CreateURL)
+        checkURL("sinks.tickets.webhook.comment_url", strings.ReplaceAll(t.Webhook.CommentURL, "{issue}", "issue"))
+        if t.Webhook.CreateURL == "" || t.Webhook.CommentURL == "" {
+            bad("sinks.tickets.webhook: create_url and comment_url are required")
+        }
+    default:
+        bad("sinks.tickets.tracker: want jira or webhook, got %q", t.Tracker)
+    }
+    if d := c.Sinks.Tickets.Dedup; d != notify.DedupDomain && d != notify.DedupURL {
+        bad("sinks.tickets.dedup: want %s or %s, got %q", notify.DedupDomain, notify.DedupURL, d)
+    }
+    if c.Sinks.Tickets.Window <= 0 {
+        bad("sinks.tickets.window: must be positive")
+    }
+    if a := c.Sinks.Abuse; a.Enabled {
+        if a.Send && c.Sinks.Email.Addr == "" {
+            bad("sinks.abuse.send: needs sinks.email's server to send reports through")
+        }
+        if !a.Send && a.Drafts == "" {
+            bad("sinks.abuse: set send or drafts, or reports go nowhere")
+        }
+        if a.Interval <= 0 {
+            bad("sinks.abuse.interval: must be positive")
+        }
+        checkURL("sinks.abuse.rdap", a.RDAP)
+        if (a.WebRisk.Project == "") != (a.WebRisk.Credentials == "") {
+            bad("sinks.abuse.web_risk: project and credentials go together")
+        }
+    }
+    hooks := map[string]bool{}
+    for i, w := range c.Sinks.Webhooks {
+        if !profileName.MatchString(w.Name) {
+            bad("sinks.webhooks[%d].name: want lowercase letters, digits, - and _, got %q", i, w.Name)
+        } else if hooks[w.Name] {
+            bad("sinks.webhooks[%d].name: %q is used twice", i, w.Name)
+        }
+        hooks[w.Name] = true
+        if w.URL == "" {
+            bad("sinks.webhooks[%d].url: a URL is required", i)
+        }
+        checkURL(fmt.Sprintf("sinks.webhooks[%d].url", i), w.URL)
+        if w.Retries < 0 || w.Backoff < 0 {
+            bad("sinks.webhooks[%d]: retries and backoff must not be negative", i)
+        }
+    }
+
+    if c.Reputation.CacheTTL < 0 {
+        bad("reputation.cache_ttl: must not be negative, got %s", c.Reputation.CacheTTL)
+    }
+    if c.Reputation.CacheTTL > 0 && c.Reputation.CacheSize < 1 {
+        bad("reputation.cache_size: must be at least 1, got %d", c.Reputation.CacheSize)
+    }
+    if c.Reputation.Serve && (c.Admin.Listen == "" || c.Admin.Token == "") {
+        bad("reputation.serve: admin.listen and admin.token are required to serve lookups")
+    }
+    if c.Reputation.Server != "" {
+        checkURL("reputation.server", c.Reputation.Server)
+        if c.Reputation.Token == "" {
+            bad("reputation.token: the server's admin token is required")
+        }
+        if c.Reputation.Timeout <= 0 {
+            bad("reputation.timeout: must be positive, got %s", c.Reputation.Timeout)
+        }
+    }
+    if c.GRPC.Listen != "" && c.Admin.Token == "" {
+        bad("grpc.listen: admin.token is required to authenticate gRPC calls")
+    }
+    if (c.GRPC.CertFile == "") != (c.GRPC.KeyFile == "") {
+        bad("grpc: cert_file and key_file must be set together")
+    }
+    if f := c.Logging.Format; f != "" && f != "text" && f != "json" {
+        bad("logging.format: want text or json, got %q", f)
+    }
+    if c.Logging.MaxSizeMB < 0 || c.Logging.MaxAgeDays < 0 || c.Logging.MaxBackups < 0 || c.Logging.RotateInterval < 0 {
+        bad("logging: max_size_mb, rotate_interval, max_age_days and max_backups must not be negative")
+    }
+    modules := map[string]bool{}
+    for _, name := range LogModules {
+        modules[name] = true
+    }
+    for name := range c.Logging.Levels {
+        if !modules[name] {
+            bad("logging.levels: unknown module %q (available: %s)", name, strings.Join(LogModules, ", "))
+        }
+    }
+    return problems
+}
+
+// secretSetting is a setting that can be kept in the OS credential store,
+// under the name of its config key.
+type secretSetting struct {
+    name  string
+    value *string
+}
+
+// secrets lists the settings `telephish secrets set` can store.
+func (c *Config) secrets() []secretSetting {
+    secrets := []secretSetting{
+        {"telegram.token", &c.Telegram.Token},
+        {"webhook.secret", &c.Webhook.Secret},
+        {"admin.token", &c.Admin.Token},
+        {"reputation.token", &c.Reputation.Token},
+        {"sinks.email.password", &c.Sinks.Email.Password},
+        {"sinks.slack.webhook", &c.Sinks.Slack.Webhook},
+        {"sinks.discord.webhook", &c.Sinks.Discord.Webhook},
+        {"sinks.teams.webhook", &c.Sinks.Teams.Webhook},
+        {"sinks.ntfy.token", &c.Sinks.Ntfy.Token},
+        {"sinks.pushover.token", &c.Sinks.Pushover.Token},
+        {"sinks.pushover.user", &c.Sinks.Pushover.User},
+        {"sinks.gotify.token", &c.Sinks.Gotify.Token},
+        {"sinks.kafka.password", &c.Sinks.Kafka.Password},
+        {"sinks.nats.token", &c.Sinks.NATS.Token},
+        {"sinks.defender.client_secret", &c.Sinks.Defender.ClientSecret},
+        {"sinks.thehive.api_key", &c.Sinks.TheHive.APIKey},
+        {"sinks.cortex.api_key", &c.Sinks.Cortex.APIKey},
+        {"sinks.tickets.jira.token", &c.Sinks.Tickets.Jira.Token},
+    }
+    for i := range c.Sinks.Webhooks {
+        w := &c.Sinks.Webhooks[i]
+        secrets = append(secrets, secretSetting{"sinks.webhooks." + w.Name + ".secret", &w.Secret})
+    }
+    return secrets
+}
+
+// applyKeystore fills the secrets the file and environment left unset from
+// the OS credential store. Without a credential store it does nothing.
+func (c *Config) applyKeystore() error {
+    if !c.Keystore {
+        return nil
+    }
+    for _, s := range c.secrets() {
+        if *s.value != "" {
+            continue
+        }
+        value, err := keystore.Get(s.name)
+        switch err {
+        case nil:
+            *s.value = value
+        case keystore.ErrNotFound:
+        case keystore.ErrUnavailable:
+            return nil
+        default:
+            return fmt.Errorf("%v (set keystore: false or TELEPHISH_KEYSTORE=0 to skip it)", err)
+        }
+    }
+    return nil
+}
+
+// RequireToken reports an error if no bot token is configured, for
+// commands that talk to Telegram.
+func (c *Config) RequireToken() error {
+    if c.Telegram.Token == "" {
+        return fmt.Errorf("telegram.token: a bot token is required (or set TELEGRAM_BOT_TOKEN, or store it with `telephish secrets set telegram.token`)")
+    }
+    return nil
+}
+
+// WatchesChat reports whether updates from chatID should be processed.
+func (c *Config) WatchesChat(chatID int64) bool {
+    if len(c.Telegram.Chats) == 0 {
+        return true
+    }
+    for _, id := range c.Telegram.Chats {
+        if id == chatID {
+            return true
+        }
+    }
+    return false
+}
+
+func splitList(s string) []string {
+    var out []string
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            out = append(out, item)
+        }
+    }
+    return out
+}
@@ -0,0 +1,252 @@
+package telephish
+
+import (
+    "crypto/subtle"
+    "embed"
+    "fmt"
+    "html/template"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+//go:embed web/*.html
+var webFiles embed.FS
+
+// dashboardDays is how far back the dashboard's trend chart goes.
+const dashboardDays = 14
+
+// dashboardAuditLimit is how many actions the audit page shows.
+const dashboardAuditLimit = 200
+
+var dashboardTemplates = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+    "build":         Build,
+    "defang":        notify.Defang,
+    "domain":        extract.DisplayDomain,
+    "host":          extract.DisplayHost,
+    "severityColor": func(s analysis.Severity) string { return fmt.Sprintf("#%06X", notify.SeverityRGB[s]) },
+}).ParseFS(webFiles, "web/*.html"))
+
+// Dashboard serves the alert history as web pages, with buttons to
+// allowlist or block a link's domain. Every page needs the admin token as
+// its Basic auth password.
+func (a *App) Dashboard() http.Handler {
+    mux := http.NewServeMux()
+    mux.HandleFunc("GET /{$}", a.dashboardIndex)
+    mux.HandleFunc("GET /alerts/{id}", a.dashboardAlert)
+    mux.HandleFunc("GET /alerts/{id}/screenshot", a.dashboardScreenshot)
+    mux.HandleFunc("POST /lists", a.dashboardLists)
+    mux.HandleFunc("GET /audit", a.dashboardAudit)
+    // Browsers send Basic credentials with cross-site form posts too
+    return requireToken(a.Config.Admin.Token, http.NewCrossOriginProtection().Handler(mux))
+}
+
+// requireToken asks for token as the Basic auth password, or accepts it as
+// a Bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !hasToken(r, token) {
+            w.Header().Set("WWW-Authenticate", `Basic realm="Telephish"`)
+            http.Error(w, "unauthorized", http.StatusUnauthorized)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// hasToken reports whether r carries token, as the Basic auth password or
+// a Bearer token.
+func hasToken(r *http.Request, token string) bool {
+    got := ""
+    if _, password, ok := r.BasicAuth(); ok {
+        got = password
+    } else if auth := r.Header.Get("Authorization"); len(auth) > 7 && auth[:7] == "Bearer " {
+        got = auth[7:]
+    }
+    return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// trendChart is the stacked bar chart of alerts per day.
+type trendChart struct {
+    Width, Height, BarWidth int
+    Bars                    []trendBar
+}
+
+type trendBar struct {
+    X        int
+    Label    string
+    Total    int
+    Segments []trendSegment
+}
+
+type trendSegment struct {
+    Y, Height int
+    Severity  analysis.Severity
+}
+
+func newTrendChart(points []store.TrendPoint, days int, now time.Time) trendChart {
+    const height, barWidth, gap = 120, 24, 6
+    chart := trendChart{Width: days * (barWidth + gap), Height: height, BarWidth: barWidth}
+
+    first := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+    counts := make([]map[analysis.Severity]int, days)
+    totals := make([]int, days)
+    max := 1
+    for _, p := range points {
+        i := int(p.Day.Sub(first) / (24 * time.Hour))
+        if i < 0 || i >= days {
+            continue
+        }
+        if counts[i] == nil {
+            counts[i] = map[analysis.Severity]int{}
+        }
+        counts[i][p.Severity] += p.Count
+        totals[i] += p.Count
+        if totals[i] > max {
+            max = totals[i]
+        }
+    }
+    for i := 0; i < days; i++ {
+        bar := trendBar{X: i * (barWidth + gap), Label: first.AddDate(0, 0, i).Format("Jan 2"), Total: totals[i]}
+        y := height
+        for _, s := range []analysis.Severity{analysis.SeverityClean, analysis.SeverityInfo, analysis.SeveritySuspicious, analysis.SeverityMalicious} {
+            h := counts[i][s] * height / max
+            if h == 0 {
+                continue
+            }
+            y -= h
+            bar.Segments = append(bar.Segments, trendSegment{Y: y, Height: h, Severity: s})
+        }
+        chart.Bars = append(chart.Bars, bar)
+    }
+    return chart
+}
+
+func (a *App) dashboardIndex(w http.ResponseWriter, r *http.Request) {
+    entries, err := a.History.Recent(100)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    // Newest first
+    for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+        entries[i], entries[j] = entries[j], entries[i]
+    }
+    now := time.Now()
+    points, err := a.History.Trend(now.AddDate(0, 0, -dashboardDays))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    renderDashboard(w, "index", map[string]interface{}{
+        "Title":      "Alerts",
+        "Days":       dashboardDays,
+        "Chart":      newTrendChart(points, dashboardDays, now),
+        "Severities": []analysis.Severity{analysis.SeverityClean, analysis.SeverityInfo, analysis.SeveritySuspicious, analysis.SeverityMalicious},
+        "Entries":    entries,
+        "Allow":      a.Lists.Domains(analysis.ListAllow),
+        "Block":      a.Lists.Domains(analysis.ListBlock),
+    })
+}
+
+func (a *App) dashboardAlert(w http.ResponseWriter, r *http.Request) {
+    entry := a.dashboardEntry(w, r)
+    if entry == nil {
+        return
+    }
+    domain := ""
+    if u, err := url.Parse(entry.Alert.URL); err == nil {
+        domain = u.Hostname()
+    }
+    renderDashboard(w, "alert", map[string]interface{}{
+        "Title":  fmt.Sprintf("Alert %d", entry.ID),
+        "Entry":  entry,
+        "Domain": domain,
+    })
+}
+
+func (a *App) dashboardScreenshot(w http.ResponseWriter, r *http.Request) {
+    entry := a.dashboardEntry(w, r)
+    if entry == nil {
+        return
+    }
+    if entry.Alert.Screenshot == "" {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "image/png")
+    http.ServeFile(w, r, entry.Alert.Screenshot)
+}
+
+// dashboardEntry looks up the history entry named in the URL, answering
+// the request itself and returning nil if there isn't one.
+func (a *App) dashboardEntry(w http.ResponseWriter, r *http.Request) *store.HistoryEntry {
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        http.NotFound(w, r)
+        return nil
+    }
+    entry, err := a.History.Get(id)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return nil
+    }
+    if entry == nil {
+        http.NotFound(w, r)
+    }
+    return entry
+}
+
+func (a *App) dashboardLists(w http.ResponseWriter, r *http.Request) {
+    list, domain, reason := r.FormValue("list"), r.FormValue("domain"), r.FormValue("reason")
+    note := reason
+    if note == "" {
+        note = "added from the dashboard"
+    }
+    var err error
+    switch r.FormValue("action") {
+    case "add":
+        err = a.Lists.Add(list, domain, note)
+    case "remove":
+        err = a.Lists.Remove(list, domain)
+    default:
+        err = fmt.Errorf("unknown action %q", r.FormValue("action"))
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    appLog.Info("list updated from dashboard", "list", list, "domain", domain, "action", r.FormValue("action"))
+    a.audit(ActorDashboard, list+"list."+r.FormValue("action"), domain, reason)
+    http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// dashboardAudit lists the newest actions in the audit log, optionally
+// only one actor's or one action.
+func (a *App) dashboardAudit(w http.ResponseWriter, r *http.Request) {
+    f := store.AuditFilter{Actor: r.FormValue("actor"), Action: r.FormValue("action"), Limit: dashboardAuditLimit}
+    events, err := a.History.AuditLog(f)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    renderDashboard(w, "audit", map[string]interface{}{
+        "Title":  "Audit log",
+        "Filter": f,
+        "Events": events,
+    })
+}
+
+func renderDashboard(w http.ResponseWriter, name string, data interface{}) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src 'self'; form-action 'self'")
+    if err := dashboardTemplates.ExecuteTemplate(w, name, data); err != nil {
+        appLog.Error("failed to render dashboard", "page", name, "err", err)
+    }
+}
@@ -0,0 +1,292 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sync"
+    "text/tabwriter"
+    "time"
+
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// inlineSinks are delivered to on the spot rather than through the
+// delivery queue: they are local, and the desktop sink's fallbacks already
+// cover its failures.
+var inlineSinks = map[string]bool{"desktop": true, "log": true}
+
+const (
+    deliveryBatch   = 100              // Deliveries read for a sink at a time
+    deliveryPoll    = time.Second      // How often the queue looks for due deliveries
+    deliveryTimeout = 2 * time.Minute  // Bounds one attempt, including a sink's own retries
+    flushTimeout    = 15 * time.Second // Bounds the last attempts made on Close
+)
+
+// queuedSink stands in for a remote sink in the routes. Rather than
+// delivering alerts it reports them queued, and History.Record puts them
+// in the delivery queue along with the alert, so a sink being down or slow
+// never blocks the workers or loses the alert.
+type queuedSink struct {
+    name       string
+    history    *store.History
+    maxPending int
+}
+
+// Notify queues the alert, unless the sink already has maxPending
+// deliveries waiting.
+func (s queuedSink) Notify(ctx context.Context, alert notify.Alert) error {
+    n, err := s.history.CountDeliveries(store.DeliveryFilter{Profile: alert.Profile, Sink: s.name})
+    if err != nil {
+        return err
+    }
+    if n >= s.maxPending {
+        return fmt.Errorf("delivery queue is full: %d alerts are waiting", n)
+    }
+    return notify.ErrQueued
+}
+
+// deliveryQueue delivers the alerts queued for an app's remote sinks. One
+// goroutine at a time works on each sink, oldest alert first. When an
+// attempt fails, the sink's deliveries are all held back until it is due
+// again, with backoff, so a sink that is down neither holds up the others
+// nor gets hammered; a delivery that runs out of attempts is
+// dead-lettered, and kept until it is retried or purged.
+type deliveryQueue struct {
+    app  *App
+    wake chan struct{}
+
+    mu   sync.Mutex
+    busy map[string]bool
+    wg   sync.WaitGroup
+}
+
+func newDeliveryQueue(app *App) *deliveryQueue {
+    return &deliveryQueue{app: app, wake: make(chan struct{}, 1), busy: map[string]bool{}}
+}
+
+// Wake has the queue look for due deliveries now, such as ones just
+// queued.
+func (q *deliveryQueue) Wake() {
+    select {
+    case q.wake <- struct{}{}:
+    default:
+    }
+}
+
+// run delivers until ctx is done.
+func (q *deliveryQueue) run(ctx context.Context) error {
+    for {
+        q.dispatch(ctx)
+        select {
+        case <-ctx.Done():
+            return nil
+        case <-q.wake:
+        case <-time.After(deliveryPoll):
+        }
+    }
+}
+
+// Close waits for the attempts in progress, then makes one more at each
+// sink's due deliveries within flushTimeout, for commands such as
+// run --once that exit right after queueing an alert.
+func (q *deliveryQueue) Close() {
+    q.wg.Wait()
+    ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+    defer cancel()
+    q.dispatch(ctx)
+    q.wg.Wait()
+}
+
+// dispatch starts draining each sink with due deliveries that isn't being
+// drained already.
+func (q *deliveryQueue) dispatch(ctx context.Context) {
+    sinks, err := q.app.History.DueSinks(q.app.Config.Profile, time.Now())
+    if err != nil {
+        deliveryLog.Error("failed to read the delivery queue", "err", err)
+        return
+    }
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    for _, sink := range sinks {
+        if q.busy[sink] {
+            continue
+        }
+        q.busy[sink] = true
+        q.wg.Add(1)
+        go q.drain(ctx, sink)
+    }
+}
+
+// drain attempts sink's due deliveries in order until one fails.
+func (q *deliveryQueue) drain(ctx context.Context, sink string) {
+    more := false
+    defer func() {
+        q.mu.Lock()
+        delete(q.busy, sink)
+        q.mu.Unlock()
+        q.wg.Done()
+        if more {
+            q.Wake()
+        }
+    }()
+    filter := store.DeliveryFilter{Profile: q.app.Config.Profile, Sink: sink}
+    due, err := q.app.History.Deliveries(filter, time.Now(), deliveryBatch)
+    if err != nil {
+        deliveryLog.Error("failed to read the delivery queue", "sink", sink, "err", err)
+        return
+    }
+    for _, d := range due {
+        if ctx.Err() != nil || !q.attempt(ctx, d) {
+            return
+        }
+    }
+    more = len(due) == deliveryBatch
+}
+
+// attempt tries to deliver d, and reports whether it went through.
+func (q *deliveryQueue) attempt(ctx context.Context, d store.Delivery) bool {
+    a := q.app
+    logger := deliveryLog.With("sink", d.Sink, "entry", d.Entry, "attempt", d.Attempts+1)
+    entry, err := a.History.Get(d.Entry)
+    if err != nil || entry == nil {
+        logger.Error("failed to read queued alert", "err", err)
+        return false
+    }
+    a.mu.RLock()
+    cfg := a.Config.Delivery
+    sink, ok := a.sinks[d.Sink]
+    a.mu.RUnlock()
+    if !ok {
+        err := fmt.Errorf("sink %s is no longer configured", d.Sink)
+        logger.Error("dead-lettering delivery", "err", err)
+        if err := a.History.DeadLetter(d, err); err != nil {
+            logger.Error("failed to dead-letter delivery", "err", err)
+            return false
+        }
+        return true
+    }
+
+    attemptCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+    err = sink.Notify(attemptCtx, entry.Alert)
+    cancel()
+    if err == nil {
+        if err := a.History.Delivered(d, notify.NewAction(d.Sink, nil)); err != nil {
+            logger.Error("failed to record delivery", "err", err)
+        }
+        logger.Debug("delivered queued alert")
+        return true
+    }
+    if ctx.Err() != nil {
+        // Shutting down; the attempt doesn't count against the sink
+        return false
+    }
+    if d.Attempts+1 >= cfg.Attempts {
+        logger.Error("giving up on delivery; dead-lettering it", "err", err)
+        if err := a.History.DeadLetter(d, err); err != nil {
+            logger.Error("failed to dead-letter delivery", "err", err)
+        }
+        return false
+    }
+    wait := cfg.Backoff << d.Attempts
+    if wait > cfg.MaxBackoff || wait <= 0 {
+        wait = cfg.MaxBackoff
+    }
+    next := time.Now().Add(wait)
+    logger.Warn("delivery failed; retrying", "err", err, "delay", wait)
+    if err := a.History.Retry(d, next, err); err != nil {
+        logger.Error("failed to reschedule delivery", "err", err)
+    }
+    if err := a.History.Postpone(store.DeliveryFilter{Profile: a.Config.Profile, Sink: d.Sink}, next); err != nil {
+        logger.Error("failed to hold back deliveries", "err", err)
+    }
+    return false
+}
+
+// StartDeliveries delivers queued alerts in the background until ctx is
+// done. Without history there is no queue, and it does nothing.
+func (a *App) StartDeliveries(ctx context.Context) {
+    if a.deliveries == nil {
+        return
+    }
+    go a.sup.run(ctx, "deliveries", a.deliveries.run)
+}
+
+// deliveriesCommand lists the delivery queue, or retries or purges its
+// dead letters.
+func deliveriesCommand(ctx context.Context, args []string) error {
+    usage := fmt.Errorf("usage: %s deliveries list|retry|purge [--sink name] [--profile name] [--dead]", os.Args[0])
+    if len(args) == 0 {
+        return usage
+    }
+    action, args := args[0], args[1:]
+    fs, configPath := newFlagSet("deliveries " + action)
+    sink := fs.String("sink", "", "only this sink's deliveries")
+    profile := fs.String("profile", "", "only the deliveries of alerts this profile's bot received")
+    dead := fs.Bool("dead", false, "list dead letters rather than pending deliveries")
+    fs.Parse(args)
+    if fs.NArg() > 0 || (action != "list" && action != "retry" && action != "purge") {
+        return usage
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    if !history.Enabled() {
+        return fmt.Errorf("history: the delivery queue is kept in the history, which is turned off")
+    }
+    filter := store.DeliveryFilter{Profile: *profile, Sink: *sink, Dead: *dead}
+
+    // What a retry or purge applied to, for the audit log
+    target := "every sink"
+    if *sink != "" {
+        target = "sink " + *sink
+    }
+    if *profile != "" {
+        target += ", profile " + *profile
+    }
+
+    switch action {
+    case "retry":
+        n, err := history.Requeue(filter)
+        if err != nil {
+            return err
+        }
+        audit(history, store.AuditEvent{Actor: cliActor(), Action: "deliveries.retry", Target: target, Reason: fmt.Sprintf("%d dead letters", n)})
+        fmt.Printf("requeued %d dead letters; a running monitor will deliver them\n", n)
+        return nil
+    case "purge":
+        n, err := history.PurgeDead(filter)
+        if err != nil {
+            return err
+        }
+        audit(history, store.AuditEvent{Actor: cliActor(), Action: "deliveries.purge", Target: target, Reason: fmt.Sprintf("%d dead letters", n)})
+        fmt.Printf("purged %d dead letters\n", n)
+        return nil
+    }
+    deliveries, err := history.Deliveries(filter, time.Time{}, -1)
+    if err != nil {
+        return err
+    }
+    if len(deliveries) == 0 {
+        fmt.Fprintln(os.Stderr, "no deliveries waiting")
+        return nil
+    }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "ID\tALERT\tSINK\tATTEMPTS\tNEXT\tERROR")
+    for _, d := range deliveries {
+        next := d.Next.Local().Format(time.DateTime)
+        if d.Dead {
+            next = "dead"
+        }
+        fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%s\t%s\n", d.ID, d.Entry, d.Sink, d.Attempts, next, tableCell(d.Error))
+    }
+    return tw.Flush()
+}
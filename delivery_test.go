@@ -0,0 +1,188 @@
+package telephish
+
+import (
+    "context"
+    "errors"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// flakySink fails its first failures calls, then delivers.
+type flakySink struct {
+    mu        sync.Mutex
+    failures  int
+    calls     int
+    delivered []string
+}
+
+func (s *flakySink) Notify(ctx context.Context, alert notify.Alert) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.calls++
+    if s.calls <= s.failures {
+        return errors.New("503 Service Unavailable")
+    }
+    s.delivered = append(s.delivered, alert.ID)
+    return nil
+}
+
+// newDeliveryApp returns an App delivering to sink through the queue kept
+// in the history at path.
+func newDeliveryApp(t *testing.T, path string, cfg DeliveryConfig, sink notify.Notifier) *App {
+    t.Helper()
+    history, err := store.OpenHistory(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { history.Close() })
+    app := &App{History: history, Config: &Config{Delivery: cfg}}
+    app.sinks = map[string]notify.Notifier{"webhook": sink}
+    app.deliveries = newDeliveryQueue(app)
+    return app
+}
+
+// queueFor records an alert with a delivery queued for the webhook sink.
+func queueFor(t *testing.T, history *store.History, id string) int64 {
+    t.Helper()
+    now := time.Now()
+    entry, err := history.Record(store.HistoryEntry{Time: now, Alert: notify.Alert{ID: id},
+        Actions: []notify.Action{{Time: now, Sink: "webhook", Status: notify.ActionQueued}}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return entry
+}
+
+// deliverOnce makes one pass over the due deliveries and waits for it.
+func deliverOnce(q *deliveryQueue) {
+    q.dispatch(context.Background())
+    q.wg.Wait()
+}
+
+func pendingDeliveries(t *testing.T, history *store.History, dead bool) []store.Delivery {
+    t.Helper()
+    deliveries, err := history.Deliveries(store.DeliveryFilter{Sink: "webhook", Dead: dead}, time.Time{}, -1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return deliveries
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+    cfg := DeliveryConfig{Queue: true, Attempts: 4, Backoff: 40 * time.Millisecond, MaxBackoff: 100 * time.Millisecond}
+    sink := &flakySink{failures: 3}
+    app := newDeliveryApp(t, filepath.Join(t.TempDir(), "history.db"), cfg, sink)
+    first := queueFor(t, app.History, "first")
+    queueFor(t, app.History, "second")
+
+    // Each failure doubles the wait, up to MaxBackoff, and holds back the
+    // alert behind it
+    for i, wait := range []time.Duration{40 * time.Millisecond, 80 * time.Millisecond, 100 * time.Millisecond} {
+        before := time.Now()
+        deliverOnce(app.deliveries)
+        after := time.Now()
+        pending := pendingDeliveries(t, app.History, false)
+        if len(pending) != 2 || pending[0].Entry != first {
+            t.Fatalf("after failure %d, pending = %+v", i+1, pending)
+        }
+        d := pending[0]
+        if d.Attempts != i+1 || d.Error != "503 Service Unavailable" {
+            t.Errorf("after failure %d, delivery = %+v", i+1, d)
+        }
+        if d.Next.Before(before.Add(wait).Truncate(time.Millisecond)) || d.Next.After(after.Add(wait)) {
+            t.Errorf("after failure %d, next attempt in %v, want %v", i+1, d.Next.Sub(before), wait)
+        }
+        if pending[1].Next.Before(d.Next) {
+            t.Errorf("after failure %d, the second alert is due before the first", i+1)
+        }
+        // Not due yet: nothing is attempted
+        deliverOnce(app.deliveries)
+        if sink.calls != i+1 {
+            t.Fatalf("%d attempts before the backoff ran out, want %d", sink.calls, i+1)
+        }
+        time.Sleep(time.Until(d.Next) + 10*time.Millisecond)
+    }
+
+    deliverOnce(app.deliveries)
+    if len(sink.delivered) != 2 || sink.delivered[0] != "first" || sink.delivered[1] != "second" {
+        t.Errorf("delivered %v, want first then second", sink.delivered)
+    }
+    if pending := pendingDeliveries(t, app.History, false); len(pending) != 0 {
+        t.Errorf("%d deliveries still pending", len(pending))
+    }
+    entry, err := app.History.Get(first)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if act := entry.Actions[0]; act.Status != notify.ActionSent || act.Error != "" {
+        t.Errorf("action after delivery = %+v", act)
+    }
+}
+
+func TestDeliveryDeadLetters(t *testing.T) {
+    cfg := DeliveryConfig{Queue: true, Attempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+    sink := &flakySink{failures: 100}
+    app := newDeliveryApp(t, filepath.Join(t.TempDir(), "history.db"), cfg, sink)
+    entry := queueFor(t, app.History, "doomed")
+
+    deliverOnce(app.deliveries)
+    time.Sleep(5 * time.Millisecond)
+    deliverOnce(app.deliveries)
+    if sink.calls != 2 {
+        t.Errorf("%d attempts, want 2", sink.calls)
+    }
+    if pending := pendingDeliveries(t, app.History, false); len(pending) != 0 {
+        t.Errorf("%d deliveries pending after the last attempt", len(pending))
+    }
+    dead := pendingDeliveries(t, app.History, true)
+    if len(dead) != 1 || dead[0].Entry != entry || dead[0].Attempts != 2 {
+        t.Fatalf("dead letters = %+v", dead)
+    }
+
+    // Dead letters aren't attempted again until retried
+    time.Sleep(5 * time.Millisecond)
+    deliverOnce(app.deliveries)
+    if sink.calls != 2 {
+        t.Errorf("a dead letter was attempted")
+    }
+}
+
+func TestDeliveryResumesAfterRestart(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "history.db")
+    cfg := DeliveryConfig{Queue: true, Attempts: 5, Backoff: 20 * time.Millisecond, MaxBackoff: time.Second}
+
+    // The sink is down, and the monitor stops after the failed attempt
+    down := &flakySink{failures: 100}
+    app := newDeliveryApp(t, path, cfg, down)
+    queueFor(t, app.History, "survivor")
+    deliverOnce(app.deliveries)
+    if down.calls != 1 {
+        t.Fatalf("%d attempts before the restart, want 1", down.calls)
+    }
+    app.History.Close()
+
+    up := &flakySink{}
+    app = newDeliveryApp(t, path, cfg, up)
+    pending := pendingDeliveries(t, app.History, false)
+    if len(pending) != 1 || pending[0].Attempts != 1 {
+        t.Fatalf("after the restart, pending = %+v, want the failed delivery", pending)
+    }
+    // Still backing off
+    deliverOnce(app.deliveries)
+    if up.calls != 0 {
+        t.Errorf("attempted before the backoff ran out")
+    }
+    time.Sleep(time.Until(pending[0].Next) + 10*time.Millisecond)
+    deliverOnce(app.deliveries)
+    if len(up.delivered) != 1 || up.delivered[0] != "survivor" {
+        t.Errorf("delivered %v after the restart, want the survivor", up.delivered)
+    }
+    if pending := pendingDeliveries(t, app.History, false); len(pending) != 0 {
+        t.Errorf("%d deliveries pending after delivering", len(pending))
+    }
+}
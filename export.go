@@ -0,0 +1,270 @@
+package telephish
+
+import (
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/redact"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// exportColumns are the CSV columns, one row per alert.
+var exportColumns = []string{"time", "alert_id", "chat_id", "chat_type", "profile", "sender", "url", "domain", "severity", "title", "text", "findings", "actions", "rescan_of"}
+
+// exportCommand writes the alert history, filtered, as CSV or JSON Lines.
+func exportCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("export")
+    format := fs.String("format", "csv", "csv, or jsonl for one JSON history entry per line")
+    output := fs.String("o", "", "file to write to instead of stdout")
+    defang := fs.Bool("defang", false, "defang links, for sharing somewhere that would make them clickable")
+    filter := addFilterFlags(fs)
+    fs.Parse(args)
+    if *format != "csv" && *format != "jsonl" {
+        return fmt.Errorf("--format: want csv or jsonl, got %q", *format)
+    }
+    f, err := filter()
+    if err != nil {
+        return err
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+
+    var w io.Writer = os.Stdout
+    var file *os.File
+    if *output != "" {
+        // History holds message text, so keep the export as private as
+        // the database.
+        if file, err = os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err != nil {
+            return fmt.Errorf("failed to create %s: %v", *output, err)
+        }
+        defer file.Close()
+        w = file
+    }
+    write := exportJSONL(w, *defang)
+    if *format == "csv" {
+        write = exportCSV(w, *defang)
+    }
+    count := 0
+    err = history.Each(f, func(entry store.HistoryEntry) error {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if cfg.Redact.Exports {
+            redactEntry(cfg.Redact.Policy, &entry)
+        }
+        count++
+        return write(&entry)
+    })
+    if err == nil {
+        err = write(nil)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to export history: %v", err)
+    }
+    if file != nil {
+        if err := file.Close(); err != nil {
+            return fmt.Errorf("failed to write %s: %v", *output, err)
+        }
+        fmt.Fprintf(os.Stderr, "exported %d alerts to %s\n", count, *output)
+    }
+    return nil
+}
+
+// addFilterFlags adds the flags selecting history entries, returning a
+// function that builds the filter once they are parsed.
+func addFilterFlags(fs *flag.FlagSet) func() (store.HistoryFilter, error) {
+    since := fs.String("since", "", "only alerts at or after this date (2006-01-02), RFC 3339 time or duration ago (24h, 30d)")
+    until := fs.String("until", "", "only alerts before this date, time or duration ago")
+    chats := fs.String("chat", "", "only alerts from these chat IDs, comma separated")
+    domains := fs.String("domain", "", "only links to these domains or their subdomains, comma separated; * matches anything, as in *.example-bank.top")
+    senders := fs.String("sender", "", "only links sent by these @usernames or user IDs, comma separated")
+    severity := fs.String("severity", "clean", "only alerts at least this severe: clean, info, suspicious or malicious")
+    verdicts := fs.String("verdict", "", "only alerts with these verdicts, comma separated")
+    return func() (store.HistoryFilter, error) {
+        var f store.HistoryFilter
+        var err error
+        if f.Since, err = parseWhen(*since); err != nil {
+            return f, fmt.Errorf("--since: %v", err)
+        }
+        if f.Until, err = parseWhen(*until); err != nil {
+            return f, fmt.Errorf("--until: %v", err)
+        }
+        for _, chat := range splitList(*chats) {
+            id, err := strconv.ParseInt(chat, 10, 64)
+            if err != nil {
+                return f, fmt.Errorf("--chat: want chat IDs, got %q", chat)
+            }
+            f.Chats = append(f.Chats, id)
+        }
+        f.Domains = splitList(*domains)
+        for _, sender := range splitList(*senders) {
+            if _, err := strconv.ParseInt(sender, 10, 64); err != nil && !strings.HasPrefix(sender, "@") {
+                sender = "@" + sender
+            }
+            f.Senders = append(f.Senders, sender)
+        }
+        if f.Severity, err = analysis.ParseSeverity(*severity); err != nil {
+            return f, fmt.Errorf("--severity: %v", err)
+        }
+        for _, name := range splitList(*verdicts) {
+            v, err := analysis.ParseSeverity(name)
+            if err != nil {
+                return f, fmt.Errorf("--verdict: %v", err)
+            }
+            f.Verdicts = append(f.Verdicts, v)
+        }
+        return f, nil
+    }
+}
+
+// parseWhen parses a local date, an RFC 3339 time, or a duration before
+// now, which may be in days (30d). An empty s is the zero time.
+func parseWhen(s string) (time.Time, error) {
+    if s == "" {
+        return time.Time{}, nil
+    }
+    if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+        return t, nil
+    }
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    if days, ok := strings.CutSuffix(s, "d"); ok {
+        if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+            return time.Now().AddDate(0, 0, -n), nil
+        }
+    }
+    if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+        return time.Now().Add(-d), nil
+    }
+    return time.Time{}, fmt.Errorf("want a date such as 2006-01-02, an RFC 3339 time or a duration such as 24h or 30d, got %q", s)
+}
+
+// redactEntry masks the personal data in entry that p covers. The link is
+// kept, being what the alert is about.
+func redactEntry(p redact.Policy, entry *store.HistoryEntry) {
+    if !p.Enabled() {
+        return
+    }
+    a := &entry.Alert
+    entry.Text = p.Body(entry.Text)
+    a.Message = p.Body(a.Message)
+    a.Title = p.String(a.Title)
+    a.Sender = p.Sender(a.Sender)
+    findings := make([]analysis.Finding, len(a.Verdict.Findings))
+    for i, f := range a.Verdict.Findings {
+        f.Description = p.String(f.Description)
+        findings[i] = f
+    }
+    a.Verdict.Findings = findings
+    for i := range entry.Actions {
+        entry.Actions[i].Error = p.String(entry.Actions[i].Error)
+    }
+}
+
+// defangEntry defangs entry's link.
+func defangEntry(entry *store.HistoryEntry) {
+    entry.Alert.URL = notify.Defang(entry.Alert.URL)
+    entry.Alert.Verdict.URL = entry.Alert.URL
+}
+
+// exportJSONL returns a function writing each entry it is given to w as a
+// line of JSON, with its link defanged if defang is set; a nil entry ends
+// the export.
+func exportJSONL(w io.Writer, defang bool) func(*store.HistoryEntry) error {
+    enc := json.NewEncoder(w)
+    enc.SetEscapeHTML(false)
+    return func(entry *store.HistoryEntry) error {
+        if entry == nil {
+            return nil
+        }
+        if defang {
+            defangEntry(entry)
+        }
+        return enc.Encode(entry)
+    }
+}
+
+// exportCSV returns a function writing each entry it is given to w as a
+// CSV row, after a header; a nil entry flushes the rows. The domain column
+// holds both forms of an internationalized domain, as alerts show it, and
+// with defang set it is defanged along with the link.
+func exportCSV(w io.Writer, defang bool) func(*store.HistoryEntry) error {
+    cw := csv.NewWriter(w)
+    header := false
+    return func(entry *store.HistoryEntry) error {
+        if !header {
+            header = true
+            if err := cw.Write(exportColumns); err != nil {
+                return err
+            }
+        }
+        if entry == nil {
+            cw.Flush()
+            return cw.Error()
+        }
+        domain := extract.DisplayDomain(entry.Alert.URL)
+        if defang {
+            defangEntry(entry)
+            domain = notify.Defang(domain)
+        }
+        a := entry.Alert
+        findings := make([]string, len(a.Verdict.Findings))
+        for i, f := range a.Verdict.Findings {
+            findings[i] = f.Analyzer + ": " + f.Description
+        }
+        actions := make([]string, len(entry.Actions))
+        for i, act := range entry.Actions {
+            actions[i] = act.Sink + ": " + act.Status
+        }
+        rescanOf := ""
+        if entry.RescanOf != 0 {
+            rescanOf = strconv.FormatInt(entry.RescanOf, 10)
+        }
+        return cw.Write([]string{
+            entry.Time.Format(time.RFC3339),
+            a.ID,
+            strconv.FormatInt(a.ChatID, 10),
+            a.ChatType,
+            csvText(a.Profile),
+            csvText(a.Sender),
+            csvText(a.URL),
+            csvText(domain),
+            a.Verdict.Severity.String(),
+            csvText(a.Title),
+            csvText(entry.Text),
+            csvText(strings.Join(findings, "; ")),
+            strings.Join(actions, "; "),
+            rescanOf,
+        })
+    }
+}
+
+// csvText keeps a cell of message-derived text from being taken for a
+// formula when the CSV is opened in a spreadsheet, by quoting it with a
+// leading apostrophe if it starts like one.
+func csvText(s string) string {
+    if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+        return "'" + s
+    }
+    return s
+}
@@ -0,0 +1,49 @@
+package extract
+
+import (
+    "net/url"
+    "strings"
+)
+
+// trackingParams are query parameters that only say where a link was
+// shared from, so links differing only in them lead to the same page.
+var trackingParams = []string{"utm_", "fbclid", "gclid", "yclid", "mc_cid", "mc_eid", "igshid"}
+
+// Canonical returns link in a form that is the same for links to the same
+// page: the scheme and host lowercased, without a default port, trailing
+// dot, fragment or tracking parameters, and with the query sorted. A link
+// that doesn't parse is returned as it is.
+func Canonical(link string) string {
+    u, err := url.Parse(strings.TrimSpace(link))
+    if err != nil || u.Host == "" {
+        return link
+    }
+    u.Scheme = strings.ToLower(u.Scheme)
+    host, port := strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), u.Port()
+    if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+        port = ""
+    }
+    if strings.Contains(host, ":") {
+        host = "[" + host + "]"
+    }
+    u.Host = host
+    if port != "" {
+        u.Host += ":" + port
+    }
+    u.User, u.Fragment, u.RawFragment = nil, "", ""
+    if u.Path == "" {
+        u.Path = "/"
+    }
+
+    query := u.Query()
+    for name := range query {
+        for _, p := range trackingParams {
+            if name == p || (strings.HasSuffix(p, "_") && strings.HasPrefix(name, p)) {
+                query.Del(name)
+            }
+        }
+    }
+    // Encode sorts by name; values keep their order
+    u.RawQuery = query.Encode()
+    return u.String()
+}
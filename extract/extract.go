@@ -0,0 +1,73 @@
+// Package extract finds the links in Telegram messages and plain text.
+package extract
+
+import (
+    "regexp"
+    "unicode/utf8"
+
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// schemePattern matches the scheme at the start of a link.
+var schemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// URL extracts the first link from a message: the text of a url entity,
+// or the target of a text_link entity, a link hidden behind other text.
+// Telegram marks bare domains such as paypa1-login.xyz/verify as links
+// too; they are given http://, which is what a client opens them with.
+func URL(message *telegram.Message) string {
+    for _, entity := range message.Entities {
+        switch entity.Type {
+        case "url":
+            if link := Slice(message.Text, entity.Offset, entity.Length); link != "" {
+                if !schemePattern.MatchString(link) {
+                    link = "http://" + link
+                }
+                return link
+            }
+        case "text_link":
+            if entity.URL != "" {
+                return entity.URL
+            }
+        }
+    }
+    return ""
+}
+
+// Slice returns the part of text an entity covers. Telegram measures
+// entity offsets and lengths in UTF-16 code units, so a character outside
+// the Basic Multilingual Plane, such as most emoji, counts as two. An
+// entity that reaches outside text or splits such a character gives "".
+func Slice(text string, offset, length int) string {
+    if offset < 0 || length <= 0 {
+        return ""
+    }
+    end := offset + length
+    start, units := -1, 0
+    for i, r := range text {
+        if units == offset {
+            start = i
+        }
+        // Checked first, as the end can follow a split start
+        if units > offset && start < 0 || units > end {
+            return ""
+        }
+        if units == end {
+            return text[start:i]
+        }
+        units += utf16Len(r)
+    }
+    if units == end && start >= 0 {
+        return text[start:]
+    }
+    return ""
+}
+
+// utf16Len is how many UTF-16 code units r takes. Invalid UTF-8 is
+// decoded as U+FFFD, a single unit.
+func utf16Len(r rune) int {
+    if r > 0xFFFF && r <= utf8.MaxRune {
+        return 2
+    }
+    return 1
+}
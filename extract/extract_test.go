@@ -0,0 +1,73 @@
+package extract
+
+import (
+    "testing"
+
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+func TestSlice(t *testing.T) {
+    tests := []struct {
+        name           string
+        text           string
+        offset, length int
+        want           string
+    }{
+        {"ascii", "see https://a.example now", 4, 17, "https://a.example"},
+        {"whole text", "https://a.example", 0, 17, "https://a.example"},
+        {"after emoji", "😀 https://a.example", 3, 17, "https://a.example"},
+        {"after two emoji", "😀😀https://a.example", 4, 17, "https://a.example"},
+        {"emoji inside", "a😀b", 1, 2, "😀"},
+        {"BMP non-ASCII", "ключ https://a.example", 5, 17, "https://a.example"},
+        {"zero length", "abc", 1, 0, ""},
+        {"zero length after emoji", "😀a", 2, 0, ""},
+        {"negative offset", "abc", -1, 2, ""},
+        {"negative length", "abc", 0, -1, ""},
+        {"past the end", "abc", 2, 5, ""},
+        {"offset past the end", "abc", 4, 1, ""},
+        {"start splits a pair", "😀a", 1, 1, ""},
+        {"start splits a pair at the end", "a😀", 2, 1, ""},
+        {"end splits a pair", "a😀b", 0, 2, ""},
+        {"start and end split pairs", "😀😀", 1, 2, ""},
+        {"empty text", "", 0, 1, ""},
+        {"huge length", "abc", 1, int(^uint(0) >> 1), ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Slice(tt.text, tt.offset, tt.length); got != tt.want {
+                t.Errorf("Slice(%q, %d, %d) = %q, want %q", tt.text, tt.offset, tt.length, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestURL(t *testing.T) {
+    url := func(offset, length int) telegram.Entity {
+        return telegram.Entity{Type: "url", Offset: offset, Length: length}
+    }
+    tests := []struct {
+        name     string
+        text     string
+        entities []telegram.Entity
+        want     string
+    }{
+        {"with scheme", "see https://a.example/x", []telegram.Entity{url(4, 19)}, "https://a.example/x"},
+        {"bare domain", "paypa1-login.xyz/verify now", []telegram.Entity{url(0, 23)}, "http://paypa1-login.xyz/verify"},
+        {"bare domain after emoji", "🔒😀 paypa1-login.xyz/verify", []telegram.Entity{url(5, 23)}, "http://paypa1-login.xyz/verify"},
+        {"bare domain after Cyrillic", "Вход: paypa1-login.xyz", []telegram.Entity{url(6, 16)}, "http://paypa1-login.xyz"},
+        {"bare domain with a port", "a.example:8080/login", []telegram.Entity{url(0, 20)}, "http://a.example:8080/login"},
+        {"bare domain with a link in the query", "a.example/?r=https://b.example", []telegram.Entity{url(0, 30)}, "http://a.example/?r=https://b.example"},
+        {"uppercase scheme", "HTTPS://A.EXAMPLE", []telegram.Entity{url(0, 17)}, "HTTPS://A.EXAMPLE"},
+        {"other scheme", "ftp://a.example/f", []telegram.Entity{url(0, 17)}, "ftp://a.example/f"},
+        {"text_link", "click here", []telegram.Entity{{Type: "text_link", Offset: 0, Length: 10, URL: "https://a.example"}}, "https://a.example"},
+        {"entity splitting an emoji skipped", "😀a.example b.example", []telegram.Entity{url(1, 10), url(12, 9)}, "http://b.example"},
+        {"no entities", "a.example", nil, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := URL(&telegram.Message{Text: tt.text, Entities: tt.entities}); got != tt.want {
+                t.Errorf("URL = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
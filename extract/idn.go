@@ -0,0 +1,240 @@
+package extract
+
+import (
+    "errors"
+    "net/url"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// HostWarning marks a host whose Unicode form isn't what its letters
+// spell in ASCII, such as аpple.com with a Cyrillic а.
+const HostWarning = "⚠"
+
+// HostForms returns host, lowercased, in its Unicode form and in its
+// ASCII form with each non-ASCII label punycoded as xn--. Labels that
+// can't be converted are kept as they are in both.
+func HostForms(host string) (string, string) {
+    labels := strings.Split(strings.ToLower(host), ".")
+    uni, asc := make([]string, len(labels)), make([]string, len(labels))
+    for i, label := range labels {
+        uni[i], asc[i] = label, label
+        if strings.HasPrefix(label, "xn--") {
+            // Nor is a label decoding to invisible or control characters,
+            // which could hide or reorder what is shown around it
+            if decoded, err := punyDecode(label[4:]); err == nil && graphic(decoded) {
+                uni[i] = decoded
+            }
+        } else if !isASCII(label) {
+            if encoded, err := punyEncode(label); err == nil {
+                asc[i] = "xn--" + encoded
+            }
+        }
+    }
+    return strings.Join(uni, "."), strings.Join(asc, ".")
+}
+
+// DisplayHost returns host as people should see it: as it is if it is
+// plain ASCII, and otherwise in both forms, with a warning, so that a
+// lookalike can't pass for the domain it imitates, e.g.
+// "⚠ аpple.com (xn--pple-43d.com)".
+func DisplayHost(host string) string {
+    uni, asc := HostForms(host)
+    if uni == asc {
+        return asc
+    }
+    return HostWarning + " " + uni + " (" + asc + ")"
+}
+
+// DisplayDomain returns the host of link as DisplayHost shows it, or ""
+// if link has none.
+func DisplayDomain(link string) string {
+    u, err := url.Parse(link)
+    if err != nil || u.Hostname() == "" {
+        return ""
+    }
+    return DisplayHost(u.Hostname())
+}
+
+// IsIDN reports whether host has a Unicode form different from its ASCII
+// one.
+func IsIDN(host string) bool {
+    uni, asc := HostForms(host)
+    return uni != asc
+}
+
+func graphic(s string) bool {
+    for _, r := range s {
+        if !unicode.IsGraphic(r) || unicode.Is(unicode.Bidi_Control, r) {
+            return false
+        }
+    }
+    return true
+}
+
+func isASCII(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if s[i] >= utf8.RuneSelf {
+            return false
+        }
+    }
+    return true
+}
+
+// Punycode parameters, from RFC 3492.
+const (
+    punyBase        = 36
+    punyTMin        = 1
+    punyTMax        = 26
+    punySkew        = 38
+    punyDamp        = 700
+    punyInitialBias = 72
+    punyInitialN    = 128
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+func punyAdapt(delta, points int, first bool) int {
+    if first {
+        delta /= punyDamp
+    } else {
+        delta /= 2
+    }
+    delta += delta / points
+    k := 0
+    for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+        delta /= punyBase - punyTMin
+        k += punyBase
+    }
+    return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+    if d < 26 {
+        return byte('a' + d)
+    }
+    return byte('0' + d - 26)
+}
+
+// punyEncode returns the punycode of label, without the xn-- prefix.
+func punyEncode(label string) (string, error) {
+    runes := []rune(label)
+    var out []byte
+    for _, r := range runes {
+        if r < utf8.RuneSelf {
+            out = append(out, byte(r))
+        }
+    }
+    basic := len(out)
+    handled := basic
+    if basic > 0 {
+        out = append(out, '-')
+    }
+    n, delta, bias := punyInitialN, 0, punyInitialBias
+    for handled < len(runes) {
+        m := int(utf8.MaxRune) + 1
+        for _, r := range runes {
+            if int(r) >= n && int(r) < m {
+                m = int(r)
+            }
+        }
+        if (m-n)*(handled+1) < 0 {
+            return "", errPunycode
+        }
+        delta += (m - n) * (handled + 1)
+        n = m
+        for _, r := range runes {
+            if int(r) < n {
+                delta++
+            }
+            if int(r) == n {
+                q := delta
+                for k := punyBase; ; k += punyBase {
+                    t := k - bias
+                    if t < punyTMin {
+                        t = punyTMin
+                    } else if t > punyTMax {
+                        t = punyTMax
+                    }
+                    if q < t {
+                        break
+                    }
+                    out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+                    q = (q - t) / (punyBase - t)
+                }
+                out = append(out, punyDigit(q))
+                bias = punyAdapt(delta, handled+1, handled == basic)
+                delta = 0
+                handled++
+            }
+        }
+        delta++
+        n++
+    }
+    return string(out), nil
+}
+
+// punyDecode returns the label the punycode s, without the xn-- prefix,
+// stands for.
+func punyDecode(s string) (string, error) {
+    var out []rune
+    pos := 0
+    if i := strings.LastIndexByte(s, '-'); i >= 0 {
+        for _, c := range s[:i] {
+            if c >= utf8.RuneSelf {
+                return "", errPunycode
+            }
+            out = append(out, c)
+        }
+        pos = i + 1
+    }
+    n, i, bias := punyInitialN, 0, punyInitialBias
+    for pos < len(s) {
+        oldi, w := i, 1
+        for k := punyBase; ; k += punyBase {
+            if pos == len(s) {
+                return "", errPunycode
+            }
+            c := s[pos]
+            pos++
+            var digit int
+            switch {
+            case c >= 'a' && c <= 'z':
+                digit = int(c - 'a')
+            case c >= 'A' && c <= 'Z':
+                digit = int(c - 'A')
+            case c >= '0' && c <= '9':
+                digit = int(c-'0') + 26
+            default:
+                return "", errPunycode
+            }
+            i += digit * w
+            if i < 0 || i > utf8.MaxRune*(len(out)+1) {
+                return "", errPunycode
+            }
+            t := k - bias
+            if t < punyTMin {
+                t = punyTMin
+            } else if t > punyTMax {
+                t = punyTMax
+            }
+            if digit < t {
+                break
+            }
+            w *= punyBase - t
+            if w > utf8.MaxRune*(len(out)+1) {
+                return "", errPunycode
+            }
+        }
+        bias = punyAdapt(i-oldi, len(out)+1, oldi == 0)
+        n += i / (len(out) + 1)
+        i %= len(out) + 1
+        if n > utf8.MaxRune {
+            return "", errPunycode
+        }
+        out = append(out[:i], append([]rune{rune(n)}, out[i:]...)...)
+        i++
+    }
+    return string(out), nil
+}
@@ -0,0 +1,44 @@
+package extract
+
+import (
+    "regexp"
+    "strings"
+)
+
+// linkPattern matches http(s) links in plain text, up to the next space,
+// quote or angle bracket.
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'\x60]+`)
+
+// Links finds the http(s) links in plain text that has no entities, such
+// as text copied to the clipboard, in order and without repeats.
+// Punctuation ending a sentence or closing brackets around a link is left
+// off it.
+func Links(text string) []string {
+    var links []string
+    seen := map[string]bool{}
+    for _, link := range linkPattern.FindAllString(text, -1) {
+        link = trimLink(link)
+        if !seen[link] && strings.Contains(link[strings.Index(link, "://")+3:], ".") {
+            seen[link] = true
+            links = append(links, link)
+        }
+    }
+    return links
+}
+
+// trimLink drops trailing punctuation from link, and closing brackets it
+// doesn't open.
+func trimLink(link string) string {
+    for {
+        trimmed := strings.TrimRight(link, ".,;:!?*")
+        for _, pair := range []string{"()", "[]", "{}"} {
+            if strings.HasSuffix(trimmed, pair[1:]) && strings.Count(trimmed, pair[:1]) < strings.Count(trimmed, pair[1:]) {
+                trimmed = trimmed[:len(trimmed)-1]
+            }
+        }
+        if trimmed == link {
+            return link
+        }
+        link = trimmed
+    }
+}
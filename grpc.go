@@ -0,0 +1,175 @@
+package telephish
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative api/telephish/v1/telephish.proto
+
+import (
+    "context"
+    "crypto/subtle"
+    "net"
+    "net/url"
+    "strings"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    "google.golang.org/protobuf/types/known/timestamppb"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    telephishv1 "github.com/hacker1337itme/telephish/api/telephish/v1"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// ServeGRPC serves the Telephish gRPC service on the configured address
+// until ctx is cancelled. It does nothing if no address is configured.
+func (a *App) ServeGRPC(ctx context.Context) error {
+    cfg := a.Config.GRPC
+    if cfg.Listen == "" {
+        return nil
+    }
+    listener, err := net.Listen("tcp", cfg.Listen)
+    if err != nil {
+        return err
+    }
+    auth := grpcAuth{token: a.Config.Admin.Token}
+    opts := []grpc.ServerOption{
+        grpc.UnaryInterceptor(auth.unary),
+        grpc.StreamInterceptor(auth.stream),
+    }
+    if cfg.CertFile != "" {
+        creds, err := credentials.NewServerTLSFromFile(cfg.CertFile, cfg.KeyFile)
+        if err != nil {
+            listener.Close()
+            return err
+        }
+        opts = append(opts, grpc.Creds(creds))
+    }
+    server := grpc.NewServer(opts...)
+    telephishv1.RegisterTelephishServer(server, &grpcService{app: a})
+
+    go func() {
+        <-ctx.Done()
+        // Streams only end when clients hang up, so don't wait for them
+        server.Stop()
+    }()
+    go func() {
+        if err := server.Serve(listener); err != nil {
+            appLog.Error("gRPC server stopped", "err", err)
+        }
+    }()
+    appLog.Info("serving gRPC", "listen", cfg.Listen, "tls", cfg.CertFile != "")
+    return nil
+}
+
+// grpcAuth checks every call for the admin token as a bearer token in the
+// authorization metadata.
+type grpcAuth struct {
+    token string
+}
+
+func (g grpcAuth) unary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+    if err := g.authorize(ctx); err != nil {
+        return nil, err
+    }
+    return handler(ctx, req)
+}
+
+func (g grpcAuth) stream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+    if err := g.authorize(ss.Context()); err != nil {
+        return err
+    }
+    return handler(srv, ss)
+}
+
+func (g grpcAuth) authorize(ctx context.Context) error {
+    md, _ := metadata.FromIncomingContext(ctx)
+    for _, value := range md.Get("authorization") {
+        token, ok := strings.CutPrefix(value, "Bearer ")
+        if ok && subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) == 1 {
+            return nil
+        }
+    }
+    return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+// grpcService implements telephishv1.TelephishServer on top of the App.
+type grpcService struct {
+    telephishv1.UnimplementedTelephishServer
+    app *App
+}
+
+func (s *grpcService) Scan(ctx context.Context, req *telephishv1.ScanRequest) (*telephishv1.ScanResponse, error) {
+    if u, err := url.Parse(req.GetUrl()); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return nil, status.Errorf(codes.InvalidArgument, "url: want an absolute http or https URL, got %q", req.GetUrl())
+    }
+    entry := s.app.ScanSubmitted(ctx, req.GetUrl(), req.GetText(), req.GetNotify(), "grpc")
+    return &telephishv1.ScanResponse{Alert: alertToProto(entry)}, nil
+}
+
+func (s *grpcService) StreamAlerts(req *telephishv1.StreamAlertsRequest, stream telephishv1.Telephish_StreamAlertsServer) error {
+    min := severityFromProto(req.GetMinSeverity())
+    chats := map[int64]bool{}
+    for _, id := range req.GetChats() {
+        chats[id] = true
+    }
+
+    entries, unsubscribe := s.app.Alerts.Subscribe()
+    defer unsubscribe()
+    for {
+        select {
+        case <-stream.Context().Done():
+            return nil
+        case entry := <-entries:
+            if entry.Alert.Verdict.Severity < min || (len(chats) > 0 && !chats[entry.Alert.ChatID]) {
+                continue
+            }
+            if err := stream.Send(alertToProto(entry)); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+func severityToProto(s analysis.Severity) telephishv1.Severity {
+    return telephishv1.Severity(int32(s) + 1)
+}
+
+func severityFromProto(s telephishv1.Severity) analysis.Severity {
+    if s == telephishv1.Severity_SEVERITY_UNSPECIFIED {
+        return analysis.SeverityClean
+    }
+    return analysis.Severity(s - 1)
+}
+
+func alertToProto(entry store.HistoryEntry) *telephishv1.Alert {
+    a := entry.Alert
+    out := &telephishv1.Alert{
+        Id:       entry.ID,
+        Time:     timestamppb.New(entry.Time),
+        AlertId:  a.ID,
+        Url:      a.URL,
+        Title:    a.Title,
+        Message:  a.Message,
+        ChatId:   a.ChatID,
+        ChatType: a.ChatType,
+        Text:     entry.Text,
+        Verdict:  &telephishv1.Verdict{Url: a.Verdict.URL, Severity: severityToProto(a.Verdict.Severity)},
+    }
+    for _, f := range a.Verdict.Findings {
+        out.Verdict.Findings = append(out.Verdict.Findings, &telephishv1.Finding{
+            Analyzer:    f.Analyzer,
+            Severity:    severityToProto(f.Severity),
+            Description: f.Description,
+        })
+    }
+    for _, act := range entry.Actions {
+        out.Actions = append(out.Actions, &telephishv1.Action{
+            Time:   timestamppb.New(act.Time),
+            Sink:   act.Sink,
+            Status: act.Status,
+            Error:  act.Error,
+        })
+    }
+    return out
+}
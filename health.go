@@ -0,0 +1,233 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "net"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// pollStaleAfter is how long the poller may go without a getUpdates round
+// trip before it counts as wedged. Long polls last 30s.
+const pollStaleAfter = 2 * time.Minute
+
+// HealthStatus is the body of /healthz and /readyz for requests with the
+// admin token.
+type HealthStatus struct {
+    OK            bool                     `json:"ok"`
+    Profile       string                   `json:"profile,omitempty"`
+    Mode          string                   `json:"mode,omitempty"` // poll or webhook, empty until started
+    LastPoll      time.Time                `json:"last_poll,omitempty"`
+    LastPollError string                   `json:"last_poll_error,omitempty"`
+    LastUpdate    time.Time                `json:"last_update,omitempty"`
+    InFlight      int64                    `json:"in_flight"`          // Updates being scanned
+    Waiting       int                      `json:"waiting"`            // Updates queued for a worker
+    Queued        int                      `json:"queued"`             // Alerts held for the next digest
+    Deliveries    int                      `json:"deliveries"`         // Deliveries waiting in the delivery queue
+    DeadLetters   int                      `json:"dead_letters"`       // Deliveries that ran out of attempts
+    Chats         []ChatQueueStatus        `json:"chats,omitempty"`    // Busy chat lanes, with workers.per_chat
+    Breakers      []analysis.BreakerStatus `json:"breakers,omitempty"` // Of the remote analyzers
+    Problems      []string                 `json:"problems,omitempty"` // Fixed descriptions, without error text
+    Build         *BuildInfo               `json:"build,omitempty"`    // Only at the top level
+
+    // Components has the supervisor's view of the poller, and of the
+    // workers, sinks and other components once one has panicked.
+    Components map[string]ComponentStatus `json:"components,omitempty"`
+
+    // Profiles has the status of each profile when several are running;
+    // the fields above are then the first profile's, and OK and Problems
+    // cover them all.
+    Profiles []HealthStatus `json:"profiles,omitempty"`
+}
+
+// health is what the poller and webhook report about themselves.
+type health struct {
+    mu         sync.Mutex
+    mode       string
+    startedAt  time.Time
+    lastPoll   time.Time
+    pollErr    error
+    lastUpdate time.Time
+}
+
+func (h *health) started(mode string) {
+    h.mu.Lock()
+    h.mode, h.startedAt = mode, time.Now()
+    h.mu.Unlock()
+}
+
+func (h *health) polled(err error) {
+    h.mu.Lock()
+    h.lastPoll, h.pollErr = time.Now(), err
+    h.mu.Unlock()
+}
+
+func (h *health) received() {
+    h.mu.Lock()
+    h.lastUpdate = time.Now()
+    h.mu.Unlock()
+}
+
+// Live reports whether the monitor is making progress: a poller that
+// hasn't returned from getUpdates in pollStaleAfter is not.
+func (a *App) Live() HealthStatus {
+    return a.withPeers((*App).live)
+}
+
+// Ready reports whether the monitor is live and connected: it has started,
+// and the last getUpdates call succeeded.
+func (a *App) Ready() HealthStatus {
+    return a.withPeers((*App).ready)
+}
+
+// withPeers returns a's status, and with profiles, each profile's status
+// with their problems combined, along with the build metadata.
+func (a *App) withPeers(status func(*App) HealthStatus) HealthStatus {
+    s := status(a)
+    if len(a.peers) > 0 {
+        s.Profiles = []HealthStatus{s}
+        for _, peer := range a.peers {
+            s.Profiles = append(s.Profiles, status(peer))
+        }
+        s.Problems = nil
+        for _, p := range s.Profiles {
+            for _, problem := range p.Problems {
+                s.Problems = append(s.Problems, p.Profile+": "+problem)
+            }
+        }
+        s.OK = len(s.Problems) == 0
+    }
+    build := Build()
+    s.Build = &build
+    return s
+}
+
+func (a *App) live() HealthStatus {
+    a.health.mu.Lock()
+    progress := a.health.lastPoll
+    if progress.IsZero() {
+        progress = a.health.startedAt
+    }
+    s := HealthStatus{
+        Profile:    a.Config.Profile,
+        Mode:       a.health.mode,
+        LastPoll:   a.health.lastPoll,
+        LastUpdate: a.health.lastUpdate,
+        InFlight:   a.inFlight.Load(),
+        Waiting:    a.waiting(),
+    }
+    if a.health.pollErr != nil {
+        s.LastPollError = a.health.pollErr.Error()
+    }
+    a.health.mu.Unlock()
+    if a.lanes != nil {
+        s.Chats = a.lanes.Status()
+    }
+    a.mu.RLock()
+    if a.digest != nil {
+        s.Queued = a.digest.Pending()
+    }
+    s.Breakers = a.Scanner.Breakers()
+    a.mu.RUnlock()
+    if a.deliveries != nil {
+        // Dead letters want looking at, but don't stop the monitor working
+        filter := store.DeliveryFilter{Profile: a.Config.Profile}
+        if n, err := a.History.CountDeliveries(filter); err == nil {
+            s.Deliveries = n
+        }
+        filter.Dead = true
+        if n, err := a.History.CountDeliveries(filter); err == nil {
+            s.DeadLetters = n
+        }
+    }
+
+    if s.Mode == "poll" && time.Since(progress) > pollStaleAfter {
+        s.Problems = append(s.Problems, "no getUpdates round trip since "+progress.Format(time.RFC3339))
+    }
+    var down []string
+    s.Components, down = a.sup.status()
+    s.Problems = append(s.Problems, down...)
+    s.OK = len(s.Problems) == 0
+    return s
+}
+
+func (a *App) ready() HealthStatus {
+    s := a.live()
+    if s.Mode == "" {
+        s.Problems = append(s.Problems, "not started")
+    }
+    // The error itself is in LastPollError; problems go on to heartbeat
+    // URLs and chats, which needn't see what a proxy or resolver said
+    if s.LastPollError != "" {
+        s.Problems = append(s.Problems, "Telegram unreachable")
+    }
+    s.OK = len(s.Problems) == 0
+    return s
+}
+
+// healthSummary is the body of /healthz and /readyz for anyone without
+// the admin token: whether the check passes, and if not, why.
+type healthSummary struct {
+    OK       bool     `json:"ok"`
+    Problems []string `json:"problems,omitempty"`
+}
+
+// AdminHandler serves /healthz (liveness) and /readyz (readiness), each
+// answering 200 or 503, and the dashboard and API if an admin token is
+// configured. The checks are open to probes, which only get a
+// healthSummary; a request with the admin token, as for the API, gets the
+// whole HealthStatus, which names chats, hosts and errors.
+func (a *App) AdminHandler() http.Handler {
+    token := a.Config.Admin.Token
+    mux := http.NewServeMux()
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { writeHealth(w, r, token, a.Live()) })
+    mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) { writeHealth(w, r, token, a.Ready()) })
+    if token != "" {
+        mux.Handle("/", a.Dashboard())
+        mux.Handle("/api/", a.API())
+    }
+    return mux
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, token string, s HealthStatus) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    if !s.OK {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+    if token != "" && hasToken(r, token) {
+        json.NewEncoder(w).Encode(s)
+        return
+    }
+    json.NewEncoder(w).Encode(healthSummary{OK: s.OK, Problems: s.Problems})
+}
+
+// ServeAdmin serves AdminHandler on the configured admin address until ctx
+// is cancelled. It does nothing if no address is configured.
+func (a *App) ServeAdmin(ctx context.Context) error {
+    addr := a.Config.Admin.Listen
+    if addr == "" {
+        return nil
+    }
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    server := &http.Server{Handler: a.AdminHandler(), ReadHeaderTimeout: 10 * time.Second}
+    go func() {
+        <-ctx.Done()
+        server.Close()
+    }()
+    go func() {
+        if err := server.Serve(listener); err != http.ErrServerClosed {
+            appLog.Error("admin server stopped", "err", err)
+        }
+    }()
+    appLog.Info("serving admin endpoints", "listen", addr, "dashboard", a.Config.Admin.Token != "")
+    return nil
+}
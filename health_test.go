@@ -0,0 +1,90 @@
+package telephish
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/hacker1337itme/telephish/telephishtest"
+)
+
+func TestHealthDetailsNeedToken(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+    app.Config.Admin.Token = "s3cret"
+    handler := app.AdminHandler()
+
+    tests := []struct {
+        name     string
+        path     string
+        auth     func(r *http.Request)
+        code     int
+        detailed bool
+    }{
+        {"live, no token", "/healthz", func(r *http.Request) {}, http.StatusOK, false},
+        {"live, wrong token", "/healthz", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusOK, false},
+        {"live, bearer token", "/healthz", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }, http.StatusOK, true},
+        {"live, basic auth", "/healthz", func(r *http.Request) { r.SetBasicAuth("admin", "s3cret") }, http.StatusOK, true},
+        // Not started yet, so not ready
+        {"ready, no token", "/readyz", func(r *http.Request) {}, http.StatusServiceUnavailable, false},
+        {"ready, bearer token", "/readyz", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }, http.StatusServiceUnavailable, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest("GET", tt.path, nil)
+            tt.auth(req)
+            rec := httptest.NewRecorder()
+            handler.ServeHTTP(rec, req)
+            if rec.Code != tt.code {
+                t.Errorf("status %d, want %d", rec.Code, tt.code)
+            }
+            var body map[string]interface{}
+            if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+                t.Fatalf("body %q: %v", rec.Body, err)
+            }
+            if _, ok := body["ok"].(bool); !ok {
+                t.Errorf("body has no ok: %s", rec.Body)
+            }
+            _, hasBuild := body["build"]
+            if hasBuild != tt.detailed {
+                t.Errorf("detailed body %v, want %v: %s", hasBuild, tt.detailed, rec.Body)
+            }
+            if !tt.detailed {
+                for key := range body {
+                    if key != "ok" && key != "problems" {
+                        t.Errorf("body without the token has %q: %s", key, rec.Body)
+                    }
+                }
+            }
+            if tt.code != http.StatusOK {
+                if problems, _ := body["problems"].([]interface{}); len(problems) == 0 {
+                    t.Errorf("failing check without problems: %s", rec.Body)
+                }
+            }
+        })
+    }
+}
+
+func TestHealthWithoutToken(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+    app.Config.Admin.Token = ""
+
+    // Without a token configured, nobody gets the details
+    req := httptest.NewRequest("GET", "/healthz", nil)
+    req.Header.Set("Authorization", "Bearer ")
+    rec := httptest.NewRecorder()
+    app.AdminHandler().ServeHTTP(rec, req)
+    var body map[string]interface{}
+    if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := body["build"]; ok || rec.Code != http.StatusOK {
+        t.Errorf("got %d %s, want 200 with the summary", rec.Code, rec.Body)
+    }
+}
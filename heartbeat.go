@@ -0,0 +1,122 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// heartbeatClient pings the heartbeat URLs. A ping that takes longer than
+// this is as good as missed.
+var heartbeatClient = &http.Client{Transport: netutil.NewTransport(nil), Timeout: 10 * time.Second}
+
+// heartbeat is what StartHeartbeat remembers between checks.
+type heartbeat struct {
+    down     bool      // Whether the chat was last told the monitor isn't working
+    lastChat time.Time // When the chat was last told it is
+}
+
+// StartHeartbeat checks every heartbeat.interval until ctx is cancelled
+// whether the monitor, with every profile, is ready, and says so: it
+// pings heartbeat.url while it is, and heartbeat.fail_url while it isn't,
+// and tells heartbeat.chat when that changes and, every chat_interval,
+// that it is still working. A service expecting the pings, such as
+// healthchecks.io, raises the alarm when they stop, which covers the
+// monitor dying altogether. It does nothing without a URL or chat.
+func (a *App) StartHeartbeat(ctx context.Context) {
+    h := a.Config.Heartbeat
+    if h.URL == "" && h.FailURL == "" && h.Chat == 0 {
+        return
+    }
+    go func() {
+        var state heartbeat
+        // The first beat waits an interval, so the poller has had the
+        // chance to reach Telegram
+        ticker := time.NewTicker(h.Interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+            }
+            if err := a.sup.protect("heartbeat", func() { a.beat(ctx, &state) }); err != nil {
+                appLog.Error("heartbeat failed", "err", err)
+            }
+        }
+    }()
+}
+
+// beat checks the monitor's health once and reports it.
+func (a *App) beat(ctx context.Context, state *heartbeat) {
+    status := a.Ready()
+    a.mu.RLock()
+    cfg := a.Config
+    a.mu.RUnlock()
+    h := cfg.Heartbeat
+
+    ping, body := h.URL, "ok"
+    if !status.OK {
+        ping, body = h.FailURL, strings.Join(status.Problems, "\n")
+    }
+    if ping != "" {
+        if err := pingHeartbeat(ctx, ping, body); err != nil {
+            appLog.Warn("failed to ping heartbeat URL", "err", err)
+        }
+    }
+
+    if h.Chat == 0 {
+        return
+    }
+    var text string
+    switch {
+    case !status.OK && !state.down:
+        text = a.Loc.T("heartbeat.down", strings.Join(status.Problems, "; "))
+    case status.OK && state.down:
+        text = a.Loc.T("heartbeat.recovered")
+    case status.OK && h.ChatInterval > 0 && time.Since(state.lastChat) >= h.ChatInterval:
+        a.health.mu.Lock()
+        up := time.Since(a.health.startedAt).Round(time.Minute)
+        a.health.mu.Unlock()
+        text = a.Loc.T("heartbeat.alive", up)
+    default:
+        return
+    }
+    // While Telegram is unreachable this fails too, and is tried again
+    // next time
+    if err := telegram.SendMessage(ctx, cfg.Telegram.Token, h.Chat, text, ""); err != nil {
+        appLog.Warn("failed to send heartbeat to chat", "chat_id", h.Chat, "err", err)
+        return
+    }
+    state.down = !status.OK
+    if status.OK {
+        state.lastChat = time.Now()
+    }
+}
+
+// pingHeartbeat posts body to url, as healthchecks.io and similar
+// services take it.
+func pingHeartbeat(ctx context.Context, url, body string) error {
+    req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+    req.Header.Set("User-Agent", "telephish/"+version)
+    resp, err := heartbeatClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,82 @@
+// Package i18n translates the monitor's user-facing strings.
+package i18n
+
+import (
+    "embed"
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+// DefaultLocale is used when no locale is configured, and for any string a
+// translation is missing.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Localizer looks up user-facing strings in the embedded translation files.
+type Localizer struct {
+    Locale   string
+    messages map[string]string
+    fallback map[string]string
+}
+
+// NewLocalizer loads the translations for locale, e.g. "de" or "pt-BR".
+// A region-specific locale falls back to its base language.
+func NewLocalizer(locale string) (*Localizer, error) {
+    fallback, err := loadLocale(DefaultLocale)
+    if err != nil {
+        return nil, err
+    }
+    if locale == "" {
+        locale = DefaultLocale
+    }
+    locale = strings.ReplaceAll(strings.ToLower(locale), "_", "-")
+
+    for _, candidate := range []string{locale, strings.SplitN(locale, "-", 2)[0]} {
+        messages, err := loadLocale(candidate)
+        if err == nil {
+            return &Localizer{Locale: candidate, messages: messages, fallback: fallback}, nil
+        }
+    }
+    return nil, fmt.Errorf("unsupported locale %q (available: %s)", locale, strings.Join(Locales(), ", "))
+}
+
+func loadLocale(locale string) (map[string]string, error) {
+    data, err := localeFiles.ReadFile("locales/" + locale + ".json")
+    if err != nil {
+        return nil, err
+    }
+    var messages map[string]string
+    if err := json.Unmarshal(data, &messages); err != nil {
+        return nil, fmt.Errorf("failed to parse locale %s: %v", locale, err)
+    }
+    return messages, nil
+}
+
+// Locales lists the embedded locales.
+func Locales() []string {
+    entries, _ := localeFiles.ReadDir("locales")
+    var locales []string
+    for _, entry := range entries {
+        locales = append(locales, strings.TrimSuffix(entry.Name(), ".json"))
+    }
+    return locales
+}
+
+// T returns the translation for key formatted with args. Missing
+// translations fall back to English, then to the key itself.
+func (l *Localizer) T(key string, args ...interface{}) string {
+    msg, ok := l.messages[key]
+    if !ok {
+        msg, ok = l.fallback[key]
+    }
+    if !ok {
+        msg = key
+    }
+    if len(args) > 0 {
+        return fmt.Sprintf(msg, args...)
+    }
+    return msg
+}
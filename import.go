@@ -0,0 +1,424 @@
+package telephish
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/internal/netutil"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Limits on indicator feeds.
+const (
+    maxFeedBytes = 64 << 20
+    feedTimeout  = time.Minute
+)
+
+// indicator is a domain or URL read from a feed.
+type indicator struct {
+    value   string
+    url     bool
+    note    string
+    expires time.Time // Zero if the feed doesn't say
+}
+
+// refanger undoes the usual defanging of indicators, e.g. hxxp://evil[.]com.
+var refanger = strings.NewReplacer("[.]", ".", "(.)", ".", "{.}", ".", "[:]", ":", "hxxp", "http", "hXXp", "http")
+
+// newIndicator classifies value as a domain or a URL, or returns false if
+// it is neither.
+func newIndicator(value, note string) (indicator, bool) {
+    value = refanger.Replace(strings.TrimSpace(value))
+    if value == "" {
+        return indicator{}, false
+    }
+    if strings.Contains(value, "://") || strings.Contains(value, "/") {
+        return indicator{value: value, url: true, note: note}, true
+    }
+    return indicator{value: value, note: note}, true
+}
+
+// parseIndicators reads the indicators in data, a feed in format: text,
+// csv, stix or misp, or auto to tell from name and the data itself.
+func parseIndicators(format, name string, data []byte) ([]indicator, error) {
+    if format == "auto" {
+        format = feedFormat(name, data)
+    }
+    switch format {
+    case "text":
+        return parseText(data), nil
+    case "csv":
+        return parseCSV(data)
+    case "stix":
+        return parseSTIX(data)
+    case "misp":
+        return parseMISP(data)
+    }
+    return nil, fmt.Errorf("unknown format %q; want text, csv, stix or misp", format)
+}
+
+// feedFormat guesses the format of a feed.
+func feedFormat(name string, data []byte) string {
+    trimmed := bytes.TrimSpace(data)
+    if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+        var probe struct {
+            Type string `json:"type"`
+        }
+        if json.Unmarshal(trimmed, &probe) == nil && probe.Type == "bundle" {
+            return "stix"
+        }
+        return "misp"
+    }
+    if strings.EqualFold(filepath.Ext(name), ".csv") {
+        return "csv"
+    }
+    return "text"
+}
+
+// parseText reads one indicator per line. Comments start with #, and
+// hosts-file lines such as "0.0.0.0 evil.example" give their host name.
+func parseText(data []byte) []indicator {
+    var indicators []indicator
+    for _, line := range strings.Split(string(data), "\n") {
+        line, _, _ = strings.Cut(line, "#")
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            continue
+        }
+        value := fields[0]
+        if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+            value = fields[1]
+        }
+        if ind, ok := newIndicator(value, ""); ok {
+            indicators = append(indicators, ind)
+        }
+    }
+    return indicators
+}
+
+// csvColumns are the headers naming a CSV feed's indicator column, and
+// its comment column.
+var (
+    csvColumns     = []string{"indicator", "ioc", "domain", "hostname", "host", "url", "value"}
+    csvNoteColumns = []string{"comment", "description", "note", "tags"}
+)
+
+// parseCSV reads the indicator column of a CSV feed, the one whose header
+// is in csvColumns, or the first if there is no header.
+func parseCSV(data []byte) ([]indicator, error) {
+    r := csv.NewReader(bytes.NewReader(data))
+    r.FieldsPerRecord = -1
+    r.Comment = '#'
+    records, err := r.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("invalid CSV: %v", err)
+    }
+    if len(records) == 0 {
+        return nil, nil
+    }
+    column, note := -1, -1
+    find := func(names []string) int {
+        for _, name := range names {
+            for i, header := range records[0] {
+                if strings.EqualFold(strings.TrimSpace(header), name) {
+                    return i
+                }
+            }
+        }
+        return -1
+    }
+    if column = find(csvColumns); column >= 0 {
+        note = find(csvNoteColumns)
+        records = records[1:]
+    } else {
+        column = 0
+    }
+    var indicators []indicator
+    for _, record := range records {
+        if column >= len(record) {
+            continue
+        }
+        comment := ""
+        if note >= 0 && note < len(record) {
+            comment = record[note]
+        }
+        if ind, ok := newIndicator(record[column], comment); ok {
+            indicators = append(indicators, ind)
+        }
+    }
+    return indicators, nil
+}
+
+// stixPattern matches the comparisons in a STIX 2 pattern that name a
+// domain or URL.
+var stixPattern = regexp.MustCompile(`(domain-name|url):value\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+// parseSTIX reads the indicators and domain-name and url objects of a
+// STIX 2 bundle. Revoked indicators are skipped, and valid_until is kept
+// as the expiry.
+func parseSTIX(data []byte) ([]indicator, error) {
+    var bundle struct {
+        Type    string `json:"type"`
+        Objects []struct {
+            Type        string    `json:"type"`
+            Name        string    `json:"name"`
+            Pattern     string    `json:"pattern"`
+            PatternType string    `json:"pattern_type"`
+            Value       string    `json:"value"`
+            ValidUntil  time.Time `json:"valid_until"`
+            Revoked     bool      `json:"revoked"`
+        } `json:"objects"`
+    }
+    if err := json.Unmarshal(data, &bundle); err != nil {
+        return nil, fmt.Errorf("invalid STIX: %v", err)
+    }
+    if bundle.Type != "bundle" {
+        return nil, fmt.Errorf("invalid STIX: want a bundle, got %q", bundle.Type)
+    }
+    var indicators []indicator
+    for _, o := range bundle.Objects {
+        switch {
+        case o.Type == "indicator" && !o.Revoked && (o.PatternType == "" || o.PatternType == "stix"):
+            for _, m := range stixPattern.FindAllStringSubmatch(o.Pattern, -1) {
+                value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(m[2])
+                if ind, ok := newIndicator(value, o.Name); ok {
+                    ind.url, ind.expires = m[1] == "url", o.ValidUntil
+                    indicators = append(indicators, ind)
+                }
+            }
+        case o.Type == "domain-name" || o.Type == "url":
+            if ind, ok := newIndicator(o.Value, ""); ok {
+                ind.url = o.Type == "url"
+                indicators = append(indicators, ind)
+            }
+        }
+    }
+    return indicators, nil
+}
+
+// mispAttribute is an attribute of a MISP event or of one of its objects.
+type mispAttribute struct {
+    Type    string `json:"type"`
+    Value   string `json:"value"`
+    Comment string `json:"comment"`
+    ToIDS   bool   `json:"to_ids"`
+}
+
+type mispEvent struct {
+    Info      string          `json:"info"`
+    Attribute []mispAttribute `json:"Attribute"`
+    Object    []struct {
+        Attribute []mispAttribute `json:"Attribute"`
+    } `json:"Object"`
+}
+
+// parseMISP reads the domain, hostname and url attributes of a MISP
+// event export: one event, a list of them, or a search response. Only
+// attributes marked for IDS use (to_ids) are read.
+func parseMISP(data []byte) ([]indicator, error) {
+    type wrapped struct {
+        Event *mispEvent `json:"Event"`
+    }
+    var events []mispEvent
+    var one wrapped
+    var response struct {
+        Response []wrapped `json:"response"`
+    }
+    var list []wrapped
+    switch {
+    case json.Unmarshal(data, &one) == nil && one.Event != nil:
+        events = append(events, *one.Event)
+    case json.Unmarshal(data, &response) == nil && response.Response != nil:
+        list = response.Response
+    case json.Unmarshal(data, &list) == nil:
+    default:
+        return nil, fmt.Errorf("invalid MISP export: want an Event, a list of them or a search response")
+    }
+    for _, w := range list {
+        if w.Event != nil {
+            events = append(events, *w.Event)
+        }
+    }
+
+    var indicators []indicator
+    add := func(a mispAttribute, info string) {
+        if !a.ToIDS {
+            return
+        }
+        // Composite types such as domain|ip put the domain first
+        kind, _, _ := strings.Cut(a.Type, "|")
+        value, _, _ := strings.Cut(a.Value, "|")
+        note := a.Comment
+        if note == "" {
+            note = info
+        }
+        switch kind {
+        case "domain", "hostname":
+            if ind, ok := newIndicator(value, note); ok {
+                ind.url = false
+                indicators = append(indicators, ind)
+            }
+        case "url":
+            if ind, ok := newIndicator(value, note); ok {
+                ind.url = true
+                indicators = append(indicators, ind)
+            }
+        }
+    }
+    for _, e := range events {
+        for _, a := range e.Attribute {
+            add(a, e.Info)
+        }
+        for _, o := range e.Object {
+            for _, a := range o.Attribute {
+                add(a, e.Info)
+            }
+        }
+    }
+    return indicators, nil
+}
+
+// readFeed reads a feed from a file, an http or https URL, or stdin for -.
+func readFeed(ctx context.Context, source string) ([]byte, error) {
+    if source == "-" {
+        return readFeedBody(os.Stdin)
+    }
+    if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+        f, err := os.Open(source)
+        if err != nil {
+            return nil, err
+        }
+        defer f.Close()
+        return readFeedBody(f)
+    }
+    ctx, cancel := context.WithTimeout(ctx, feedTimeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+    if err != nil {
+        return nil, err
+    }
+    client := &http.Client{Transport: netutil.NewTransport(nil)}
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("%s returned %s", source, resp.Status)
+    }
+    return readFeedBody(resp.Body)
+}
+
+// readFeedBody reads r, refusing a feed larger than maxFeedBytes rather
+// than importing part of it: a cut-off last line could name another
+// domain.
+func readFeedBody(r io.Reader) ([]byte, error) {
+    data, err := io.ReadAll(io.LimitReader(r, maxFeedBytes+1))
+    if err != nil {
+        return nil, err
+    }
+    if len(data) > maxFeedBytes {
+        return nil, fmt.Errorf("feed is larger than %d MiB", maxFeedBytes>>20)
+    }
+    return data, nil
+}
+
+// importCommand puts the domains of an indicator feed on the blocklist,
+// or the allowlist, tagged with where they came from.
+func importCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("import")
+    format := fs.String("format", "auto", "text, csv, stix or misp; auto tells from the file")
+    list := fs.String("list", analysis.ListBlock, "the list to import into: block or allow")
+    source := fs.String("source", "", "name of the feed, tagging its entries (default the file name)")
+    expire := fs.Duration("expire", 0, "how long the entries count, unless the feed says; 0 for ever")
+    urls := fs.Bool("urls", false, "also list the hosts of URL indicators, which may be shared sites")
+    replace := fs.Bool("replace", false, "take off what this source put on the list before")
+    dryRun := fs.Bool("dry-run", false, "only say what would be imported")
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        return fmt.Errorf("usage: %s import [--format f] [--list block|allow] [--source name] [--expire duration] [--urls] [--replace] <file|url|->", os.Args[0])
+    }
+    if *list != analysis.ListBlock && *list != analysis.ListAllow {
+        return fmt.Errorf("--list: want block or allow, got %q", *list)
+    }
+    feed := fs.Arg(0)
+    if *source == "" {
+        *source = strings.TrimSuffix(filepath.Base(feed), filepath.Ext(feed))
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if cfg.Lists == "" {
+        return fmt.Errorf("lists: set a lists file to import into")
+    }
+    data, err := readFeed(ctx, feed)
+    if err != nil {
+        return fmt.Errorf("failed to read %s: %v", feed, err)
+    }
+    indicators, err := parseIndicators(*format, feed, data)
+    if err != nil {
+        return fmt.Errorf("%s: %v", feed, err)
+    }
+
+    entries := map[string]analysis.ListEntry{}
+    skippedURLs := 0
+    for _, ind := range indicators {
+        domain := ind.value
+        if ind.url {
+            if !*urls {
+                skippedURLs++
+                continue
+            }
+            if !strings.Contains(domain, "://") {
+                domain = "http://" + domain
+            }
+        }
+        e := analysis.ListEntry{Note: ind.note, Expires: ind.expires}
+        if e.Expires.IsZero() && *expire > 0 {
+            e.Expires = time.Now().Add(*expire).UTC()
+        }
+        entries[domain] = e
+    }
+    if *dryRun {
+        fmt.Printf("would import up to %d domains from %s to the %slist", len(entries), *source, *list)
+        if skippedURLs > 0 {
+            fmt.Printf(", skipping %d URLs", skippedURLs)
+        }
+        fmt.Println()
+        return nil
+    }
+
+    lists, err := analysis.LoadLists(cfg.Lists)
+    if err != nil {
+        return err
+    }
+    added, skipped, err := lists.Import(*list, *source, entries, *replace)
+    if err != nil {
+        return err
+    }
+    auditConfigured(cfg, store.AuditEvent{Actor: cliActor(), Action: "import", Target: *source,
+        Reason: fmt.Sprintf("%d domains to the %slist", added, *list)})
+    fmt.Printf("imported %d domains from %s to the %slist\n", added, *source, *list)
+    if skipped > 0 {
+        fmt.Printf("skipped %d invalid domains, or ones listed by hand or on the other list\n", skipped)
+    }
+    if skippedURLs > 0 {
+        fmt.Printf("skipped %d URLs; pass --urls to list their hosts\n", skippedURLs)
+    }
+    fmt.Println("reload a running monitor to use them")
+    return nil
+}
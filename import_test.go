@@ -0,0 +1,125 @@
+package telephish
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParseFeeds(t *testing.T) {
+    until := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+    tests := []struct {
+        file    string
+        format  string
+        want    []indicator
+        wantErr string
+    }{
+        {"stix-bundle.json", "auto", []indicator{
+            {value: "login-paypa1.example", note: "PayPal credential phishing", expires: until},
+            {value: "http://secure-paypa1.example/signin", url: true, note: "PayPal credential phishing", expires: until},
+            {value: `http://o'brien.example/a\b`, url: true, note: "Quoted"},
+            {value: "observed.example"},
+            {value: "https://observed.example/verify", url: true},
+        }, ""},
+        {"stix-truncated.json", "stix", nil, "invalid STIX"},
+        {"stix-not-bundle.json", "stix", nil, `want a bundle, got "indicator"`},
+        {"misp-event.json", "auto", []indicator{
+            {value: "login-paypa1.example", note: "landing page"},
+            {value: "https://secure-paypa1.example/signin", url: true, note: "Credential phishing campaign"},
+            {value: "mail-paypa1.example", note: "Credential phishing campaign"},
+            {value: "cdn.paypa1.example", note: "Credential phishing campaign"},
+        }, ""},
+        {"misp-search.json", "auto", []indicator{
+            {value: "first.example", note: "First"},
+            {value: "http://second.example/x", url: true, note: "Second"},
+        }, ""},
+        {"misp-list.json", "misp", []indicator{{value: "listed.example", note: "Listed"}}, ""},
+        {"misp-malformed.json", "misp", nil, "invalid MISP export"},
+        {"stix-bundle.json", "misp", nil, "invalid MISP export"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.file+" as "+tt.format, func(t *testing.T) {
+            data, err := os.ReadFile(filepath.Join("testdata", "feeds", tt.file))
+            if err != nil {
+                t.Fatal(err)
+            }
+            got, err := parseIndicators(tt.format, tt.file, data)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Errorf("err = %v, want %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("indicators:\n%+v\nwant:\n%+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestFeedFormat(t *testing.T) {
+    tests := []struct {
+        name, data, want string
+    }{
+        {"feed.json", `{"type": "bundle", "objects": []}`, "stix"},
+        {"feed.json", `  {"Event": {}}`, "misp"},
+        {"feed.json", `[{"Event": {}}]`, "misp"},
+        {"feed.json", `{"type": "bundle"`, "misp"},
+        {"domains.CSV", "domain,comment\nevil.example,x\n", "csv"},
+        {"domains.txt", "evil.example\n", "text"},
+        {"-", "", "text"},
+    }
+    for _, tt := range tests {
+        if got := feedFormat(tt.name, []byte(tt.data)); got != tt.want {
+            t.Errorf("feedFormat(%q, %q) = %q, want %q", tt.name, tt.data, got, tt.want)
+        }
+    }
+}
+
+func TestReadFeedRefusesOversized(t *testing.T) {
+    dir := t.TempDir()
+    fits, oversized := filepath.Join(dir, "fits.txt"), filepath.Join(dir, "oversized.txt")
+    for path, size := range map[string]int64{fits: maxFeedBytes, oversized: maxFeedBytes + 1} {
+        f, err := os.Create(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := f.Truncate(size); err != nil {
+            t.Fatal(err)
+        }
+        f.Close()
+    }
+    ctx := context.Background()
+    if data, err := readFeed(ctx, fits); err != nil || len(data) != maxFeedBytes {
+        t.Errorf("reading a feed of the largest size: %d bytes, %v", len(data), err)
+    }
+    if _, err := readFeed(ctx, oversized); err == nil || !strings.Contains(err.Error(), "larger than 64 MiB") {
+        t.Errorf("reading an oversized file: %v, want it refused", err)
+    }
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        io.Copy(w, io.LimitReader(zeros{}, maxFeedBytes+1))
+    }))
+    defer srv.Close()
+    if _, err := readFeed(ctx, srv.URL+"/feed.txt"); err == nil || !strings.Contains(err.Error(), "larger than 64 MiB") {
+        t.Errorf("downloading an oversized feed: %v, want it refused", err)
+    }
+}
+
+// zeros reads as endless zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+    clear(p)
+    return len(p), nil
+}
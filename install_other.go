@@ -0,0 +1,15 @@
+//go:build !windows
+
+package telephish
+
+import "fmt"
+
+// Install is only needed for Windows toasts.
+func Install(configPath string) error {
+    return fmt.Errorf("install is only supported on Windows")
+}
+
+// Uninstall is only needed for Windows toasts.
+func Uninstall() error {
+    return fmt.Errorf("uninstall is only supported on Windows")
+}
@@ -0,0 +1,216 @@
+//go:build windows
+
+package telephish
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "syscall"
+    "unsafe"
+
+    "github.com/go-ole/go-ole"
+    "golang.org/x/sys/windows/registry"
+)
+
+var (
+    clsidShellLink    = ole.NewGUID("{00021401-0000-0000-C000-000000000046}")
+    iidShellLinkW     = ole.NewGUID("{000214F9-0000-0000-C000-000000000046}")
+    iidPropertyStore  = ole.NewGUID("{886D8EEB-8CF2-4446-8D02-CDBA1DBDCF99}")
+    iidPersistFile    = ole.NewGUID("{0000010B-0000-0000-C000-000000000046}")
+    fmtidAppUserModel = ole.NewGUID("{9F4C2855-9F79-4B39-A8D0-E1D42DE1D5F3}")
+)
+
+// Vtable slots used on the shell link and its property store.
+const (
+    shellLinkSetIconLocation = 17
+    shellLinkSetPath         = 20
+    propertyStoreSetValue    = 6
+    propertyStoreCommit      = 7
+    persistFileSave          = 6
+)
+
+// propertyKey mirrors PROPERTYKEY.
+type propertyKey struct {
+    fmtid ole.GUID
+    pid   uint32
+}
+
+// propVariant mirrors a PROPVARIANT holding a VT_LPWSTR.
+type propVariant struct {
+    vt       uint16
+    reserved [3]uint16
+    val      uintptr
+    _        uintptr
+}
+
+const vtLPWSTR = 31
+
+// Install registers the AppUserModelID and creates the Start Menu shortcut
+// Windows needs before it will show toasts from an unpackaged app. The
+// toast buttons' actions run with the config at configPath, if set.
+func Install(configPath string) error {
+    exe, err := os.Executable()
+    if err != nil {
+        return fmt.Errorf("failed to locate executable: %v", err)
+    }
+
+    if err := registerAppID(); err != nil {
+        return err
+    }
+    if configPath != "" {
+        // Protocol links start in an arbitrary directory, so pin the
+        // config to an absolute path
+        if configPath, err = filepath.Abs(configPath); err != nil {
+            return err
+        }
+    }
+    if err := registerProtocol(exe, configPath); err != nil {
+        return err
+    }
+
+    if err := ole.CoInitialize(0); err != nil {
+        return fmt.Errorf("failed to initialize OLE: %v", err)
+    }
+    defer ole.CoUninitialize()
+
+    if err := createShortcut(shortcutPath(), exe); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Uninstall removes the shortcut, AppUserModelID and protocol registration.
+func Uninstall() error {
+    if err := os.Remove(shortcutPath()); err != nil && !os.IsNotExist(err) {
+        return fmt.Errorf("failed to remove shortcut: %v", err)
+    }
+    if err := registry.DeleteKey(registry.CURRENT_USER, appIDKeyPath()); err != nil && err != registry.ErrNotExist {
+        return fmt.Errorf("failed to remove AppUserModelID registration: %v", err)
+    }
+    // Keys must be deleted leaf first
+    for _, path := range []string{`\shell\open\command`, `\shell\open`, `\shell`, ``} {
+        if err := registry.DeleteKey(registry.CURRENT_USER, protocolKeyPath()+path); err != nil && err != registry.ErrNotExist {
+            return fmt.Errorf("failed to remove protocol registration: %v", err)
+        }
+    }
+    return nil
+}
+
+func protocolKeyPath() string {
+    return `Software\Classes\` + ProtocolScheme
+}
+
+// registerProtocol routes telephish: links, used by toast buttons such as
+// "Open in Sandbox", back to this executable's protocol command.
+func registerProtocol(exe, configPath string) error {
+    key, _, err := registry.CreateKey(registry.CURRENT_USER, protocolKeyPath(), registry.SET_VALUE)
+    if err != nil {
+        return fmt.Errorf("failed to register protocol: %v", err)
+    }
+    defer key.Close()
+    if err := key.SetStringValue("", "URL:"+AppName+" Protocol"); err != nil {
+        return fmt.Errorf("failed to register protocol: %v", err)
+    }
+    if err := key.SetStringValue("URL Protocol", ""); err != nil {
+        return fmt.Errorf("failed to register protocol: %v", err)
+    }
+
+    command, _, err := registry.CreateKey(registry.CURRENT_USER, protocolKeyPath()+`\shell\open\command`, registry.SET_VALUE)
+    if err != nil {
+        return fmt.Errorf("failed to register protocol command: %v", err)
+    }
+    defer command.Close()
+    line := fmt.Sprintf(`"%s" protocol`, exe)
+    if configPath != "" {
+        line += fmt.Sprintf(` --config "%s"`, configPath)
+    }
+    if err := command.SetStringValue("", line+` "%1"`); err != nil {
+        return fmt.Errorf("failed to register protocol command: %v", err)
+    }
+    return nil
+}
+
+func appIDKeyPath() string {
+    return `Software\Classes\AppUserModelId\` + AppID
+}
+
+func shortcutPath() string {
+    return filepath.Join(os.Getenv("APPDATA"), `Microsoft\Windows\Start Menu\Programs`, AppName+".lnk")
+}
+
+func registerAppID() error {
+    key, _, err := registry.CreateKey(registry.CURRENT_USER, appIDKeyPath(), registry.SET_VALUE)
+    if err != nil {
+        return fmt.Errorf("failed to register AppUserModelID: %v", err)
+    }
+    defer key.Close()
+
+    if err := key.SetStringValue("DisplayName", AppName); err != nil {
+        return fmt.Errorf("failed to set display name: %v", err)
+    }
+    if icon := os.Getenv("TELEPHISH_ICON"); icon != "" {
+        if err := key.SetStringValue("IconUri", icon); err != nil {
+            return fmt.Errorf("failed to set icon: %v", err)
+        }
+    }
+    return nil
+}
+
+// createShortcut writes a .lnk to target with System.AppUserModel.ID set,
+// which is what ties toasts from this process to the Start Menu entry.
+func createShortcut(path, target string) error {
+    link, err := ole.CreateInstance(clsidShellLink, iidShellLinkW)
+    if err != nil {
+        return fmt.Errorf("failed to create shell link: %v", err)
+    }
+    defer link.Release()
+
+    targetPtr, _ := syscall.UTF16PtrFromString(target)
+    if err := comCall(link, shellLinkSetPath, uintptr(unsafe.Pointer(targetPtr))); err != nil {
+        return fmt.Errorf("failed to set shortcut target: %v", err)
+    }
+    if err := comCall(link, shellLinkSetIconLocation, uintptr(unsafe.Pointer(targetPtr)), 0); err != nil {
+        return fmt.Errorf("failed to set shortcut icon: %v", err)
+    }
+
+    // Stamp the AppUserModelID on the shortcut
+    store, err := link.QueryInterface(iidPropertyStore)
+    if err != nil {
+        return fmt.Errorf("failed to query IPropertyStore: %v", err)
+    }
+    defer store.Release()
+
+    appIDPtr, _ := syscall.UTF16PtrFromString(AppID)
+    key := propertyKey{fmtid: *fmtidAppUserModel, pid: 5}
+    value := propVariant{vt: vtLPWSTR, val: uintptr(unsafe.Pointer(appIDPtr))}
+    if err := comCall(&store.IUnknown, propertyStoreSetValue, uintptr(unsafe.Pointer(&key)), uintptr(unsafe.Pointer(&value))); err != nil {
+        return fmt.Errorf("failed to set AppUserModelID: %v", err)
+    }
+    if err := comCall(&store.IUnknown, propertyStoreCommit); err != nil {
+        return fmt.Errorf("failed to commit shortcut properties: %v", err)
+    }
+
+    // Save the shortcut to disk
+    file, err := link.QueryInterface(iidPersistFile)
+    if err != nil {
+        return fmt.Errorf("failed to query IPersistFile: %v", err)
+    }
+    defer file.Release()
+
+    pathPtr, _ := syscall.UTF16PtrFromString(path)
+    if err := comCall(&file.IUnknown, persistFileSave, uintptr(unsafe.Pointer(pathPtr)), 1); err != nil {
+        return fmt.Errorf("failed to save shortcut: %v", err)
+    }
+    return nil
+}
+
+// comCall invokes the method at index in obj's vtable.
+func comCall(obj *ole.IUnknown, index int, args ...uintptr) error {
+    vtbl := (*[32]uintptr)(unsafe.Pointer(obj.RawVTable))
+    hr, _, _ := syscall.SyscallN(vtbl[index], append([]uintptr{uintptr(unsafe.Pointer(obj))}, args...)...)
+    if hr != 0 {
+        return ole.NewError(hr)
+    }
+    return nil
+}
@@ -0,0 +1,33 @@
+// Package fsutil writes the monitor's state files safely.
+package fsutil
+
+import (
+    "os"
+    "path/filepath"
+)
+
+// WriteFile replaces path with data by writing a temporary file
+// beside it and renaming it into place, so readers see the old or the new
+// contents and never a partial write.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+    tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name()) // No-op once renamed
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmp.Name(), perm); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,36 @@
+// Package netutil holds the HTTP transport settings shared by the monitor's
+// clients.
+package netutil
+
+import (
+    "crypto/tls"
+    "net"
+    "net/http"
+    "syscall"
+    "time"
+)
+
+// NewTransport returns the transport settings every client starts from:
+// bounded dial and TLS handshake times, TLS 1.2 or later, and a small pool
+// of kept-alive connections per host. dial, if not nil, is the dialer's
+// Control function.
+func NewTransport(dial func(network, address string, c syscall.RawConn) error) *http.Transport {
+    dialer := NewDialer(dial, nil)
+    return &http.Transport{
+        Proxy:                 http.ProxyFromEnvironment,
+        DialContext:           dialer.DialContext,
+        ForceAttemptHTTP2:     true,
+        MaxIdleConns:          100,
+        MaxIdleConnsPerHost:   4,
+        IdleConnTimeout:       90 * time.Second,
+        TLSHandshakeTimeout:   10 * time.Second,
+        ExpectContinueTimeout: time.Second,
+        TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+    }
+}
+
+// NewDialer returns the dialer NewTransport connects with, resolving names
+// with resolver, or the system's if it is nil.
+func NewDialer(dial func(network, address string, c syscall.RawConn) error, resolver *net.Resolver) *net.Dialer {
+    return &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: dial, Resolver: resolver}
+}
@@ -0,0 +1,57 @@
+package netutil
+
+import (
+    "context"
+    "errors"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "syscall"
+    "testing"
+)
+
+func TestTransportControl(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer srv.Close()
+
+    var dialed []string
+    refuse := func(network, address string, _ syscall.RawConn) error {
+        dialed = append(dialed, address)
+        return errors.New("refused by control")
+    }
+    client := &http.Client{Transport: NewTransport(refuse)}
+    client.Transport.(*http.Transport).Proxy = nil
+    if _, err := client.Get(srv.URL); err == nil || !strings.Contains(err.Error(), "refused by control") {
+        t.Errorf("Get through a refusing control: %v", err)
+    }
+    if want := srv.Listener.Addr().String(); len(dialed) != 1 || dialed[0] != want {
+        t.Errorf("control saw %v, want [%s]", dialed, want)
+    }
+
+    client = &http.Client{Transport: NewTransport(nil)}
+    client.Transport.(*http.Transport).Proxy = nil
+    resp, err := client.Get(srv.URL)
+    if err != nil {
+        t.Fatalf("Get without a control: %v", err)
+    }
+    resp.Body.Close()
+}
+
+func TestDialerResolver(t *testing.T) {
+    asked := false
+    resolver := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+        asked = true
+        return nil, errors.New("resolver down")
+    }}
+    control := func(network, address string, _ syscall.RawConn) error {
+        t.Errorf("control called for %s with the resolver down", address)
+        return nil
+    }
+    if _, err := NewDialer(control, resolver).DialContext(context.Background(), "tcp", "phish.example:80"); err == nil {
+        t.Errorf("dialed a name the resolver couldn't resolve")
+    }
+    if !asked {
+        t.Errorf("the dialer didn't use its resolver")
+    }
+}
@@ -0,0 +1,108 @@
+// Package pdf writes simple PDF documents: lines of text in the standard
+// Helvetica fonts and filled rectangles, on A4 pages.
+package pdf
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+)
+
+// A4 page size, in points.
+const (
+    PageWidth  = 595
+    PageHeight = 842
+)
+
+// Document is a PDF being written, one page after another. Coordinates are
+// in points from the bottom left of the page.
+type Document struct {
+    pages []*bytes.Buffer
+}
+
+// NewPage starts a new page, which the drawing methods draw on.
+func (d *Document) NewPage() {
+    d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) page() *bytes.Buffer {
+    if len(d.pages) == 0 {
+        d.NewPage()
+    }
+    return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline starting at x, y. Characters the
+// standard fonts don't have are drawn as "?".
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+    font := "F1"
+    if bold {
+        font = "F2"
+    }
+    fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// Rect fills a rectangle with its bottom left corner at x, y in the color
+// rgb, e.g. 0xFF0000 for red.
+func (d *Document) Rect(x, y, w, h float64, rgb int) {
+    r, g, b := float64(rgb>>16&0xFF)/255, float64(rgb>>8&0xFF)/255, float64(rgb&0xFF)/255
+    fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg %.1f %.1f %.1f %.1f re f\n", r, g, b, x, y, w, h)
+}
+
+// Bytes returns the finished document.
+func (d *Document) Bytes() []byte {
+    d.page()
+    var out bytes.Buffer
+    var offsets []int
+    object := func(format string, args ...interface{}) {
+        offsets = append(offsets, out.Len())
+        fmt.Fprintf(&out, "%d 0 obj\n", len(offsets))
+        fmt.Fprintf(&out, format, args...)
+        out.WriteString("\nendobj\n")
+    }
+
+    // Objects 1 to 4 are the catalog, page tree and fonts; each page then
+    // takes two, itself and its contents
+    out.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+    var kids []string
+    for i := range d.pages {
+        kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+    }
+    object("<< /Type /Catalog /Pages 2 0 R >>")
+    object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+    object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+    object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+    for i, content := range d.pages {
+        object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+            PageWidth, PageHeight, 6+2*i)
+        object("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes())
+    }
+
+    xref := out.Len()
+    fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+    for _, offset := range offsets {
+        fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+    }
+    fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+    return out.Bytes()
+}
+
+// escape encodes s as the body of a PDF string in WinAnsiEncoding, which
+// for the Latin-1 characters printed here matches their code points.
+func escape(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        switch {
+        case r == '(' || r == ')' || r == '\\':
+            b.WriteByte('\\')
+            b.WriteRune(r)
+        case r >= 0x20 && r < 0x7F:
+            b.WriteRune(r)
+        case r >= 0xA0 && r <= 0xFF:
+            fmt.Fprintf(&b, "\\%03o", r)
+        default:
+            b.WriteByte('?')
+        }
+    }
+    return b.String()
+}
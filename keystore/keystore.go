@@ -0,0 +1,55 @@
+// Package keystore keeps secrets such as the bot token in the platform's
+// credential store: Credential Manager on Windows, where blobs are
+// protected with DPAPI, the login Keychain on macOS, and the Secret
+// Service through libsecret elsewhere. Secrets belong to the user who
+// stored them, so store them as the account the monitor runs as.
+package keystore
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Service names the monitor's entries in the credential store.
+const Service = "telephish"
+
+var (
+    // ErrNotFound is returned by Get and Delete when nothing is stored
+    // under a name.
+    ErrNotFound = errors.New("secret not found")
+    // ErrUnavailable is returned when the platform has no usable
+    // credential store, e.g. secret-tool isn't installed.
+    ErrUnavailable = errors.New("no credential store available")
+)
+
+// Get returns the secret stored under name.
+func Get(name string) (string, error) {
+    value, err := get(name)
+    if err != nil && err != ErrNotFound && err != ErrUnavailable {
+        return "", fmt.Errorf("failed to read %s from the credential store: %v", name, err)
+    }
+    return value, err
+}
+
+// Set stores value under name, replacing any secret stored there.
+func Set(name, value string) error {
+    if value == "" {
+        return fmt.Errorf("refusing to store an empty secret for %s", name)
+    }
+    if err := set(name, value); err != nil {
+        if err == ErrUnavailable {
+            return err
+        }
+        return fmt.Errorf("failed to store %s in the credential store: %v", name, err)
+    }
+    return nil
+}
+
+// Delete removes the secret stored under name.
+func Delete(name string) error {
+    err := del(name)
+    if err != nil && err != ErrNotFound && err != ErrUnavailable {
+        return fmt.Errorf("failed to delete %s from the credential store: %v", name, err)
+    }
+    return err
+}
@@ -0,0 +1,64 @@
+//go:build darwin
+
+package keystore
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os/exec"
+    "strings"
+)
+
+// errSecItemNotFound is the exit status of security(1) when there is no
+// matching item.
+const errSecItemNotFound = 44
+
+// security runs the security tool. Commands are given on stdin with -i so
+// that secrets never appear in the process list.
+func security(command string) (string, error) {
+    path, err := exec.LookPath("security")
+    if err != nil {
+        return "", ErrUnavailable
+    }
+    cmd := exec.Command(path, "-i")
+    cmd.Stdin = strings.NewReader(command + "\n")
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err := cmd.Run(); err != nil {
+        return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+    }
+    // In interactive mode failures are reported on stderr, not by the exit
+    // status.
+    if msg := strings.TrimSpace(stderr.String()); msg != "" {
+        if strings.Contains(msg, "could not be found") || strings.Contains(msg, fmt.Sprint(errSecItemNotFound)) {
+            return "", ErrNotFound
+        }
+        return "", errors.New(msg)
+    }
+    // Without a terminal there is no prompt, but don't rely on it
+    return strings.TrimPrefix(stdout.String(), "security> "), nil
+}
+
+// quote quotes s for the security tool's command parser.
+func quote(s string) string {
+    return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func get(name string) (string, error) {
+    out, err := security("find-generic-password -s " + quote(Service) + " -a " + quote(name) + " -w")
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimSuffix(out, "\n"), nil
+}
+
+func set(name, value string) error {
+    _, err := security("add-generic-password -U -s " + quote(Service) + " -a " + quote(name) + " -l " + quote(Service+" "+name) + " -w " + quote(value))
+    return err
+}
+
+func del(name string) error {
+    _, err := security("delete-generic-password -s " + quote(Service) + " -a " + quote(name))
+    return err
+}
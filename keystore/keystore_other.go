@@ -0,0 +1,60 @@
+//go:build !windows && !darwin
+
+package keystore
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os/exec"
+    "strings"
+)
+
+// secretTool runs libsecret's secret-tool with input on stdin, which is
+// how it takes the secret to store.
+func secretTool(input string, args ...string) (string, error) {
+    path, err := exec.LookPath("secret-tool")
+    if err != nil {
+        return "", ErrUnavailable
+    }
+    cmd := exec.Command(path, args...)
+    cmd.Stdin = strings.NewReader(input)
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err := cmd.Run(); err != nil {
+        var exit *exec.ExitError
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            return "", fmt.Errorf("%v: %s", err, msg)
+        }
+        if errors.As(err, &exit) && exit.ExitCode() == 1 {
+            // lookup and clear exit 1, silently, when nothing matches
+            return "", ErrNotFound
+        }
+        return "", err
+    }
+    return stdout.String(), nil
+}
+
+func get(name string) (string, error) {
+    out, err := secretTool("", "lookup", "service", Service, "name", name)
+    if err != nil {
+        return "", err
+    }
+    if out == "" {
+        return "", ErrNotFound
+    }
+    return out, nil
+}
+
+func set(name, value string) error {
+    _, err := secretTool(value, "store", "--label", Service+" "+name, "service", Service, "name", name)
+    return err
+}
+
+func del(name string) error {
+    if _, err := get(name); err != nil {
+        return err
+    }
+    _, err := secretTool("", "clear", "service", Service, "name", name)
+    return err
+}
@@ -0,0 +1,99 @@
+//go:build windows
+
+package keystore
+
+import (
+    "unsafe"
+
+    "golang.org/x/sys/windows"
+)
+
+var (
+    advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+    procCredReadW  = advapi32.NewProc("CredReadW")
+    procCredWriteW = advapi32.NewProc("CredWriteW")
+    procCredDelete = advapi32.NewProc("CredDeleteW")
+    procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+    credTypeGeneric         = 1
+    credPersistLocalMachine = 2 // Survives logoff; still readable only by this user
+)
+
+// credential is CREDENTIALW.
+type credential struct {
+    Flags              uint32
+    Type               uint32
+    TargetName         *uint16
+    Comment            *uint16
+    LastWritten        windows.Filetime
+    CredentialBlobSize uint32
+    CredentialBlob     *byte
+    Persist            uint32
+    AttributeCount     uint32
+    Attributes         uintptr
+    TargetAlias        *uint16
+    UserName           *uint16
+}
+
+// target is the Credential Manager entry for name, e.g.
+// telephish:telegram.token.
+func target(name string) (*uint16, error) {
+    return windows.UTF16PtrFromString(Service + ":" + name)
+}
+
+func get(name string) (string, error) {
+    t, err := target(name)
+    if err != nil {
+        return "", err
+    }
+    var cred *credential
+    r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(t)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+    if r == 0 {
+        if err == windows.ERROR_NOT_FOUND {
+            return "", ErrNotFound
+        }
+        return "", err
+    }
+    defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+    return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func set(name, value string) error {
+    t, err := target(name)
+    if err != nil {
+        return err
+    }
+    user, err := windows.UTF16PtrFromString(name)
+    if err != nil {
+        return err
+    }
+    blob := []byte(value)
+    cred := credential{
+        Type:               credTypeGeneric,
+        TargetName:         t,
+        CredentialBlobSize: uint32(len(blob)),
+        CredentialBlob:     &blob[0],
+        Persist:            credPersistLocalMachine,
+        UserName:           user,
+    }
+    if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+        return err
+    }
+    return nil
+}
+
+func del(name string) error {
+    t, err := target(name)
+    if err != nil {
+        return err
+    }
+    if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(t)), credTypeGeneric, 0); r == 0 {
+        if err == windows.ERROR_NOT_FOUND {
+            return ErrNotFound
+        }
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,52 @@
+package telephish
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+)
+
+// errLocked is returned by lockInstance when another process holds the
+// lock.
+var errLocked = errors.New("locked")
+
+// LockInstances takes the instance lock of each bot cfg watches, so that
+// launching the monitor twice for one bot fails instead of leaving two
+// getUpdates consumers fighting over the offset and alerting twice. The
+// locks are held until unlock is called or the process exits.
+func LockInstances(cfg *Config) (unlock func(), err error) {
+    var releases []func()
+    unlock = func() {
+        for i := len(releases) - 1; i >= 0; i-- {
+            releases[i]()
+        }
+    }
+    for _, p := range cfg.ProfileConfigs() {
+        release, holder, err := lockInstance(instanceLockName(p.Telegram.Token))
+        if err != nil {
+            unlock()
+            bot := "this bot"
+            if p.Profile != "" {
+                bot = "profile " + p.Profile + "'s bot"
+            }
+            if err != errLocked {
+                return nil, fmt.Errorf("failed to lock %s: %v", bot, err)
+            }
+            if holder != "" {
+                return nil, fmt.Errorf("telephish is already running for %s (%s); stop it first", bot, holder)
+            }
+            return nil, fmt.Errorf("telephish is already running for %s; stop it first", bot)
+        }
+        releases = append(releases, release)
+    }
+    return unlock, nil
+}
+
+// instanceLockName names the lock for the bot with token. It is derived
+// from the token, so two configs watching one bot share it, without
+// putting the token itself in a file or object name.
+func instanceLockName(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return "telephish-" + hex.EncodeToString(sum[:8])
+}
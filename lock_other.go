@@ -0,0 +1,61 @@
+//go:build !windows
+
+package telephish
+
+import (
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "syscall"
+)
+
+// lockInstance takes an flock on name.lock in lockDir, which also records
+// the holder's PID. The kernel drops the lock when the process exits, so a
+// crash never leaves it stuck. holder describes who has the lock if it's
+// taken.
+func lockInstance(name string) (release func(), holder string, err error) {
+    dir, err := lockDir()
+    if err != nil {
+        return nil, "", err
+    }
+    f, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_RDWR|os.O_CREATE, 0o600)
+    if err != nil {
+        return nil, "", err
+    }
+    if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+        defer f.Close()
+        if err != syscall.EWOULDBLOCK {
+            return nil, "", err
+        }
+        data := make([]byte, 32)
+        n, _ := f.Read(data)
+        if pid, perr := strconv.Atoi(strings.TrimSpace(string(data[:n]))); perr == nil {
+            holder = "pid " + strconv.Itoa(pid)
+        }
+        return nil, holder, errLocked
+    }
+    f.Truncate(0)
+    f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+    return func() {
+        syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+        f.Close()
+    }, "", nil
+}
+
+// lockDir returns the directory for lock files: the runtime directory
+// systemd gives the unit, or else the user's, or their cache directory.
+func lockDir() (string, error) {
+    if dir := os.Getenv("RUNTIME_DIRECTORY"); dir != "" {
+        return dir, nil
+    }
+    dir := os.Getenv("XDG_RUNTIME_DIR")
+    if dir == "" {
+        var err error
+        if dir, err = os.UserCacheDir(); err != nil {
+            dir = os.TempDir()
+        }
+    }
+    dir = filepath.Join(dir, "telephish")
+    return dir, os.MkdirAll(dir, 0o700)
+}
@@ -0,0 +1,29 @@
+package telephish
+
+import (
+    "golang.org/x/sys/windows"
+)
+
+// lockInstance creates a named mutex in the global namespace, so the
+// service and a copy started from a desktop session see each other.
+// Windows destroys it when the last handle closes, including when the
+// process dies.
+func lockInstance(name string) (release func(), holder string, err error) {
+    namePtr, err := windows.UTF16PtrFromString(`Global\` + name)
+    if err != nil {
+        return nil, "", err
+    }
+    handle, err := windows.CreateMutex(nil, false, namePtr)
+    // Access is denied if another user, such as the service account,
+    // created it
+    if err == windows.ERROR_ALREADY_EXISTS || err == windows.ERROR_ACCESS_DENIED {
+        if handle != 0 {
+            windows.CloseHandle(handle)
+        }
+        return nil, "", errLocked
+    }
+    if err != nil {
+        return nil, "", err
+    }
+    return func() { windows.CloseHandle(handle) }, "", nil
+}
@@ -0,0 +1,22 @@
+package telephish
+
+import "github.com/hacker1337itme/telephish/logging"
+
+// LogModules are the parts of the monitor whose level can be set on its
+// own with logging.levels.
+var LogModules = []string{"app", "poll", "webhook", "notify", "digest", "commands", "service", "systemd", "install", "capture", "delivery", "clipboard"}
+
+// Module loggers. Records carry a module attribute, and each module's level
+// can be raised or lowered independently.
+var (
+    appLog       = logging.Module("app")
+    pollLog      = logging.Module("poll")
+    webhookLog   = logging.Module("webhook")
+    notifyLog    = logging.Module("notify")
+    commandsLog  = logging.Module("commands")
+    serviceLog   = logging.Module("service")
+    systemdLog   = logging.Module("systemd")
+    installLog   = logging.Module("install")
+    deliveryLog  = logging.Module("delivery")
+    clipboardLog = logging.Module("clipboard")
+)
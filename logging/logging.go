@@ -0,0 +1,142 @@
+// Package logging sends the monitor's logs to text or JSON output with a
+// level per module, optionally to a rotated file.
+package logging
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "github.com/hacker1337itme/telephish/redact"
+)
+
+// Config controls log output.
+type Config struct {
+    Format string                `yaml:"format"` // text or json
+    Level  slog.Level            `yaml:"level"`
+    Levels map[string]slog.Level `yaml:"levels"` // Per-module overrides of Level
+
+    // File, if set, receives logs instead of stderr and is rotated.
+    File           string        `yaml:"file"`
+    MaxSizeMB      int           `yaml:"max_size_mb"`
+    RotateInterval time.Duration `yaml:"rotate_interval"`
+    MaxAgeDays     int           `yaml:"max_age_days"`
+    MaxBackups     int           `yaml:"max_backups"`
+    Compress       bool          `yaml:"compress"`
+
+    // Redact masks personal data in every record, and bot tokens even if
+    // it is zero. It is set from the top-level redact settings rather than
+    // under logging.
+    Redact redact.Policy `yaml:"-"`
+}
+
+// logOutput is where module loggers currently send records. It starts as
+// text on stderr and is replaced by Setup once the config is read.
+var logOutput atomic.Pointer[logState]
+
+type logState struct {
+    handler slog.Handler
+    level   slog.Level
+    levels  map[string]slog.Level
+}
+
+func init() {
+    logOutput.Store(&logState{handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})})
+}
+
+// Setup sends log records to w as text or JSON, filtered by the configured
+// levels and masked by the redaction policy.
+func Setup(cfg Config, w io.Writer) error {
+    opts := &slog.HandlerOptions{
+        Level:       slog.LevelDebug, // Filtered per module instead
+        ReplaceAttr: cfg.Redact.Attr,
+    }
+    var handler slog.Handler
+    switch cfg.Format {
+    case "", "text":
+        handler = slog.NewTextHandler(w, opts)
+    case "json":
+        handler = slog.NewJSONHandler(w, opts)
+    default:
+        return fmt.Errorf("logging.format: want text or json, got %q", cfg.Format)
+    }
+    logOutput.Store(&logState{handler: handler, level: cfg.Level, levels: cfg.Levels})
+    slog.SetDefault(slog.New(handler))
+    return nil
+}
+
+// Writer opens the configured log file, or returns fallback if logs aren't
+// going to a file.
+func Writer(cfg Config, fallback io.Writer) (io.Writer, error) {
+    if cfg.File == "" {
+        return fallback, nil
+    }
+    return OpenRotatingFile(cfg)
+}
+
+// ParseLevels parses per-module levels written as "poll=debug,notify=warn".
+func ParseLevels(spec string) (map[string]slog.Level, error) {
+    levels := map[string]slog.Level{}
+    for _, item := range strings.Split(spec, ",") {
+        if item = strings.TrimSpace(item); item == "" {
+            continue
+        }
+        module, name, ok := strings.Cut(item, "=")
+        if !ok {
+            return nil, fmt.Errorf("want module=level, got %q", item)
+        }
+        var level slog.Level
+        if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+            return nil, err
+        }
+        levels[strings.TrimSpace(module)] = level
+    }
+    return levels, nil
+}
+
+// Module returns the logger for a part of the monitor. Its records carry a
+// module attribute, and its level can be set apart from the others.
+func Module(module string) *slog.Logger {
+    return slog.New(moduleHandler{module: module})
+}
+
+// moduleHandler applies its module's level and hands records to the
+// current output, replaying any attributes and groups added with With.
+type moduleHandler struct {
+    module string
+    wrap   []func(slog.Handler) slog.Handler
+}
+
+func (h moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+    state := logOutput.Load()
+    min, ok := state.levels[h.module]
+    if !ok {
+        min = state.level
+    }
+    return level >= min
+}
+
+func (h moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+    handler := logOutput.Load().handler.WithAttrs([]slog.Attr{slog.String("module", h.module)})
+    for _, wrap := range h.wrap {
+        handler = wrap(handler)
+    }
+    return handler.Handle(ctx, r)
+}
+
+func (h moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h moduleHandler) WithGroup(name string) slog.Handler {
+    return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h moduleHandler) with(wrap func(slog.Handler) slog.Handler) slog.Handler {
+    return moduleHandler{module: h.module, wrap: append(h.wrap[:len(h.wrap):len(h.wrap)], wrap)}
+}
@@ -0,0 +1,173 @@
+package logging
+
+import (
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// backupTimeFormat names rotated files so they sort oldest first.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is a log file that is rotated once it passes MaxSize bytes
+// or has been written to for Interval. Rotated files are renamed with a
+// timestamp, optionally gzipped, and removed once there are more than
+// MaxBackups of them or they are older than MaxAge. Zero disables a limit.
+type RotatingFile struct {
+    Path       string
+    MaxSize    int64
+    Interval   time.Duration
+    MaxAge     time.Duration
+    MaxBackups int
+    Compress   bool
+
+    mu     sync.Mutex
+    file   *os.File
+    size   int64
+    opened time.Time
+}
+
+// OpenRotatingFile opens the log file described by cfg, appending to it if
+// it already exists.
+func OpenRotatingFile(cfg Config) (*RotatingFile, error) {
+    r := &RotatingFile{
+        Path:       cfg.File,
+        MaxSize:    int64(cfg.MaxSizeMB) << 20,
+        Interval:   cfg.RotateInterval,
+        MaxAge:     time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+        MaxBackups: cfg.MaxBackups,
+        Compress:   cfg.Compress,
+    }
+    if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create log directory: %v", err)
+    }
+    if err := r.open(); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+
+// Write appends p to the file, rotating first if p would take it over
+// MaxSize or the file is due by Interval.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if r.file == nil {
+        if err := r.open(); err != nil {
+            return 0, err
+        }
+    }
+    full := r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize
+    due := r.Interval > 0 && time.Since(r.opened) >= r.Interval
+    if full || due {
+        if err := r.rotate(); err != nil {
+            return 0, err
+        }
+    }
+    n, err := r.file.Write(p)
+    r.size += int64(n)
+    return n, err
+}
+
+// Close closes the current file.
+func (r *RotatingFile) Close() error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.file == nil {
+        return nil
+    }
+    err := r.file.Close()
+    r.file = nil
+    return err
+}
+
+func (r *RotatingFile) open() error {
+    f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+    if err != nil {
+        return fmt.Errorf("failed to open log file: %v", err)
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return fmt.Errorf("failed to open log file: %v", err)
+    }
+    r.file, r.size, r.opened = f, info.Size(), time.Now()
+    return nil
+}
+
+func (r *RotatingFile) rotate() error {
+    if err := r.file.Close(); err != nil {
+        return fmt.Errorf("failed to close log file: %v", err)
+    }
+    r.file = nil
+
+    ext := filepath.Ext(r.Path)
+    backup := strings.TrimSuffix(r.Path, ext) + "-" + time.Now().Format(backupTimeFormat) + ext
+    if err := os.Rename(r.Path, backup); err != nil {
+        return fmt.Errorf("failed to rotate log file: %v", err)
+    }
+    if r.Compress {
+        // A failed compression keeps the uncompressed backup.
+        if err := compressFile(backup); err == nil {
+            os.Remove(backup)
+        }
+    }
+    r.prune()
+    return r.open()
+}
+
+// prune removes backups beyond MaxBackups or older than MaxAge.
+func (r *RotatingFile) prune() {
+    ext := filepath.Ext(r.Path)
+    prefix := strings.TrimSuffix(r.Path, ext) + "-"
+    matches, _ := filepath.Glob(prefix + "[0-9]*" + ext)
+    compressed, _ := filepath.Glob(prefix + "[0-9]*" + ext + ".gz")
+    backups := append(matches, compressed...)
+    // Newest first; the timestamp in the name sorts chronologically.
+    sort.Slice(backups, func(i, j int) bool {
+        return strings.TrimSuffix(backups[i], ".gz") > strings.TrimSuffix(backups[j], ".gz")
+    })
+
+    for i, path := range backups {
+        expired := r.MaxBackups > 0 && i >= r.MaxBackups
+        if !expired && r.MaxAge > 0 {
+            if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > r.MaxAge {
+                expired = true
+            }
+        }
+        if expired {
+            os.Remove(path)
+        }
+    }
+}
+
+func compressFile(path string) error {
+    in, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+    if err != nil {
+        return err
+    }
+    gz := gzip.NewWriter(out)
+    if _, err := io.Copy(gz, in); err != nil {
+        out.Close()
+        os.Remove(path + ".gz")
+        return err
+    }
+    if err := gz.Close(); err != nil {
+        out.Close()
+        os.Remove(path + ".gz")
+        return err
+    }
+    return out.Close()
+}
@@ -0,0 +1,292 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "mime/multipart"
+    "net"
+    "net/textproto"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "sync"
+    "text/template"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// AbuseTemplate is the default body of abuse reports. It is plain text,
+// and the link in it defanged, so that neither the report nor a reply
+// quoting it can be clicked through to the site.
+const AbuseTemplate = `Hello,
+
+The link below is being used for phishing, sent to people in Telegram
+chats. Please take the site down{{if .Registrar}} or suspend the domain{{end}}.
+
+    {{.Defanged}}
+
+Domain:     {{.Host}}{{with .Registrar}} (registered through {{.}}){{end}}
+{{- with .IP}}
+Address:    {{.}}{{with $.Network}} ({{.}}){{end}}{{end}}
+Seen:       {{.Seen.UTC.Format "2006-01-02 15:04:05 MST"}}
+
+Why it was flagged:
+{{range .Findings}}  - {{.Description}}
+{{end}}
+{{- if .Screenshot}}
+A screenshot of the page is attached.{{end}} The attached evidence.json
+holds the full verdict. The link above is defanged (hxxp, [.]) so it can't
+be opened by accident.
+
+This report was generated by Telephish{{with .Reporter}} for {{.}}{{end}}.
+`
+
+// AbuseDetails are what a report template is given.
+type AbuseDetails struct {
+    URL        string
+    Defanged   string
+    Host       string
+    IP         string // The address the host resolved to, if it did
+    Registrar  string // The registrar's name, if known
+    Network    string // The hosting network's name, if known
+    Seen       time.Time
+    Findings   []analysis.Finding
+    Screenshot bool
+    Reporter   string // Who the report is from
+}
+
+// AbuseReport is how a link was reported.
+type AbuseReport struct {
+    Contacts []AbuseContact `json:"contacts"`
+    Draft    string         `json:"draft,omitempty"` // Path of the .eml file written, if any
+    Sent     bool           `json:"sent"`            // Whether it was emailed to the contacts
+    WebRisk  bool           `json:"web_risk"`        // Whether it was submitted to Google
+}
+
+// AbuseReporter reports phishing links to whoever can take them down: it
+// looks up the abuse contacts of the link's registrar and hosting network
+// over RDAP, and writes a report to them, with the page's screenshot and
+// the verdict attached, as a draft to review or sends it straight away.
+// With WebRisk it also submits the link to Google Safe Browsing.
+//
+// As a sink it only reports malicious links, and each domain only once
+// every Interval, so it can share a route with others.
+type AbuseReporter struct {
+    RDAPServer string
+    Mail       *EmailNotifier // Sends the reports; nil only writes drafts
+    From       string         // Reply-To of reports, and who they are for
+    CC         []string
+    Drafts     string // Directory reports are written to as .eml files
+    Template   *template.Template
+    WebRisk    *WebRiskSubmitter
+    Ledger     TicketLedger // Remembers reported domains; nil keeps them in memory
+    Interval   time.Duration
+    Resolver   *net.Resolver // Finds the host's address; nil is the system's
+
+    mu sync.Mutex
+}
+
+// NewAbuseReporter returns a reporter using the default template that
+// reports each domain at most once a week.
+func NewAbuseReporter(ledger TicketLedger) *AbuseReporter {
+    if ledger == nil {
+        ledger = &memoryLedger{tickets: map[string]memoryTicket{}}
+    }
+    return &AbuseReporter{
+        Template: template.Must(template.New("abuse").Parse(AbuseTemplate)),
+        Ledger:   ledger,
+        Interval: 7 * 24 * time.Hour,
+    }
+}
+
+// Notify reports the alert's link if its verdict is malicious and its
+// domain hasn't been reported within Interval.
+func (r *AbuseReporter) Notify(ctx context.Context, alert Alert) error {
+    if alert.Verdict.Severity < analysis.SeverityMalicious {
+        return nil
+    }
+    host := strings.ToLower(linkHost(alert))
+    if host == "" {
+        return nil
+    }
+    now := time.Now()
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if _, ok, err := r.Ledger.OpenTicket("abuse", host, now.Add(-r.Interval)); err != nil || ok {
+        return err
+    }
+    report, err := r.Report(ctx, alert, now)
+    // A report that got anywhere isn't made again when the delivery is
+    // retried, so nobody is emailed twice
+    if report != nil && (report.Sent || report.Draft != "" || report.WebRisk) {
+        if serr := r.Ledger.SaveTicket("abuse", host, "abuse:"+now.UTC().Format(time.RFC3339), now); err == nil {
+            err = serr
+        }
+    }
+    return err
+}
+
+// Report reports the alert's link, seen then, whatever its verdict.
+func (r *AbuseReporter) Report(ctx context.Context, alert Alert, seen time.Time) (*AbuseReport, error) {
+    host := linkHost(alert)
+    if host == "" {
+        return nil, fmt.Errorf("abuse: %q has no host to report", alert.URL)
+    }
+    details := AbuseDetails{
+        URL: alert.URL, Defanged: Defang(alert.URL), Host: host, Seen: seen,
+        Findings: alert.Verdict.Findings, Screenshot: alert.Screenshot != "", Reporter: r.From,
+    }
+    domain := host
+    if ip := net.ParseIP(host); ip != nil {
+        details.IP, domain = host, ""
+    } else {
+        resolver := r.Resolver
+        if resolver == nil {
+            resolver = net.DefaultResolver
+        }
+        if addrs, err := resolver.LookupIPAddr(ctx, host); err == nil && len(addrs) > 0 {
+            details.IP = addrs[0].IP.String()
+        }
+    }
+
+    report := &AbuseReport{}
+    contacts, lookupErr := LookupAbuse(ctx, r.RDAPServer, domain, details.IP)
+    report.Contacts = contacts
+    for _, c := range contacts {
+        if c.Role == "registrar" {
+            details.Registrar = c.Name
+        } else {
+            details.Network = c.Name
+        }
+    }
+    var errs []string
+    if r.WebRisk != nil {
+        comment := "Phishing link sent in Telegram chats, flagged by Telephish: " + findingsSummary(alert)
+        if err := r.WebRisk.Submit(ctx, alert.URL, comment); err != nil {
+            errs = append(errs, err.Error())
+        } else {
+            report.WebRisk = true
+        }
+    }
+
+    msg, err := r.compose(details, alert, contacts)
+    if err != nil {
+        return report, err
+    }
+    if r.Drafts != "" {
+        if err := os.MkdirAll(r.Drafts, 0o700); err != nil {
+            return report, err
+        }
+        name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102-150405"), strings.NewReplacer(":", "_", "/", "_").Replace(host))
+        report.Draft = filepath.Join(r.Drafts, name)
+        if err := os.WriteFile(report.Draft, msg, 0o600); err != nil {
+            return report, err
+        }
+    }
+    switch {
+    case lookupErr != nil:
+        errs = append(errs, fmt.Sprintf("no abuse contact found: %v", lookupErr))
+    case r.Mail != nil:
+        mail := *r.Mail
+        mail.To = append(abuseRecipients(contacts), r.CC...)
+        if err := mail.send(ctx, msg); err != nil {
+            errs = append(errs, fmt.Sprintf("failed to send abuse report: %v", err))
+        } else {
+            report.Sent = true
+        }
+    }
+    if len(errs) > 0 {
+        return report, fmt.Errorf("abuse: %s", strings.Join(errs, "; "))
+    }
+    return report, nil
+}
+
+// abuseRecipients returns the contacts' addresses, each once.
+func abuseRecipients(contacts []AbuseContact) []string {
+    var to []string
+    for _, c := range contacts {
+        if !slices.Contains(to, c.Email) {
+            to = append(to, c.Email)
+        }
+    }
+    return to
+}
+
+// compose renders the report as an email to contacts, attaching the
+// screenshot and the verdict as evidence.
+func (r *AbuseReporter) compose(details AbuseDetails, alert Alert, contacts []AbuseContact) ([]byte, error) {
+    var body bytes.Buffer
+    if err := r.Template.Execute(&body, details); err != nil {
+        return nil, fmt.Errorf("failed to render abuse report: %v", err)
+    }
+    // The evidence leaves out the chat, the sender and the message, which
+    // the recipients don't need to know
+    evidence, err := json.MarshalIndent(map[string]interface{}{
+        "url":     alert.URL,
+        "host":    details.Host,
+        "ip":      details.IP,
+        "seen":    details.Seen.UTC(),
+        "verdict": alert.Verdict,
+    }, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+
+    var msg bytes.Buffer
+    mw := multipart.NewWriter(&msg)
+    from := r.From
+    if r.Mail != nil && r.Mail.From != "" {
+        from = r.Mail.From
+    }
+    fmt.Fprintf(&msg, "From: %s\r\n", from)
+    fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(abuseRecipients(contacts), ", "))
+    if len(r.CC) > 0 {
+        fmt.Fprintf(&msg, "Cc: %s\r\n", strings.Join(r.CC, ", "))
+    }
+    if r.From != "" {
+        fmt.Fprintf(&msg, "Reply-To: %s\r\n", r.From)
+    }
+    fmt.Fprintf(&msg, "Subject: %s\r\n", encodeHeader("Phishing report: "+Defang(details.Host)))
+    fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+    fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+    attach := func(contentType, name string, data []byte) error {
+        header := textproto.MIMEHeader{
+            "Content-Type":              {contentType},
+            "Content-Transfer-Encoding": {"base64"},
+        }
+        if name != "" {
+            header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+        }
+        part, err := mw.CreatePart(header)
+        if err != nil {
+            return err
+        }
+        writeBase64(part, data)
+        return nil
+    }
+    if err := attach("text/plain; charset=utf-8", "", body.Bytes()); err != nil {
+        return nil, err
+    }
+    if alert.Screenshot != "" {
+        image, err := os.ReadFile(alert.Screenshot)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read screenshot: %v", err)
+        }
+        if err := attach("image/png", "screenshot.png", image); err != nil {
+            return nil, err
+        }
+    }
+    if err := attach("application/json", "evidence.json", evidence); err != nil {
+        return nil, err
+    }
+    if err := mw.Close(); err != nil {
+        return nil, err
+    }
+    return msg.Bytes(), nil
+}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+    "context"
+    "crypto/tls"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/nats-io/nats.go"
+    "github.com/nats-io/nats.go/jetstream"
+    "github.com/segmentio/kafka-go"
+    "github.com/segmentio/kafka-go/sasl"
+    "github.com/segmentio/kafka-go/sasl/plain"
+    "github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Delivery guarantees for the message bus sinks.
+const (
+    AtMostOnce  = "at-most-once"  // Fire and forget
+    AtLeastOnce = "at-least-once" // Wait for the bus to acknowledge; retries may duplicate
+)
+
+// BusKeys are what a bus message can be keyed by: its chat, sender, link
+// host, profile or alert ID, or nothing.
+var BusKeys = []string{"chat", "sender", "host", "profile", "id", "none"}
+
+// busMessage is the JSON published for each alert.
+type busMessage struct {
+    Time  time.Time `json:"time"`
+    Score int       `json:"score"`
+    Alert Alert     `json:"alert"`
+}
+
+// busPayload encodes alert for a bus. The link is left as received, as in
+// CEF records, for pipelines matching it against threat feeds.
+func busPayload(alert Alert) ([]byte, error) {
+    return json.Marshal(busMessage{Time: time.Now().UTC(), Score: Score(alert), Alert: alert})
+}
+
+// busKey returns the part of alert named by key, one of BusKeys.
+func busKey(alert Alert, key string) string {
+    switch key {
+    case "chat":
+        return strconv.FormatInt(alert.ChatID, 10)
+    case "sender":
+        return alert.Sender
+    case "host":
+        if u, err := url.Parse(alert.URL); err == nil {
+            return u.Hostname()
+        }
+    case "profile":
+        return alert.Profile
+    case "id":
+        return alert.ID
+    }
+    return ""
+}
+
+// KafkaNotifier publishes alerts to a Kafka topic. Messages are keyed, so
+// alerts sharing a key, such as one chat's, land in one partition in
+// order.
+type KafkaNotifier struct {
+    Key    string // One of BusKeys
+    writer *kafka.Writer
+}
+
+// KafkaOptions configures a Kafka sink beyond its brokers and topic.
+type KafkaOptions struct {
+    Key      string // One of BusKeys
+    Delivery string // AtLeastOnce waits for every in-sync replica
+    TLS      bool
+    SASL     string // plain, scram-sha-256 or scram-sha-512, or empty for none
+    Username string
+    Password string
+}
+
+// NewKafkaNotifier returns a sink publishing to topic through brokers.
+// Connections are made on the first alert.
+func NewKafkaNotifier(brokers []string, topic string, opts KafkaOptions) (*KafkaNotifier, error) {
+    transport := &kafka.Transport{ClientID: strings.ToLower(AppName), DialTimeout: sinkTimeout}
+    if opts.TLS {
+        transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+    }
+    var err error
+    if transport.SASL, err = kafkaMechanism(opts.SASL, opts.Username, opts.Password); err != nil {
+        return nil, err
+    }
+    w := &kafka.Writer{
+        Addr:         kafka.TCP(brokers...),
+        Topic:        topic,
+        Balancer:     &kafka.Hash{},
+        BatchTimeout: 10 * time.Millisecond,
+        RequiredAcks: kafka.RequireAll,
+        Transport:    transport,
+    }
+    if opts.Delivery == AtMostOnce {
+        w.RequiredAcks, w.MaxAttempts = kafka.RequireNone, 1
+    }
+    return &KafkaNotifier{Key: opts.Key, writer: w}, nil
+}
+
+func kafkaMechanism(name, username, password string) (sasl.Mechanism, error) {
+    switch name {
+    case "":
+        return nil, nil
+    case "plain":
+        return plain.Mechanism{Username: username, Password: password}, nil
+    case "scram-sha-256":
+        return scram.Mechanism(scram.SHA256, username, password)
+    case "scram-sha-512":
+        return scram.Mechanism(scram.SHA512, username, password)
+    }
+    return nil, fmt.Errorf("unknown Kafka SASL mechanism %q", name)
+}
+
+// Notify publishes the alert, waiting for the acknowledgement delivery
+// asks for.
+func (n *KafkaNotifier) Notify(ctx context.Context, alert Alert) error {
+    payload, err := busPayload(alert)
+    if err != nil {
+        return err
+    }
+    msg := kafka.Message{Value: payload, Headers: []kafka.Header{{Key: "id", Value: []byte(alert.ID)}}}
+    if key := busKey(alert, n.Key); key != "" {
+        msg.Key = []byte(key)
+    }
+    if _, ok := ctx.Deadline(); !ok {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, sinkTimeout)
+        defer cancel()
+    }
+    if err := n.writer.WriteMessages(ctx, msg); err != nil {
+        return fmt.Errorf("failed to publish to Kafka: %v", err)
+    }
+    return nil
+}
+
+// Close flushes and closes the connections to the brokers.
+func (n *KafkaNotifier) Close() error {
+    return n.writer.Close()
+}
+
+// NATSNotifier publishes alerts to a NATS subject, with the key, if any,
+// appended as its last token so subscribers can filter on it, e.g.
+// telephish.alerts.* or telephish.alerts.-1001234. At least once delivery
+// publishes through JetStream, which must have a stream on the subject,
+// and sets the alert ID as the message ID so JetStream drops duplicates.
+type NATSNotifier struct {
+    Subject  string
+    Key      string // One of BusKeys
+    Delivery string
+
+    conn *nats.Conn
+    js   jetstream.JetStream
+}
+
+// NATSOptions configures a NATS sink beyond its server and subject.
+type NATSOptions struct {
+    Key       string // One of BusKeys
+    Delivery  string // AtLeastOnce publishes through JetStream
+    Token     string
+    CredsFile string // NATS credentials file, for decentralized auth
+}
+
+// NewNATSNotifier returns a sink publishing to subject on the servers in
+// serverURL, a comma separated list. The connection is retried in the
+// background if the server is down.
+func NewNATSNotifier(serverURL, subject string, opts NATSOptions) (*NATSNotifier, error) {
+    natsOpts := []nats.Option{
+        nats.Name(strings.ToLower(AppName)),
+        nats.RetryOnFailedConnect(true),
+        nats.MaxReconnects(-1),
+        nats.Timeout(sinkTimeout),
+    }
+    if opts.Token != "" {
+        natsOpts = append(natsOpts, nats.Token(opts.Token))
+    }
+    if opts.CredsFile != "" {
+        natsOpts = append(natsOpts, nats.UserCredentials(opts.CredsFile))
+    }
+    conn, err := nats.Connect(serverURL, natsOpts...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+    }
+    n := &NATSNotifier{Subject: subject, Key: opts.Key, Delivery: opts.Delivery, conn: conn}
+    if opts.Delivery == AtLeastOnce {
+        if n.js, err = jetstream.New(conn); err != nil {
+            conn.Close()
+            return nil, fmt.Errorf("failed to set up JetStream: %v", err)
+        }
+    }
+    return n, nil
+}
+
+// subjectTokens replaces what NATS reserves in subject tokens.
+var subjectTokens = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_")
+
+// Notify publishes the alert, waiting for JetStream to store it with at
+// least once delivery.
+func (n *NATSNotifier) Notify(ctx context.Context, alert Alert) error {
+    payload, err := busPayload(alert)
+    if err != nil {
+        return err
+    }
+    subject := n.Subject
+    if key := busKey(alert, n.Key); key != "" {
+        subject += "." + subjectTokens.Replace(key)
+    }
+    if _, ok := ctx.Deadline(); !ok {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, sinkTimeout)
+        defer cancel()
+    }
+    if n.js != nil {
+        if _, err := n.js.Publish(ctx, subject, payload, jetstream.WithMsgID(alert.ID)); err != nil {
+            return fmt.Errorf("failed to publish to JetStream: %v", err)
+        }
+        return nil
+    }
+    if err := n.conn.Publish(subject, payload); err != nil {
+        return fmt.Errorf("failed to publish to NATS: %v", err)
+    }
+    return nil
+}
+
+// Close delivers what is still buffered and closes the connection.
+func (n *NATSNotifier) Close() error {
+    return n.conn.Drain()
+}
@@ -0,0 +1,16 @@
+package notify
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+// sinkTimeout bounds each call to a webhook or push sink.
+const sinkTimeout = 10 * time.Second
+
+// newSinkClient returns the client for webhook and push sinks.
+func newSinkClient() *http.Client {
+    return &http.Client{Transport: netutil.NewTransport(nil), Timeout: sinkTimeout}
+}
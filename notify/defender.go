@@ -0,0 +1,179 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// Microsoft endpoints the Defender sink signs in to and submits to.
+const (
+    defenderLoginURL    = "https://login.microsoftonline.com"
+    defenderGraphURL    = "https://graph.microsoft.com"
+    defenderEndpointURL = "https://api.securitycenter.microsoft.com"
+)
+
+// DefenderCategories are the categories a link can be submitted under.
+var DefenderCategories = []string{"phishing", "malware"}
+
+// DefenderNotifier submits malicious links to Microsoft through the Graph
+// threat submission API, so that once Microsoft confirms them SmartScreen
+// and Defender block them for everyone. With Indicators it also adds a
+// block indicator to the tenant's Defender for Endpoint, which blocks the
+// link on the organisation's own machines straight away.
+//
+// Alerts below malicious are ignored, so the sink can share a route with
+// others.
+type DefenderNotifier struct {
+    TenantID     string
+    ClientID     string
+    ClientSecret string
+    Category     string        // phishing or malware; default phishing
+    Indicators   bool          // Also add a Defender for Endpoint block indicator
+    Expiry       time.Duration // How long indicators last; 0 for ever
+
+    mu     sync.Mutex
+    tokens map[string]defenderToken // By scope
+}
+
+type defenderToken struct {
+    value   string
+    expires time.Time
+}
+
+// NewDefenderNotifier returns a sink signing in as the Entra ID app
+// registration clientID in tenant.
+func NewDefenderNotifier(tenant, clientID, secret string) *DefenderNotifier {
+    return &DefenderNotifier{TenantID: tenant, ClientID: clientID, ClientSecret: secret, tokens: map[string]defenderToken{}}
+}
+
+// Notify submits the alert's link if its verdict is malicious.
+func (n *DefenderNotifier) Notify(ctx context.Context, alert Alert) error {
+    if alert.Verdict.Severity < analysis.SeverityMalicious {
+        return nil
+    }
+    return n.Submit(ctx, alert.URL)
+}
+
+// Submit submits link to Microsoft and, with Indicators, blocks it across
+// the tenant.
+func (n *DefenderNotifier) Submit(ctx context.Context, link string) error {
+    u, err := url.Parse(link)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("refusing to submit %q: not an http(s) URL", link)
+    }
+    category := n.Category
+    if category == "" {
+        category = "phishing"
+    }
+    submission := map[string]interface{}{
+        "@odata.type": "#microsoft.graph.security.urlThreatSubmission",
+        "category":    category,
+        "url":         link,
+    }
+    if err := n.post(ctx, defenderGraphURL, "/beta/security/threatSubmission/urlThreats", submission); err != nil {
+        return fmt.Errorf("threat submission: %v", err)
+    }
+    if !n.Indicators {
+        return nil
+    }
+    indicator := map[string]interface{}{
+        "indicatorValue": link,
+        "indicatorType":  "Url",
+        "action":         "Block",
+        "severity":       "High",
+        "title":          AppName + ": " + category + " link",
+        "description":    "Reported as " + category + " by " + AppName + ".",
+        "generateAlert":  true,
+    }
+    if n.Expiry > 0 {
+        indicator["expirationTime"] = time.Now().Add(n.Expiry).UTC().Format(time.RFC3339)
+    }
+    if err := n.post(ctx, defenderEndpointURL, "/api/indicators", indicator); err != nil {
+        return fmt.Errorf("block indicator: %v", err)
+    }
+    return nil
+}
+
+// post sends body as JSON to the API at base, signed in for that API.
+func (n *DefenderNotifier) post(ctx context.Context, base, path string, body interface{}) error {
+    token, err := n.token(ctx, base+"/.default")
+    if err != nil {
+        return err
+    }
+    data, err := json.Marshal(body)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", base+path, bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("%s returned %s: %s", base, resp.Status, strings.TrimSpace(string(msg)))
+    }
+    return nil
+}
+
+// token returns an access token for scope, signing in with the client
+// credentials when the cached one is missing or about to expire.
+func (n *DefenderNotifier) token(ctx context.Context, scope string) (string, error) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    if t, ok := n.tokens[scope]; ok && time.Now().Before(t.expires) {
+        return t.value, nil
+    }
+
+    form := url.Values{
+        "grant_type":    {"client_credentials"},
+        "client_id":     {n.ClientID},
+        "client_secret": {n.ClientSecret},
+        "scope":         {scope},
+    }
+    endpoint := defenderLoginURL + "/" + url.PathEscape(n.TenantID) + "/oauth2/v2.0/token"
+    req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+    if err != nil {
+        return "", err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    var res struct {
+        AccessToken string `json:"access_token"`
+        ExpiresIn   int    `json:"expires_in"`
+        Error       string `json:"error"`
+        Description string `json:"error_description"`
+    }
+    if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&res); err != nil {
+        return "", fmt.Errorf("sign-in returned %s", resp.Status)
+    }
+    if resp.StatusCode != http.StatusOK || res.AccessToken == "" {
+        return "", fmt.Errorf("sign-in failed: %s: %s", res.Error, res.Description)
+    }
+    if n.tokens == nil {
+        n.tokens = map[string]defenderToken{}
+    }
+    // Renew a minute early so a token can't expire mid-request
+    n.tokens[scope] = defenderToken{res.AccessToken, time.Now().Add(time.Duration(res.ExpiresIn)*time.Second - time.Minute)}
+    return res.AccessToken, nil
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+    "context"
+    "fmt"
+    "html/template"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/logging"
+)
+
+// digestLog logs digest deliveries.
+var digestLog = logging.Module("digest")
+
+// digestReport is the details page a digest toast opens.
+var digestReport = template.Must(template.New("digest").Funcs(template.FuncMap{"defang": Defang}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif">
+<h2>{{.Title}}</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Severity</th><th>Chat</th><th>URL</th><th>Findings</th></tr>
+{{range .Alerts}}<tr><td>{{.Verdict.Severity}}</td><td>{{.Origin}}</td><td><code>{{defang .URL}}</code></td><td>{{range .Verdict.Findings}}{{.Description}}<br>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// DigestNotifier holds back alerts at or below MaxSeverity and delivers them
+// to Next as a single digest every Interval, so a stream of low-severity
+// links doesn't bury the desktop in toasts. More severe alerts pass
+// straight through.
+type DigestNotifier struct {
+    Next        Notifier
+    MaxSeverity analysis.Severity
+    Interval    time.Duration
+    Loc         *i18n.Localizer
+
+    mu      sync.Mutex
+    pending []Alert
+    stop    chan struct{}
+    done    chan struct{}
+}
+
+// NewDigestNotifier starts a digest that flushes every interval.
+func NewDigestNotifier(next Notifier, maxSeverity analysis.Severity, interval time.Duration, loc *i18n.Localizer) *DigestNotifier {
+    d := &DigestNotifier{
+        Next:        next,
+        MaxSeverity: maxSeverity,
+        Interval:    interval,
+        Loc:         loc,
+        stop:        make(chan struct{}),
+        done:        make(chan struct{}),
+    }
+    go d.run()
+    return d
+}
+
+// Notify queues low-severity alerts and forwards the rest.
+func (d *DigestNotifier) Notify(ctx context.Context, alert Alert) error {
+    if alert.Verdict.Severity > d.MaxSeverity {
+        return d.Next.Notify(ctx, alert)
+    }
+    d.mu.Lock()
+    d.pending = append(d.pending, alert)
+    d.mu.Unlock()
+    return nil
+}
+
+// Pending returns how many alerts are waiting for the next digest.
+func (d *DigestNotifier) Pending() int {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return len(d.pending)
+}
+
+// Close stops the timer and delivers whatever is still queued.
+func (d *DigestNotifier) Close() error {
+    close(d.stop)
+    <-d.done
+    return d.Flush(context.Background())
+}
+
+// Stop ends the timer and returns the queued alerts undelivered, for a
+// replacement to take over.
+func (d *DigestNotifier) Stop() []Alert {
+    close(d.stop)
+    <-d.done
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    pending := d.pending
+    d.pending = nil
+    return pending
+}
+
+func (d *DigestNotifier) run() {
+    defer close(d.done)
+    ticker := time.NewTicker(d.Interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            if err := d.Flush(context.Background()); err != nil {
+                digestLog.Error("failed to deliver digest", "err", err)
+            }
+        case <-d.stop:
+            return
+        }
+    }
+}
+
+// Flush delivers queued alerts now: a single alert as itself, several as
+// one digest alert whose link opens a details page.
+func (d *DigestNotifier) Flush(ctx context.Context) error {
+    d.mu.Lock()
+    pending := d.pending
+    d.pending = nil
+    d.mu.Unlock()
+
+    switch len(pending) {
+    case 0:
+        return nil
+    case 1:
+        return d.Next.Notify(ctx, pending[0])
+    }
+
+    digest := Alert{
+        ID:      fmt.Sprintf("digest-%d", time.Now().Unix()),
+        Title:   d.Loc.T("digest.title", len(pending), int(d.Interval.Minutes())),
+        Message: d.Loc.T("digest.message"),
+    }
+    for _, alert := range pending {
+        if alert.Verdict.Severity > digest.Verdict.Severity {
+            digest.Verdict.Severity = alert.Verdict.Severity
+        }
+    }
+
+    report, err := d.writeReport(digest.Title, pending)
+    if err != nil {
+        return err
+    }
+    digest.URL = report
+    return d.Next.Notify(ctx, digest)
+}
+
+// writeReport renders the digest details page to a temp file and returns
+// its file:// URL.
+func (d *DigestNotifier) writeReport(title string, alerts []Alert) (string, error) {
+    f, err := os.CreateTemp("", "telephish-digest-*.html")
+    if err != nil {
+        return "", fmt.Errorf("failed to create digest report: %v", err)
+    }
+    defer f.Close()
+
+    data := struct {
+        Title  string
+        Alerts []Alert
+    }{title, alerts}
+    if err := digestReport.Execute(f, data); err != nil {
+        return "", fmt.Errorf("failed to render digest report: %v", err)
+    }
+
+    path, _ := filepath.Abs(f.Name())
+    u := url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(path), "/")}
+    return u.String(), nil
+}
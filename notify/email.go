@@ -0,0 +1,254 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "encoding/base64"
+    "fmt"
+    "html/template"
+    "mime/multipart"
+    "net"
+    "net/smtp"
+    "net/textproto"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+)
+
+// emailTemplate is the HTML body of alert emails. html/template escapes
+// every value, so message content can't inject markup.
+const emailTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Alert.Title}}</h2>
+<p>{{.Alert.Message}}</p>
+<p><b>URL:</b> <code>{{.Alert.URL}}</code></p>
+<p><b>{{t "alert.verdict"}}:</b> {{severity .Alert.Verdict.Severity}}</p>
+{{if .Alert.Verdict.Findings}}
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Analyzer</th><th>Severity</th><th>Finding</th></tr>
+{{range .Alert.Verdict.Findings}}<tr><td>{{.Analyzer}}</td><td>{{severity .Severity}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}
+<p style="color: #888">{{.Sent.Format "2006-01-02 15:04:05 MST"}} · {{.Alert.Origin}}</p>
+</body>
+</html>
+`
+
+// EmailNotifier emails alerts over SMTP to a fixed list of recipients.
+type EmailNotifier struct {
+    Addr     string // host:port of the SMTP server
+    Username string
+    Password string
+    From     string
+    To       []string
+
+    body *template.Template
+}
+
+// NewEmailNotifier returns an email sink using loc for its labels.
+func NewEmailNotifier(addr, username, password, from string, to []string, loc *i18n.Localizer) *EmailNotifier {
+    body := template.Must(template.New("email").Funcs(template.FuncMap{
+        "t":        func(key string) string { return loc.T(key) },
+        "severity": func(s analysis.Severity) string { return loc.T("severity." + s.String()) },
+    }).Parse(emailTemplate))
+    return &EmailNotifier{Addr: addr, Username: username, Password: password, From: from, To: to, body: body}
+}
+
+// Notify sends the alert as an HTML email, attaching the page screenshot
+// when the alert has one.
+func (n *EmailNotifier) Notify(ctx context.Context, alert Alert) error {
+    msg, err := n.compose(alert)
+    if err != nil {
+        return err
+    }
+
+    if err := n.send(ctx, msg); err != nil {
+        return fmt.Errorf("failed to send email: %v", err)
+    }
+    return nil
+}
+
+// smtpTimeout bounds a whole SMTP conversation when ctx has no deadline.
+const smtpTimeout = 30 * time.Second
+
+// send does what smtp.SendMail does, upgrading to TLS and authenticating
+// when the server supports it, but gives up when ctx is done.
+func (n *EmailNotifier) send(ctx context.Context, msg []byte) error {
+    host, _, _ := net.SplitHostPort(n.Addr)
+    var dialer net.Dialer
+    conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+    if err != nil {
+        return err
+    }
+    deadline, ok := ctx.Deadline()
+    if !ok {
+        deadline = time.Now().Add(smtpTimeout)
+    }
+    conn.SetDeadline(deadline)
+    // Closing the connection unblocks the client if ctx is cancelled
+    stop := context.AfterFunc(ctx, func() { conn.Close() })
+    defer stop()
+
+    c, err := smtp.NewClient(conn, host)
+    if err != nil {
+        conn.Close()
+        return err
+    }
+    defer c.Close()
+    if ok, _ := c.Extension("STARTTLS"); ok {
+        if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+            return err
+        }
+    }
+    if n.Username != "" {
+        if ok, _ := c.Extension("AUTH"); ok {
+            if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+                return err
+            }
+        }
+    }
+    if err := c.Mail(n.From); err != nil {
+        return err
+    }
+    for _, to := range n.To {
+        if err := c.Rcpt(to); err != nil {
+            return err
+        }
+    }
+    w, err := c.Data()
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(msg); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return c.Quit()
+}
+
+func (n *EmailNotifier) compose(alert Alert) ([]byte, error) {
+    var body bytes.Buffer
+    data := struct {
+        Alert Alert
+        Sent  time.Time
+    }{alert, time.Now()}
+    if err := n.body.Execute(&body, data); err != nil {
+        return nil, fmt.Errorf("failed to render email: %v", err)
+    }
+
+    var msg bytes.Buffer
+    mw := multipart.NewWriter(&msg)
+
+    host := alert.URL
+    if u, err := url.Parse(alert.URL); err == nil && u.Host != "" {
+        host = u.Host
+    }
+    subject := fmt.Sprintf("[%s] %s: %s", AppName, strings.ToUpper(alert.Verdict.Severity.String()), host)
+
+    fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+    fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+    fmt.Fprintf(&msg, "Subject: %s\r\n", encodeHeader(subject))
+    fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+    fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+    part, err := mw.CreatePart(textproto.MIMEHeader{
+        "Content-Type":              {"text/html; charset=utf-8"},
+        "Content-Transfer-Encoding": {"base64"},
+    })
+    if err != nil {
+        return nil, err
+    }
+    writeBase64(part, body.Bytes())
+
+    if alert.Screenshot != "" {
+        image, err := os.ReadFile(alert.Screenshot)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read screenshot: %v", err)
+        }
+        part, err := mw.CreatePart(textproto.MIMEHeader{
+            "Content-Type":              {"image/png"},
+            "Content-Transfer-Encoding": {"base64"},
+            "Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", filepath.Base(alert.Screenshot))},
+        })
+        if err != nil {
+            return nil, err
+        }
+        writeBase64(part, image)
+    }
+
+    if err := mw.Close(); err != nil {
+        return nil, err
+    }
+    return msg.Bytes(), nil
+}
+
+// SendReport emails a report with html as its body, attaching attachment
+// as a file named name unless it is nil.
+func (n *EmailNotifier) SendReport(ctx context.Context, subject string, html []byte, name, contentType string, attachment []byte) error {
+    var msg bytes.Buffer
+    mw := multipart.NewWriter(&msg)
+    fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+    fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+    fmt.Fprintf(&msg, "Subject: %s\r\n", encodeHeader(fmt.Sprintf("[%s] %s", AppName, subject)))
+    fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+    fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+    part, err := mw.CreatePart(textproto.MIMEHeader{
+        "Content-Type":              {"text/html; charset=utf-8"},
+        "Content-Transfer-Encoding": {"base64"},
+    })
+    if err != nil {
+        return err
+    }
+    writeBase64(part, html)
+    if attachment != nil {
+        part, err := mw.CreatePart(textproto.MIMEHeader{
+            "Content-Type":              {contentType},
+            "Content-Transfer-Encoding": {"base64"},
+            "Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", name)},
+        })
+        if err != nil {
+            return err
+        }
+        writeBase64(part, attachment)
+    }
+    if err := mw.Close(); err != nil {
+        return err
+    }
+
+    if err := n.send(ctx, msg.Bytes()); err != nil {
+        return fmt.Errorf("failed to send email: %v", err)
+    }
+    return nil
+}
+
+// writeBase64 writes data base64-encoded in 76 character lines.
+func writeBase64(w interface{ Write([]byte) (int, error) }, data []byte) {
+    encoded := base64.StdEncoding.EncodeToString(data)
+    for len(encoded) > 76 {
+        w.Write([]byte(encoded[:76] + "\r\n"))
+        encoded = encoded[76:]
+    }
+    w.Write([]byte(encoded + "\r\n"))
+}
+
+// encodeHeader MIME-encodes a header value that may contain non-ASCII text.
+func encodeHeader(s string) string {
+    for _, r := range s {
+        if r > 127 {
+            return "=?utf-8?B?" + base64.StdEncoding.EncodeToString([]byte(s)) + "?="
+        }
+    }
+    return s
+}
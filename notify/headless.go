@@ -0,0 +1,82 @@
+package notify
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// severityColors are ANSI colors used for the severity column on terminals.
+var severityColors = map[analysis.Severity]string{
+    analysis.SeverityClean:      "\033[32m",
+    analysis.SeverityInfo:       "\033[36m",
+    analysis.SeveritySuspicious: "\033[33m",
+    analysis.SeverityMalicious:  "\033[31;1m",
+}
+
+// SeverityColor returns the ANSI color the severity column of alerts is
+// drawn in on terminals.
+func SeverityColor(s analysis.Severity) string {
+    return severityColors[s]
+}
+
+// HeadlessNotifier streams alerts as one formatted line each, for running
+// the monitor on servers or inside tmux where there is no desktop.
+type HeadlessNotifier struct {
+    Out   io.Writer
+    Color bool
+
+    mu sync.Mutex
+}
+
+// NewHeadlessNotifier writes to stdout, colorizing when it is a terminal
+// and NO_COLOR is not set.
+func NewHeadlessNotifier() *HeadlessNotifier {
+    return &HeadlessNotifier{Out: os.Stdout, Color: IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""}
+}
+
+// Notify writes the alert line.
+func (n *HeadlessNotifier) Notify(ctx context.Context, alert Alert) error {
+    line := FormatAlertLine(time.Now(), alert, n.Color)
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    _, err := fmt.Fprintln(n.Out, line)
+    return err
+}
+
+// FormatAlertLine renders an alert as a single line: time, severity,
+// profile, chat, URL and findings.
+func FormatAlertLine(t time.Time, alert Alert, color bool) string {
+    severity := fmt.Sprintf("%-10s", strings.ToUpper(alert.Verdict.Severity.String()))
+    if color {
+        severity = severityColors[alert.Verdict.Severity] + severity + "\033[0m"
+    }
+
+    var findings []string
+    for _, f := range alert.Verdict.Findings {
+        findings = append(findings, f.Description)
+    }
+
+    chat := fmt.Sprintf("chat=%d", alert.ChatID)
+    if alert.Profile != "" {
+        chat = "profile=" + alert.Profile + "  " + chat
+    }
+    line := fmt.Sprintf("%s  %s  %s  %s", t.Format(time.RFC3339), severity, chat, alert.URL)
+    if len(findings) > 0 {
+        line += "  — " + strings.Join(findings, "; ")
+    }
+    return line
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,190 @@
+// Package notify delivers alerts to chat, email, webhook and push sinks.
+// Desktop notifications are platform specific and live with the monitor
+// itself.
+package notify
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/logging"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// Alert is a notification about a link received by the bot.
+type Alert struct {
+    ID       string           `json:"id"` // Stable per message; reused to replace progress toasts
+    Title    string           `json:"title"`
+    Message  string           `json:"message"`
+    URL      string           `json:"url"`
+    ChatID   int64            `json:"chat_id"`           // Chat the link was received in, 0 if unknown
+    ChatType string           `json:"chat_type"`         // "private", "group", "supergroup" or "channel"
+    Profile  string           `json:"profile,omitempty"` // Profile whose bot received the link, if any
+    Sender   string           `json:"sender,omitempty"`  // @username or user ID of whoever sent the link, if known
+    Verdict  analysis.Verdict `json:"verdict"`
+
+    Screenshot string `json:"screenshot,omitempty"` // Path to a PNG of the page, if one was taken
+}
+
+// Origin describes where the alert's link was received: the chat, and the
+// profile if there is one.
+func (a Alert) Origin() string {
+    if a.Profile != "" {
+        return fmt.Sprintf("%s · chat %d", a.Profile, a.ChatID)
+    }
+    return fmt.Sprintf("chat %d", a.ChatID)
+}
+
+// AppName is how alerts name the monitor, e.g. in email subjects.
+const AppName = "Telephish"
+
+// notifyLog logs deliveries.
+var notifyLog = logging.Module("notify")
+
+// Notifier delivers alerts to the user, giving up once ctx is done.
+type Notifier interface {
+    Notify(ctx context.Context, alert Alert) error
+}
+
+// Action records what happened to an alert at one sink.
+type Action struct {
+    Time   time.Time `json:"time"`
+    Sink   string    `json:"sink"`
+    Status string    `json:"status"` // ActionSent, ActionFailed, ActionSuppressed or ActionQueued
+    Error  string    `json:"error,omitempty"` // For ActionQueued, why the last attempt failed
+}
+
+// Action statuses.
+const (
+    ActionSent       = "sent"
+    ActionFailed     = "failed"
+    ActionSuppressed = "suppressed"
+    ActionQueued     = "queued" // Waiting in the delivery queue
+)
+
+// ErrQueued is returned by a sink that has queued the alert for delivery
+// later rather than failed to deliver it.
+var ErrQueued = errors.New("queued for delivery")
+
+// deliverer is a Notifier that can report per-sink actions.
+type deliverer interface {
+    Deliver(ctx context.Context, alert Alert) []Action
+}
+
+// Deliver sends alert through n and reports what was done with it.
+// Notifiers that don't report per-sink actions are recorded as one action
+// under name.
+func Deliver(ctx context.Context, n Notifier, name string, alert Alert) []Action {
+    if d, ok := n.(deliverer); ok {
+        return d.Deliver(ctx, alert)
+    }
+    return []Action{NewAction(name, n.Notify(ctx, alert))}
+}
+
+// NewAction records the outcome of delivering an alert to sink.
+func NewAction(sink string, err error) Action {
+    act := Action{Time: time.Now().UTC(), Sink: sink, Status: ActionSent}
+    if err == ErrQueued {
+        act.Status = ActionQueued
+    } else if err != nil {
+        act.Status, act.Error = ActionFailed, err.Error()
+    }
+    return act
+}
+
+// ActionsError summarizes the failed actions as one error, nil if none
+// failed.
+func ActionsError(actions []Action) error {
+    var errs []string
+    for _, act := range actions {
+        if act.Status == ActionFailed {
+            errs = append(errs, act.Sink+": "+act.Error)
+        }
+    }
+    if len(errs) == 0 {
+        return nil
+    }
+    return fmt.Errorf("failed to deliver to %d of %d sinks: %s", len(errs), len(actions), strings.Join(errs, "; "))
+}
+
+// TelegramNotifier replies with the alert in the chat the link came from.
+type TelegramNotifier struct {
+    Token     string
+    Templates *Templates
+}
+
+// Notify sends the alert back to the originating chat.
+func (n TelegramNotifier) Notify(ctx context.Context, alert Alert) error {
+    if n.Token == "" || alert.ChatID == 0 {
+        return fmt.Errorf("no chat to reply to")
+    }
+    text, err := n.Templates.RenderTelegram(alert)
+    if err != nil {
+        return err
+    }
+    return telegram.SendMessage(ctx, n.Token, alert.ChatID, text, "MarkdownV2")
+}
+
+// LogNotifier writes alerts to the log, at warning level for suspicious
+// and malicious links.
+type LogNotifier struct{}
+
+// Notify logs the alert. It never fails.
+func (LogNotifier) Notify(ctx context.Context, alert Alert) error {
+    level := slog.LevelInfo
+    if alert.Verdict.Severity >= analysis.SeveritySuspicious {
+        level = slog.LevelWarn
+    }
+    findings := make([]string, len(alert.Verdict.Findings))
+    for i, f := range alert.Verdict.Findings {
+        findings[i] = f.Analyzer + ": " + f.Description
+    }
+    attrs := []interface{}{"message", alert.Message, "url", alert.URL, "chat_id", alert.ChatID,
+        "verdict", alert.Verdict.Severity, "findings", findings}
+    if alert.Profile != "" {
+        attrs = append(attrs, "profile", alert.Profile)
+    }
+    notifyLog.Log(ctx, level, alert.Title, attrs...)
+    return nil
+}
+
+// MultiNotifier delivers every alert to all of its notifiers, e.g. the
+// desktop plus an email sink.
+type MultiNotifier []Notifier
+
+// Notify delivers the alert everywhere, reporting any notifiers that failed.
+func (m MultiNotifier) Notify(ctx context.Context, alert Alert) error {
+    var errs []string
+    for _, n := range m {
+        if err := n.Notify(ctx, alert); err != nil {
+            errs = append(errs, fmt.Sprintf("%T: %v", n, err))
+        }
+    }
+    if len(errs) > 0 {
+        return fmt.Errorf("failed to deliver to %d of %d notifiers: %s", len(errs), len(m), strings.Join(errs, "; "))
+    }
+    return nil
+}
+
+// FallbackNotifier tries each notifier in order until one succeeds, so a
+// headless session or disabled notifications doesn't lose the alert.
+type FallbackNotifier []Notifier
+
+// Notify delivers the alert through the first notifier that succeeds.
+func (f FallbackNotifier) Notify(ctx context.Context, alert Alert) error {
+    var errs []string
+    for _, n := range f {
+        err := n.Notify(ctx, alert)
+        if err == nil {
+            return nil
+        }
+        notifyLog.Warn("notifier failed, falling back", "notifier", fmt.Sprintf("%T", n), "alert", alert.ID, "err", err)
+        errs = append(errs, err.Error())
+    }
+    return fmt.Errorf("all notifiers failed: %s", strings.Join(errs, "; "))
+}
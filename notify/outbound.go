@@ -0,0 +1,181 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "text/template"
+    "time"
+)
+
+// maxRetryDelay caps the wait between attempts of an outbound webhook,
+// including one a receiver asks for with Retry-After.
+const maxRetryDelay = time.Minute
+
+// Headers of outbound webhook requests. The signature is the hex
+// HMAC-SHA256, keyed with the webhook's secret, of the timestamp, a dot
+// and the body, so a receiver can both authenticate a request and reject
+// replays of old ones.
+const (
+    SignatureHeader = "X-Telephish-Signature" // sha256=<hex>
+    TimestampHeader = "X-Telephish-Timestamp" // Unix seconds
+    DeliveryHeader  = "X-Telephish-Delivery"  // The alert ID, the same on every retry
+)
+
+// OutboundWebhook posts alerts as JSON to an arbitrary URL, such as a SOAR
+// platform or an in-house service. The body is rendered from a template
+// if one is set and is otherwise the message bus payload, with the link as
+// received. Failed requests are retried with backoff.
+type OutboundWebhook struct {
+    URL     string
+    Headers map[string]string
+    Secret  string        // Signs each request if set
+    Retries int           // Further attempts after a failed one
+    Backoff time.Duration // Before the first retry, doubling after each
+
+    tmpl *template.Template
+}
+
+// NewOutboundWebhook returns a webhook sink posting to url, rendering its
+// body from the template in templatePath if it is set.
+//
+// The template receives the Alert with every string escaped for use inside
+// a JSON string, so "url": "{{.URL}}" is safe whatever the link holds.
+// defang defangs a link, score rates the alert as in CEF records, and now
+// is the time of delivery in RFC 3339. The result must be valid JSON.
+func NewOutboundWebhook(url, templatePath string) (*OutboundWebhook, error) {
+    n := &OutboundWebhook{URL: url, Backoff: time.Second}
+    if templatePath != "" {
+        tmpl, err := parseTemplate("webhook", templatePath, "", template.FuncMap{
+            "defang": Defang,
+            "score":  Score,
+            "now":    func() string { return time.Now().UTC().Format(time.RFC3339) },
+        })
+        if err != nil {
+            return nil, err
+        }
+        n.tmpl = tmpl
+    }
+    return n, nil
+}
+
+// EscapeJSON escapes s for use inside a JSON string.
+func EscapeJSON(s string) string {
+    quoted, _ := json.Marshal(s)
+    return string(quoted[1 : len(quoted)-1])
+}
+
+// body renders the request body for alert.
+func (n *OutboundWebhook) body(alert Alert) ([]byte, error) {
+    if n.tmpl == nil {
+        return busPayload(alert)
+    }
+    text, err := render(n.tmpl, escapeStrings(alert, EscapeJSON))
+    if err != nil {
+        return nil, err
+    }
+    if !json.Valid([]byte(text)) {
+        return nil, fmt.Errorf("webhook template %s rendered invalid JSON", n.tmpl.Name())
+    }
+    return []byte(text), nil
+}
+
+// Sign returns the SignatureHeader value for body sent at timestamp, as
+// receivers should compute it to check a request.
+func Sign(secret, timestamp string, body []byte) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(timestamp + "."))
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify posts the alert, retrying whatever may succeed on another try:
+// network errors, 408, 429 and 5xx responses.
+func (n *OutboundWebhook) Notify(ctx context.Context, alert Alert) error {
+    body, err := n.body(alert)
+    if err != nil {
+        return err
+    }
+    delay := n.Backoff
+    for attempt := 0; ; attempt++ {
+        retry, wait, err := n.post(ctx, alert.ID, body)
+        if err == nil {
+            return nil
+        }
+        if !retry || attempt >= n.Retries {
+            return err
+        }
+        if wait < delay {
+            wait = delay
+        }
+        if wait > maxRetryDelay {
+            wait = maxRetryDelay
+        }
+        notifyLog.Warn("webhook delivery failed; retrying", "host", hostOf(n.URL), "err", err, "delay", wait)
+        select {
+        case <-ctx.Done():
+            return err
+        case <-time.After(wait):
+        }
+        delay *= 2
+    }
+}
+
+// hostOf returns the host of link, to log a webhook without whatever
+// token its URL holds.
+func hostOf(link string) string {
+    u, err := url.Parse(link)
+    if err != nil {
+        return ""
+    }
+    return u.Host
+}
+
+// post makes one attempt at delivering body, reporting whether a failure
+// is worth retrying and how long the receiver asked to wait first.
+func (n *OutboundWebhook) post(ctx context.Context, id string, body []byte) (retry bool, wait time.Duration, err error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+    if err != nil {
+        return false, 0, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", AppName)
+    for name, value := range n.Headers {
+        req.Header.Set(name, value)
+    }
+    req.Header.Set(DeliveryHeader, id)
+    if n.Secret != "" {
+        timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+        req.Header.Set(TimestampHeader, timestamp)
+        req.Header.Set(SignatureHeader, Sign(n.Secret, timestamp, body))
+    }
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return ctx.Err() == nil, 0, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+        return false, 0, nil
+    }
+    msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+    err = fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+    switch {
+    case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+        if seconds, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+            wait = time.Duration(seconds) * time.Second
+        }
+        return true, wait, err
+    case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+        return true, 0, err
+    }
+    return false, 0, err
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+    "context"
+    "crypto/hmac"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func TestSign(t *testing.T) {
+    // Computed independently, with Python's hmac module
+    tests := []struct {
+        secret, timestamp, body string
+        want                    string
+    }{
+        {"whsec_test", "1700000000", `{"id":"msg-1001-7","url":"http://phish.example/"}`, "sha256=af11637b8e1c84c3b595f263658ec6e1cc47155d26acd76461fd49b87212b212"},
+        {"", "0", "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+    }
+    for _, tt := range tests {
+        if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+            t.Errorf("Sign(%q, %q, %q) = %s, want %s", tt.secret, tt.timestamp, tt.body, got, tt.want)
+        }
+    }
+}
+
+func TestOutboundWebhookSignature(t *testing.T) {
+    type request struct {
+        header http.Header
+        body   []byte
+    }
+    got := make(chan request, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := io.ReadAll(r.Body)
+        got <- request{r.Header, body}
+    }))
+    defer srv.Close()
+
+    n, err := NewOutboundWebhook(srv.URL, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    n.Secret = "whsec_test"
+    if err := n.Notify(context.Background(), Alert{ID: "msg-1001-7", URL: "http://phish.example/"}); err != nil {
+        t.Fatal(err)
+    }
+    req := <-got
+
+    timestamp := req.header.Get(TimestampHeader)
+    sent, err := strconv.ParseInt(timestamp, 10, 64)
+    if err != nil || time.Since(time.Unix(sent, 0)).Abs() > time.Minute {
+        t.Errorf("%s = %q, want the time of sending", TimestampHeader, timestamp)
+    }
+    // As a receiver checks it
+    want := Sign("whsec_test", timestamp, req.body)
+    if signature := req.header.Get(SignatureHeader); !hmac.Equal([]byte(signature), []byte(want)) {
+        t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+    }
+    if Sign("other", timestamp, req.body) == want || Sign("whsec_test", timestamp, append(req.body, ' ')) == want {
+        t.Errorf("the signature doesn't depend on the secret and body")
+    }
+    if id := req.header.Get(DeliveryHeader); id != "msg-1001-7" {
+        t.Errorf("%s = %q", DeliveryHeader, id)
+    }
+
+    // Unsigned without a secret
+    n.Secret = ""
+    if err := n.Notify(context.Background(), Alert{ID: "msg-1001-8"}); err != nil {
+        t.Fatal(err)
+    }
+    if req := <-got; req.header.Get(SignatureHeader) != "" || req.header.Get(TimestampHeader) != "" {
+        t.Errorf("signed without a secret: %v", req.header)
+    }
+}
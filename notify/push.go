@@ -0,0 +1,124 @@
+package notify
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+)
+
+// PriorityMap maps verdict severities onto a push service's priority scale.
+type PriorityMap map[analysis.Severity]int
+
+// Default priorities for each push service.
+var (
+    NtfyPriorities     = PriorityMap{analysis.SeverityClean: 1, analysis.SeverityInfo: 2, analysis.SeveritySuspicious: 4, analysis.SeverityMalicious: 5}
+    PushoverPriorities = PriorityMap{analysis.SeverityClean: -2, analysis.SeverityInfo: -1, analysis.SeveritySuspicious: 1, analysis.SeverityMalicious: 2}
+    GotifyPriorities   = PriorityMap{analysis.SeverityClean: 0, analysis.SeverityInfo: 2, analysis.SeveritySuspicious: 6, analysis.SeverityMalicious: 9}
+)
+
+// pushBody is the short plain-text body shared by all push sinks.
+func pushBody(alert Alert, loc *i18n.Localizer) string {
+    body := fmt.Sprintf("%s: %s\n%s", loc.T("alert.verdict"), loc.T("severity."+alert.Verdict.Severity.String()), Defang(alert.URL))
+    for _, f := range alert.Verdict.Findings {
+        body += "\n• " + f.Description
+    }
+    return body
+}
+
+// doPush sends req and treats any non-2xx status as an error.
+func doPush(req *http.Request) error {
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("push service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+    }
+    return nil
+}
+
+// NtfyNotifier publishes alerts to an ntfy topic.
+type NtfyNotifier struct {
+    TopicURL   string // e.g. https://ntfy.sh/my-alerts
+    Token      string // Optional access token
+    Priorities PriorityMap
+    Loc        *i18n.Localizer
+}
+
+// Notify publishes the alert to the topic.
+func (n NtfyNotifier) Notify(ctx context.Context, alert Alert) error {
+    req, err := http.NewRequestWithContext(ctx, "POST", n.TopicURL, strings.NewReader(pushBody(alert, n.Loc)))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Title", alert.Title)
+    req.Header.Set("Priority", fmt.Sprint(n.Priorities[alert.Verdict.Severity]))
+    req.Header.Set("Tags", alert.Verdict.Severity.String())
+    if n.Token != "" {
+        req.Header.Set("Authorization", "Bearer "+n.Token)
+    }
+    return doPush(req)
+}
+
+// PushoverNotifier sends alerts through the Pushover API.
+type PushoverNotifier struct {
+    AppToken   string
+    UserKey    string
+    Priorities PriorityMap
+    Loc        *i18n.Localizer
+}
+
+// Notify sends the alert to the Pushover user.
+func (n PushoverNotifier) Notify(ctx context.Context, alert Alert) error {
+    priority := n.Priorities[alert.Verdict.Severity]
+    form := url.Values{
+        "token":    {n.AppToken},
+        "user":     {n.UserKey},
+        "title":    {alert.Title},
+        "message":  {pushBody(alert, n.Loc)},
+        "priority": {fmt.Sprint(priority)},
+    }
+    // Emergency priority keeps alerting until acknowledged
+    if priority == 2 {
+        form.Set("retry", "60")
+        form.Set("expire", "3600")
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    return doPush(req)
+}
+
+// GotifyNotifier sends alerts to a Gotify server.
+type GotifyNotifier struct {
+    ServerURL  string // e.g. https://gotify.example.com
+    AppToken   string
+    Priorities PriorityMap
+    Loc        *i18n.Localizer
+}
+
+// Notify posts the alert as a Gotify message.
+func (n GotifyNotifier) Notify(ctx context.Context, alert Alert) error {
+    form := url.Values{
+        "title":    {alert.Title},
+        "message":  {pushBody(alert, n.Loc)},
+        "priority": {fmt.Sprint(n.Priorities[alert.Verdict.Severity])},
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(n.ServerURL, "/")+"/message", strings.NewReader(form.Encode()))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("X-Gotify-Key", n.AppToken)
+    return doPush(req)
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// DefaultRDAPServer redirects each query to the registry responsible for
+// the domain or address, found in IANA's bootstrap files.
+const DefaultRDAPServer = "https://rdap.org"
+
+// AbuseContact is who to report a domain or address to.
+type AbuseContact struct {
+    Role  string `json:"role"` // registrar or hosting
+    Name  string `json:"name"` // The registrar or network, if known
+    Email string `json:"email"`
+}
+
+// rdapObject is the part of an RDAP domain or IP network response the
+// lookup reads.
+type rdapObject struct {
+    Name     string       `json:"name"` // Of an IP network
+    Entities []rdapEntity `json:"entities"`
+}
+
+type rdapEntity struct {
+    Roles      []string          `json:"roles"`
+    VCardArray []json.RawMessage `json:"vcardArray"` // ["vcard", [[name, params, type, value], ...]]
+    Entities   []rdapEntity      `json:"entities"`
+}
+
+// vcard returns the first value of the jCard property name, e.g. email or
+// fn, or "".
+func (e rdapEntity) vcard(name string) string {
+    if len(e.VCardArray) < 2 {
+        return ""
+    }
+    var props [][]json.RawMessage
+    if err := json.Unmarshal(e.VCardArray[1], &props); err != nil {
+        return ""
+    }
+    for _, p := range props {
+        var prop, value string
+        if len(p) < 4 || json.Unmarshal(p[0], &prop) != nil || prop != name {
+            continue
+        }
+        if json.Unmarshal(p[3], &value) == nil && value != "" {
+            return strings.TrimPrefix(value, "mailto:")
+        }
+    }
+    return ""
+}
+
+func (e rdapEntity) has(role string) bool {
+    for _, r := range e.Roles {
+        if r == role {
+            return true
+        }
+    }
+    return false
+}
+
+// abuseEmail finds the email of an entity with the abuse role among
+// entities and the entities they contain, and the name of the entity
+// holding it.
+func abuseEmail(entities []rdapEntity, parent string) (name, email string) {
+    for _, e := range entities {
+        if e.has("abuse") {
+            if email := e.vcard("email"); email != "" {
+                return parent, email
+            }
+        }
+        if name, email := abuseEmail(e.Entities, e.vcard("fn")); email != "" {
+            return name, email
+        }
+    }
+    return "", ""
+}
+
+// LookupAbuse asks RDAP server who to report domain's registration to and,
+// if ip isn't empty, the network hosting it to. Contacts that couldn't be
+// found are left out; an error is returned only if none were.
+func LookupAbuse(ctx context.Context, server, domain, ip string) ([]AbuseContact, error) {
+    if server == "" {
+        server = DefaultRDAPServer
+    }
+    server = strings.TrimSuffix(server, "/")
+    var contacts []AbuseContact
+    var errs []string
+    lookup := func(role, path string) {
+        obj, err := rdapQuery(ctx, server+path)
+        if err != nil {
+            errs = append(errs, err.Error())
+            return
+        }
+        name, email := abuseEmail(obj.Entities, "")
+        if email == "" {
+            errs = append(errs, fmt.Sprintf("%s: no abuse contact", path))
+            return
+        }
+        if role == "registrar" {
+            for _, e := range obj.Entities {
+                if e.has("registrar") {
+                    name = e.vcard("fn")
+                }
+            }
+        } else if name == "" {
+            name = obj.Name
+        }
+        contacts = append(contacts, AbuseContact{Role: role, Name: name, Email: email})
+    }
+    // Registries only know registered domains, so a subdomain is tried
+    // without its labels one by one, down to the last two
+    for d := strings.TrimSuffix(domain, "."); strings.Contains(d, "."); {
+        before := len(errs)
+        lookup("registrar", "/domain/"+url.PathEscape(d))
+        if len(errs) == before {
+            break
+        }
+        _, parent, _ := strings.Cut(d, ".")
+        if !strings.Contains(parent, ".") {
+            break
+        }
+        d = parent
+    }
+    if ip != "" {
+        lookup("hosting", "/ip/"+url.PathEscape(ip))
+    }
+    if len(contacts) == 0 {
+        return nil, fmt.Errorf("rdap: %s", strings.Join(errs, "; "))
+    }
+    return contacts, nil
+}
+
+func rdapQuery(ctx context.Context, endpoint string) (*rdapObject, error) {
+    req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Accept", "application/rdap+json")
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("%s returned %s", endpoint, resp.Status)
+    }
+    var obj rdapObject
+    if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&obj); err != nil {
+        return nil, fmt.Errorf("%s: %v", endpoint, err)
+    }
+    return &obj, nil
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+    "encoding/json"
+    "io"
+    "net/url"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/extract"
+)
+
+// ResultSchema names the version of Result. Within a version fields are
+// only ever added; renaming, removing or changing the meaning of one
+// makes a new version. api/telephish/v1/result.schema.json describes it.
+const ResultSchema = "telephish.result/v1"
+
+// Result is the stable JSON form of a scanned link, which scan --json and
+// run --output=jsonl write for other programs to read. Unlike the history
+// and bus payloads, which follow the monitor's internals, it only changes
+// as ResultSchema says.
+type Result struct {
+    Schema        string          `json:"schema"`
+    ID            string          `json:"id"`                   // The alert's, stable per message
+    HistoryID     int64           `json:"history_id,omitempty"` // Its history entry, if recorded
+    Time          time.Time       `json:"time"`
+    URL           string          `json:"url"`                      // As received, not defanged
+    Domain        string          `json:"domain,omitempty"`         // The link's host, xn-- encoded if internationalized
+    DomainUnicode string          `json:"domain_unicode,omitempty"` // Its Unicode form, if that is different
+    Severity      string          `json:"severity"`                 // clean, info, suspicious or malicious
+    Score         int             `json:"score"`                    // 0 to 10, as in CEF records
+    Findings      []ResultFinding `json:"findings"`
+    DurationMS    int64           `json:"duration_ms"`
+    ChatID        int64           `json:"chat_id,omitempty"`
+    ChatType      string          `json:"chat_type,omitempty"`
+    Profile       string          `json:"profile,omitempty"`
+    Sender        string          `json:"sender,omitempty"`
+    RescanOf      int64           `json:"rescan_of,omitempty"` // History ID of the entry this rescanned
+    Actions       []ResultAction  `json:"actions"`             // Empty for scans that aren't delivered
+}
+
+// ResultFinding is one finding of a Result.
+type ResultFinding struct {
+    Analyzer    string                 `json:"analyzer"`
+    Severity    string                 `json:"severity"`
+    Description string                 `json:"description"`
+    Facts       map[string]interface{} `json:"facts,omitempty"`
+}
+
+// ResultAction is what happened to a Result's alert at one sink.
+type ResultAction struct {
+    Sink   string    `json:"sink"`
+    Status string    `json:"status"` // sent, failed, suppressed or queued
+    Error  string    `json:"error,omitempty"`
+    Time   time.Time `json:"time"`
+}
+
+// NewResult returns the result of alert, scanned at t, and delivered as
+// actions say.
+func NewResult(alert Alert, t time.Time, actions []Action) Result {
+    r := Result{
+        Schema:     ResultSchema,
+        ID:         alert.ID,
+        Time:       t.UTC(),
+        URL:        alert.URL,
+        Severity:   alert.Verdict.Severity.String(),
+        Score:      Score(alert),
+        Findings:   make([]ResultFinding, 0, len(alert.Verdict.Findings)),
+        DurationMS: alert.Verdict.Duration.Milliseconds(),
+        ChatID:     alert.ChatID,
+        ChatType:   alert.ChatType,
+        Profile:    alert.Profile,
+        Sender:     alert.Sender,
+        Actions:    make([]ResultAction, 0, len(actions)),
+    }
+    if u, err := url.Parse(alert.URL); err == nil && u.Hostname() != "" {
+        uni, ascii := extract.HostForms(u.Hostname())
+        r.Domain = ascii
+        if uni != ascii {
+            r.DomainUnicode = uni
+        }
+    }
+    for _, f := range alert.Verdict.Findings {
+        r.Findings = append(r.Findings, ResultFinding{Analyzer: f.Analyzer, Severity: f.Severity.String(), Description: f.Description, Facts: f.Facts})
+    }
+    for _, a := range actions {
+        r.Actions = append(r.Actions, ResultAction{Sink: a.Sink, Status: a.Status, Error: a.Error, Time: a.Time.UTC()})
+    }
+    return r
+}
+
+// ResultWriter writes results as JSON Lines, one object per line, and is
+// safe for concurrent use.
+type ResultWriter struct {
+    mu  sync.Mutex
+    enc *json.Encoder
+}
+
+// NewResultWriter returns a writer of results to w.
+func NewResultWriter(w io.Writer) *ResultWriter {
+    enc := json.NewEncoder(w)
+    enc.SetEscapeHTML(false)
+    return &ResultWriter{enc: enc}
+}
+
+// Write writes r as one line.
+func (w *ResultWriter) Write(r Result) error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.enc.Encode(r)
+}
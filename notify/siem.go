@@ -0,0 +1,152 @@
+package notify
+
+import (
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// SIEM record formats.
+const (
+    FormatRFC5424 = "rfc5424" // Plain syslog with structured data
+    FormatCEF     = "cef"     // ArcSight Common Event Format
+    FormatLEEF    = "leef"    // QRadar Log Event Extended Format 1.0
+)
+
+// cefSeverities are the base CEF severities (0-10) of each verdict; Score
+// raises them with the number of findings behind the verdict.
+var cefSeverities = map[analysis.Severity]int{
+    analysis.SeverityClean:      0,
+    analysis.SeverityInfo:       2,
+    analysis.SeveritySuspicious: 5,
+    analysis.SeverityMalicious:  8,
+}
+
+// Score rates an alert from 0 to 10 for SIEM correlation: the verdict's
+// base (0 clean, 2 info, 5 suspicious, 8 malicious) plus one for each
+// further finding at that severity, up to two. It is the CEF severity and
+// the LEEF sev.
+func Score(alert Alert) int {
+    score := cefSeverities[alert.Verdict.Severity]
+    if alert.Verdict.Severity < analysis.SeveritySuspicious {
+        return score
+    }
+    backing := 0
+    for _, f := range alert.Verdict.Findings {
+        if f.Severity >= alert.Verdict.Severity {
+            backing++
+        }
+    }
+    if backing > 3 {
+        backing = 3
+    }
+    if backing > 1 {
+        score += backing - 1
+    }
+    return score
+}
+
+// signatureID identifies the kind of event in CEF and LEEF headers, so
+// correlation rules can match on it.
+func signatureID(alert Alert) string {
+    return "verdict-" + alert.Verdict.Severity.String()
+}
+
+// findingsSummary lists the findings on one line.
+func findingsSummary(alert Alert) string {
+    lines := make([]string, len(alert.Verdict.Findings))
+    for i, f := range alert.Verdict.Findings {
+        lines[i] = f.Analyzer + ": " + f.Description
+    }
+    return strings.Join(lines, "; ")
+}
+
+// linkHost is the host of the alert's link, or "" if it has none.
+func linkHost(alert Alert) string {
+    u, err := url.Parse(alert.URL)
+    if err != nil {
+        return ""
+    }
+    return u.Hostname()
+}
+
+var (
+    cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+    cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// CEFRecord renders alert as an ArcSight CEF record from version of the
+// monitor. The link goes in request as received, not defanged, so rules
+// can match it against threat feeds; suser is the sender, cs1 to cs3 the
+// chat, profile and verdict, and cn1 the score.
+func CEFRecord(alert Alert, version string, now time.Time) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+        AppName, strings.ToLower(AppName), cefHeaderEscaper.Replace(version),
+        signatureID(alert), cefHeaderEscaper.Replace(alert.Title), Score(alert))
+
+    var ext []string
+    field := func(key, value string) {
+        if value != "" {
+            ext = append(ext, key+"="+cefExtensionEscaper.Replace(value))
+        }
+    }
+    field("rt", strconv.FormatInt(now.UnixMilli(), 10))
+    field("externalId", alert.ID)
+    field("request", alert.URL)
+    field("dhost", linkHost(alert))
+    field("suser", alert.Sender)
+    field("cat", "phishing")
+    field("cs1Label", "chat")
+    field("cs1", strconv.FormatInt(alert.ChatID, 10))
+    if alert.Profile != "" {
+        field("cs2Label", "profile")
+        field("cs2", alert.Profile)
+    }
+    field("cs3Label", "verdict")
+    field("cs3", alert.Verdict.Severity.String())
+    field("cn1Label", "score")
+    field("cn1", strconv.Itoa(Score(alert)))
+    field("msg", findingsSummary(alert))
+    b.WriteString(strings.Join(ext, " "))
+    return b.String()
+}
+
+// leefEscaper keeps values from breaking LEEF 1.0's tab-separated
+// attributes, which have no escaping of their own.
+var leefEscaper = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// LEEFRecord renders alert as a QRadar LEEF 1.0 record from version of the
+// monitor, with the same fields as CEFRecord under LEEF's names where it
+// has them: url, usrName and sev.
+func LEEFRecord(alert Alert, version string, now time.Time) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|%s|",
+        AppName, strings.ToLower(AppName), cefHeaderEscaper.Replace(version), signatureID(alert))
+
+    var attrs []string
+    field := func(key, value string) {
+        if value != "" {
+            attrs = append(attrs, key+"="+leefEscaper.Replace(value))
+        }
+    }
+    field("devTime", now.UTC().Format("Jan 02 2006 15:04:05.000 UTC"))
+    field("devTimeFormat", "MMM dd yyyy HH:mm:ss.SSS z")
+    field("cat", "phishing")
+    field("sev", strconv.Itoa(Score(alert)))
+    field("externalId", alert.ID)
+    field("url", alert.URL)
+    field("dhost", linkHost(alert))
+    field("usrName", alert.Sender)
+    field("chat", strconv.FormatInt(alert.ChatID, 10))
+    field("profile", alert.Profile)
+    field("verdict", alert.Verdict.Severity.String())
+    field("title", alert.Title)
+    field("findings", findingsSummary(alert))
+    b.WriteString(strings.Join(attrs, "\t"))
+    return b.String()
+}
@@ -0,0 +1,154 @@
+package notify
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// SyslogSeverities maps verdict severities onto syslog severities:
+// critical, warning, notice and informational.
+var SyslogSeverities = PriorityMap{analysis.SeverityClean: 6, analysis.SeverityInfo: 5, analysis.SeveritySuspicious: 4, analysis.SeverityMalicious: 2}
+
+// SyslogFacilities are the facility names the syslog sink accepts.
+var SyslogFacilities = map[string]int{
+    "user": 1, "daemon": 3, "auth": 4, "authpriv": 10,
+    "local0": 16, "local1": 17, "local2": 18, "local3": 19,
+    "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSDID names the structured data element alerts carry. 32473 is the
+// private enterprise number reserved for documentation (RFC 5612).
+const syslogSDID = "telephish@32473"
+
+// SyslogNotifier sends alerts to a syslog collector as RFC 5424 messages,
+// over UDP, TCP or TLS (RFC 5425). Stream transports frame messages by
+// octet counting. Each alert opens its own connection, so a collector
+// restart never leaves the sink holding a dead one.
+type SyslogNotifier struct {
+    Network  string // udp, tcp or tls
+    Addr     string // host:port of the collector
+    Facility int
+    Severity PriorityMap
+    TLS      *tls.Config // For tls; nil verifies against the system roots
+    Format   string      // FormatRFC5424 (the default), FormatCEF or FormatLEEF
+    Version  string      // The monitor's version, for CEF and LEEF headers
+
+    hostname string
+}
+
+// NewSyslogNotifier returns a syslog sink for network and addr, trusting
+// the CA certificates in caFile for tls if it is set.
+func NewSyslogNotifier(network, addr string, facility int, caFile string) (*SyslogNotifier, error) {
+    n := &SyslogNotifier{Network: network, Addr: addr, Facility: facility, Severity: SyslogSeverities}
+    n.hostname, _ = os.Hostname()
+    if network == "tls" {
+        host, _, _ := net.SplitHostPort(addr)
+        n.TLS = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+        if caFile != "" {
+            pem, err := os.ReadFile(caFile)
+            if err != nil {
+                return nil, fmt.Errorf("failed to read syslog CA file: %v", err)
+            }
+            n.TLS.RootCAs = x509.NewCertPool()
+            if !n.TLS.RootCAs.AppendCertsFromPEM(pem) {
+                return nil, fmt.Errorf("syslog CA file %s has no PEM certificates", caFile)
+            }
+        }
+    }
+    return n, nil
+}
+
+// Notify sends the alert to the collector.
+func (n *SyslogNotifier) Notify(ctx context.Context, alert Alert) error {
+    if _, ok := ctx.Deadline(); !ok {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, sinkTimeout)
+        defer cancel()
+    }
+    conn, err := n.dial(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to connect to syslog: %v", err)
+    }
+    defer conn.Close()
+    deadline, _ := ctx.Deadline()
+    conn.SetDeadline(deadline)
+
+    msg := n.format(alert, time.Now())
+    if n.Network != "udp" {
+        msg = strconv.Itoa(len(msg)) + " " + msg
+    }
+    if _, err := conn.Write([]byte(msg)); err != nil {
+        return fmt.Errorf("failed to send to syslog: %v", err)
+    }
+    return nil
+}
+
+func (n *SyslogNotifier) dial(ctx context.Context) (net.Conn, error) {
+    if n.Network == "tls" {
+        dialer := &tls.Dialer{Config: n.TLS}
+        return dialer.DialContext(ctx, "tcp", n.Addr)
+    }
+    var dialer net.Dialer
+    return dialer.DialContext(ctx, n.Network, n.Addr)
+}
+
+// format renders the alert as an RFC 5424 message. In the default format
+// that is the verdict and its origin as structured data, and a one-line
+// summary with the link defanged, since collectors often render messages
+// as HTML; otherwise the message is the CEF or LEEF record.
+func (n *SyslogNotifier) format(alert Alert, now time.Time) string {
+    severity, ok := n.Severity[alert.Verdict.Severity]
+    if !ok {
+        severity = 6
+    }
+    hostname := n.hostname
+    if hostname == "" {
+        hostname = "-"
+    }
+    header := fmt.Sprintf("<%d>1 %s %s %s %d alert", n.Facility*8+severity,
+        now.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), hostname, strings.ToLower(AppName), os.Getpid())
+    switch n.Format {
+    case FormatCEF:
+        return header + " - " + CEFRecord(alert, n.Version, now)
+    case FormatLEEF:
+        return header + " - " + LEEFRecord(alert, n.Version, now)
+    }
+
+    var sd strings.Builder
+    sd.WriteString("[" + syslogSDID)
+    param := func(name, value string) {
+        fmt.Fprintf(&sd, ` %s="%s"`, name, sdEscaper.Replace(value))
+    }
+    param("id", alert.ID)
+    param("severity", alert.Verdict.Severity.String())
+    param("url", Defang(alert.URL))
+    param("chat_id", strconv.FormatInt(alert.ChatID, 10))
+    if alert.ChatType != "" {
+        param("chat_type", alert.ChatType)
+    }
+    if alert.Profile != "" {
+        param("profile", alert.Profile)
+    }
+    if alert.Sender != "" {
+        param("sender", alert.Sender)
+    }
+    for _, f := range alert.Verdict.Findings {
+        param("finding", f.Analyzer+": "+f.Description)
+    }
+    sd.WriteString("]")
+
+    summary := fmt.Sprintf("%s: %s %s", alert.Title, strings.ToUpper(alert.Verdict.Severity.String()), Defang(alert.URL))
+    return header + " " + sd.String() + " \ufeff" + strings.ReplaceAll(summary, "\n", " ")
+}
+
+// sdEscaper escapes the characters RFC 5424 reserves in parameter values.
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
@@ -0,0 +1,315 @@
+package notify
+
+import (
+    "bytes"
+    "encoding/xml"
+    "fmt"
+    "html"
+    "net/url"
+    "os"
+    "reflect"
+    "strings"
+    "text/template"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/i18n"
+)
+
+// SnoozeMinutes is how long "Remind me in 1h" holds an alert back before
+// Windows shows the toast again.
+const SnoozeMinutes = 60
+
+// DefaultToastTemplate is the toast XML used when no custom template is set.
+const DefaultToastTemplate = `
+    <toast>
+        <visual>
+            <binding template='ToastGeneric'>
+                <text>{{.Title}}</text>
+                <text>{{.Message}}</text>
+                <text>{{t "alert.verdict"}}: {{severity .Verdict.Severity}}</text>
+                {{with .Domain}}<text placement='attribution'>{{.}}</text>{{end}}
+            </binding>
+        </visual>
+        <actions>
+            <input id='snoozeTime' type='selection' defaultInput='{{snoozeMinutes}}'>
+                <selection id='{{snoozeMinutes}}' content='{{t "toast.snooze_option"}}'/>
+            </input>
+            <action content='{{t "toast.open"}}' arguments='{{openURI .URL}}' activationType='protocol'/>
+            <action content='{{t "toast.sandbox"}}' arguments='{{sandboxURI .URL}}' activationType='protocol'/>
+            {{with submitURI .URL .Verdict.Severity}}<action content='{{t "toast.submit"}}' arguments='{{.}}' activationType='protocol'/>{{end}}
+            <action content='{{t "toast.snooze"}}' arguments='snooze' hint-inputId='snoozeTime' activationType='system'/>
+        </actions>
+    </toast>`
+
+// DefaultTelegramTemplate is the MarkdownV2 reply used when no custom
+// template is set.
+const DefaultTelegramTemplate = `*{{.Title}}*
+{{.Message}}
+{{.URL}}
+{{if .IDN}}{{.Domain}}
+{{end}}{{t "alert.verdict"}}: *{{severity .Verdict.Severity}}*
+{{range .Verdict.Findings}}• {{.Description}}
+{{end}}`
+
+// DefaultWarningTemplate is the MarkdownV2 warning the bot replies with in
+// groups that have warnings on, used when no custom template is set.
+const DefaultWarningTemplate = `⚠️ {{if .Brand}}{{t "warning.impersonates" .Brand}}{{else}}{{t "warning.flagged" (severity .Verdict.Severity)}}{{end}}` +
+    `{{if eq .DomainAgeDays 0}}{{t "warning.registered_today"}}{{else if eq .DomainAgeDays 1}}{{t "warning.registered_yesterday"}}` +
+    `{{else if gt .DomainAgeDays 1}}{{t "warning.registered" .DomainAgeDays}}{{end}} — {{t "warning.advice"}}` +
+    `{{if .IDN}}
+{{.Domain}}{{end}}`
+
+// DefaultProgressTemplate is the toast shown while a slow scan runs. The
+// {progress...} placeholders are toast data bindings updated in place.
+const DefaultProgressTemplate = `
+    <toast>
+        <visual>
+            <binding template='ToastGeneric'>
+                <text>{{t "progress.title"}}</text>
+                <text>{{.URL}}</text>
+                <progress value='{progressValue}' valueStringOverride='{progressValueString}' status='{progressStatus}'/>
+            </binding>
+        </visual>
+    </toast>`
+
+// Templates renders alerts into toast XML, Telegram reply text and the
+// warnings posted in groups.
+//
+// The templates receive the Alert as their data, with Domain, the link's
+// host as extract.DisplayHost shows it, IDN, whether that has both a
+// Unicode and an xn-- form that differ, and Brand and DomainAgeDays, from
+// the brand and domain_age_days facts of the findings (-1 without one).
+// Every string reachable from the alert is escaped for the output format
+// before rendering, so message content can't inject markup; literal text
+// in the template itself is trusted and left as written. The t function
+// looks up a translated string, escaped the same way, and fills in its
+// arguments, which are escaped already if they come from the alert. In
+// toasts, openURI builds the protocol link that opens a URL in a throwaway
+// browser profile, sandboxURI the one that opens it in Windows Sandbox,
+// and submitURI the one that reports it to Microsoft, or "" unless the
+// verdict is malicious and submission is set up.
+type Templates struct {
+    toast    *template.Template
+    telegram *template.Template
+    warning  *template.Template
+    progress *template.Template
+}
+
+// ToastLinks build the protocol links behind the toast buttons, and must
+// percent-encode the link.
+type ToastLinks struct {
+    Open    func(link string) string
+    Sandbox func(link string) string
+    Submit  func(link string) string // nil if links can't be submitted
+}
+
+// LoadTemplates parses the toast, Telegram and warning templates from the
+// given files. An empty path selects the built-in default for that
+// template.
+func LoadTemplates(toastPath, telegramPath, warningPath string, loc *i18n.Localizer, links ToastLinks) (*Templates, error) {
+    // The URLs arrive XML-escaped; the URIs are percent-encoded and need
+    // no further escaping.
+    toastFuncs := templateFuncs(loc, EscapeXML)
+    toastFuncs["openURI"] = func(escapedURL string) string {
+        return links.Open(html.UnescapeString(escapedURL))
+    }
+    toastFuncs["sandboxURI"] = func(escapedURL string) string {
+        return links.Sandbox(html.UnescapeString(escapedURL))
+    }
+    toastFuncs["submitURI"] = func(escapedURL string, severity analysis.Severity) string {
+        if links.Submit == nil || severity < analysis.SeverityMalicious {
+            return ""
+        }
+        return links.Submit(html.UnescapeString(escapedURL))
+    }
+
+    toast, err := parseTemplate("toast", toastPath, DefaultToastTemplate, toastFuncs)
+    if err != nil {
+        return nil, err
+    }
+    telegram, err := parseTemplate("telegram", telegramPath, DefaultTelegramTemplate, templateFuncs(loc, EscapeMarkdown))
+    if err != nil {
+        return nil, err
+    }
+    warning, err := parseTemplate("warning", warningPath, DefaultWarningTemplate, templateFuncs(loc, EscapeMarkdown))
+    if err != nil {
+        return nil, err
+    }
+    progress, err := parseTemplate("progress", "", DefaultProgressTemplate, toastFuncs)
+    if err != nil {
+        return nil, err
+    }
+    return &Templates{toast: toast, telegram: telegram, warning: warning, progress: progress}, nil
+}
+
+// templateFuncs are the functions available to every template, escaping
+// their output with escape.
+func templateFuncs(loc *i18n.Localizer, escape func(string) string) template.FuncMap {
+    return template.FuncMap{
+        "snoozeMinutes": func() int { return SnoozeMinutes },
+        "t": func(key string, args ...interface{}) string {
+            if len(args) == 0 {
+                return escape(loc.T(key))
+            }
+            return fmt.Sprintf(escape(loc.T(key)), args...)
+        },
+        "severity": func(s analysis.Severity) string {
+            return escape(loc.T("severity." + s.String()))
+        },
+    }
+}
+
+func parseTemplate(name, path, fallback string, funcs template.FuncMap) (*template.Template, error) {
+    text := fallback
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read %s template: %v", name, err)
+        }
+        text = string(data)
+    }
+    tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse %s template: %v", name, err)
+    }
+    return tmpl, nil
+}
+
+// templateData is what templates are given for an alert.
+type templateData struct {
+    Alert
+    Domain        string
+    IDN           bool
+    Brand         string
+    DomainAgeDays int
+}
+
+func newTemplateData(alert Alert) templateData {
+    data := templateData{Alert: alert, Domain: extract.DisplayDomain(alert.URL), DomainAgeDays: -1}
+    if u, err := url.Parse(alert.URL); err == nil {
+        data.IDN = extract.IsIDN(u.Hostname())
+    }
+    // Plugins report facts as JSON, so numbers arrive as float64
+    for _, f := range alert.Verdict.Findings {
+        if brand, ok := f.Facts["brand"].(string); ok && data.Brand == "" {
+            data.Brand = brand
+        }
+        switch age := f.Facts["domain_age_days"].(type) {
+        case float64:
+            data.DomainAgeDays = int(age)
+        case int:
+            data.DomainAgeDays = age
+        }
+    }
+    return data
+}
+
+// RenderToast renders the alert as toast XML.
+func (t *Templates) RenderToast(alert Alert) (string, error) {
+    return render(t.toast, escapeStrings(newTemplateData(alert), EscapeXML))
+}
+
+// RenderProgress renders the progress toast shown while the alert's link is
+// being scanned.
+func (t *Templates) RenderProgress(alert Alert) (string, error) {
+    return render(t.progress, escapeStrings(newTemplateData(alert), EscapeXML))
+}
+
+// RenderTelegram renders the alert as a MarkdownV2 Telegram message.
+func (t *Templates) RenderTelegram(alert Alert) (string, error) {
+    return render(t.telegram, escapeStrings(newTemplateData(alert), EscapeMarkdown))
+}
+
+// RenderWarning renders the MarkdownV2 warning posted in reply to the
+// alert's message in its group.
+func (t *Templates) RenderWarning(alert Alert) (string, error) {
+    return render(t.warning, escapeStrings(newTemplateData(alert), EscapeMarkdown))
+}
+
+func render(tmpl *template.Template, data interface{}) (string, error) {
+    var buf bytes.Buffer
+    if err := tmpl.Execute(&buf, data); err != nil {
+        return "", fmt.Errorf("failed to render %s template: %v", tmpl.Name(), err)
+    }
+    return buf.String(), nil
+}
+
+// EscapeXML escapes s for use in XML text and attribute values.
+func EscapeXML(s string) string {
+    var buf bytes.Buffer
+    xml.EscapeText(&buf, []byte(s))
+    return buf.String()
+}
+
+// markdownReplacer escapes the characters reserved by Telegram MarkdownV2.
+var markdownReplacer = strings.NewReplacer(
+    `\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+    "~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+    "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// EscapeMarkdown escapes s for use in a Telegram MarkdownV2 message.
+func EscapeMarkdown(s string) string {
+    return markdownReplacer.Replace(s)
+}
+
+// escapeStrings returns a copy of v with escape applied to every string it
+// contains, including those nested in structs, pointers, interfaces,
+// slices and maps.
+func escapeStrings(v interface{}, escape func(string) string) interface{} {
+    return escapeValue(reflect.ValueOf(v), escape).Interface()
+}
+
+func escapeValue(v reflect.Value, escape func(string) string) reflect.Value {
+    switch v.Kind() {
+    case reflect.String:
+        out := reflect.New(v.Type()).Elem()
+        out.SetString(escape(v.String()))
+        return out
+    case reflect.Interface:
+        // Such as the values of a finding's Facts
+        if v.IsNil() {
+            return v
+        }
+        out := reflect.New(v.Type()).Elem()
+        out.Set(escapeValue(v.Elem(), escape))
+        return out
+    case reflect.Ptr:
+        if v.IsNil() {
+            return v
+        }
+        out := reflect.New(v.Type().Elem())
+        out.Elem().Set(escapeValue(v.Elem(), escape))
+        return out
+    case reflect.Struct:
+        out := reflect.New(v.Type()).Elem()
+        out.Set(v)
+        for i := 0; i < v.NumField(); i++ {
+            if out.Field(i).CanSet() {
+                out.Field(i).Set(escapeValue(v.Field(i), escape))
+            }
+        }
+        return out
+    case reflect.Slice:
+        if v.IsNil() {
+            return v
+        }
+        out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+        for i := 0; i < v.Len(); i++ {
+            out.Index(i).Set(escapeValue(v.Index(i), escape))
+        }
+        return out
+    case reflect.Map:
+        if v.IsNil() {
+            return v
+        }
+        out := reflect.MakeMapWithSize(v.Type(), v.Len())
+        iter := v.MapRange()
+        for iter.Next() {
+            out.SetMapIndex(iter.Key(), escapeValue(iter.Value(), escape))
+        }
+        return out
+    }
+    return v
+}
@@ -0,0 +1,154 @@
+package notify
+
+import (
+    "encoding/json"
+    "encoding/xml"
+    "io"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+)
+
+// hostile is a fact value a plugin could have copied from the page.
+const hostile = `</text><action content='x' arguments='https://evil.example' activationType='protocol'/><text>"*_[a](b)`
+
+func hostileAlert() Alert {
+    return Alert{
+        Title: "Phishing link", Message: "Check this", URL: "https://a.example/login",
+        Verdict: analysis.Verdict{
+            Severity: analysis.SeverityMalicious,
+            Findings: []analysis.Finding{{
+                Analyzer: "plugin", Severity: analysis.SeverityMalicious, Description: "flagged",
+                Facts: map[string]interface{}{
+                    "title":           hostile,
+                    "tags":            []interface{}{hostile, 3.0},
+                    "domain_age_days": 2.0,
+                },
+            }},
+        },
+    }
+}
+
+func writeTemplate(t *testing.T, text string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "template.tmpl")
+    if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestEscapeStringsFacts(t *testing.T) {
+    facts := map[string]interface{}{
+        "s":     "<b>",
+        "list":  []interface{}{"<i>", 1.0, nil},
+        "map":   map[string]interface{}{"k": "&"},
+        "n":     2.0,
+        "ok":    true,
+        "empty": nil,
+    }
+    got := escapeStrings(facts, EscapeXML)
+    want := map[string]interface{}{
+        "s":     "&lt;b&gt;",
+        "list":  []interface{}{"&lt;i&gt;", 1.0, nil},
+        "map":   map[string]interface{}{"k": "&amp;"},
+        "n":     2.0,
+        "ok":    true,
+        "empty": nil,
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("escapeStrings = %#v, want %#v", got, want)
+    }
+    if facts["s"] != "<b>" {
+        t.Errorf("escapeStrings changed its argument")
+    }
+}
+
+func TestRenderToastEscapesFacts(t *testing.T) {
+    loc, err := i18n.NewLocalizer("en")
+    if err != nil {
+        t.Fatal(err)
+    }
+    toast := writeTemplate(t, `<toast><visual><binding template='ToastGeneric'><text>{{.Title}}</text>`+
+        `{{range .Verdict.Findings}}<text>{{.Facts.title}}</text>{{range .Facts.tags}}<text>{{.}}</text>{{end}}{{end}}`+
+        `</binding></visual></toast>`)
+    links := ToastLinks{Open: func(string) string { return "open" }, Sandbox: func(string) string { return "sandbox" }}
+    tmpls, err := LoadTemplates(toast, "", "", loc, links)
+    if err != nil {
+        t.Fatal(err)
+    }
+    out, err := tmpls.RenderToast(hostileAlert())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Well-formed, with the fact as text and no action element
+    var texts []string
+    dec := xml.NewDecoder(strings.NewReader(out))
+    for {
+        tok, err := dec.Token()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatalf("toast isn't well-formed XML: %v\n%s", err, out)
+        }
+        switch tok := tok.(type) {
+        case xml.StartElement:
+            if tok.Name.Local == "action" {
+                t.Errorf("fact injected an action: %s", out)
+            }
+        case xml.CharData:
+            texts = append(texts, string(tok))
+        }
+    }
+    if n := strings.Count(strings.Join(texts, "\n"), hostile); n != 2 {
+        t.Errorf("fact shown %d times as text, want 2: %s", n, out)
+    }
+}
+
+func TestRenderTelegramEscapesFacts(t *testing.T) {
+    loc, err := i18n.NewLocalizer("en")
+    if err != nil {
+        t.Fatal(err)
+    }
+    telegram := writeTemplate(t, `*{{.Title}}*{{range .Verdict.Findings}} {{.Facts.title}}{{end}}`)
+    tmpls, err := LoadTemplates("", telegram, "", loc, ToastLinks{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    out, err := tmpls.RenderTelegram(hostileAlert())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := "*Phishing link* " + EscapeMarkdown(hostile); out != want {
+        t.Errorf("RenderTelegram = %q, want %q", out, want)
+    }
+}
+
+func TestWebhookTemplateEscapesFacts(t *testing.T) {
+    n, err := NewOutboundWebhook("https://hooks.example", writeTemplate(t,
+        `{"title": "{{(index .Verdict.Findings 0).Facts.title}}", "age": {{(index .Verdict.Findings 0).Facts.domain_age_days}}}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    body, err := n.body(hostileAlert())
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got struct {
+        Title string  `json:"title"`
+        Age   float64 `json:"age"`
+    }
+    if err := json.Unmarshal(body, &got); err != nil {
+        t.Fatalf("body %s: %v", body, err)
+    }
+    if got.Title != hostile || got.Age != 2 {
+        t.Errorf("body decoded to %+v, want the fact as it was", got)
+    }
+}
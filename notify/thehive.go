@@ -0,0 +1,287 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// theHiveSeverities are TheHive's severities (1 low to 4 critical) of the
+// verdicts the sink sends.
+var theHiveSeverities = map[analysis.Severity]int{
+    analysis.SeveritySuspicious: 2,
+    analysis.SeverityMalicious:  3,
+}
+
+// observableFacts are the plugin facts that become observables, with
+// their TheHive data type. A fact may hold one value or a list.
+var observableFacts = map[string]string{
+    "ip":     "ip",
+    "ips":    "ip",
+    "md5":    "hash",
+    "sha1":   "hash",
+    "sha256": "hash",
+}
+
+// TheHiveNotifier opens an alert in TheHive for malicious links, with the
+// link, its domain or IP and any IPs and hashes reported by plugins as
+// observables, and the chat it was received in as the description, so
+// the incident can be triaged and merged into a case there.
+//
+// Alerts below malicious are ignored, so the sink can share a route with
+// others.
+type TheHiveNotifier struct {
+    URL          string // e.g. https://thehive.example.com
+    APIKey       string
+    Organisation string   // Sent as X-Organisation, if set
+    TLP          int      // 0 white/clear to 4 red
+    PAP          int      // 0 white/clear to 3 red
+    Tags         []string // Added to every alert
+}
+
+// Notify opens a TheHive alert if the verdict is malicious. The alert's
+// sourceRef is the alert ID, so TheHive refuses a second one for the same
+// message.
+func (n TheHiveNotifier) Notify(ctx context.Context, alert Alert) error {
+    if alert.Verdict.Severity < analysis.SeverityMalicious {
+        return nil
+    }
+    tags := append([]string{"telephish", "verdict:" + alert.Verdict.Severity.String()}, n.Tags...)
+    if alert.ChatID != 0 {
+        tags = append(tags, "chat:"+strconv.FormatInt(alert.ChatID, 10))
+    }
+    if alert.Profile != "" {
+        tags = append(tags, "profile:"+alert.Profile)
+    }
+    body := map[string]interface{}{
+        "type":        "telephish",
+        "source":      AppName,
+        "sourceRef":   alert.ID,
+        "title":       AppName + ": " + alert.Title,
+        "description": theHiveDescription(alert),
+        "severity":    theHiveSeverities[alert.Verdict.Severity],
+        "tlp":         n.TLP,
+        "pap":         n.PAP,
+        "tags":        tags,
+        "observables": Observables(alert),
+    }
+    header := http.Header{}
+    if n.Organisation != "" {
+        header.Set("X-Organisation", n.Organisation)
+    }
+    if err := postAPI(ctx, n.URL+"/api/v1/alert", n.APIKey, header, body, nil); err != nil {
+        return fmt.Errorf("thehive: %v", err)
+    }
+    return nil
+}
+
+// Observable is an indicator attached to a TheHive alert.
+type Observable struct {
+    DataType string   `json:"dataType"` // url, domain, ip or hash
+    Data     string   `json:"data"`
+    Message  string   `json:"message,omitempty"`
+    IOC      bool     `json:"ioc"`
+    Tags     []string `json:"tags,omitempty"`
+}
+
+// Observables returns the alert's indicators: its link, the link's domain
+// or IP, and the IPs and hashes plugins reported as facts.
+func Observables(alert Alert) []Observable {
+    var list []Observable
+    seen := map[string]bool{}
+    add := func(o Observable) {
+        if o.Data == "" || seen[o.DataType+" "+o.Data] {
+            return
+        }
+        seen[o.DataType+" "+o.Data] = true
+        list = append(list, o)
+    }
+    add(Observable{DataType: "url", Data: alert.URL, Message: "Link received in " + alert.Origin(), IOC: true})
+    if host := linkHost(alert); net.ParseIP(host) != nil {
+        add(Observable{DataType: "ip", Data: host, Message: "Host of the link", IOC: true})
+    } else {
+        add(Observable{DataType: "domain", Data: host, Message: "Domain of the link", IOC: true})
+    }
+    for _, f := range alert.Verdict.Findings {
+        names := make([]string, 0, len(f.Facts))
+        for name := range f.Facts {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            dataType, ok := observableFacts[name]
+            if !ok {
+                continue
+            }
+            var values []interface{}
+            switch v := f.Facts[name].(type) {
+            case []interface{}:
+                values = v
+            case []string:
+                for _, s := range v {
+                    values = append(values, s)
+                }
+            default:
+                values = []interface{}{v}
+            }
+            for _, v := range values {
+                if s, ok := v.(string); ok {
+                    add(Observable{DataType: dataType, Data: s, Message: "Reported by " + f.Analyzer, Tags: []string{name}})
+                }
+            }
+        }
+    }
+    return list
+}
+
+// theHiveDescription describes in Markdown where the link was received
+// and why it was flagged.
+func theHiveDescription(alert Alert) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "%s flagged a link as **%s** in a Telegram message.\n\n", AppName, alert.Verdict.Severity)
+    fmt.Fprintf(&b, "| | |\n|---|---|\n")
+    fmt.Fprintf(&b, "| Link | `%s` |\n", Defang(alert.URL))
+    fmt.Fprintf(&b, "| Chat | %d (%s) |\n", alert.ChatID, alert.ChatType)
+    if alert.Sender != "" {
+        fmt.Fprintf(&b, "| Sender | %s |\n", alert.Sender)
+    }
+    if alert.Profile != "" {
+        fmt.Fprintf(&b, "| Profile | %s |\n", alert.Profile)
+    }
+    fmt.Fprintf(&b, "| Score | %d/10 |\n", Score(alert))
+    if len(alert.Verdict.Findings) > 0 {
+        b.WriteString("\n**Findings**\n\n")
+        for _, f := range alert.Verdict.Findings {
+            fmt.Fprintf(&b, "- %s (%s): %s\n", f.Analyzer, f.Severity, f.Description)
+        }
+    }
+    if alert.Message != "" {
+        fmt.Fprintf(&b, "\n**Message**\n\n> %s\n", strings.ReplaceAll(alert.Message, "\n", "\n> "))
+    }
+    return b.String()
+}
+
+// CortexNotifier runs Cortex analyzers on malicious links, so their
+// reports are waiting for whoever triages the incident. Analyzers are
+// named as in Cortex, e.g. VirusTotal_GetReport_3_1, and looked up once.
+//
+// Alerts below malicious are ignored, so the sink can share a route with
+// others.
+type CortexNotifier struct {
+    URL       string // e.g. https://cortex.example.com
+    APIKey    string
+    Analyzers []string
+    TLP       int
+
+    mu  sync.Mutex
+    ids map[string]string // Analyzer IDs by name
+}
+
+// Notify runs the analyzers on the alert's link if its verdict is
+// malicious.
+func (n *CortexNotifier) Notify(ctx context.Context, alert Alert) error {
+    if alert.Verdict.Severity < analysis.SeverityMalicious {
+        return nil
+    }
+    ids, err := n.analyzerIDs(ctx)
+    if err != nil {
+        return fmt.Errorf("cortex: %v", err)
+    }
+    job := map[string]interface{}{
+        "data":     alert.URL,
+        "dataType": "url",
+        "tlp":      n.TLP,
+        "message":  AppName + ": " + alert.Title + ", " + alert.Origin(),
+    }
+    var errs []string
+    for _, name := range n.Analyzers {
+        id, ok := ids[name]
+        if !ok {
+            errs = append(errs, fmt.Sprintf("no analyzer %q for URLs is enabled", name))
+            continue
+        }
+        if err := postAPI(ctx, n.URL+"/api/analyzer/"+url.PathEscape(id)+"/run", n.APIKey, nil, job, nil); err != nil {
+            errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+        }
+    }
+    if len(errs) > 0 {
+        return fmt.Errorf("cortex: %s", strings.Join(errs, "; "))
+    }
+    return nil
+}
+
+// analyzerIDs returns the IDs of the enabled analyzers that take URLs, by
+// name, asking Cortex the first time.
+func (n *CortexNotifier) analyzerIDs(ctx context.Context) (map[string]string, error) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    if n.ids != nil {
+        return n.ids, nil
+    }
+    req, err := http.NewRequestWithContext(ctx, "GET", n.URL+"/api/analyzer/type/url", nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Authorization", "Bearer "+n.APIKey)
+    var analyzers []struct {
+        ID   string `json:"id"`
+        Name string `json:"name"`
+    }
+    if err := doAPI(req, &analyzers); err != nil {
+        return nil, err
+    }
+    ids := map[string]string{}
+    for _, a := range analyzers {
+        ids[a.Name] = a.ID
+    }
+    n.ids = ids
+    return ids, nil
+}
+
+// postAPI posts body as JSON to endpoint with a bearer key, decoding the
+// response into out unless it is nil.
+func postAPI(ctx context.Context, endpoint, key string, header http.Header, body, out interface{}) error {
+    data, err := json.Marshal(body)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    for name, values := range header {
+        req.Header[name] = values
+    }
+    req.Header.Set("Authorization", "Bearer "+key)
+    req.Header.Set("Content-Type", "application/json")
+    return doAPI(req, out)
+}
+
+// doAPI sends req, failing on a status other than 2xx, and decodes the
+// response into out unless it is nil.
+func doAPI(req *http.Request, out interface{}) error {
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+    }
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
@@ -0,0 +1,364 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// Ticket deduplication keys.
+const (
+    DedupDomain = "domain" // One ticket per host of the link
+    DedupURL    = "url"    // One ticket per link
+)
+
+// trackerError is a tracker's refusal of a request.
+type trackerError struct {
+    Status int
+    msg    string
+}
+
+func (e *trackerError) Error() string {
+    return e.msg
+}
+
+// issueGone reports whether err means the issue commented on no longer
+// exists, so a new one should be opened.
+func issueGone(err error) bool {
+    var t *trackerError
+    return errors.As(err, &t) && (t.Status == http.StatusNotFound || t.Status == http.StatusGone)
+}
+
+// Issue is a ticket to open in a tracker.
+type Issue struct {
+    Summary     string
+    Description string // Plain text
+    Labels      []string
+    Alert       Alert
+}
+
+// Tracker is an issue tracker the ticket sink opens issues in.
+type Tracker interface {
+    // Name identifies the tracker and project, e.g. jira:SEC, so tickets
+    // opened in one aren't looked for in another.
+    Name() string
+    // CreateIssue opens issue and returns its key, e.g. SEC-123.
+    CreateIssue(ctx context.Context, issue Issue) (string, error)
+    // Comment adds text to the issue key.
+    Comment(ctx context.Context, key, text string, alert Alert) error
+}
+
+// TicketLedger remembers which issue was opened for which deduplication
+// key. The history implements it, so tickets are still found after a
+// restart.
+type TicketLedger interface {
+    // OpenTicket returns the issue opened in tracker for key since then,
+    // if there is one.
+    OpenTicket(tracker, key string, since time.Time) (issue string, ok bool, err error)
+    // SaveTicket records a sighting of key, filed under issue.
+    SaveTicket(tracker, key, issue string, at time.Time) error
+}
+
+// TicketNotifier opens a ticket for each link at or above MinSeverity,
+// and comments on that ticket when the link, or with DedupDomain another
+// link on the same host, is seen again within Window, so repeated
+// sightings don't flood the tracker.
+type TicketNotifier struct {
+    Tracker     Tracker
+    Ledger      TicketLedger // Nil keeps tickets in memory
+    MinSeverity analysis.Severity
+    Dedup       string        // DedupDomain or DedupURL
+    Window      time.Duration // How long sightings go to the same ticket
+    Labels      []string
+
+    mu sync.Mutex // Serializes lookups and creation, so a key gets one ticket
+}
+
+// NewTicketNotifier returns a sink opening tickets in tracker for
+// malicious links, one per domain for 30 days.
+func NewTicketNotifier(tracker Tracker, ledger TicketLedger) *TicketNotifier {
+    if ledger == nil {
+        ledger = &memoryLedger{tickets: map[string]memoryTicket{}}
+    }
+    return &TicketNotifier{Tracker: tracker, Ledger: ledger, MinSeverity: analysis.SeverityMalicious, Dedup: DedupDomain, Window: 30 * 24 * time.Hour}
+}
+
+// Notify opens a ticket for the alert, or comments on the one already
+// open for its link.
+func (n *TicketNotifier) Notify(ctx context.Context, alert Alert) error {
+    if alert.Verdict.Severity < n.MinSeverity {
+        return nil
+    }
+    key := alert.URL
+    if n.Dedup != DedupURL {
+        key = strings.ToLower(linkHost(alert))
+    }
+    tracker := n.Tracker.Name()
+    now := time.Now()
+
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    issue, ok, err := n.Ledger.OpenTicket(tracker, key, now.Add(-n.Window))
+    if err != nil {
+        return err
+    }
+    if ok {
+        err = n.Tracker.Comment(ctx, issue, ticketSighting(alert), alert)
+        if err == nil {
+            return n.Ledger.SaveTicket(tracker, key, issue, now)
+        }
+        if !issueGone(err) {
+            return fmt.Errorf("%s: failed to comment on %s: %v", tracker, issue, err)
+        }
+    }
+    issue, err = n.Tracker.CreateIssue(ctx, Issue{
+        Summary:     fmt.Sprintf("%s: %s link %s", AppName, alert.Verdict.Severity, Defang(key)),
+        Description: ticketDescription(alert),
+        Labels:      append([]string{"telephish"}, n.Labels...),
+        Alert:       alert,
+    })
+    if err != nil {
+        return fmt.Errorf("%s: failed to open ticket: %v", tracker, err)
+    }
+    return n.Ledger.SaveTicket(tracker, key, issue, now)
+}
+
+// ticketDescription describes the first sighting of a link, in plain text
+// that reads the same in every tracker.
+func ticketDescription(alert Alert) string {
+    lines := []string{
+        fmt.Sprintf("%s flagged a link as %s in a Telegram message.", AppName, alert.Verdict.Severity),
+        "",
+        "Link: " + Defang(alert.URL),
+        fmt.Sprintf("Chat: %d (%s)", alert.ChatID, alert.ChatType),
+    }
+    if alert.Sender != "" {
+        lines = append(lines, "Sender: "+alert.Sender)
+    }
+    if alert.Profile != "" {
+        lines = append(lines, "Profile: "+alert.Profile)
+    }
+    lines = append(lines, fmt.Sprintf("Score: %d/10", Score(alert)))
+    if len(alert.Verdict.Findings) > 0 {
+        lines = append(lines, "", "Findings:")
+        for _, f := range alert.Verdict.Findings {
+            lines = append(lines, fmt.Sprintf("- %s (%s): %s", f.Analyzer, f.Severity, f.Description))
+        }
+    }
+    lines = append(lines, "", "Later sightings are added as comments.")
+    return strings.Join(lines, "\n")
+}
+
+// ticketSighting describes a later sighting for a comment.
+func ticketSighting(alert Alert) string {
+    s := fmt.Sprintf("Seen again %s in %s: %s, %s", time.Now().UTC().Format("2006-01-02 15:04 MST"), alert.Origin(), Defang(alert.URL), alert.Verdict.Severity)
+    if alert.Sender != "" {
+        s += ", sent by " + alert.Sender
+    }
+    return s
+}
+
+type memoryTicket struct {
+    issue  string
+    opened time.Time
+}
+
+// memoryLedger keeps tickets for as long as the process runs.
+type memoryLedger struct {
+    mu      sync.Mutex
+    tickets map[string]memoryTicket
+}
+
+func (l *memoryLedger) OpenTicket(tracker, key string, since time.Time) (string, bool, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    t, ok := l.tickets[tracker+"\x00"+key]
+    if !ok || t.opened.Before(since) {
+        return "", false, nil
+    }
+    return t.issue, true, nil
+}
+
+func (l *memoryLedger) SaveTicket(tracker, key, issue string, at time.Time) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if t, ok := l.tickets[tracker+"\x00"+key]; ok && t.issue == issue {
+        return nil
+    }
+    l.tickets[tracker+"\x00"+key] = memoryTicket{issue: issue, opened: at}
+    return nil
+}
+
+// JiraTracker opens issues in a Jira Cloud project through the REST API
+// v3, signing in with an Atlassian account's email and API token.
+type JiraTracker struct {
+    URL       string // e.g. https://example.atlassian.net
+    Email     string
+    Token     string
+    Project   string // Project key, e.g. SEC
+    IssueType string // Default Task
+    Priority  string // Priority name, e.g. High; empty leaves Jira's default
+}
+
+func (j JiraTracker) Name() string {
+    return "jira:" + j.Project
+}
+
+func (j JiraTracker) CreateIssue(ctx context.Context, issue Issue) (string, error) {
+    issueType := j.IssueType
+    if issueType == "" {
+        issueType = "Task"
+    }
+    fields := map[string]interface{}{
+        "project":     map[string]string{"key": j.Project},
+        "issuetype":   map[string]string{"name": issueType},
+        "summary":     issue.Summary,
+        "description": jiraDocument(issue.Description),
+        "labels":      issue.Labels,
+    }
+    if j.Priority != "" {
+        fields["priority"] = map[string]string{"name": j.Priority}
+    }
+    var created struct {
+        Key string `json:"key"`
+    }
+    if err := j.post(ctx, "/rest/api/3/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+        return "", err
+    }
+    return created.Key, nil
+}
+
+func (j JiraTracker) Comment(ctx context.Context, key, text string, alert Alert) error {
+    return j.post(ctx, "/rest/api/3/issue/"+url.PathEscape(key)+"/comment", map[string]interface{}{"body": jiraDocument(text)}, nil)
+}
+
+func (j JiraTracker) post(ctx context.Context, path string, body, out interface{}) error {
+    data, err := json.Marshal(body)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", j.URL+path, bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(j.Email+":"+j.Token)))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Accept", "application/json")
+    return doTrackerAPI(req, out)
+}
+
+// jiraDocument converts plain text to the Atlassian Document Format the
+// v3 API takes, a paragraph per line.
+func jiraDocument(text string) map[string]interface{} {
+    var content []interface{}
+    for _, line := range strings.Split(text, "\n") {
+        if line == "" {
+            // Empty text nodes are refused; an empty paragraph is a blank line
+            content = append(content, map[string]interface{}{"type": "paragraph"})
+            continue
+        }
+        content = append(content, map[string]interface{}{
+            "type":    "paragraph",
+            "content": []interface{}{map[string]string{"type": "text", "text": line}},
+        })
+    }
+    return map[string]interface{}{"type": "doc", "version": 1, "content": content}
+}
+
+// WebhookTracker opens issues in any tracker with an HTTP API, such as
+// GitHub, Gitea or a ticketing system's own webhook. It posts
+//
+//    {"title": ..., "body": ..., "labels": [...], "severity": ..., "url": ..., "alert": {...}}
+//
+// to CreateURL and reads the new issue's key from the response's KeyField.
+// Comments post {"body": ..., "alert": {...}} to CommentURL, in which
+// {issue} is replaced by the key.
+type WebhookTracker struct {
+    CreateURL  string
+    CommentURL string // e.g. https://api.github.com/repos/acme/soc/issues/{issue}/comments
+    Headers    map[string]string
+    KeyField   string // Default id
+}
+
+func (w WebhookTracker) Name() string {
+    return "webhook:" + w.CreateURL
+}
+
+func (w WebhookTracker) CreateIssue(ctx context.Context, issue Issue) (string, error) {
+    body := map[string]interface{}{
+        "title":    issue.Summary,
+        "body":     issue.Description,
+        "labels":   issue.Labels,
+        "severity": issue.Alert.Verdict.Severity.String(),
+        "url":      issue.Alert.URL,
+        "alert":    issue.Alert,
+    }
+    var created map[string]interface{}
+    if err := w.post(ctx, w.CreateURL, body, &created); err != nil {
+        return "", err
+    }
+    field := w.KeyField
+    if field == "" {
+        field = "id"
+    }
+    switch key := created[field].(type) {
+    case string:
+        if key != "" {
+            return key, nil
+        }
+    case float64:
+        return strconv.FormatFloat(key, 'f', -1, 64), nil
+    }
+    return "", fmt.Errorf("the response has no %q field with the issue's key", field)
+}
+
+func (w WebhookTracker) Comment(ctx context.Context, key, text string, alert Alert) error {
+    endpoint := strings.ReplaceAll(w.CommentURL, "{issue}", url.PathEscape(key))
+    return w.post(ctx, endpoint, map[string]interface{}{"body": text, "alert": alert}, nil)
+}
+
+func (w WebhookTracker) post(ctx context.Context, endpoint string, body, out interface{}) error {
+    data, err := json.Marshal(body)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    for name, value := range w.Headers {
+        req.Header.Set(name, value)
+    }
+    return doTrackerAPI(req, out)
+}
+
+// doTrackerAPI is doAPI, but returns a refusal as a trackerError, so a
+// missing issue can be told apart.
+func doTrackerAPI(req *http.Request, out interface{}) error {
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return &trackerError{resp.StatusCode, fmt.Sprintf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))}
+    }
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
@@ -0,0 +1,182 @@
+package notify
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+)
+
+// SeverityRGB are the accent colors used for each severity in chat sinks.
+var SeverityRGB = map[analysis.Severity]int{
+    analysis.SeverityClean:      0x2EB67D,
+    analysis.SeverityInfo:       0x36C5F0,
+    analysis.SeveritySuspicious: 0xECB22E,
+    analysis.SeverityMalicious:  0xE01E5A,
+}
+
+// webhookClient is used by all outgoing webhook sinks.
+var webhookClient = newSinkClient()
+
+// Defang rewrites a URL so chat clients don't turn it into a clickable
+// link or fetch a preview of it: http → hxxp and dots become [.].
+func Defang(link string) string {
+    link = strings.Replace(link, "http", "hxxp", 1)
+    return strings.ReplaceAll(link, ".", "[.]")
+}
+
+// postJSON posts payload as JSON and treats any non-2xx status as an error.
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    resp, err := webhookClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+    }
+    return nil
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook as Block Kit.
+type SlackNotifier struct {
+    WebhookURL string
+    Loc        *i18n.Localizer
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Notify posts the alert to Slack.
+func (n SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+    severity := n.Loc.T("severity." + alert.Verdict.Severity.String())
+    summary := fmt.Sprintf("*%s:* %s\n`%s`", n.Loc.T("alert.verdict"), severity, slackEscaper.Replace(Defang(alert.URL)))
+
+    blocks := []map[string]interface{}{
+        {"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": alert.Title}},
+        {"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": slackEscaper.Replace(alert.Message)}},
+        {"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": summary}},
+    }
+    if len(alert.Verdict.Findings) > 0 {
+        var lines []string
+        for _, f := range alert.Verdict.Findings {
+            lines = append(lines, fmt.Sprintf("• *%s* %s", f.Analyzer, slackEscaper.Replace(f.Description)))
+        }
+        blocks = append(blocks, map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": strings.Join(lines, "\n")}})
+    }
+    blocks = append(blocks, map[string]interface{}{
+        "type":     "context",
+        "elements": []map[string]interface{}{{"type": "mrkdwn", "text": AppName + " · " + alert.Origin()}},
+    })
+
+    return postJSON(ctx, n.WebhookURL, map[string]interface{}{
+        "text": fmt.Sprintf("%s: %s", strings.ToUpper(alert.Verdict.Severity.String()), Defang(alert.URL)),
+        "attachments": []map[string]interface{}{{
+            "color":  fmt.Sprintf("#%06X", SeverityRGB[alert.Verdict.Severity]),
+            "blocks": blocks,
+        }},
+    })
+}
+
+// DiscordNotifier posts alerts to a Discord webhook as an embed.
+type DiscordNotifier struct {
+    WebhookURL string
+    Loc        *i18n.Localizer
+}
+
+var discordEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`)
+
+// Notify posts the alert to Discord.
+func (n DiscordNotifier) Notify(ctx context.Context, alert Alert) error {
+    fields := []map[string]interface{}{
+        {"name": n.Loc.T("alert.verdict"), "value": n.Loc.T("severity." + alert.Verdict.Severity.String()), "inline": true},
+        {"name": "URL", "value": "`" + strings.ReplaceAll(Defang(alert.URL), "`", "'") + "`", "inline": true},
+    }
+    for _, f := range alert.Verdict.Findings {
+        fields = append(fields, map[string]interface{}{"name": f.Analyzer, "value": discordEscaper.Replace(f.Description)})
+    }
+    // Discord caps embeds at 25 fields
+    if len(fields) > 25 {
+        fields = fields[:25]
+    }
+
+    return postJSON(ctx, n.WebhookURL, map[string]interface{}{
+        "username":         AppName,
+        "allowed_mentions": map[string]interface{}{"parse": []string{}},
+        "embeds": []map[string]interface{}{{
+            "title":       alert.Title,
+            "description": discordEscaper.Replace(alert.Message),
+            "color":       SeverityRGB[alert.Verdict.Severity],
+            "fields":      fields,
+            "footer":      map[string]interface{}{"text": alert.Origin()},
+            "timestamp":   time.Now().UTC().Format(time.RFC3339),
+        }},
+    })
+}
+
+// TeamsNotifier posts alerts to a Microsoft Teams webhook as an Adaptive Card.
+type TeamsNotifier struct {
+    WebhookURL string
+    Loc        *i18n.Localizer
+}
+
+// teamsColors maps severities onto Adaptive Card text colors.
+var teamsColors = map[analysis.Severity]string{
+    analysis.SeverityClean:      "good",
+    analysis.SeverityInfo:       "accent",
+    analysis.SeveritySuspicious: "warning",
+    analysis.SeverityMalicious:  "attention",
+}
+
+// Notify posts the alert to Teams.
+func (n TeamsNotifier) Notify(ctx context.Context, alert Alert) error {
+    facts := []map[string]string{
+        {"title": n.Loc.T("alert.verdict"), "value": n.Loc.T("severity." + alert.Verdict.Severity.String())},
+        {"title": "URL", "value": Defang(alert.URL)},
+        {"title": "Chat", "value": fmt.Sprint(alert.ChatID)},
+    }
+    if alert.Profile != "" {
+        facts = append(facts, map[string]string{"title": "Profile", "value": alert.Profile})
+    }
+    body := []map[string]interface{}{
+        {"type": "TextBlock", "text": alert.Title, "weight": "bolder", "size": "medium", "color": teamsColors[alert.Verdict.Severity]},
+        {"type": "TextBlock", "text": alert.Message, "wrap": true},
+        {"type": "FactSet", "facts": facts},
+    }
+    if len(alert.Verdict.Findings) > 0 {
+        var findings []map[string]string
+        for _, f := range alert.Verdict.Findings {
+            findings = append(findings, map[string]string{"title": f.Analyzer, "value": f.Description})
+        }
+        body = append(body, map[string]interface{}{"type": "FactSet", "facts": findings, "separator": true})
+    }
+
+    return postJSON(ctx, n.WebhookURL, map[string]interface{}{
+        "type": "message",
+        "attachments": []map[string]interface{}{{
+            "contentType": "application/vnd.microsoft.card.adaptive",
+            "content": map[string]interface{}{
+                "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+                "type":    "AdaptiveCard",
+                "version": "1.4",
+                "body":    body,
+            },
+        }},
+    })
+}
@@ -0,0 +1,207 @@
+package telephish
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/capture"
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// Plugin executables are named for what they extend: analyzer-<name> adds
+// an analyzer and sink-<name> adds a sink, with any extension.
+const (
+    analyzerPluginPrefix = "analyzer-"
+    sinkPluginPrefix     = "sink-"
+)
+
+// maxPluginOutput caps how much a plugin may write to stdout or stderr.
+const maxPluginOutput = 1 << 20
+
+// Plugins are the external analyzers and sinks found in the plugins
+// directory.
+type Plugins struct {
+    Analyzers []analysis.Analyzer
+    Sinks     map[string]notify.Notifier
+}
+
+// DiscoverPlugins finds the plugin executables in cfg.Dir. An empty Dir
+// means no plugins.
+func DiscoverPlugins(cfg PluginsConfig) (*Plugins, error) {
+    plugins := &Plugins{Sinks: map[string]notify.Notifier{}}
+    if cfg.Dir == "" {
+        return plugins, nil
+    }
+    entries, err := os.ReadDir(cfg.Dir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read plugins directory: %v", err)
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+    for _, entry := range entries {
+        file := entry.Name()
+        path := filepath.Join(cfg.Dir, file)
+        info, err := os.Stat(path)
+        if err != nil || info.IsDir() || !isExecutable(path, info) {
+            continue
+        }
+        name := strings.TrimSuffix(file, filepath.Ext(file))
+        plugin := ExecPlugin{Path: path, Timeout: cfg.Timeout}
+        switch {
+        case strings.HasPrefix(name, analyzerPluginPrefix):
+            plugin.Name = strings.TrimPrefix(name, analyzerPluginPrefix)
+            plugins.Analyzers = append(plugins.Analyzers, PluginAnalyzer{plugin})
+        case strings.HasPrefix(name, sinkPluginPrefix):
+            plugin.Name = strings.TrimPrefix(name, sinkPluginPrefix)
+            if _, ok := plugins.Sinks[plugin.Name]; ok {
+                return nil, fmt.Errorf("plugin %s: more than one sink is named %q", file, plugin.Name)
+            }
+            plugins.Sinks[plugin.Name] = PluginSink{plugin}
+        default:
+            continue
+        }
+        if plugin.Name == "" {
+            return nil, fmt.Errorf("plugin %s has no name after its prefix", file)
+        }
+        appLog.Info("loaded plugin", "path", path, "name", plugin.Name)
+    }
+    return plugins, nil
+}
+
+// ExecPlugin runs an external executable that reads a JSON request on
+// stdin and writes a JSON response on stdout. A non-zero exit status is a
+// failure, reported with whatever the plugin wrote to stderr.
+type ExecPlugin struct {
+    Name    string
+    Path    string
+    Timeout time.Duration // Zero means no limit
+}
+
+// Run sends request to the plugin and decodes its response into response,
+// if that isn't nil. The plugin is killed if ctx is done first.
+func (p ExecPlugin) Run(ctx context.Context, request, response interface{}) error {
+    input, err := json.Marshal(request)
+    if err != nil {
+        return err
+    }
+    if p.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+        defer cancel()
+    }
+    cmd := exec.CommandContext(ctx, p.Path)
+    cmd.Dir = filepath.Dir(p.Path)
+    cmd.Stdin = bytes.NewReader(input)
+    stdout := &limitedBuffer{limit: maxPluginOutput}
+    stderr := &limitedBuffer{limit: maxPluginOutput}
+    cmd.Stdout, cmd.Stderr = stdout, stderr
+
+    err = cmd.Run()
+    if rec := capture.FromContext(ctx); rec != nil {
+        exchange := pluginCapture{Plugin: p.Name, Request: input, Stdout: stdout.String(), Stderr: stderr.String()}
+        if err != nil {
+            exchange.Error = err.Error()
+        }
+        rec.Record("plugin", exchange)
+    }
+    if err != nil {
+        if ctx.Err() == context.DeadlineExceeded {
+            return fmt.Errorf("plugin %s timed out after %s", p.Name, p.Timeout)
+        }
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            return fmt.Errorf("plugin %s failed: %v: %s", p.Name, err, msg)
+        }
+        return fmt.Errorf("plugin %s failed: %v", p.Name, err)
+    }
+    if response == nil {
+        return nil
+    }
+    if stdout.truncated {
+        return fmt.Errorf("plugin %s wrote more than %d bytes", p.Name, maxPluginOutput)
+    }
+    if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+        return fmt.Errorf("plugin %s returned invalid JSON: %v", p.Name, err)
+    }
+    return nil
+}
+
+// pluginCapture is the record of one plugin run.
+type pluginCapture struct {
+    Plugin  string          `json:"plugin"`
+    Request json.RawMessage `json:"request"`
+    Stdout  string          `json:"stdout"`
+    Stderr  string          `json:"stderr,omitempty"`
+    Error   string          `json:"error,omitempty"`
+}
+
+// PluginAnalyzer is an analyzer plugin. It receives
+//
+//	{"url": "...", "text": "..."}
+//
+// and answers with
+//
+//	{"findings": [{"severity": "suspicious", "description": "..."}]}
+type PluginAnalyzer struct {
+    ExecPlugin
+}
+
+// Name returns the plugin's name.
+func (a PluginAnalyzer) Name() string { return a.ExecPlugin.Name }
+
+// Slow reports true, since a plugin may do anything.
+func (PluginAnalyzer) Slow() bool { return true }
+
+// Analyze runs the plugin against target.
+func (a PluginAnalyzer) Analyze(ctx context.Context, target analysis.Target) ([]analysis.Finding, error) {
+    request := struct {
+        URL  string `json:"url"`
+        Text string `json:"text"`
+    }{target.URL, target.Text}
+    var response struct {
+        Findings []analysis.Finding `json:"findings"`
+    }
+    if err := a.Run(ctx, request, &response); err != nil {
+        return nil, err
+    }
+    for i := range response.Findings {
+        response.Findings[i].Analyzer = a.Name()
+    }
+    return response.Findings, nil
+}
+
+// PluginSink is a sink plugin. It receives the alert as JSON, the same
+// shape the API returns, and succeeds by exiting with status 0.
+type PluginSink struct {
+    ExecPlugin
+}
+
+// Notify hands the alert to the plugin.
+func (s PluginSink) Notify(ctx context.Context, alert notify.Alert) error {
+    return s.Run(ctx, alert, nil)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards
+// the rest, so a runaway plugin can't exhaust memory.
+type limitedBuffer struct {
+    bytes.Buffer
+    limit     int
+    truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+    if room := b.limit - b.Len(); len(p) > room {
+        b.Buffer.Write(p[:max(room, 0)])
+        b.truncated = true
+        return len(p), nil
+    }
+    return b.Buffer.Write(p)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package telephish
+
+import "os"
+
+// isExecutable reports whether the file can be run as a plugin.
+func isExecutable(_ string, info os.FileInfo) bool {
+    return info.Mode()&0o111 != 0
+}
@@ -0,0 +1,16 @@
+package telephish
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// isExecutable reports whether the file can be run as a plugin.
+func isExecutable(path string, _ os.FileInfo) bool {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".exe", ".com", ".bat", ".cmd":
+        return true
+    }
+    return false
+}
@@ -0,0 +1,270 @@
+package telephish
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "maps"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "strings"
+    "sync"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/hacker1337itme/telephish/keystore"
+)
+
+// ProfileConfig is a named policy, such as "family" or "work-soc", run
+// with its own bot alongside the other profiles in one process. Whatever a
+// profile leaves out is taken from the top-level settings: its analyzers,
+// thresholds and sinks blocks are laid over the top-level ones, so it only
+// lists what it changes, while routes, if given, replace them.
+type ProfileConfig struct {
+    Name       string          `yaml:"name"`
+    Telegram   ProfileTelegram `yaml:"telegram"`
+    State      string          `yaml:"state"` // Defaults to the top-level state file with the name added
+    Analyzers  yaml.Node       `yaml:"analyzers"`
+    Thresholds yaml.Node       `yaml:"thresholds"`
+    Sinks      yaml.Node       `yaml:"sinks"`
+    Routes     []Route         `yaml:"routes"`
+}
+
+// ProfileTelegram is the bot a profile watches. The proxy is shared by all
+// profiles.
+type ProfileTelegram struct {
+    Token string  `yaml:"token"`
+    Chats []int64 `yaml:"chats"` // Empty means the top-level chats
+}
+
+// profileName is what profile names look like, since they end up in file
+// names and keystore entries.
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// profileSecret is the keystore name of a profile's bot token.
+func profileSecret(name string) string {
+    return "profiles." + name + ".telegram.token"
+}
+
+// resolveProfiles derives a config for each profile from c. Invalid names
+// are left for Validate to report.
+func (c *Config) resolveProfiles() error {
+    c.profiles = nil
+    for i, profile := range c.Profiles {
+        p := *c
+        p.Profiles, p.profiles, p.Profile = nil, nil, profile.Name
+        p.unshare()
+
+        if profile.Telegram.Token != "" {
+            p.Telegram.Token = profile.Telegram.Token
+        } else if c.Keystore && profileName.MatchString(profile.Name) {
+            switch token, err := keystore.Get(profileSecret(profile.Name)); err {
+            case nil:
+                p.Telegram.Token = token
+            case keystore.ErrNotFound, keystore.ErrUnavailable:
+            default:
+                return fmt.Errorf("%v (set keystore: false or TELEPHISH_KEYSTORE=0 to skip it)", err)
+            }
+        }
+        if len(profile.Telegram.Chats) > 0 {
+            p.Telegram.Chats = profile.Telegram.Chats
+        }
+        if profile.State != "" {
+            p.State = profile.State
+        } else if c.State != "" {
+            ext := filepath.Ext(c.State)
+            p.State = strings.TrimSuffix(c.State, ext) + "-" + profile.Name + ext
+        }
+        if err := overlay(&profile.Analyzers, &p.Analyzers); err != nil {
+            return fmt.Errorf("profiles[%d].analyzers: %v", i, err)
+        }
+        if err := overlay(&profile.Thresholds, &p.Thresholds); err != nil {
+            return fmt.Errorf("profiles[%d].thresholds: %v", i, err)
+        }
+        if err := overlay(&profile.Sinks, &p.Sinks); err != nil {
+            return fmt.Errorf("profiles[%d].sinks: %v", i, err)
+        }
+        if len(profile.Routes) > 0 {
+            p.Routes = profile.Routes
+        }
+        c.profiles = append(c.profiles, &p)
+    }
+    return nil
+}
+
+// unshare gives c copies of the maps in the settings overlay decodes
+// profiles over, which a copy of a config shares with the original. yaml.v3
+// replaces slices whole but adds to maps already there, so a profile's
+// headers would otherwise end up in the top-level config and every other
+// profile.
+func (c *Config) unshare() {
+    c.Analyzers.Headers = maps.Clone(c.Analyzers.Headers)
+    c.Sinks.Tickets.Webhook.Headers = maps.Clone(c.Sinks.Tickets.Webhook.Headers)
+    c.Sinks.Webhooks = slices.Clone(c.Sinks.Webhooks)
+    for i := range c.Sinks.Webhooks {
+        c.Sinks.Webhooks[i].Headers = maps.Clone(c.Sinks.Webhooks[i].Headers)
+    }
+}
+
+// overlay decodes node, if it was given, over the settings already in dst,
+// rejecting unknown fields as LoadConfig does.
+func overlay(node *yaml.Node, dst interface{}) error {
+    if node.Kind == 0 {
+        return nil
+    }
+    data, err := yaml.Marshal(node)
+    if err != nil {
+        return err
+    }
+    dec := yaml.NewDecoder(bytes.NewReader(data))
+    dec.KnownFields(true)
+    return dec.Decode(dst)
+}
+
+// profileProblems lists what is wrong with the profiles, besides the
+// problems their settings share with the top-level config.
+func (c *Config) profileProblems(shared []string) []string {
+    var problems []string
+    known := map[string]bool{}
+    for _, s := range shared {
+        known[s] = true
+    }
+    names, tokens := map[string]bool{}, map[string]string{}
+    for i, profile := range c.Profiles {
+        switch {
+        case !profileName.MatchString(profile.Name):
+            problems = append(problems, fmt.Sprintf("profiles[%d].name: want lowercase letters, digits, - and _, got %q", i, profile.Name))
+        case names[profile.Name]:
+            problems = append(problems, fmt.Sprintf("profiles[%d].name: %q is used by another profile", i, profile.Name))
+        }
+        names[profile.Name] = true
+    }
+    for _, p := range c.profiles {
+        if other, ok := tokens[p.Telegram.Token]; ok && p.Telegram.Token != "" {
+            problems = append(problems, fmt.Sprintf("profiles: %s and %s use the same bot token; each profile needs its own bot", other, p.Profile))
+        }
+        tokens[p.Telegram.Token] = p.Profile
+        for _, problem := range p.problems() {
+            if !known[problem] {
+                problems = append(problems, "profiles "+p.Profile+": "+problem)
+            }
+        }
+    }
+    return problems
+}
+
+// ProfileConfigs returns the config of each profile, or just c if it has
+// none.
+func (c *Config) ProfileConfigs() []*Config {
+    if len(c.profiles) == 0 {
+        return []*Config{c}
+    }
+    return c.profiles
+}
+
+// ForProfile returns the config of the named profile. An empty name is the
+// first profile, or c itself if it has none.
+func (c *Config) ForProfile(name string) (*Config, error) {
+    configs := c.ProfileConfigs()
+    if name == "" {
+        return configs[0], nil
+    }
+    for _, p := range configs {
+        if p.Profile == name {
+            return p, nil
+        }
+    }
+    return nil, fmt.Errorf("no profile named %q", name)
+}
+
+// RequireTokens is RequireToken for every profile.
+func (c *Config) RequireTokens() error {
+    for _, p := range c.ProfileConfigs() {
+        if err := p.RequireToken(); err != nil {
+            if p.Profile != "" {
+                return fmt.Errorf("profile %s: %v", p.Profile, err)
+            }
+            return err
+        }
+    }
+    return nil
+}
+
+// profileNames lists the profiles' names, for noticing when they change.
+func (c *Config) profileNames() []string {
+    var names []string
+    for _, p := range c.Profiles {
+        names = append(names, p.Name)
+    }
+    return names
+}
+
+// NewApps returns an App for each profile in cfg, or for cfg itself if it
+// has none. The apps share the history, chat preferences, lists and alert
+// broker, so the first one's servers, dashboard and health checks cover
+// every profile.
+func NewApps(cfg *Config) ([]*App, error) {
+    configs := cfg.ProfileConfigs()
+    first, err := newApp(configs[0], nil)
+    if err != nil {
+        return nil, err
+    }
+    apps := []*App{first}
+    for _, pc := range configs[1:] {
+        app, err := newApp(pc, first)
+        if err != nil {
+            closeApps(apps)
+            return nil, err
+        }
+        apps = append(apps, app)
+    }
+    first.peers = apps[1:]
+    return apps, nil
+}
+
+// closeApps closes apps, the first last since it owns what they share.
+func closeApps(apps []*App) error {
+    var first error
+    for i := len(apps) - 1; i >= 0; i-- {
+        if err := apps[i].Close(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
+
+// pollApps polls every app at once until ctx is cancelled or one of them
+// fails, which stops the others. Unless once is set, each poller is
+// supervised: one that fails or panics is restarted with backoff rather
+// than failing.
+func pollApps(ctx context.Context, apps []*App, once bool) error {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    var wg sync.WaitGroup
+    errs := make([]error, len(apps))
+    for i, app := range apps {
+        wg.Add(1)
+        go func(i int, app *App) {
+            defer wg.Done()
+            if once {
+                errs[i] = app.Poll(ctx, once)
+            } else {
+                errs[i] = app.sup.run(ctx, "poller", func(ctx context.Context) error { return app.Poll(ctx, false) })
+            }
+            if errs[i] != nil {
+                cancel()
+            }
+        }(i, app)
+    }
+    wg.Wait()
+    for i, err := range errs {
+        if err != nil {
+            if profile := apps[i].Config.Profile; profile != "" {
+                return fmt.Errorf("profile %s: %v", profile, err)
+            }
+            return err
+        }
+    }
+    return nil
+}
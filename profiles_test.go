@@ -0,0 +1,72 @@
+package telephish
+
+import (
+    "reflect"
+    "testing"
+
+    "gopkg.in/yaml.v3"
+)
+
+func TestProfilesDontShareHeaders(t *testing.T) {
+    cfg := DefaultConfig()
+    err := yaml.Unmarshal([]byte(`
+analyzers:
+  headers: {X-Top: top}
+sinks:
+  tickets:
+    webhook:
+      headers: {X-Top: top}
+  webhooks:
+    - name: hook
+      url: https://hooks.example/alert
+      headers: {X-Top: top}
+profiles:
+  - name: a
+    telegram: {token: "1:a"}
+    analyzers:
+      headers: {X-A: a}
+    sinks:
+      tickets:
+        webhook:
+          headers: {X-A: a}
+  - name: b
+    telegram: {token: "2:b"}
+`), &cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := cfg.resolveProfiles(); err != nil {
+        t.Fatal(err)
+    }
+    a, err := cfg.ForProfile("a")
+    if err != nil {
+        t.Fatal(err)
+    }
+    b, err := cfg.ForProfile("b")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    top, both := map[string]string{"X-Top": "top"}, map[string]string{"X-Top": "top", "X-A": "a"}
+    for _, c := range []struct {
+        name string
+        got  map[string]string
+        want map[string]string
+    }{
+        {"top-level analyzers", cfg.Analyzers.Headers, top},
+        {"top-level tickets", cfg.Sinks.Tickets.Webhook.Headers, top},
+        {"a analyzers", a.Analyzers.Headers, both},
+        {"a tickets", a.Sinks.Tickets.Webhook.Headers, both},
+        {"b analyzers", b.Analyzers.Headers, top},
+        {"b tickets", b.Sinks.Tickets.Webhook.Headers, top},
+    } {
+        if !reflect.DeepEqual(c.got, c.want) {
+            t.Errorf("%s headers = %v, want %v", c.name, c.got, c.want)
+        }
+    }
+
+    a.Sinks.Webhooks[0].Headers["X-A"] = "a"
+    if _, ok := cfg.Sinks.Webhooks[0].Headers["X-A"]; ok {
+        t.Errorf("profile a's webhook headers are the top-level config's")
+    }
+}
@@ -0,0 +1,95 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// errEnough stops reading the history once a query has its limit.
+var errEnough = errors.New("enough entries")
+
+// queryCommand searches the history, printing what it finds as a table or
+// JSON.
+func queryCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("query")
+    limit := fs.Int("n", 0, "show at most this many alerts, the oldest first (default all)")
+    asJSON := fs.Bool("json", false, "print a JSON array of history entries instead of a table")
+    filter := addFilterFlags(fs)
+    fs.Parse(args)
+    if fs.NArg() > 0 {
+        return fmt.Errorf("usage: %s query [--domain d] [--sender s] [--chat id] [--verdict v] [--since t] [--until t] [--json]", os.Args[0])
+    }
+    f, err := filter()
+    if err != nil {
+        return err
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+
+    entries := []store.HistoryEntry{}
+    err = history.Each(f, func(entry store.HistoryEntry) error {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if cfg.Redact.Exports {
+            redactEntry(cfg.Redact.Policy, &entry)
+        }
+        entries = append(entries, entry)
+        if *limit > 0 && len(entries) >= *limit {
+            return errEnough
+        }
+        return nil
+    })
+    if err != nil && err != errEnough {
+        return fmt.Errorf("failed to query history: %v", err)
+    }
+
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetEscapeHTML(false)
+        enc.SetIndent("", "  ")
+        return enc.Encode(entries)
+    }
+    if len(entries) == 0 {
+        fmt.Fprintln(os.Stderr, "no alerts match")
+        return nil
+    }
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "ID\tTIME\tVERDICT\tCHAT\tSENDER\tURL\tFINDINGS")
+    for _, e := range entries {
+        var findings []string
+        for _, finding := range e.Alert.Verdict.Findings {
+            findings = append(findings, finding.Analyzer)
+        }
+        sender := e.Alert.Sender
+        if sender == "" {
+            sender = "-"
+        }
+        fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Local().Format(time.DateTime),
+            e.Alert.Verdict.Severity, strconv.FormatInt(e.Alert.ChatID, 10), sender,
+            tableCell(e.Alert.URL), strings.Join(findings, ","))
+    }
+    return tw.Flush()
+}
+
+// tableCell keeps a value from breaking the table's columns and rows.
+func tableCell(s string) string {
+    return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,134 @@
+// Package redact masks personal data, such as the email addresses and
+// phone numbers in messages, before it leaves the monitor in logs and
+// exports.
+package redact
+
+import (
+    "fmt"
+    "log/slog"
+    "regexp"
+    "strings"
+)
+
+// Policy says which personal data to mask. Telegram bot tokens are masked
+// whatever it says, so the zero Policy masks only those.
+type Policy struct {
+    Emails  bool `yaml:"emails"`
+    Phones  bool `yaml:"phones"`
+    Bodies  bool `yaml:"bodies"`  // Message text, replaced whole by its length
+    Senders bool `yaml:"senders"` // @usernames and user IDs of whoever sent a link
+}
+
+// Masks put in place of what a Policy hides.
+const (
+    EmailMask  = "[email]"
+    PhoneMask  = "[phone]"
+    SenderMask = "[sender]"
+    TokenMask  = "[token]" // Replaces the secret half of a bot token
+)
+
+var (
+    emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+    // International numbers, an area code in brackets, or 555-123-4567;
+    // not bare digit runs, which are more often IDs and timestamps
+    phonePattern = regexp.MustCompile(`\+\d[\d ().-]{6,}\d|\(\d{2,4}\) ?\d{3}[ .-]?\d{3,4}\b|\b\d{3}[.-]\d{3}[.-]\d{4}\b`)
+    // The bot's ID, which is kept, and the secret, as in .../bot<token>/...
+    tokenPattern = regexp.MustCompile(`(\d{5,}):[A-Za-z0-9_-]{30,}`)
+)
+
+// Parse parses a list of what to mask, such as "emails,phones", with
+// "all" for everything and "none" for nothing.
+func Parse(spec string) (Policy, error) {
+    var p Policy
+    for _, item := range strings.Split(spec, ",") {
+        switch strings.TrimSpace(item) {
+        case "", "none":
+        case "emails":
+            p.Emails = true
+        case "phones":
+            p.Phones = true
+        case "bodies":
+            p.Bodies = true
+        case "senders":
+            p.Senders = true
+        case "all":
+            p = Policy{Emails: true, Phones: true, Bodies: true, Senders: true}
+        default:
+            return p, fmt.Errorf("want emails, phones, bodies, senders, all or none, got %q", item)
+        }
+    }
+    return p, nil
+}
+
+// Enabled reports whether the policy masks anything.
+func (p Policy) Enabled() bool {
+    return p != Policy{}
+}
+
+// String masks the bot tokens, email addresses and phone numbers in s.
+func (p Policy) String(s string) string {
+    s = Tokens(s)
+    if p.Emails {
+        s = emailPattern.ReplaceAllLiteralString(s, EmailMask)
+    }
+    if p.Phones {
+        s = phonePattern.ReplaceAllLiteralString(s, PhoneMask)
+    }
+    return s
+}
+
+// Tokens masks the secret of every Telegram bot token in s.
+func Tokens(s string) string {
+    return tokenPattern.ReplaceAllString(s, "${1}:"+TokenMask)
+}
+
+// Body masks message text: all of it if bodies are masked, otherwise the
+// email addresses and phone numbers in it.
+func (p Policy) Body(s string) string {
+    if p.Bodies && s != "" {
+        return fmt.Sprintf("[%d characters]", len([]rune(s)))
+    }
+    return p.String(s)
+}
+
+// Sender masks a sender, if senders are masked.
+func (p Policy) Sender(s string) string {
+    if p.Senders && s != "" {
+        return SenderMask
+    }
+    return p.String(s)
+}
+
+// Attr masks a log attribute, for slog.HandlerOptions.ReplaceAttr. Links
+// are left alone but for bot tokens, since they are what the monitor
+// reports on; the message and payload attributes are masked as bodies and
+// the sender attribute as a sender.
+func (p Policy) Attr(groups []string, a slog.Attr) slog.Attr {
+    var s string
+    switch v := a.Value.Any().(type) {
+    case string:
+        s = v
+    case error:
+        s = v.Error()
+    case []string:
+        masked := make([]string, len(v))
+        for i := range v {
+            masked[i] = p.String(v[i])
+        }
+        return slog.Any(a.Key, masked)
+    default:
+        return a
+    }
+    switch a.Key {
+    case "url", "link", "host", "domain":
+        if masked := Tokens(s); masked != s {
+            return slog.String(a.Key, masked)
+        }
+        return a
+    case "message", "text", "payload":
+        return slog.String(a.Key, p.Body(s))
+    case "sender":
+        return slog.String(a.Key, p.Sender(s))
+    }
+    return slog.String(a.Key, p.String(s))
+}
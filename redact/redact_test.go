@@ -0,0 +1,50 @@
+package redact
+
+import (
+    "errors"
+    "log/slog"
+    "strings"
+    "testing"
+)
+
+const token = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+
+func TestTokens(t *testing.T) {
+    tests := []struct{ in, want string }{
+        {`Get "https://api.telegram.org/bot` + token + `/getUpdates?offset=7": EOF`, `Get "https://api.telegram.org/bot123456789:[token]/getUpdates?offset=7": EOF`},
+        {token, "123456789:[token]"},
+        {"update 123456789: no message", "update 123456789: no message"},
+        {"12:34:56", "12:34:56"},
+    }
+    for _, tt := range tests {
+        if got := Tokens(tt.in); got != tt.want {
+            t.Errorf("Tokens(%q) = %q, want %q", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestAttrMasksTokensWithoutPolicy(t *testing.T) {
+    var p Policy
+    for _, a := range []slog.Attr{
+        slog.Any("err", errors.New("dial /bot"+token+"/getUpdates")),
+        slog.String("url", "https://api.telegram.org/bot"+token+"/sendMessage"),
+        slog.String("detail", token),
+    } {
+        got := p.Attr(nil, a).Value.String()
+        if got == a.Value.String() || !strings.Contains(got, TokenMask) {
+            t.Errorf("Attr(%s) = %q, want the token masked", a.Key, got)
+        }
+    }
+    if a := slog.String("url", "https://example.com/login"); p.Attr(nil, a).Value.String() != a.Value.String() {
+        t.Errorf("Attr changed a link without a token")
+    }
+}
+
+func TestPolicyString(t *testing.T) {
+    p := Policy{Emails: true, Phones: true}
+    in := "mail bob@example.com or call +1 (555) 123-4567"
+    want := "mail " + EmailMask + " or call " + PhoneMask
+    if got := p.String(in); got != want {
+        t.Errorf("String(%q) = %q, want %q", in, got, want)
+    }
+}
@@ -0,0 +1,136 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/signal"
+    "reflect"
+    "syscall"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Reload re-reads the config file and swaps in a pipeline built from it,
+// so analyzers, thresholds, rules, routes, sinks, digests, templates, the
+// locale, watched chats, chat preferences and lists change without
+// restarting. Updates being processed finish with the old pipeline first,
+// and alerts waiting for a digest move to the new one. If anything in the
+// new config is invalid the old pipeline is kept.
+//
+// The bot token and proxy, webhook, admin, gRPC, history, state, logging
+// and worker settings are in use by running servers and files, so changes to
+// them wait for a restart, as does adding or removing profiles. With
+// profiles, the first app reloads them all.
+func (a *App) Reload() error {
+    cfg, err := LoadConfig(a.Config.path)
+    if err != nil {
+        return err
+    }
+    if !reflect.DeepEqual(cfg.profileNames(), a.Config.profileNames()) {
+        appLog.Warn("setting changed; restart to apply it", "setting", "profiles")
+    }
+    if err := a.reload(cfg); err != nil {
+        return err
+    }
+    for _, peer := range a.peers {
+        if err := peer.reload(cfg); err != nil {
+            return fmt.Errorf("profile %s: %v", peer.Config.Profile, err)
+        }
+    }
+    return nil
+}
+
+// reload swaps in the pipeline for a's profile in cfg.
+func (a *App) reload(cfg *Config) error {
+    a.reloadMu.Lock()
+    defer a.reloadMu.Unlock()
+
+    cfg, err := cfg.ForProfile(a.Config.Profile)
+    if err != nil {
+        return err
+    }
+    keepRestartSettings(cfg, a.Config)
+    prefs, err := store.LoadChatPreferences(cfg.ChatPrefs)
+    if err != nil {
+        return err
+    }
+    lists, err := analysis.LoadLists(cfg.Lists)
+    if err != nil {
+        return err
+    }
+    // The live prefs and lists are updated in place, since bot commands
+    // and the dashboard hold on to them
+    p, err := buildPipeline(cfg, a.Prefs, a.Lists, a.History, a.capture, a.cache, &a.sup)
+    if err != nil {
+        return err
+    }
+
+    a.mu.Lock()
+    old := a.pipeline
+    a.Config, a.pipeline = cfg, p
+    a.Prefs.Replace(prefs)
+    a.Lists.Replace(lists)
+    a.mu.Unlock()
+
+    if old.digest != nil {
+        for _, alert := range old.digest.Stop() {
+            if err := p.desktop.Notify(context.Background(), alert); err != nil {
+                appLog.Error("failed to deliver alert held for digest", "alert", alert.ID, "err", err)
+            }
+        }
+    }
+    closeSinks(old.connected)
+    appLog.Info("reloaded config", "path", cfg.path)
+    return nil
+}
+
+// keepRestartSettings copies the settings Reload can't apply from the
+// running config into cfg, warning about any that were changed.
+func keepRestartSettings(cfg, running *Config) {
+    keep := func(name string, dst, src interface{}) {
+        d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+        if !reflect.DeepEqual(d.Interface(), s.Interface()) {
+            appLog.Warn("setting changed; restart to apply it", "setting", name)
+        }
+        d.Set(s)
+    }
+    keep("telegram.token", &cfg.Telegram.Token, &running.Telegram.Token)
+    keep("telegram.proxy", &cfg.Telegram.Proxy, &running.Telegram.Proxy)
+    keep("webhook", &cfg.Webhook, &running.Webhook)
+    keep("admin", &cfg.Admin, &running.Admin)
+    keep("grpc", &cfg.GRPC, &running.GRPC)
+    keep("history", &cfg.History, &running.History)
+    keep("state", &cfg.State, &running.State)
+    keep("logging", &cfg.Logging, &running.Logging)
+    keep("workers", &cfg.Workers, &running.Workers)
+    keep("debug", &cfg.Debug, &running.Debug)
+    keep("rescan.interval", &cfg.Rescan.Interval, &running.Rescan.Interval)
+    keep("reports.schedule", &cfg.Reports.Schedule, &running.Reports.Schedule)
+    keep("heartbeat.interval", &cfg.Heartbeat.Interval, &running.Heartbeat.Interval)
+    keep("reputation.cache_size", &cfg.Reputation.CacheSize, &running.Reputation.CacheSize)
+    keep("output", &cfg.Output, &running.Output)
+    cfg.TUI = running.TUI
+    cfg.applyOutput()
+}
+
+// ReloadOnSignal calls Reload whenever the process gets SIGHUP, until ctx
+// is cancelled. A failed reload is logged and the old config kept.
+func (a *App) ReloadOnSignal(ctx context.Context) {
+    hup := make(chan os.Signal, 1)
+    signal.Notify(hup, syscall.SIGHUP)
+    go func() {
+        defer signal.Stop(hup)
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-hup:
+                if err := a.Reload(); err != nil {
+                    appLog.Error("failed to reload config; keeping the old one", "err", err)
+                }
+            }
+        }
+    }()
+}
@@ -0,0 +1,372 @@
+package telephish
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/internal/pdf"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// reportBarWidth is how wide the longest bar of a report's chart is, in
+// pixels or points.
+const reportBarWidth = 300
+
+// reportSeverities orders the verdicts in reports, worst first.
+var reportSeverities = []analysis.Severity{analysis.SeverityMalicious, analysis.SeveritySuspicious, analysis.SeverityInfo, analysis.SeverityClean}
+
+// report is a summary of the history over a week or a month.
+type report struct {
+    Title      string // e.g. "Weekly report"
+    Period     string // e.g. "2026-10-05 to 2026-10-11"
+    Summary    *store.Summary
+    Days       []reportDay
+    Severities []analysis.Severity
+    Generated  time.Time
+}
+
+// reportDay is a bar of the chart of alerts per day.
+type reportDay struct {
+    Label string
+    Total int
+    Bars  []reportBar
+}
+
+type reportBar struct {
+    Severity analysis.Severity
+    Count    int
+    Width    int
+}
+
+// reportPeriod returns the last whole week, starting on Monday, or month
+// before now. Periods follow UTC days, as the dashboard's chart does.
+func reportPeriod(schedule string, now time.Time) (since, until time.Time) {
+    now = now.UTC()
+    if schedule == "monthly" {
+        until = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+        return until.AddDate(0, -1, 0), until
+    }
+    today := now.Truncate(24 * time.Hour)
+    until = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+    return until.AddDate(0, 0, -7), until
+}
+
+// nextReport returns when the period after the one ending at until is
+// over.
+func nextReport(schedule string, until time.Time) time.Time {
+    if schedule == "monthly" {
+        return until.AddDate(0, 1, 0)
+    }
+    return until.AddDate(0, 0, 7)
+}
+
+// newReport summarizes the alerts in history for the last period of
+// schedule before now.
+func newReport(history *store.History, schedule string, top int, now time.Time) (*report, error) {
+    since, until := reportPeriod(schedule, now)
+    summary, err := history.Summarize(since, until, top)
+    if err != nil {
+        return nil, err
+    }
+    title := "Weekly report"
+    if schedule == "monthly" {
+        title = "Monthly report"
+    }
+    r := &report{
+        Title:      title,
+        Period:     fmt.Sprintf("%s to %s", since.Format(time.DateOnly), until.AddDate(0, 0, -1).Format(time.DateOnly)),
+        Summary:    summary,
+        Severities: reportSeverities,
+        Generated:  now,
+    }
+
+    counts := map[int64]map[analysis.Severity]int{}
+    max := 1
+    for _, p := range summary.Days {
+        day := p.Day.Unix()
+        if counts[day] == nil {
+            counts[day] = map[analysis.Severity]int{}
+        }
+        counts[day][p.Severity] += p.Count
+    }
+    for day := since; day.Before(until); day = day.AddDate(0, 0, 1) {
+        d := reportDay{Label: day.Format("Mon Jan 2")}
+        for _, s := range reportSeverities {
+            if n := counts[day.Unix()][s]; n > 0 {
+                d.Total += n
+                d.Bars = append(d.Bars, reportBar{Severity: s, Count: n})
+            }
+        }
+        if d.Total > max {
+            max = d.Total
+        }
+        r.Days = append(r.Days, d)
+    }
+    for i := range r.Days {
+        for j := range r.Days[i].Bars {
+            bar := &r.Days[i].Bars[j]
+            bar.Width = bar.Count * reportBarWidth / max
+            if bar.Width == 0 {
+                bar.Width = 1
+            }
+        }
+    }
+    return r, nil
+}
+
+// html renders the report as a page that also reads as an email.
+func (r *report) html() ([]byte, error) {
+    var buf bytes.Buffer
+    if err := dashboardTemplates.ExecuteTemplate(&buf, "report", r); err != nil {
+        return nil, fmt.Errorf("failed to render report: %v", err)
+    }
+    return buf.Bytes(), nil
+}
+
+// pdf renders the report as a PDF document.
+func (r *report) pdf() []byte {
+    const left, top, bottom = 50, pdf.PageHeight - 60, 50
+    var doc pdf.Document
+    y := float64(top)
+    // next moves down a line of height, starting a new page if it wouldn't fit
+    next := func(height float64) {
+        y -= height
+        if y < bottom {
+            doc.NewPage()
+            y = top - height
+        }
+    }
+    heading := func(s string) {
+        next(28)
+        doc.Text(left, y, 13, true, s)
+    }
+
+    doc.Text(left, y, 18, true, "Telephish "+r.Title)
+    next(18)
+    doc.Text(left, y, 10, false, r.Period+" (UTC)")
+
+    heading("Verdicts")
+    next(16)
+    doc.Text(left, y, 10, true, fmt.Sprintf("%d alerts", r.Summary.Total))
+    for _, s := range r.Severities {
+        next(14)
+        doc.Rect(left, y-1, 8, 8, notify.SeverityRGB[s])
+        doc.Text(left+14, y, 10, false, fmt.Sprintf("%s: %d", s, r.Summary.Verdicts[s.String()]))
+    }
+
+    heading("Alerts per day")
+    for _, d := range r.Days {
+        next(14)
+        doc.Text(left, y, 9, false, d.Label)
+        x := float64(left + 80)
+        for _, bar := range d.Bars {
+            doc.Rect(x, y-2, float64(bar.Width), 10, notify.SeverityRGB[bar.Severity])
+            x += float64(bar.Width)
+        }
+        doc.Text(x+6, y, 9, false, strconv.Itoa(d.Total))
+    }
+
+    tallies := func(title, none string, list []store.Tally, name func(string) string) {
+        heading(title)
+        if len(list) == 0 {
+            next(14)
+            doc.Text(left, y, 10, false, none)
+        }
+        for _, t := range list {
+            next(14)
+            doc.Text(left, y, 10, false, strconv.Itoa(t.Count))
+            doc.Text(left+40, y, 10, false, name(t.Name))
+        }
+    }
+    // The font only has Latin-1, so domains are named by their xn-- form
+    tallies("Top domains", "No suspicious or malicious links.", r.Summary.Domains, func(name string) string {
+        uni, ascii := extract.HostForms(name)
+        if uni != ascii {
+            return notify.Defang(ascii) + " (IDN)"
+        }
+        return notify.Defang(ascii)
+    })
+    tallies("Most targeted chats", "No chats received suspicious or malicious links.", r.Summary.Chats, func(name string) string { return "chat " + name })
+
+    next(28)
+    doc.Text(left, y, 8, false, "Generated "+r.Generated.Local().Format("2006-01-02 15:04:05 MST")+" by Telephish "+version)
+    return doc.Bytes()
+}
+
+// file returns the report as a file of format, with its name and content
+// type.
+func (r *report) file(format string) (name, contentType string, data []byte, err error) {
+    name = fmt.Sprintf("telephish-report-%s", r.Summary.Since.Format(time.DateOnly))
+    if format == "pdf" {
+        return name + ".pdf", "application/pdf", r.pdf(), nil
+    }
+    data, err = r.html()
+    return name + ".html", "text/html; charset=utf-8", data, err
+}
+
+// sendReport delivers r to each of cfg.Reports.Sinks: to email as the
+// body, with a PDF attached if that is the format, and to Telegram chats
+// as a file.
+func sendReport(ctx context.Context, cfg *Config, r *report) error {
+    name, contentType, data, err := r.file(cfg.Reports.Format)
+    if err != nil {
+        return err
+    }
+    subject := r.Title + ", " + r.Period
+    var errs []error
+    for _, sink := range cfg.Reports.Sinks {
+        switch sink {
+        case "email":
+            e := cfg.Sinks.Email
+            if e.Addr == "" {
+                errs = append(errs, fmt.Errorf("reports.sinks: email needs sinks.email"))
+                continue
+            }
+            body, err := r.html()
+            if err != nil {
+                return err
+            }
+            var attachment []byte
+            if cfg.Reports.Format == "pdf" {
+                attachment = data
+            }
+            email := &notify.EmailNotifier{Addr: e.Addr, Username: e.Username, Password: e.Password, From: e.From, To: e.To}
+            if err := email.SendReport(ctx, subject, body, name, contentType, attachment); err != nil {
+                errs = append(errs, err)
+            }
+        case "telegram":
+            for _, chat := range cfg.Reports.Chats {
+                if err := telegram.SendDocument(ctx, cfg.Telegram.Token, chat, name, data, "Telephish "+subject); err != nil {
+                    errs = append(errs, fmt.Errorf("chat %d: %v", chat, err))
+                }
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// StartReports sends a report each time a week or month, as
+// reports.schedule says, is over, until ctx is cancelled. It does nothing
+// without a schedule. A report the monitor wasn't running for can be sent
+// with `telephish report --send`.
+func (a *App) StartReports(ctx context.Context) {
+    schedule := a.Config.Reports.Schedule
+    if schedule == "" {
+        return
+    }
+    go func() {
+        for {
+            _, until := reportPeriod(schedule, time.Now())
+            timer := time.NewTimer(time.Until(nextReport(schedule, until)))
+            select {
+            case <-ctx.Done():
+                timer.Stop()
+                return
+            case <-timer.C:
+            }
+            var err error
+            if perr := a.sup.protect("reports", func() { err = a.sendReport(ctx, schedule) }); perr != nil {
+                err = perr
+            }
+            if err != nil {
+                appLog.Error("failed to send report", "schedule", schedule, "err", err)
+            }
+        }
+    }()
+}
+
+func (a *App) sendReport(ctx context.Context, schedule string) error {
+    a.mu.RLock()
+    cfg := a.Config
+    a.mu.RUnlock()
+    r, err := newReport(a.History, schedule, cfg.Reports.Top, time.Now())
+    if err != nil {
+        return err
+    }
+    if err := sendReport(ctx, cfg, r); err != nil {
+        return err
+    }
+    appLog.Info("sent report", "period", r.Period, "alerts", r.Summary.Total, "sinks", cfg.Reports.Sinks)
+    return nil
+}
+
+// reportCommand writes or sends the report for the last whole week or
+// month.
+func reportCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("report")
+    period := fs.String("period", "", "weekly or monthly (default reports.schedule, or weekly)")
+    format := fs.String("format", "", "html or pdf (default reports.format)")
+    out := fs.String("o", "", "write the report to this file instead of stdout")
+    send := fs.Bool("send", false, "send the report to reports.sinks instead of writing it")
+    asJSON := fs.Bool("json", false, "print the totals as JSON instead of a report")
+    fs.Parse(args)
+    if fs.NArg() > 0 {
+        return fmt.Errorf("usage: %s report [--period weekly|monthly] [--format html|pdf] [-o file] [--send] [--json]", os.Args[0])
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if *period == "" {
+        *period = cfg.Reports.Schedule
+    }
+    if *period == "" {
+        *period = "weekly"
+    }
+    if *period != "weekly" && *period != "monthly" {
+        return fmt.Errorf("--period: want weekly or monthly, got %q", *period)
+    }
+    if *format != "" {
+        if *format != "html" && *format != "pdf" {
+            return fmt.Errorf("--format: want html or pdf, got %q", *format)
+        }
+        cfg.Reports.Format = *format
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    if !history.Enabled() {
+        return fmt.Errorf("history: reports summarize the history, which is turned off")
+    }
+    r, err := newReport(history, *period, cfg.Reports.Top, time.Now())
+    if err != nil {
+        return err
+    }
+
+    switch {
+    case *asJSON:
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetEscapeHTML(false)
+        enc.SetIndent("", "  ")
+        return enc.Encode(r.Summary)
+    case *send:
+        if err := sendReport(ctx, cfg, r); err != nil {
+            return err
+        }
+        fmt.Fprintf(os.Stderr, "sent the report for %s to %s\n", r.Period, strings.Join(cfg.Reports.Sinks, " and "))
+        return nil
+    }
+    _, _, data, err := r.file(cfg.Reports.Format)
+    if err != nil {
+        return err
+    }
+    if *out == "" {
+        _, err = os.Stdout.Write(data)
+        return err
+    }
+    return os.WriteFile(*out, data, 0o644)
+}
@@ -0,0 +1,137 @@
+package telephish
+
+import (
+    "context"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Rescan scans again the links received in the last rescan.days whose
+// verdict was below suspicious, since phishing pages are often armed hours
+// after the link is shared. A link that now looks suspicious or worse
+// raises a retroactive alert referring to the original message, once per
+// message. It returns how many alerts were raised.
+func (a *App) Rescan(ctx context.Context) (int, error) {
+    a.mu.RLock()
+    cfg := a.Config.Rescan
+    a.mu.RUnlock()
+    since := time.Now().AddDate(0, 0, -cfg.Days)
+    entries, err := a.History.Rescannable(a.Config.Profile, since, analysis.SeveritySuspicious, cfg.Limit)
+    if err != nil {
+        return 0, err
+    }
+
+    // Messages often share a link, which only needs scanning once
+    verdicts := map[string]analysis.Verdict{}
+    alerted := 0
+    for _, original := range entries {
+        if ctx.Err() != nil {
+            break
+        }
+        link := original.Alert.URL
+        logger := appLog.With("url", link, "rescan_of", original.ID)
+        if a.Config.Profile != "" {
+            logger = logger.With("profile", a.Config.Profile)
+        }
+        verdict, ok := verdicts[link]
+        if !ok {
+            a.mu.RLock()
+            release := a.domains.acquire(link)
+            verdict = a.Scanner.Scan(ctx, analysis.Target{URL: link, Text: original.Text, Language: a.textLanguage(original.Alert)}, nil)
+            release()
+            a.mu.RUnlock()
+            verdicts[link] = verdict
+        }
+        if verdict.Severity < analysis.SeveritySuspicious {
+            logger.Debug("link still looks clean")
+            continue
+        }
+
+        a.mu.RLock()
+        entry := a.retroactive(original, verdict)
+        logger.Info("verdict changed on rescan", "was", original.Alert.Verdict.Severity)
+        a.finish(ctx, logger, entry, true)
+        a.mu.RUnlock()
+        alerted++
+    }
+    return alerted, nil
+}
+
+// retroactive returns the entry for an alert on original's link, rescanned
+// with verdict.
+func (a *App) retroactive(original store.HistoryEntry, verdict analysis.Verdict) store.HistoryEntry {
+    alert := original.Alert
+    alert.ID += "-rescan"
+    alert.Title = a.Loc.T("rescan.title")
+    alert.Message = a.Loc.T("rescan.message", original.Time.Local().Format("2006-01-02 15:04"),
+        a.Loc.T("severity."+verdict.Severity.String()), original.Text)
+    alert.Verdict = verdict
+    alert.Screenshot = ""
+    return store.HistoryEntry{
+        UpdateID:  original.UpdateID,
+        MessageID: original.MessageID,
+        Text:      original.Text,
+        Alert:     alert,
+        RescanOf:  original.ID,
+    }
+}
+
+// StartRescans calls Rescan every rescan.interval until ctx is cancelled.
+// It does nothing if the interval is zero.
+func (a *App) StartRescans(ctx context.Context) {
+    interval := a.Config.Rescan.Interval
+    if interval <= 0 {
+        return
+    }
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                var alerted int
+                var err error
+                if perr := a.sup.protect("rescan", func() { alerted, err = a.Rescan(ctx) }); perr != nil {
+                    err = perr
+                }
+                if err != nil {
+                    appLog.Error("failed to rescan links", "err", err)
+                    continue
+                }
+                appLog.Debug("rescanned links", "alerts", alerted)
+            }
+        }
+    }()
+}
+
+// rescanCommand runs one round of rescans for every profile.
+func rescanCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("rescan")
+    fs.Parse(args)
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    apps, err := NewApps(cfg)
+    if err != nil {
+        return err
+    }
+    defer closeApps(apps)
+    for _, app := range apps {
+        alerted, err := app.Rescan(ctx)
+        if err != nil {
+            return err
+        }
+        logger := appLog
+        if app.Config.Profile != "" {
+            logger = logger.With("profile", app.Config.Profile)
+        }
+        logger.Info("rescanned links", "alerts", alerted)
+    }
+    return nil
+}
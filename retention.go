@@ -0,0 +1,146 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/capture"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// PruneResult counts what Prune deleted.
+type PruneResult struct {
+    Alerts      int
+    Screenshots int
+    Captures    int
+}
+
+// Prune deletes the alerts, screenshots and debug captures older than the
+// retention settings allow as of now. The history and capture directory
+// are shared by every profile, so one app's Prune covers them all.
+func (a *App) Prune(now time.Time) (PruneResult, error) {
+    a.mu.RLock()
+    cfg := a.Config.Retention
+    captureDir := a.Config.Debug.CaptureDir
+    a.mu.RUnlock()
+    if a.capture != nil {
+        captureDir = a.capture.Dir()
+    }
+    return prune(a.History, cfg, captureDir, now)
+}
+
+// prune deletes what has outlived cfg from history and captureDir.
+func prune(history *store.History, cfg RetentionConfig, captureDir string, now time.Time) (PruneResult, error) {
+    daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+    var res PruneResult
+    var screenshots []string
+    if cfg.AlertDays > 0 {
+        n, shots, err := history.Prune(daysAgo(cfg.AlertDays))
+        if err != nil {
+            return res, err
+        }
+        res.Alerts = n
+        screenshots = append(screenshots, shots...)
+    }
+    if cfg.ScreenshotDays > 0 {
+        shots, err := history.DropScreenshots(daysAgo(cfg.ScreenshotDays))
+        if err != nil {
+            return res, err
+        }
+        screenshots = append(screenshots, shots...)
+    }
+    for _, path := range screenshots {
+        if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+            appLog.Warn("failed to delete screenshot", "path", path, "err", err)
+            continue
+        }
+        res.Screenshots++
+    }
+    if cfg.CaptureDays > 0 && captureDir != "" {
+        n, err := capture.Prune(captureDir, daysAgo(cfg.CaptureDays))
+        res.Captures = n
+        if err != nil {
+            return res, err
+        }
+    }
+    return res, nil
+}
+
+// StartPruning calls Prune at once and then every retention.interval
+// until ctx is cancelled. It does nothing if nothing is ever pruned.
+func (a *App) StartPruning(ctx context.Context) {
+    if !a.Config.Retention.Enabled() {
+        return
+    }
+    run := func() {
+        var res PruneResult
+        var err error
+        if perr := a.sup.protect("prune", func() { res, err = a.Prune(time.Now()) }); perr != nil {
+            err = perr
+        }
+        if err != nil {
+            appLog.Error("failed to prune old data", "err", err)
+            return
+        }
+        appLog.Debug("pruned old data", "alerts", res.Alerts, "screenshots", res.Screenshots, "captures", res.Captures)
+    }
+    go func() {
+        run()
+        ticker := time.NewTicker(a.Config.Retention.Interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                run()
+            }
+        }
+    }()
+}
+
+// pruneCommand deletes what has outlived the retention settings, without
+// waiting for a running monitor to get round to it.
+func pruneCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("prune")
+    now := fs.Bool("now", false, "prune now; required, since what is pruned can't be recovered")
+    fs.Parse(args)
+    if fs.NArg() > 0 {
+        return fmt.Errorf("usage: %s prune --now", os.Args[0])
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    r := cfg.Retention
+    if !r.Enabled() {
+        return fmt.Errorf("retention: set alert_days, screenshot_days or capture_days to prune anything")
+    }
+    if !*now {
+        var doomed []string
+        for _, setting := range []struct {
+            what string
+            days int
+        }{{"alerts", r.AlertDays}, {"screenshots", r.ScreenshotDays}, {"captures", r.CaptureDays}} {
+            if setting.days > 0 {
+                doomed = append(doomed, fmt.Sprintf("%s older than %d days", setting.what, setting.days))
+            }
+        }
+        return fmt.Errorf("this deletes %s for good; pass --now to go ahead", strings.Join(doomed, ", "))
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    res, err := prune(history, r, cfg.Debug.CaptureDir, time.Now())
+    if err != nil {
+        return err
+    }
+    fmt.Printf("pruned %d alerts, %d screenshots and %d captures\n", res.Alerts, res.Screenshots, res.Captures)
+    return nil
+}
@@ -0,0 +1,60 @@
+package telephish
+
+import (
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Roles of Telegram users, deciding the bot commands they may use. Each
+// may use the commands of the ones below it.
+const (
+    RoleOwner  = "owner"  // Grants roles with /role
+    RoleAdmin  = "admin"  // Changes chat settings and blocks domains
+    RoleViewer = "viewer" // Scans links and asks for the status
+    RoleNone   = "none"   // Uses no commands
+)
+
+var roleRanks = map[string]int{RoleNone: 0, RoleViewer: 1, RoleAdmin: 2, RoleOwner: 3}
+
+// commandRoles is the role each bot command needs.
+var commandRoles = map[string]string{
+    "/scan":     RoleViewer,
+    "/status":   RoleViewer,
+    "/prefs":    RoleViewer,
+    "/mute":     RoleAdmin,
+    "/unmute":   RoleAdmin,
+    "/alerts":   RoleAdmin,
+    "/warnings": RoleAdmin,
+    "/language": RoleAdmin,
+    "/block":    RoleAdmin,
+    "/unblock":  RoleAdmin,
+    "/role":     RoleOwner,
+}
+
+func validRole(role string) bool {
+    _, ok := roleRanks[role]
+    return ok
+}
+
+// roleAllows reports whether role may do what needs the role want.
+func roleAllows(role, want string) bool {
+    return roleRanks[role] >= roleRanks[want]
+}
+
+// RoleOf returns the role of a Telegram user: the one telegram.roles gives
+// them, or else one granted with /role, or else the default role.
+func (c *Config) RoleOf(userID int64, prefs *store.ChatPreferences) string {
+    if role, ok := c.Telegram.Roles[userID]; ok {
+        return role
+    }
+    if role, ok := prefs.Role(userID); ok && validRole(role) && role != RoleOwner {
+        return role
+    }
+    if c.Telegram.DefaultRole != "" {
+        return c.Telegram.DefaultRole
+    }
+    if len(c.Telegram.Roles) == 0 {
+        // Before roles, anyone in a watched chat could use every command
+        return RoleAdmin
+    }
+    return RoleNone
+}
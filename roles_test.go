@@ -0,0 +1,171 @@
+package telephish
+
+import (
+    "context"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telephishtest"
+)
+
+func TestRoleAllows(t *testing.T) {
+    tests := []struct {
+        role, want string
+        ok         bool
+    }{
+        {RoleOwner, RoleOwner, true},
+        {RoleOwner, RoleAdmin, true},
+        {RoleOwner, RoleViewer, true},
+        {RoleAdmin, RoleOwner, false},
+        {RoleAdmin, RoleAdmin, true},
+        {RoleAdmin, RoleViewer, true},
+        {RoleViewer, RoleAdmin, false},
+        {RoleViewer, RoleViewer, true},
+        {RoleNone, RoleViewer, false},
+        {"", RoleViewer, false},
+        {"superuser", RoleViewer, false},
+    }
+    for _, tt := range tests {
+        if got := roleAllows(tt.role, tt.want); got != tt.ok {
+            t.Errorf("roleAllows(%q, %q) = %v, want %v", tt.role, tt.want, got, tt.ok)
+        }
+    }
+}
+
+func TestCommandRoles(t *testing.T) {
+    want := map[string]string{
+        "/scan": RoleViewer, "/status": RoleViewer, "/prefs": RoleViewer,
+        "/mute": RoleAdmin, "/unmute": RoleAdmin, "/alerts": RoleAdmin, "/warnings": RoleAdmin, "/language": RoleAdmin, "/block": RoleAdmin, "/unblock": RoleAdmin,
+        "/role": RoleOwner,
+    }
+    for command, role := range want {
+        if got := commandRoles[command]; got != role {
+            t.Errorf("%s needs %q, want %q", command, got, role)
+        }
+    }
+    for command, role := range commandRoles {
+        if _, ok := want[command]; !ok {
+            t.Errorf("%s needs %q, which this test doesn't know of", command, role)
+        }
+        if !validRole(role) || role == RoleNone {
+            t.Errorf("%s needs %q", command, role)
+        }
+    }
+}
+
+func TestRoleOf(t *testing.T) {
+    prefs, err := store.LoadChatPreferences(filepath.Join(t.TempDir(), "chats.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for id, role := range map[int64]string{1: RoleViewer, 2: RoleAdmin, 3: RoleOwner, 4: "superuser"} {
+        if err := prefs.SetRole(id, role); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var cfg Config
+    if got := cfg.RoleOf(9, prefs); got != RoleAdmin {
+        t.Errorf("without any roles, a stranger is %q, want admin", got)
+    }
+    cfg.Telegram.Roles = map[int64]string{1: RoleOwner, 5: RoleViewer}
+    tests := []struct {
+        userID int64
+        want   string
+    }{
+        {1, RoleOwner},  // The config beats a grant
+        {5, RoleViewer}, // From the config
+        {2, RoleAdmin},  // Granted
+        {3, RoleNone},   // Owner can only come from the config
+        {4, RoleNone},   // Not a role
+        {9, RoleNone},   // A stranger, once roles are listed
+    }
+    for _, tt := range tests {
+        if got := cfg.RoleOf(tt.userID, prefs); got != tt.want {
+            t.Errorf("RoleOf(%d) = %q, want %q", tt.userID, got, tt.want)
+        }
+    }
+    cfg.Telegram.DefaultRole = RoleViewer
+    if got := cfg.RoleOf(9, prefs); got != RoleViewer {
+        t.Errorf("with default_role viewer, a stranger is %q", got)
+    }
+}
+
+func TestRoleCommand(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+    app.Config.Telegram.Roles = map[int64]string{telephishtest.Sender.ID: RoleOwner, 5: RoleViewer}
+    loc, err := i18n.NewLocalizer("en")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        args  string
+        reply string
+        user  int64
+        role  string // The user's role afterwards
+    }{
+        {"/role 7 admin", loc.T("role.set", int64(7), RoleAdmin), 7, RoleAdmin},
+        {"/role 7 VIEWER", loc.T("role.set", int64(7), RoleViewer), 7, RoleViewer},
+        {"/role 8 owner", loc.T("role.usage"), 8, RoleNone},
+        {"/role 7 owner", loc.T("role.usage"), 7, RoleViewer},
+        {"/role 5 admin", loc.T("role.fixed", int64(5)), 5, RoleViewer},
+        {"/role 5 none", loc.T("role.fixed", int64(5)), 5, RoleViewer},
+        {"/role 1001 none", loc.T("role.fixed", telephishtest.Sender.ID), telephishtest.Sender.ID, RoleOwner},
+        {"/role 7 superuser", loc.T("role.usage"), 7, RoleViewer},
+        {"/role seven admin", loc.T("role.usage"), 7, RoleViewer},
+        {"/role 7", loc.T("role.usage"), 7, RoleViewer},
+        {"/role 7 none", loc.T("role.set", int64(7), RoleNone), 7, RoleNone},
+    }
+    for _, tt := range tests {
+        if got := app.roleCommand("test", strings.Fields(tt.args)); got != tt.reply {
+            t.Errorf("%s replied %q, want %q", tt.args, got, tt.reply)
+        }
+        if got := app.Config.RoleOf(tt.user, app.Prefs); got != tt.role {
+            t.Errorf("after %s, user %d is %q, want %q", tt.args, tt.user, got, tt.role)
+        }
+    }
+}
+
+func TestCommandDeniedByRole(t *testing.T) {
+    api := telephishtest.NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    app := newTestApp(t, api)
+    // The fixtures' sender is a viewer
+    app.Config.Telegram.Roles = map[int64]string{telephishtest.Sender.ID: RoleViewer, 5: RoleOwner}
+    loc, err := i18n.NewLocalizer("en")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    api.Push(telephishtest.Command("/mute"), telephishtest.Command("/role 1001 admin"), telephishtest.Command("/prefs"))
+    if err := app.Poll(context.Background(), true); err != nil {
+        t.Fatal(err)
+    }
+    sent, err := api.WaitSent(3, 5*time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i, want := range []string{loc.T("commands.denied", "/mute"), loc.T("commands.denied", "/role")} {
+        if sent[i].Text != want {
+            t.Errorf("reply %d = %q, want %q", i, sent[i].Text, want)
+        }
+    }
+    if strings.HasPrefix(sent[2].Text, loc.T("commands.denied", "/prefs")) {
+        t.Errorf("a viewer was refused /prefs")
+    }
+    if pref := app.Prefs.For(telephishtest.PrivateChatID, "private"); pref.Muted {
+        t.Errorf("a viewer muted the chat")
+    }
+    if got := app.Config.RoleOf(telephishtest.Sender.ID, app.Prefs); got != RoleViewer {
+        t.Errorf("a viewer made themselves %q", got)
+    }
+}
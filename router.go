@@ -0,0 +1,187 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/expr-lang/expr/vm"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// Route sends alerts at or above a severity, optionally only from some
+// chats or matching a rule condition, to a set of named sinks.
+type Route struct {
+    Severity analysis.Severity `yaml:"severity"`
+    Chats    []int64           `yaml:"chats"` // Empty matches every chat
+    When     string            `yaml:"when"`  // Rule condition; empty always holds
+    Sinks    []string          `yaml:"sinks"`
+
+    condition *vm.Program
+}
+
+// Matches reports whether the route applies to alert.
+func (r Route) Matches(alert notify.Alert) bool {
+    if alert.Verdict.Severity < r.Severity {
+        return false
+    }
+    if r.condition != nil && !evalCondition(notifyLog, r.condition, alert) {
+        return false
+    }
+    if len(r.Chats) == 0 {
+        return true
+    }
+    for _, chat := range r.Chats {
+        if chat == alert.ChatID {
+            return true
+        }
+    }
+    return false
+}
+
+// Router delivers each alert to the sinks of the first route it matches.
+// Alerts matching no route are dropped.
+type Router struct {
+    Routes []Route
+    Sinks  map[string]notify.Notifier
+}
+
+// NewRouter checks that every route names a known sink and compiles their
+// conditions.
+func NewRouter(routes []Route, sinks map[string]notify.Notifier) (*Router, error) {
+    routes = append([]Route(nil), routes...)
+    for i := range routes {
+        route := &routes[i]
+        if route.When != "" {
+            condition, err := compileCondition(route.When)
+            if err != nil {
+                return nil, fmt.Errorf("route for %s: %v", route.Severity, err)
+            }
+            route.condition = condition
+        }
+        for _, name := range route.Sinks {
+            if _, ok := sinks[name]; !ok {
+                return nil, fmt.Errorf("route for %s uses unknown or unconfigured sink %q (configured: %s)",
+                    route.Severity, name, strings.Join(sinkNames(sinks), ", "))
+            }
+        }
+    }
+    return &Router{Routes: routes, Sinks: sinks}, nil
+}
+
+// DefaultRoutes sends every alert to every configured sink except the
+// Telegram reply and plain log, which the desktop chain already falls
+// back to.
+func DefaultRoutes(sinks map[string]notify.Notifier) []Route {
+    var names []string
+    for _, name := range sinkNames(sinks) {
+        if name != "log" && name != "telegram" {
+            names = append(names, name)
+        }
+    }
+    return []Route{{Severity: analysis.SeverityClean, Sinks: names}}
+}
+
+// Notify delivers the alert along its route.
+func (r *Router) Notify(ctx context.Context, alert notify.Alert) error {
+    return notify.ActionsError(r.Deliver(ctx, alert))
+}
+
+// Deliver sends the alert to each sink of its route and reports how each
+// went. An alert matching no route gets no actions.
+func (r *Router) Deliver(ctx context.Context, alert notify.Alert) []notify.Action {
+    for _, route := range r.Routes {
+        if !route.Matches(alert) {
+            continue
+        }
+        actions := make([]notify.Action, 0, len(route.Sinks))
+        for _, name := range route.Sinks {
+            actions = append(actions, notify.NewAction(name, r.Sinks[name].Notify(ctx, alert)))
+        }
+        return actions
+    }
+    return nil
+}
+
+// ParseRoutes parses a route list such as
+//
+//    malicious:desktop,slack,email; suspicious:desktop; info:log
+//
+// Routes are tried in order and the first match wins, so list the most
+// severe first. A severity may be limited to chats with @, e.g.
+// "suspicious@-1001234|-1005678:slack".
+func ParseRoutes(spec string) ([]Route, error) {
+    var routes []Route
+    for i, part := range strings.Split(spec, ";") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        match, sinks, ok := strings.Cut(part, ":")
+        if !ok {
+            return nil, fmt.Errorf("route %d %q: expected severity:sink,sink", i+1, part)
+        }
+
+        var route Route
+        severity, chats, _ := strings.Cut(strings.TrimSpace(match), "@")
+        s, err := analysis.ParseSeverity(severity)
+        if err != nil {
+            return nil, fmt.Errorf("route %d %q: %v", i+1, part, err)
+        }
+        route.Severity = s
+        if chats != "" {
+            for _, chat := range strings.Split(chats, "|") {
+                id, err := strconv.ParseInt(strings.TrimSpace(chat), 10, 64)
+                if err != nil {
+                    return nil, fmt.Errorf("route %d %q: invalid chat id %q", i+1, part, chat)
+                }
+                route.Chats = append(route.Chats, id)
+            }
+        }
+        for _, sink := range strings.Split(sinks, ",") {
+            if sink = strings.TrimSpace(sink); sink != "" {
+                route.Sinks = append(route.Sinks, sink)
+            }
+        }
+        if len(route.Sinks) == 0 {
+            return nil, fmt.Errorf("route %d %q: no sinks listed", i+1, part)
+        }
+        routes = append(routes, route)
+    }
+    return routes, nil
+}
+
+func sinkNames(sinks map[string]notify.Notifier) []string {
+    var names []string
+    for name := range sinks {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// ChatFilter drops alerts the originating chat's preferences exclude.
+type ChatFilter struct {
+    Prefs *store.ChatPreferences
+    Next  notify.Notifier
+}
+
+// Notify forwards the alert if its chat wants it.
+func (f ChatFilter) Notify(ctx context.Context, alert notify.Alert) error {
+    return notify.ActionsError(f.Deliver(ctx, alert))
+}
+
+// Deliver forwards the alert if its chat wants it, and otherwise records
+// that chat preferences suppressed it.
+func (f ChatFilter) Deliver(ctx context.Context, alert notify.Alert) []notify.Action {
+    if !f.Prefs.For(alert.ChatID, alert.ChatType).Allows(alert.Verdict.Severity) {
+        return []notify.Action{{Time: time.Now().UTC(), Sink: "chat_prefs", Status: notify.ActionSuppressed}}
+    }
+    return notify.Deliver(ctx, f.Next, "notifier", alert)
+}
@@ -0,0 +1,150 @@
+package telephish
+
+import (
+    "fmt"
+    "log/slog"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/expr-lang/expr"
+    "github.com/expr-lang/expr/vm"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// Rule is a user-written condition over a scanned link, such as
+//
+//    domain_age_days < 7 && has_login_form && !allowlisted
+//
+// When it holds, the rule can set the verdict's severity or suppress the
+// alert entirely. See ruleEnv for the variables a condition can use.
+type Rule struct {
+    Name     string             `yaml:"name"`
+    When     string             `yaml:"when"`
+    Severity *analysis.Severity `yaml:"severity"` // Replaces the verdict's severity
+    Suppress bool               `yaml:"suppress"` // Records the alert but sends it nowhere
+}
+
+// Rules are the compiled rules, applied in order.
+type Rules struct {
+    rules    []Rule
+    programs []*vm.Program
+}
+
+// CompileRules compiles every rule's condition.
+func CompileRules(rules []Rule) (*Rules, error) {
+    compiled := &Rules{rules: rules}
+    for i, rule := range rules {
+        program, err := compileCondition(rule.When)
+        if err != nil {
+            return nil, fmt.Errorf("rule %d %q: %v", i+1, rule.Name, err)
+        }
+        compiled.programs = append(compiled.programs, program)
+    }
+    return compiled, nil
+}
+
+// Apply runs each rule against the alert, changing its severity as they
+// say. It returns the name of the first matching rule that suppresses the
+// alert, or "" if none does.
+func (r *Rules) Apply(logger *slog.Logger, alert *notify.Alert) (suppressedBy string) {
+    for i, rule := range r.rules {
+        if !evalCondition(logger, r.programs[i], *alert) {
+            continue
+        }
+        logger.Debug("rule matched", "rule", rule.Name)
+        if rule.Severity != nil {
+            alert.Verdict.Severity = *rule.Severity
+            alert.Verdict.Findings = append(alert.Verdict.Findings, analysis.Finding{
+                Analyzer:    "rules",
+                Severity:    *rule.Severity,
+                Description: fmt.Sprintf("rule %q sets the verdict to %s", rule.Name, rule.Severity),
+            })
+        }
+        if rule.Suppress && suppressedBy == "" {
+            suppressedBy = rule.Name
+        }
+    }
+    return suppressedBy
+}
+
+// suppressedAction records that rule kept an alert from every sink.
+func suppressedAction(rule string) notify.Action {
+    return notify.Action{Time: time.Now().UTC(), Sink: "rule:" + rule, Status: notify.ActionSuppressed}
+}
+
+// compileCondition compiles a boolean expression. Variables are only known
+// at run time, since plugins can report facts of their own.
+func compileCondition(condition string) (*vm.Program, error) {
+    if strings.TrimSpace(condition) == "" {
+        return nil, fmt.Errorf("no condition given")
+    }
+    return expr.Compile(condition, expr.AsBool(), expr.AllowUndefinedVariables())
+}
+
+// evalCondition reports whether the condition holds for alert. A condition
+// that fails to run, e.g. by comparing a fact no analyzer reported, does
+// not hold.
+func evalCondition(logger *slog.Logger, program *vm.Program, alert notify.Alert) bool {
+    out, err := expr.Run(program, ruleEnv(alert))
+    if err != nil {
+        logger.Debug("rule condition failed", "err", err)
+        return false
+    }
+    ok, _ := out.(bool)
+    return ok
+}
+
+// ruleEnv returns the variables a condition is evaluated with:
+//
+//    url, domain, tld        the link and its host
+//    chat_id, chat_type      where it was received
+//    severity                "clean", "info", "suspicious" or "malicious"
+//    suspicious_count        findings at suspicious or worse
+//    findings                each with analyzer, severity and description
+//
+// plus every fact attached to a finding, such as has_login_form,
+// allowlisted and blocklisted (always present), or domain_age_days from a
+// plugin.
+func ruleEnv(alert notify.Alert) map[string]interface{} {
+    domain := ""
+    if u, err := url.Parse(alert.URL); err == nil {
+        domain = strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+    }
+    tld := domain
+    if i := strings.LastIndex(domain, "."); i >= 0 {
+        tld = domain[i+1:]
+    }
+
+    env := map[string]interface{}{
+        "has_login_form": false,
+        "allowlisted":    false,
+        "blocklisted":    false,
+    }
+    findings := make([]map[string]interface{}, 0, len(alert.Verdict.Findings))
+    suspicious := 0
+    for _, f := range alert.Verdict.Findings {
+        for name, value := range f.Facts {
+            env[name] = value
+        }
+        if f.Severity >= analysis.SeveritySuspicious {
+            suspicious++
+        }
+        findings = append(findings, map[string]interface{}{
+            "analyzer":    f.Analyzer,
+            "severity":    f.Severity.String(),
+            "description": f.Description,
+        })
+    }
+    env["url"] = alert.URL
+    env["domain"] = domain
+    env["tld"] = tld
+    env["chat_id"] = alert.ChatID
+    env["chat_type"] = alert.ChatType
+    env["severity"] = alert.Verdict.Severity.String()
+    env["suspicious_count"] = suspicious
+    env["findings"] = findings
+    return env
+}
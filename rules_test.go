@@ -0,0 +1,163 @@
+package telephish
+
+import (
+    "io"
+    "log/slog"
+    "testing"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+func severityPtr(s analysis.Severity) *analysis.Severity {
+    return &s
+}
+
+func ruleAlert() notify.Alert {
+    return notify.Alert{
+        URL:      "https://Login.PayPa1.XYZ./verify",
+        ChatID:   -1002,
+        ChatType: "group",
+        Verdict: analysis.Verdict{
+            Severity: analysis.SeveritySuspicious,
+            Findings: []analysis.Finding{
+                {Analyzer: "url", Severity: analysis.SeveritySuspicious, Description: "lookalike domain"},
+                {Analyzer: "page", Severity: analysis.SeverityInfo, Description: "login form", Facts: map[string]interface{}{"has_login_form": true}},
+                {Analyzer: "whois", Severity: analysis.SeverityClean, Description: "new domain", Facts: map[string]interface{}{"domain_age_days": 3.0}},
+            },
+        },
+    }
+}
+
+func TestCompileRules(t *testing.T) {
+    tests := []struct {
+        when string
+        ok   bool
+    }{
+        {"domain_age_days < 7 && has_login_form", true},
+        {`tld in ["zip", "xyz"]`, true},
+        {`any(findings, .analyzer == "url")`, true},
+        {"", false},
+        {"   ", false},
+        {"domain_age_days <", false},
+        {`"not a bool"`, false},
+        {"1 + 2", false},
+    }
+    for _, tt := range tests {
+        _, err := CompileRules([]Rule{{Name: "r", When: tt.when}})
+        if (err == nil) != tt.ok {
+            t.Errorf("CompileRules(%q) = %v, want ok %v", tt.when, err, tt.ok)
+        }
+    }
+}
+
+func TestRulesApply(t *testing.T) {
+    malicious, clean := severityPtr(analysis.SeverityMalicious), severityPtr(analysis.SeverityClean)
+    tests := []struct {
+        name         string
+        rules        []Rule
+        severity     analysis.Severity
+        suppressedBy string
+        ruleFindings int
+    }{
+        {"no rules", nil, analysis.SeveritySuspicious, "", 0},
+        {
+            "facts from findings",
+            []Rule{{Name: "young login", When: "domain_age_days < 7 && has_login_form", Severity: malicious}},
+            analysis.SeverityMalicious, "", 1,
+        },
+        {
+            "domain and tld lowercased without the trailing dot",
+            []Rule{{Name: "tld", When: `domain == "login.paypa1.xyz" && tld == "xyz"`, Severity: malicious}},
+            analysis.SeverityMalicious, "", 1,
+        },
+        {
+            "chat and findings",
+            []Rule{{Name: "groups", When: `chat_type == "group" && chat_id == -1002 && suspicious_count == 1 && any(findings, .analyzer == "page")`, Severity: malicious}},
+            analysis.SeverityMalicious, "", 1,
+        },
+        {
+            "no match",
+            []Rule{{Name: "old", When: "domain_age_days > 365", Severity: clean}},
+            analysis.SeveritySuspicious, "", 0,
+        },
+        {
+            "a missing fact doesn't match",
+            []Rule{{Name: "reputation", When: "reputation_score > 50", Severity: malicious}},
+            analysis.SeveritySuspicious, "", 0,
+        },
+        {
+            "a failing condition doesn't match",
+            []Rule{{Name: "bad", When: `domain_age_days > "seven"`, Severity: malicious}},
+            analysis.SeveritySuspicious, "", 0,
+        },
+        {
+            "always present facts",
+            []Rule{{Name: "listed", When: "!allowlisted && !blocklisted", Severity: malicious}},
+            analysis.SeverityMalicious, "", 1,
+        },
+        {
+            "later rules see earlier changes",
+            []Rule{
+                {Name: "raise", When: "has_login_form", Severity: malicious},
+                {Name: "suppress malicious", When: `severity == "malicious"`, Suppress: true},
+            },
+            analysis.SeverityMalicious, "suppress malicious", 1,
+        },
+        {
+            "the last severity wins",
+            []Rule{
+                {Name: "raise", When: "has_login_form", Severity: malicious},
+                {Name: "lower", When: `domain endsWith ".xyz"`, Severity: clean},
+            },
+            analysis.SeverityClean, "", 2,
+        },
+        {
+            "the first suppressing rule is named",
+            []Rule{
+                {Name: "not this", When: "false", Suppress: true},
+                {Name: "first", When: "true", Suppress: true},
+                {Name: "second", When: "true", Suppress: true},
+            },
+            analysis.SeveritySuspicious, "first", 0,
+        },
+        {
+            "suppressing and setting the severity",
+            []Rule{{Name: "quiet", When: "has_login_form", Severity: clean, Suppress: true}},
+            analysis.SeverityClean, "quiet", 1,
+        },
+    }
+    logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rules, err := CompileRules(tt.rules)
+            if err != nil {
+                t.Fatal(err)
+            }
+            alert := ruleAlert()
+            before := len(alert.Verdict.Findings)
+            if got := rules.Apply(logger, &alert); got != tt.suppressedBy {
+                t.Errorf("suppressed by %q, want %q", got, tt.suppressedBy)
+            }
+            if alert.Verdict.Severity != tt.severity {
+                t.Errorf("severity %s, want %s", alert.Verdict.Severity, tt.severity)
+            }
+            added := alert.Verdict.Findings[before:]
+            if len(added) != tt.ruleFindings {
+                t.Fatalf("rules added %d findings, want %d: %+v", len(added), tt.ruleFindings, added)
+            }
+            for _, f := range added {
+                if f.Analyzer != "rules" {
+                    t.Errorf("rule finding from analyzer %q", f.Analyzer)
+                }
+            }
+        })
+    }
+}
+
+func TestSuppressedAction(t *testing.T) {
+    action := suppressedAction("quiet")
+    if action.Sink != "rule:quiet" || action.Status != notify.ActionSuppressed || action.Time.IsZero() {
+        t.Errorf("suppressedAction = %+v", action)
+    }
+}
@@ -0,0 +1,101 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "net/url"
+    "os"
+    "os/exec"
+    "time"
+)
+
+// safeOpenFlags lock down the throwaway profile: no extensions, sync,
+// first-run prompts or background traffic of its own.
+var safeOpenFlags = []string{
+    "--no-first-run",
+    "--no-default-browser-check",
+    "--disable-extensions",
+    "--disable-sync",
+    "--disable-background-networking",
+    "--disable-component-update",
+    "--new-window",
+}
+
+// OpenURI returns the protocol link behind the toast's "Open browser"
+// button, which opens link with SafeOpen.
+func OpenURI(link string) string {
+    return ProtocolURI("open", link)
+}
+
+// findBrowser returns the browser safe_open.browser names, or else the
+// first of browserCandidates that is installed.
+func findBrowser(cfg SafeOpenConfig) (string, error) {
+    if cfg.Browser != "" {
+        return exec.LookPath(cfg.Browser)
+    }
+    for _, candidate := range browserCandidates() {
+        if path, err := exec.LookPath(candidate); err == nil {
+            return path, nil
+        }
+    }
+    return "", fmt.Errorf("no Edge, Chrome or Chromium found; set safe_open.browser")
+}
+
+// SafeOpen opens link in a new window of a Chromium-based browser, with a
+// profile of its own that nothing else uses, and deletes the profile once
+// the browser exits, along with any cookies, cache or downloads the page
+// left. It waits for the browser to exit.
+func SafeOpen(cfg SafeOpenConfig, link string) error {
+    u, err := url.Parse(link)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("refusing to open %q: not an http(s) URL", link)
+    }
+    browser, err := findBrowser(cfg)
+    if err != nil {
+        return err
+    }
+    profile, err := os.MkdirTemp("", "telephish-profile-*")
+    if err != nil {
+        return fmt.Errorf("failed to create browser profile: %v", err)
+    }
+    defer removeProfile(profile)
+
+    args := append([]string{"--user-data-dir=" + profile}, safeOpenFlags...)
+    args = append(args, cfg.Args...)
+    // -- ends the flags, so the link can't be taken for one
+    args = append(args, "--", u.String())
+    cmd := exec.Command(browser, args...)
+    if err := cmd.Start(); err != nil {
+        return fmt.Errorf("failed to start %s: %v", browser, err)
+    }
+    appLog.Info("opened link in a throwaway profile", "url", link, "browser", browser)
+    return cmd.Wait()
+}
+
+// removeProfile deletes a throwaway profile, waiting a little for the
+// browser's helper processes to let go of its files.
+func removeProfile(dir string) {
+    var err error
+    for i := 0; i < 10; i++ {
+        if err = os.RemoveAll(dir); err == nil {
+            return
+        }
+        time.Sleep(time.Second)
+    }
+    appLog.Warn("failed to delete browser profile", "dir", dir, "err", err)
+}
+
+// openCommand opens a link in a throwaway browser profile, as the toast's
+// "Open browser" button does.
+func openCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("open")
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        return fmt.Errorf("usage: %s open <url>", os.Args[0])
+    }
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    return SafeOpen(cfg.SafeOpen, fs.Arg(0))
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package telephish
+
+// browserCandidates are the Chromium-based browsers SafeOpen looks for,
+// in order.
+func browserCandidates() []string {
+    return []string{
+        "microsoft-edge",
+        "google-chrome",
+        "chromium",
+        "chromium-browser",
+        "/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+        "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+    }
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package telephish
+
+import (
+    "os"
+    "path/filepath"
+)
+
+// browserCandidates are the Chromium-based browsers SafeOpen looks for,
+// in order: Edge, which every Windows 10 and 11 machine has, then Chrome.
+func browserCandidates() []string {
+    var paths []string
+    for _, exe := range []string{`Microsoft\Edge\Application\msedge.exe`, `Google\Chrome\Application\chrome.exe`} {
+        for _, dir := range []string{os.Getenv("ProgramFiles(x86)"), os.Getenv("ProgramFiles"), os.Getenv("LOCALAPPDATA")} {
+            if dir != "" {
+                paths = append(paths, filepath.Join(dir, exe))
+            }
+        }
+    }
+    return paths
+}
@@ -0,0 +1,136 @@
+package telephish
+
+import (
+    "fmt"
+    "net/url"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// ProtocolScheme is the URI scheme the install step registers so toast
+// buttons can hand actions back to this binary.
+const ProtocolScheme = "telephish"
+
+// wsbTemplate is a locked-down Windows Sandbox configuration that opens a
+// single URL at logon. Nothing from the host is shared with the sandbox.
+const wsbTemplate = `<Configuration>
+  <VGpu>Disable</VGpu>
+  <Networking>Enable</Networking>
+  <ClipboardRedirection>Disable</ClipboardRedirection>
+  <PrinterRedirection>Disable</PrinterRedirection>
+  <AudioInput>Disable</AudioInput>
+  <VideoInput>Disable</VideoInput>
+  <ProtectedClient>Enable</ProtectedClient>
+  <LogonCommand>
+    <Command>%s</Command>
+  </LogonCommand>
+</Configuration>
+`
+
+// ProtocolURI returns the protocol link a toast button uses to have this
+// binary act on link, e.g. open it in the sandbox or submit it.
+func ProtocolURI(action, link string) string {
+    return ProtocolScheme + ":" + action + "?url=" + url.QueryEscape(link)
+}
+
+// SandboxURI returns the protocol link a toast button uses to open link in
+// Windows Sandbox.
+func SandboxURI(link string) string {
+    return ProtocolURI("sandbox", link)
+}
+
+// ParseProtocolURI extracts the action and URL from a link built by
+// ProtocolURI.
+func ParseProtocolURI(arg string) (action, link string, err error) {
+    u, err := url.Parse(arg)
+    if err != nil || u.Scheme != ProtocolScheme {
+        return "", "", fmt.Errorf("invalid %s: link %q", ProtocolScheme, arg)
+    }
+    // Some launchers add a slash: telephish:sandbox/?url=...
+    action, rawQuery, _ := strings.Cut(u.Opaque, "?")
+    action = strings.TrimSuffix(action, "/")
+    query, err := url.ParseQuery(rawQuery)
+    if err != nil || query.Get("url") == "" {
+        query = u.Query()
+    }
+    if query.Get("url") == "" {
+        return "", "", fmt.Errorf("%s link has no url", action)
+    }
+    return action, query.Get("url"), nil
+}
+
+// ParseSandboxURI extracts the URL from a link built by SandboxURI. Plain
+// URLs are returned unchanged.
+func ParseSandboxURI(arg string) (string, error) {
+    if !strings.HasPrefix(arg, ProtocolScheme+":") {
+        return arg, nil
+    }
+    action, link, err := ParseProtocolURI(arg)
+    if err != nil {
+        return "", err
+    }
+    if action != "sandbox" {
+        return "", fmt.Errorf("not a sandbox link: %q", arg)
+    }
+    return link, nil
+}
+
+// SandboxAvailable reports whether Windows Sandbox is installed.
+func SandboxAvailable() bool {
+    _, err := os.Stat(sandboxExe())
+    return err == nil
+}
+
+func sandboxExe() string {
+    return filepath.Join(os.Getenv("WINDIR"), "System32", "WindowsSandbox.exe")
+}
+
+// SandboxConfig returns a .wsb configuration that opens link in the
+// sandbox's browser.
+func SandboxConfig(link string) (string, error) {
+    u, err := url.Parse(link)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return "", fmt.Errorf("refusing to open %q in the sandbox: not an http(s) URL", link)
+    }
+    // Percent-encode anything that could break out of the quoted argument
+    var quoted strings.Builder
+    for _, r := range u.String() {
+        if r <= ' ' || r == '"' || r == 0x7f {
+            fmt.Fprintf(&quoted, "%%%02X", r)
+        } else {
+            quoted.WriteRune(r)
+        }
+    }
+    command := fmt.Sprintf(`explorer.exe "%s"`, quoted.String())
+    return fmt.Sprintf(wsbTemplate, notify.EscapeXML(command)), nil
+}
+
+// OpenInSandbox writes a .wsb for link and launches Windows Sandbox with it.
+func OpenInSandbox(link string) error {
+    if !SandboxAvailable() {
+        return fmt.Errorf("Windows Sandbox is not available; enable the \"Windows Sandbox\" optional feature")
+    }
+    config, err := SandboxConfig(link)
+    if err != nil {
+        return err
+    }
+
+    f, err := os.CreateTemp("", "telephish-*.wsb")
+    if err != nil {
+        return fmt.Errorf("failed to create sandbox config: %v", err)
+    }
+    if _, err := f.WriteString(config); err != nil {
+        f.Close()
+        return fmt.Errorf("failed to write sandbox config: %v", err)
+    }
+    f.Close()
+
+    if err := exec.Command(sandboxExe(), f.Name()).Start(); err != nil {
+        return fmt.Errorf("failed to start Windows Sandbox: %v", err)
+    }
+    return nil
+}
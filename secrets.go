@@ -0,0 +1,104 @@
+package telephish
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/hacker1337itme/telephish/keystore"
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// secretsCommand manages the secrets kept in the OS credential store.
+func secretsCommand(ctx context.Context, args []string) error {
+    usage := fmt.Errorf("usage: %s secrets set|delete <name> | list", os.Args[0])
+    if len(args) == 0 {
+        return usage
+    }
+    action, args := args[0], args[1:]
+    if action == "list" && len(args) == 0 {
+        return listSecrets()
+    }
+    if len(args) != 1 || (action != "set" && action != "delete") {
+        return usage
+    }
+    name := args[0]
+    if !knownSecret(name) {
+        return fmt.Errorf("unknown secret %q; run %s secrets list to see them", name, os.Args[0])
+    }
+
+    if action == "delete" {
+        if err := keystore.Delete(name); err != nil {
+            return err
+        }
+        fmt.Printf("deleted %s from the credential store\n", name)
+        return nil
+    }
+    value, err := readSecret(name)
+    if err != nil {
+        return err
+    }
+    if err := keystore.Set(name, value); err != nil {
+        return err
+    }
+    fmt.Printf("stored %s in the credential store; remove it from the config file and environment, which take precedence\n", name)
+    return nil
+}
+
+// knownSecret reports whether name is a secret setting, a profile's bot
+// token or an outbound webhook's secret.
+func knownSecret(name string) bool {
+    if profile, ok := strings.CutPrefix(name, "profiles."); ok {
+        profile, ok = strings.CutSuffix(profile, ".telegram.token")
+        return ok && profileName.MatchString(profile)
+    }
+    if hook, ok := strings.CutPrefix(name, "sinks.webhooks."); ok {
+        hook, ok = strings.CutSuffix(hook, ".secret")
+        return ok && profileName.MatchString(hook)
+    }
+    for _, s := range (&Config{}).secrets() {
+        if s.name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// listSecrets prints each secret setting and whether it is stored.
+func listSecrets() error {
+    for _, s := range (&Config{}).secrets() {
+        status := "stored"
+        if _, err := keystore.Get(s.name); err == keystore.ErrNotFound {
+            status = "-"
+        } else if err != nil {
+            return err
+        }
+        fmt.Printf("%-24s %s\n", s.name, status)
+    }
+    return nil
+}
+
+// readSecret reads a secret from stdin: one line, prompted for without
+// echo at a terminal, so that it stays out of the shell history.
+func readSecret(name string) (string, error) {
+    if notify.IsTerminal(os.Stdin) {
+        fmt.Fprintf(os.Stderr, "%s: ", name)
+        restore, err := disableEcho(os.Stdin)
+        if err != nil {
+            return "", fmt.Errorf("failed to turn off echo: %v", err)
+        }
+        defer fmt.Fprintln(os.Stderr)
+        defer restore()
+    }
+    line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && line == "" {
+        return "", fmt.Errorf("failed to read %s: %v", name, err)
+    }
+    value := strings.TrimRight(line, "\r\n")
+    if value == "" {
+        return "", fmt.Errorf("no value given for %s", name)
+    }
+    return value, nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package telephish
+
+import (
+    "context"
+    "fmt"
+)
+
+// serviceCommand is only available on Windows.
+func serviceCommand(ctx context.Context, args []string) error {
+    return fmt.Errorf("the service command is only supported on Windows")
+}
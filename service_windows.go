@@ -0,0 +1,260 @@
+//go:build windows
+
+package telephish
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "golang.org/x/sys/windows/svc"
+    "golang.org/x/sys/windows/svc/eventlog"
+    "golang.org/x/sys/windows/svc/mgr"
+
+    "github.com/hacker1337itme/telephish/logging"
+)
+
+// ServiceName is the name the monitor is registered under with the SCM.
+const ServiceName = "telephish"
+
+// serviceCommand implements `service install|uninstall|start|stop|reload|run`.
+func serviceCommand(ctx context.Context, args []string) error {
+    if len(args) == 0 {
+        return fmt.Errorf("usage: %s service install|uninstall|start|stop|reload", os.Args[0])
+    }
+    action, args := args[0], args[1:]
+
+    fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+    configPath := fs.String("config", "", "path to the YAML config file the service runs with")
+    account := fs.String("account", `NT AUTHORITY\LocalService`, "account the service runs as")
+    password := fs.String("password", "", "password for --account, if it needs one")
+    fs.Parse(args)
+
+    switch action {
+    case "install":
+        return installService(*configPath, *account, *password)
+    case "uninstall":
+        return uninstallService()
+    case "start":
+        return controlService(func(s *mgr.Service) error { return s.Start() })
+    case "stop":
+        return controlService(func(s *mgr.Service) error {
+            _, err := s.Control(svc.Stop)
+            return err
+        })
+    case "reload":
+        return controlService(func(s *mgr.Service) error {
+            _, err := s.Control(svc.ParamChange)
+            return err
+        })
+    case "run":
+        return runService(*configPath)
+    }
+    return fmt.Errorf("unknown service action %q", action)
+}
+
+func installService(configPath, account, password string) error {
+    exe, err := os.Executable()
+    if err != nil {
+        return fmt.Errorf("failed to locate executable: %v", err)
+    }
+    args := []string{"service", "run"}
+    if configPath != "" {
+        // The service starts in System32, so pin the config to an absolute path
+        abs, err := filepath.Abs(configPath)
+        if err != nil {
+            return err
+        }
+        args = append(args, "--config", abs)
+    }
+
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("failed to connect to service manager: %v", err)
+    }
+    defer m.Disconnect()
+
+    s, err := m.CreateService(ServiceName, exe, mgr.Config{
+        DisplayName:      AppName + " phishing link monitor",
+        Description:      "Scans links sent to the Telegram bot and alerts on phishing.",
+        StartType:        mgr.StartAutomatic,
+        ServiceStartName: account,
+        Password:         password,
+    }, args...)
+    if err != nil {
+        return fmt.Errorf("failed to create service: %v", err)
+    }
+    defer s.Close()
+
+    // Restart after crashes: quickly at first, then back off
+    recovery := []mgr.RecoveryAction{
+        {Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+        {Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+        {Type: mgr.ServiceRestart, Delay: 5 * time.Minute},
+    }
+    if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+        return fmt.Errorf("failed to set recovery actions: %v", err)
+    }
+
+    if err := eventlog.InstallAsEventCreate(ServiceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+        serviceLog.Warn("failed to register event log source", "err", err)
+    }
+    serviceLog.Info("installed service", "service", ServiceName, "account", account)
+    return nil
+}
+
+func uninstallService() error {
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("failed to connect to service manager: %v", err)
+    }
+    defer m.Disconnect()
+
+    s, err := m.OpenService(ServiceName)
+    if err != nil {
+        return fmt.Errorf("service %s is not installed: %v", ServiceName, err)
+    }
+    defer s.Close()
+    if err := s.Delete(); err != nil {
+        return fmt.Errorf("failed to delete service: %v", err)
+    }
+    eventlog.Remove(ServiceName)
+    serviceLog.Info("removed service", "service", ServiceName)
+    return nil
+}
+
+func controlService(fn func(*mgr.Service) error) error {
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("failed to connect to service manager: %v", err)
+    }
+    defer m.Disconnect()
+
+    s, err := m.OpenService(ServiceName)
+    if err != nil {
+        return fmt.Errorf("service %s is not installed: %v", ServiceName, err)
+    }
+    defer s.Close()
+    return fn(s)
+}
+
+// runService is what the SCM launches. Logs go to the Windows event log
+// since a service has no console.
+func runService(configPath string) error {
+    var logs io.Writer = os.Stderr
+    if elog, err := eventlog.Open(ServiceName); err == nil {
+        defer elog.Close()
+        logs = eventLogWriter{elog}
+        logging.Setup(logging.Config{}, logs)
+    }
+    return svc.Run(ServiceName, &monitorService{configPath: configPath, logs: logs})
+}
+
+// monitorService reports status to the SCM while the poller runs.
+type monitorService struct {
+    configPath string
+    logs       io.Writer
+}
+
+// Execute runs the monitor until the SCM asks it to stop.
+func (m *monitorService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+    status <- svc.Status{State: svc.StartPending}
+
+    cfg, err := LoadConfig(m.configPath)
+    var logs io.Writer
+    if err == nil {
+        logs, err = logging.Writer(cfg.Logging, m.logs)
+    }
+    if err == nil {
+        err = logging.Setup(cfg.Logging, logs)
+    }
+    if err == nil {
+        err = cfg.RequireTokens()
+    }
+    var unlock func()
+    if err == nil {
+        unlock, err = LockInstances(cfg)
+    }
+    if unlock != nil {
+        defer unlock()
+    }
+    var apps []*App
+    if err == nil {
+        serviceLog.Info("starting", Build().LogAttrs()...)
+        apps, err = NewApps(cfg)
+    }
+    if err != nil {
+        serviceLog.Error("failed to start service", "err", err)
+        return true, 1
+    }
+    defer closeApps(apps)
+    app := apps[0]
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    if err := app.StartServers(ctx); err != nil {
+        serviceLog.Error("failed to start servers", "err", err)
+        return true, 1
+    }
+    app.StartPruning(ctx)
+    app.StartBlockExpiry(ctx)
+    app.StartReports(ctx)
+    app.StartHeartbeat(ctx)
+    for _, app := range apps {
+        app.StartRescans(ctx)
+        app.StartDeliveries(ctx)
+    }
+    failed := make(chan error, 1)
+    go func() { failed <- pollApps(ctx, apps, false) }()
+
+    status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange}
+    for {
+        select {
+        case err := <-failed:
+            serviceLog.Error("monitor stopped", "err", err)
+            return true, 2
+        case req := <-requests:
+            switch req.Cmd {
+            case svc.Interrogate:
+                status <- req.CurrentStatus
+            case svc.ParamChange:
+                if err := app.Reload(); err != nil {
+                    serviceLog.Error("failed to reload config; keeping the old one", "err", err)
+                }
+                status <- req.CurrentStatus
+            case svc.Stop, svc.Shutdown:
+                // Let the update being scanned finish and the offset be
+                // confirmed; the deferred Close then flushes the digest.
+                status <- svc.Status{State: svc.StopPending, WaitHint: 60000}
+                cancel()
+                if err := <-failed; err != nil {
+                    serviceLog.Error("failed to stop monitor cleanly", "err", err)
+                }
+                return false, 0
+            }
+        }
+    }
+}
+
+// eventLogWriter sends log records to the event log, as errors or
+// warnings according to the record's level. Each Write is one text or
+// JSON record.
+type eventLogWriter struct {
+    elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+    msg := string(p)
+    switch {
+    case strings.Contains(msg, "level=ERROR") || strings.Contains(msg, `"level":"ERROR"`):
+        return len(p), w.elog.Error(1, msg)
+    case strings.Contains(msg, "level=WARN") || strings.Contains(msg, `"level":"WARN"`):
+        return len(p), w.elog.Warning(1, msg)
+    }
+    return len(p), w.elog.Info(1, msg)
+}
@@ -0,0 +1,157 @@
+package telephish
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// BuildSinks creates every configured sink, keyed by the name routes use.
+// "desktop" (or stdout in headless mode) and "log" are always present.
+// The tickets sink remembers its tickets in history.
+func BuildSinks(cfg *Config, toast ToastNotifier, templates *notify.Templates, loc *i18n.Localizer, history *store.History) (map[string]notify.Notifier, error) {
+    token := cfg.Telegram.Token
+    sinks := map[string]notify.Notifier{
+        "log": notify.LogNotifier{},
+        "desktop": notify.FallbackNotifier{
+            toast,
+            BalloonNotifier{},
+            notify.TelegramNotifier{Token: token, Templates: templates},
+            notify.LogNotifier{},
+        },
+    }
+    if cfg.Headless {
+        headless := notify.NewHeadlessNotifier()
+        if cfg.Output == "jsonl" {
+            // Stdout carries the results
+            headless.Out, headless.Color = os.Stderr, notify.IsTerminal(os.Stderr) && os.Getenv("NO_COLOR") == ""
+        } else if cfg.TUI {
+            // The terminal UI shows alerts itself
+            headless.Out, headless.Color = io.Discard, false
+        }
+        sinks["desktop"] = headless
+    }
+
+    if token != "" {
+        sinks["telegram"] = notify.TelegramNotifier{Token: token, Templates: templates}
+    }
+    if e := cfg.Sinks.Email; e.Addr != "" {
+        sinks["email"] = notify.NewEmailNotifier(e.Addr, e.Username, e.Password, e.From, e.To, loc)
+    }
+    if hook := cfg.Sinks.Slack.Webhook; hook != "" {
+        sinks["slack"] = notify.SlackNotifier{WebhookURL: hook, Loc: loc}
+    }
+    if hook := cfg.Sinks.Discord.Webhook; hook != "" {
+        sinks["discord"] = notify.DiscordNotifier{WebhookURL: hook, Loc: loc}
+    }
+    if hook := cfg.Sinks.Teams.Webhook; hook != "" {
+        sinks["teams"] = notify.TeamsNotifier{WebhookURL: hook, Loc: loc}
+    }
+    if n := cfg.Sinks.Ntfy; n.URL != "" {
+        sinks["ntfy"] = notify.NtfyNotifier{TopicURL: n.URL, Token: n.Token, Priorities: notify.NtfyPriorities, Loc: loc}
+    }
+    if p := cfg.Sinks.Pushover; p.Token != "" {
+        sinks["pushover"] = notify.PushoverNotifier{AppToken: p.Token, UserKey: p.User, Priorities: notify.PushoverPriorities, Loc: loc}
+    }
+    if g := cfg.Sinks.Gotify; g.URL != "" {
+        sinks["gotify"] = notify.GotifyNotifier{ServerURL: g.URL, AppToken: g.Token, Priorities: notify.GotifyPriorities, Loc: loc}
+    }
+    if s := cfg.Sinks.Syslog; s.Addr != "" {
+        syslog, err := notify.NewSyslogNotifier(s.Network, s.Addr, notify.SyslogFacilities[s.Facility], s.CAFile)
+        if err != nil {
+            return nil, err
+        }
+        syslog.Format, syslog.Version = s.Format, version
+        sinks["syslog"] = syslog
+    }
+    if k := cfg.Sinks.Kafka; len(k.Brokers) > 0 {
+        kafka, err := notify.NewKafkaNotifier(k.Brokers, k.Topic, notify.KafkaOptions{
+            Key: k.Key, Delivery: k.Delivery, TLS: k.TLS, SASL: k.SASL, Username: k.Username, Password: k.Password,
+        })
+        if err != nil {
+            return nil, err
+        }
+        sinks["kafka"] = kafka
+    }
+    if n := cfg.Sinks.NATS; n.URL != "" {
+        nats, err := notify.NewNATSNotifier(n.URL, n.Subject, notify.NATSOptions{
+            Key: n.Key, Delivery: n.Delivery, Token: n.Token, CredsFile: n.CredsFile,
+        })
+        if err != nil {
+            closeSinks(sinks)
+            return nil, err
+        }
+        sinks["nats"] = nats
+    }
+    if d := cfg.Sinks.Defender; d.TenantID != "" {
+        sinks["defender"] = newDefender(d)
+    }
+    if t := cfg.Sinks.TheHive; t.URL != "" {
+        sinks["thehive"] = notify.TheHiveNotifier{
+            URL: strings.TrimSuffix(t.URL, "/"), APIKey: t.APIKey, Organisation: t.Organisation, TLP: t.TLP, PAP: t.PAP, Tags: t.Tags,
+        }
+    }
+    if x := cfg.Sinks.Cortex; x.URL != "" {
+        sinks["cortex"] = &notify.CortexNotifier{URL: strings.TrimSuffix(x.URL, "/"), APIKey: x.APIKey, Analyzers: x.Analyzers, TLP: x.TLP}
+    }
+    if t := cfg.Sinks.Tickets; t.Tracker != "" {
+        var tracker notify.Tracker = notify.WebhookTracker{
+            CreateURL: t.Webhook.CreateURL, CommentURL: t.Webhook.CommentURL, Headers: t.Webhook.Headers, KeyField: t.Webhook.KeyField,
+        }
+        if t.Tracker == "jira" {
+            j := t.Jira
+            tracker = notify.JiraTracker{
+                URL: strings.TrimSuffix(j.URL, "/"), Email: j.Email, Token: j.Token, Project: j.Project, IssueType: j.IssueType, Priority: j.Priority,
+            }
+        }
+        var ledger notify.TicketLedger
+        if history.Enabled() {
+            ledger = history
+        }
+        tickets := notify.NewTicketNotifier(tracker, ledger)
+        tickets.MinSeverity, tickets.Dedup, tickets.Window, tickets.Labels = t.Severity, t.Dedup, t.Window, t.Labels
+        sinks["tickets"] = tickets
+    }
+    if cfg.Sinks.Abuse.Enabled {
+        abuse, err := newAbuseReporter(cfg, history)
+        if err != nil {
+            closeSinks(sinks)
+            return nil, err
+        }
+        sinks["abuse"] = abuse
+    }
+    for _, w := range cfg.Sinks.Webhooks {
+        if _, ok := sinks[w.Name]; ok {
+            closeSinks(sinks)
+            return nil, fmt.Errorf("webhook %q has the same name as a built-in sink", w.Name)
+        }
+        hook, err := notify.NewOutboundWebhook(w.URL, w.Template)
+        if err != nil {
+            closeSinks(sinks)
+            return nil, fmt.Errorf("webhook %q: %v", w.Name, err)
+        }
+        hook.Headers, hook.Secret, hook.Retries = w.Headers, w.Secret, w.Retries
+        if w.Backoff > 0 {
+            hook.Backoff = w.Backoff
+        }
+        sinks[w.Name] = hook
+    }
+    return sinks, nil
+}
+
+// closeSinks closes the sinks holding connections, such as the message
+// bus sinks.
+func closeSinks(sinks map[string]notify.Notifier) {
+    for name, sink := range sinks {
+        if c, ok := sink.(io.Closer); ok {
+            if err := c.Close(); err != nil {
+                appLog.Warn("failed to close sink", "sink", name, "err", err)
+            }
+        }
+    }
+}
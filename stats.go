@@ -0,0 +1,97 @@
+package telephish
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "text/tabwriter"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// statsCommand prints totals from the history for a quick look at how the
+// monitor is doing: scans per day, verdicts, scan times and how often each
+// analyzer ran, which for reputation plugins is their API quota used.
+func statsCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("stats")
+    since := fs.String("since", "7d", "total the scans since this date, RFC 3339 time or duration ago, e.g. 24h or 30d")
+    asJSON := fs.Bool("json", false, "print the totals as JSON instead of tables")
+    fs.Parse(args)
+    if fs.NArg() > 0 {
+        return fmt.Errorf("usage: %s stats [--since time] [--json]", os.Args[0])
+    }
+    from, err := parseWhen(*since)
+    if err != nil {
+        return fmt.Errorf("--since: %v", err)
+    }
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    if !history.Enabled() {
+        return fmt.Errorf("history: stats are totals of the history, which is turned off")
+    }
+    stats, err := history.Stats(from)
+    if err != nil {
+        return err
+    }
+
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetEscapeHTML(false)
+        enc.SetIndent("", "  ")
+        return enc.Encode(stats)
+    }
+    if stats.Scans == 0 {
+        fmt.Fprintf(os.Stderr, "no scans since %s\n", stats.Since.Local().Format(time.DateTime))
+        return nil
+    }
+    severities := []analysis.Severity{analysis.SeverityClean, analysis.SeverityInfo, analysis.SeveritySuspicious, analysis.SeverityMalicious}
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+    fmt.Fprint(tw, "DAY (UTC)\tSCANS\t")
+    for _, s := range severities {
+        fmt.Fprintf(tw, "%s\t", s)
+    }
+    fmt.Fprintln(tw)
+    for _, d := range stats.Days {
+        fmt.Fprintf(tw, "%s\t%d\t", d.Day.Format(time.DateOnly), d.Scans)
+        for _, s := range severities {
+            fmt.Fprintf(tw, "%d\t", d.Verdicts[s.String()])
+        }
+        fmt.Fprintln(tw)
+    }
+    fmt.Fprintf(tw, "total\t%d\t", stats.Scans)
+    for _, s := range severities {
+        fmt.Fprintf(tw, "%d (%.0f%%)\t", stats.Verdicts[s.String()], 100*float64(stats.Verdicts[s.String()])/float64(stats.Scans))
+    }
+    fmt.Fprintln(tw)
+    if err := tw.Flush(); err != nil {
+        return err
+    }
+
+    fmt.Printf("\nscan time: mean %s, max %s\n\n", millis(stats.MeanMS), millis(float64(stats.MaxMS)))
+    if len(stats.Analyzers) == 0 {
+        // Older scans didn't record their analyzer runs
+        return nil
+    }
+    tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+    fmt.Fprintln(tw, "ANALYZER\tRUNS\tCALLS\tCACHED\tFAILED\tMEAN TIME\t")
+    for _, a := range stats.Analyzers {
+        fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\t\n", a.Analyzer, a.Runs, a.Calls, a.Cached, a.Failed, millis(a.MeanMS))
+    }
+    return tw.Flush()
+}
+
+// millis formats a number of milliseconds as a duration.
+func millis(ms float64) string {
+    return time.Duration(ms * float64(time.Millisecond)).Round(time.Millisecond).String()
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+    "fmt"
+    "time"
+)
+
+// AuditEvent is an entry in the audit log: something the monitor did on
+// its own, or someone did through it, that changes what it does or what
+// happens to a link.
+type AuditEvent struct {
+    ID      int64     `json:"id"`
+    Time    time.Time `json:"time"`
+    Actor   string    `json:"actor"`  // telephish for the monitor itself, or who did it, e.g. telegram:12345
+    Action  string    `json:"action"` // e.g. block, allowlist.add
+    Target  string    `json:"target"` // What it was done to, e.g. a domain
+    Reason  string    `json:"reason,omitempty"`
+    Profile string    `json:"profile,omitempty"`
+}
+
+// AuditFilter selects audit events. Zero fields match everything.
+type AuditFilter struct {
+    Since  time.Time
+    Actor  string
+    Action string
+    Limit  int // The newest this many
+}
+
+// Audit appends e to the audit log, at the current time if it has none.
+// Events can't be changed or deleted afterwards, not even by pruning.
+func (h *History) Audit(e AuditEvent) error {
+    if h.db == nil {
+        return nil
+    }
+    if e.Time.IsZero() {
+        e.Time = time.Now()
+    }
+    if _, err := h.db.Exec(`INSERT INTO audit (time, actor, action, target, reason, profile) VALUES (?, ?, ?, ?, ?, ?)`,
+        e.Time.UnixMilli(), e.Actor, e.Action, e.Target, e.Reason, e.Profile); err != nil {
+        return fmt.Errorf("failed to write audit log: %v", err)
+    }
+    return nil
+}
+
+// AuditLog returns the audit events f selects, newest first.
+func (h *History) AuditLog(f AuditFilter) ([]AuditEvent, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    query := `SELECT id, time, actor, action, target, reason, profile FROM audit WHERE time >= ?`
+    args := []interface{}{f.Since.UnixMilli()}
+    if f.Actor != "" {
+        query += ` AND actor = ?`
+        args = append(args, f.Actor)
+    }
+    if f.Action != "" {
+        query += ` AND action = ?`
+        args = append(args, f.Action)
+    }
+    query += ` ORDER BY id DESC`
+    if f.Limit > 0 {
+        query += fmt.Sprintf(` LIMIT %d`, f.Limit)
+    }
+    rows, err := h.db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query audit log: %v", err)
+    }
+    defer rows.Close()
+    var events []AuditEvent
+    for rows.Next() {
+        var e AuditEvent
+        var at int64
+        if err := rows.Scan(&e.ID, &at, &e.Actor, &e.Action, &e.Target, &e.Reason, &e.Profile); err != nil {
+            return nil, err
+        }
+        e.Time = time.UnixMilli(at).UTC()
+        events = append(events, e)
+    }
+    return events, rows.Err()
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+    "database/sql"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Block is a domain blocked on this machine after a malicious verdict, or
+// by hand.
+type Block struct {
+    Domain  string    `json:"domain"`
+    Mode    string    `json:"mode"`            // How it is blocked: hosts or firewall
+    Addrs   []string  `json:"addrs,omitempty"` // The addresses a firewall rule blocks
+    URL     string    `json:"url,omitempty"`   // The link that got it blocked, if any
+    Time    time.Time `json:"time"`
+    Expires time.Time `json:"expires,omitempty"` // Zero for never
+}
+
+// AddBlock records b, replacing any block of the same domain.
+func (h *History) AddBlock(b Block) error {
+    if h.db == nil {
+        return fmt.Errorf("blocks need the history database")
+    }
+    var expires int64
+    if !b.Expires.IsZero() {
+        expires = b.Expires.UnixMilli()
+    }
+    if _, err := h.db.Exec(`INSERT OR REPLACE INTO blocks (domain, mode, addrs, url, time, expires) VALUES (?, ?, ?, ?, ?, ?)`,
+        b.Domain, b.Mode, strings.Join(b.Addrs, ","), b.URL, b.Time.UnixMilli(), expires); err != nil {
+        return fmt.Errorf("failed to record block: %v", err)
+    }
+    return nil
+}
+
+const blockColumns = `domain, mode, addrs, url, time, expires`
+
+// Blocks returns the blocks in force, soonest to expire first, or if
+// expiredBy isn't zero only those expiring by then.
+func (h *History) Blocks(expiredBy time.Time) ([]Block, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    query := `SELECT ` + blockColumns + ` FROM blocks`
+    var args []interface{}
+    if !expiredBy.IsZero() {
+        query += ` WHERE expires > 0 AND expires <= ?`
+        args = append(args, expiredBy.UnixMilli())
+    }
+    rows, err := h.db.Query(query+` ORDER BY expires = 0, expires, domain`, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query blocks: %v", err)
+    }
+    defer rows.Close()
+    var blocks []Block
+    for rows.Next() {
+        b, err := scanBlock(rows)
+        if err != nil {
+            return nil, err
+        }
+        blocks = append(blocks, b)
+    }
+    return blocks, rows.Err()
+}
+
+// Block returns the block of domain, if there is one.
+func (h *History) Block(domain string) (Block, bool, error) {
+    if h.db == nil {
+        return Block{}, false, nil
+    }
+    b, err := scanBlock(h.db.QueryRow(`SELECT `+blockColumns+` FROM blocks WHERE domain = ?`, domain))
+    if err == sql.ErrNoRows {
+        return Block{}, false, nil
+    } else if err != nil {
+        return Block{}, false, fmt.Errorf("failed to query blocks: %v", err)
+    }
+    return b, true, nil
+}
+
+func scanBlock(row interface{ Scan(...interface{}) error }) (Block, error) {
+    var b Block
+    var addrs string
+    var at, expires int64
+    if err := row.Scan(&b.Domain, &b.Mode, &addrs, &b.URL, &at, &expires); err != nil {
+        return b, err
+    }
+    if addrs != "" {
+        b.Addrs = strings.Split(addrs, ",")
+    }
+    b.Time = time.UnixMilli(at).UTC()
+    if expires > 0 {
+        b.Expires = time.UnixMilli(expires).UTC()
+    }
+    return b, nil
+}
+
+// RemoveBlock forgets the block of domain.
+func (h *History) RemoveBlock(domain string) error {
+    if h.db == nil {
+        return nil
+    }
+    if _, err := h.db.Exec(`DELETE FROM blocks WHERE domain = ?`, domain); err != nil {
+        return fmt.Errorf("failed to remove block: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+    "database/sql"
+    "fmt"
+    "time"
+
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// Delivery is an alert waiting in the delivery queue for one sink, or
+// dead-lettered there after running out of attempts.
+type Delivery struct {
+    ID       int64     `json:"id"`
+    Entry    int64     `json:"entry"` // The history entry of the alert
+    Sink     string    `json:"sink"`
+    Attempts int       `json:"attempts"`
+    Next     time.Time `json:"next"` // When it is next tried, if it isn't dead
+    Error    string    `json:"error,omitempty"`
+    Dead     bool      `json:"dead"`
+}
+
+// DeliveryFilter selects deliveries for listing, retrying and purging.
+type DeliveryFilter struct {
+    Profile string // The profile whose bot received the alert
+    Sink    string // Every sink if empty
+    Dead    bool   // Dead letters rather than pending deliveries
+}
+
+// where returns the SQL condition selecting f's deliveries, and its
+// arguments.
+func (f DeliveryFilter) where() (string, []interface{}) {
+    where := `dead = ? AND alert IN (SELECT id FROM alerts WHERE profile = ?)`
+    args := []interface{}{f.Dead, f.Profile}
+    if f.Sink != "" {
+        where += ` AND sink = ?`
+        args = append(args, f.Sink)
+    }
+    return where, args
+}
+
+// Deliveries returns up to limit of the deliveries f selects, or all of
+// them if limit is negative, that are due by before, or whenever if before
+// is zero, oldest first.
+func (h *History) Deliveries(f DeliveryFilter, before time.Time, limit int) ([]Delivery, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    where, args := f.where()
+    if !before.IsZero() {
+        where += ` AND next <= ?`
+        args = append(args, before.UnixMilli())
+    }
+    rows, err := h.db.Query(`SELECT id, alert, sink, attempts, next, error, dead FROM deliveries WHERE `+where+` ORDER BY id LIMIT ?`,
+        append(args, limit)...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query deliveries: %v", err)
+    }
+    defer rows.Close()
+    var deliveries []Delivery
+    for rows.Next() {
+        var d Delivery
+        var next int64
+        if err := rows.Scan(&d.ID, &d.Entry, &d.Sink, &d.Attempts, &next, &d.Error, &d.Dead); err != nil {
+            return nil, err
+        }
+        d.Next = time.UnixMilli(next).UTC()
+        deliveries = append(deliveries, d)
+    }
+    return deliveries, rows.Err()
+}
+
+// DueSinks returns the sinks with deliveries for profile's alerts due by
+// now.
+func (h *History) DueSinks(profile string, now time.Time) ([]string, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    rows, err := h.db.Query(`SELECT DISTINCT sink FROM deliveries
+        WHERE dead = 0 AND next <= ? AND alert IN (SELECT id FROM alerts WHERE profile = ?)`, now.UnixMilli(), profile)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query deliveries: %v", err)
+    }
+    defer rows.Close()
+    var sinks []string
+    for rows.Next() {
+        var sink string
+        if err := rows.Scan(&sink); err != nil {
+            return nil, err
+        }
+        sinks = append(sinks, sink)
+    }
+    return sinks, rows.Err()
+}
+
+// CountDeliveries returns how many deliveries f selects.
+func (h *History) CountDeliveries(f DeliveryFilter) (int, error) {
+    if h.db == nil {
+        return 0, nil
+    }
+    where, args := f.where()
+    var n int
+    if err := h.db.QueryRow(`SELECT COUNT(*) FROM deliveries WHERE `+where, args...).Scan(&n); err != nil {
+        return 0, fmt.Errorf("failed to count deliveries: %v", err)
+    }
+    return n, nil
+}
+
+// Delivered takes d off the queue and records act, how it went in the
+// end, as the alert's action for the sink.
+func (h *History) Delivered(d Delivery, act notify.Action) error {
+    tx, err := h.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err := tx.Exec(`DELETE FROM deliveries WHERE id = ?`, d.ID); err != nil {
+        return fmt.Errorf("failed to dequeue delivery: %v", err)
+    }
+    if err := updateAction(tx, d, act); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// Retry records a failed attempt at d, which is tried again at next.
+func (h *History) Retry(d Delivery, next time.Time, attemptErr error) error {
+    tx, err := h.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err := tx.Exec(`UPDATE deliveries SET attempts = attempts + 1, next = ?, error = ? WHERE id = ?`,
+        next.UnixMilli(), attemptErr.Error(), d.ID); err != nil {
+        return fmt.Errorf("failed to reschedule delivery: %v", err)
+    }
+    act := notify.Action{Time: time.Now().UTC(), Sink: d.Sink, Status: notify.ActionQueued, Error: attemptErr.Error()}
+    if err := updateAction(tx, d, act); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// Postpone holds back every delivery f selects that is due before until,
+// without counting an attempt, so a sink that is down is left alone while
+// it backs off.
+func (h *History) Postpone(f DeliveryFilter, until time.Time) error {
+    where, args := f.where()
+    if _, err := h.db.Exec(`UPDATE deliveries SET next = ? WHERE next < ? AND `+where,
+        append([]interface{}{until.UnixMilli(), until.UnixMilli()}, args...)...); err != nil {
+        return fmt.Errorf("failed to postpone deliveries: %v", err)
+    }
+    return nil
+}
+
+// DeadLetter gives up on d after its last attempt failed with attemptErr.
+// It stays in the queue, dead, until retried or purged, and the alert's
+// action for the sink is marked failed.
+func (h *History) DeadLetter(d Delivery, attemptErr error) error {
+    tx, err := h.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err := tx.Exec(`UPDATE deliveries SET attempts = attempts + 1, error = ?, dead = 1 WHERE id = ?`,
+        attemptErr.Error(), d.ID); err != nil {
+        return fmt.Errorf("failed to dead-letter delivery: %v", err)
+    }
+    act := notify.Action{Time: time.Now().UTC(), Sink: d.Sink, Status: notify.ActionFailed, Error: attemptErr.Error()}
+    if err := updateAction(tx, d, act); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// updateAction replaces the action for d's sink on its alert with act.
+func updateAction(tx *sql.Tx, d Delivery, act notify.Action) error {
+    if _, err := tx.Exec(`UPDATE actions SET time = ?, status = ?, error = ? WHERE alert = ? AND sink = ?`,
+        act.Time.UnixMilli(), act.Status, act.Error, d.Entry, d.Sink); err != nil {
+        return fmt.Errorf("failed to record action: %v", err)
+    }
+    return nil
+}
+
+// Requeue puts the dead letters f selects back in the queue with fresh
+// attempts, due now, and returns how many there were.
+func (h *History) Requeue(f DeliveryFilter) (int, error) {
+    if h.db == nil {
+        return 0, nil
+    }
+    f.Dead = true
+    where, args := f.where()
+    tx, err := h.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+    now := time.Now().UTC()
+    if _, err := tx.Exec(`UPDATE actions SET time = ?, status = ?, error = '' WHERE EXISTS (SELECT 1 FROM deliveries
+        WHERE deliveries.alert = actions.alert AND deliveries.sink = actions.sink AND `+where+`)`,
+        append([]interface{}{now.UnixMilli(), notify.ActionQueued}, args...)...); err != nil {
+        return 0, fmt.Errorf("failed to record actions: %v", err)
+    }
+    res, err := tx.Exec(`UPDATE deliveries SET attempts = 0, next = ?, error = '', dead = 0 WHERE `+where,
+        append([]interface{}{now.UnixMilli()}, args...)...)
+    if err != nil {
+        return 0, fmt.Errorf("failed to requeue deliveries: %v", err)
+    }
+    n, _ := res.RowsAffected()
+    return int(n), tx.Commit()
+}
+
+// PurgeDead deletes the dead letters f selects, leaving their alerts'
+// actions failed, and returns how many there were.
+func (h *History) PurgeDead(f DeliveryFilter) (int, error) {
+    if h.db == nil {
+        return 0, nil
+    }
+    f.Dead = true
+    where, args := f.where()
+    res, err := h.db.Exec(`DELETE FROM deliveries WHERE `+where, args...)
+    if err != nil {
+        return 0, fmt.Errorf("failed to purge deliveries: %v", err)
+    }
+    n, _ := res.RowsAffected()
+    return int(n), nil
+}
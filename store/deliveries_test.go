@@ -0,0 +1,169 @@
+package store
+
+import (
+    "errors"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// queueAlert records an alert queued for sinks, at t.
+func queueAlert(t *testing.T, h *History, at time.Time, sinks ...string) int64 {
+    t.Helper()
+    entry := HistoryEntry{Time: at, Alert: notify.Alert{ID: "a", URL: "http://phish.example/"}}
+    for _, sink := range sinks {
+        entry.Actions = append(entry.Actions, notify.Action{Time: at, Sink: sink, Status: notify.ActionQueued})
+    }
+    id, err := h.Record(entry)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return id
+}
+
+// actionFor returns the action for sink on entry id.
+func actionFor(t *testing.T, h *History, id int64, sink string) notify.Action {
+    t.Helper()
+    entry, err := h.Get(id)
+    if err != nil || entry == nil {
+        t.Fatalf("Get(%d) = %v, %v", id, entry, err)
+    }
+    for _, act := range entry.Actions {
+        if act.Sink == sink {
+            return act
+        }
+    }
+    t.Fatalf("entry %d has no action for %s", id, sink)
+    return notify.Action{}
+}
+
+func TestDeliveryQueue(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "history.db")
+    h, err := OpenHistory(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer func() { h.Close() }()
+
+    start := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+    first := queueAlert(t, h, start, "webhook", "slack")
+    second := queueAlert(t, h, start.Add(time.Second), "webhook")
+    // Delivered on the spot, so not queued
+    if _, err := h.Record(HistoryEntry{Time: start, Actions: []notify.Action{{Time: start, Sink: "log", Status: notify.ActionSent}}}); err != nil {
+        t.Fatal(err)
+    }
+
+    webhook := DeliveryFilter{Sink: "webhook"}
+    due, err := h.Deliveries(webhook, time.Now(), -1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(due) != 2 || due[0].Entry != first || due[1].Entry != second || due[0].Attempts != 0 {
+        t.Fatalf("webhook deliveries = %+v, want the two alerts in order", due)
+    }
+    if sinks, err := h.DueSinks("", time.Now()); err != nil || len(sinks) != 2 {
+        t.Errorf("DueSinks = %v, %v; want webhook and slack", sinks, err)
+    }
+
+    // A failed attempt: retried later, and the sink's other deliveries
+    // held back with it
+    next := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+    if err := h.Retry(due[0], next, errors.New("503 Service Unavailable")); err != nil {
+        t.Fatal(err)
+    }
+    if err := h.Postpone(webhook, next); err != nil {
+        t.Fatal(err)
+    }
+    if due, err := h.Deliveries(webhook, time.Now(), -1); err != nil || len(due) != 0 {
+        t.Errorf("after a failure, %d webhook deliveries due, want none", len(due))
+    }
+    if sinks, err := h.DueSinks("", time.Now()); err != nil || len(sinks) != 1 || sinks[0] != "slack" {
+        t.Errorf("DueSinks = %v, %v; want only slack", sinks, err)
+    }
+    if act := actionFor(t, h, first, "webhook"); act.Status != notify.ActionQueued || act.Error != "503 Service Unavailable" {
+        t.Errorf("action after a failed attempt = %+v", act)
+    }
+
+    // The queue survives a restart
+    h.Close()
+    if h, err = OpenHistory(path); err != nil {
+        t.Fatal(err)
+    }
+    all, err := h.Deliveries(webhook, time.Time{}, -1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(all) != 2 || all[0].Attempts != 1 || !all[0].Next.Equal(next) || all[0].Error != "503 Service Unavailable" || !all[1].Next.Equal(next) {
+        t.Fatalf("after reopening, webhook deliveries = %+v", all)
+    }
+
+    // Delivered once it is due again
+    if err := h.Delivered(all[0], notify.Action{Time: time.Now(), Sink: "webhook", Status: notify.ActionSent}); err != nil {
+        t.Fatal(err)
+    }
+    if act := actionFor(t, h, first, "webhook"); act.Status != notify.ActionSent || act.Error != "" {
+        t.Errorf("action after delivery = %+v", act)
+    }
+    if act := actionFor(t, h, first, "slack"); act.Status != notify.ActionQueued {
+        t.Errorf("another sink's action changed: %+v", act)
+    }
+
+    // Out of attempts: dead-lettered, then retried by hand, then purged
+    if err := h.DeadLetter(all[1], errors.New("410 Gone")); err != nil {
+        t.Fatal(err)
+    }
+    if n, err := h.CountDeliveries(webhook); err != nil || n != 0 {
+        t.Errorf("%d webhook deliveries pending after dead-lettering, want 0", n)
+    }
+    dead := DeliveryFilter{Sink: "webhook", Dead: true}
+    if letters, err := h.Deliveries(dead, time.Time{}, -1); err != nil || len(letters) != 1 || letters[0].Attempts != 1 || letters[0].Error != "410 Gone" {
+        t.Errorf("dead letters = %+v, %v", letters, err)
+    }
+    if act := actionFor(t, h, second, "webhook"); act.Status != notify.ActionFailed || act.Error != "410 Gone" {
+        t.Errorf("action after dead-lettering = %+v", act)
+    }
+    if n, err := h.Requeue(DeliveryFilter{Sink: "webhook"}); err != nil || n != 1 {
+        t.Fatalf("Requeue = %d, %v; want 1", n, err)
+    }
+    requeued, err := h.Deliveries(webhook, time.Now().Add(time.Second), -1)
+    if err != nil || len(requeued) != 1 || requeued[0].Attempts != 0 || requeued[0].Error != "" {
+        t.Errorf("requeued deliveries = %+v, %v; want one due with fresh attempts", requeued, err)
+    }
+    if act := actionFor(t, h, second, "webhook"); act.Status != notify.ActionQueued {
+        t.Errorf("action after requeueing = %+v", act)
+    }
+    if err := h.DeadLetter(requeued[0], errors.New("410 Gone")); err != nil {
+        t.Fatal(err)
+    }
+    if n, err := h.PurgeDead(DeliveryFilter{}); err != nil || n != 1 {
+        t.Errorf("PurgeDead = %d, %v; want 1", n, err)
+    }
+    if n, err := h.CountDeliveries(dead); err != nil || n != 0 {
+        t.Errorf("%d dead letters left after purging", n)
+    }
+}
+
+func TestDeliveriesByProfile(t *testing.T) {
+    h, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer h.Close()
+    now := time.Now()
+    queueAlert(t, h, now, "webhook")
+    if _, err := h.Record(HistoryEntry{Time: now, Alert: notify.Alert{Profile: "work"},
+        Actions: []notify.Action{{Time: now, Sink: "webhook", Status: notify.ActionQueued}}}); err != nil {
+        t.Fatal(err)
+    }
+    for _, profile := range []string{"", "work"} {
+        due, err := h.Deliveries(DeliveryFilter{Profile: profile}, now, -1)
+        if err != nil || len(due) != 1 {
+            t.Errorf("profile %q has %d deliveries, %v; want 1", profile, len(due), err)
+        }
+    }
+    if sinks, err := h.DueSinks("home", now); err != nil || len(sinks) != 0 {
+        t.Errorf("DueSinks for a profile without alerts = %v, %v", sinks, err)
+    }
+}
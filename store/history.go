@@ -0,0 +1,593 @@
+// Package store keeps what the monitor remembers: the alert history, the
+// polling state and per-chat preferences.
+package store
+
+import (
+    "database/sql"
+    "fmt"
+    "net/url"
+    "path"
+    "strings"
+    "time"
+
+    _ "modernc.org/sqlite"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// HistoryEntry is one processed message: the alert raised for its link,
+// with the analyzers' findings and verdict, and what was done with it.
+type HistoryEntry struct {
+    ID        int64           `json:"id"`
+    Time      time.Time       `json:"time"`
+    UpdateID  int64           `json:"update_id,omitempty"`
+    MessageID int64           `json:"message_id,omitempty"`
+    Text      string          `json:"text,omitempty"`
+    Alert     notify.Alert    `json:"alert"`
+    Actions   []notify.Action `json:"actions"`
+
+    // RescanOf is the ID of the entry whose link was scanned again and
+    // raised this alert, or zero if this is the first scan.
+    RescanOf int64 `json:"rescan_of,omitempty"`
+}
+
+// historySchema lists the migrations that bring a database up to date, in
+// order; PRAGMA user_version records how many have been applied.
+var historySchema = []string{
+    `CREATE TABLE alerts (
+        id         INTEGER PRIMARY KEY AUTOINCREMENT,
+        time       INTEGER NOT NULL, -- Unix milliseconds, UTC
+        alert_id   TEXT NOT NULL,
+        update_id  INTEGER NOT NULL,
+        message_id INTEGER NOT NULL,
+        chat_id    INTEGER NOT NULL,
+        chat_type  TEXT NOT NULL,
+        text       TEXT NOT NULL,
+        url        TEXT NOT NULL,
+        title      TEXT NOT NULL,
+        message    TEXT NOT NULL,
+        severity   INTEGER NOT NULL,
+        screenshot TEXT NOT NULL
+    );
+    CREATE INDEX alerts_time ON alerts (time);
+    CREATE INDEX alerts_url ON alerts (url);
+    CREATE INDEX alerts_chat ON alerts (chat_id, time);
+    CREATE TABLE findings (
+        alert       INTEGER NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+        analyzer    TEXT NOT NULL,
+        severity    INTEGER NOT NULL,
+        description TEXT NOT NULL
+    );
+    CREATE INDEX findings_alert ON findings (alert);
+    CREATE TABLE actions (
+        alert  INTEGER NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+        time   INTEGER NOT NULL,
+        sink   TEXT NOT NULL,
+        status TEXT NOT NULL,
+        error  TEXT NOT NULL
+    );
+    CREATE INDEX actions_alert ON actions (alert);`,
+    `ALTER TABLE alerts ADD COLUMN profile TEXT NOT NULL DEFAULT '';`,
+    `ALTER TABLE alerts ADD COLUMN rescan_of INTEGER NOT NULL DEFAULT 0;
+    CREATE INDEX alerts_rescan ON alerts (rescan_of);`,
+    `ALTER TABLE alerts ADD COLUMN sender TEXT NOT NULL DEFAULT '';`,
+    `CREATE TABLE deliveries (
+        id       INTEGER PRIMARY KEY AUTOINCREMENT,
+        alert    INTEGER NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+        sink     TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        next     INTEGER NOT NULL, -- Unix milliseconds of the next attempt
+        error    TEXT NOT NULL DEFAULT '',
+        dead     INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX deliveries_due ON deliveries (dead, next);
+    CREATE INDEX deliveries_sink ON deliveries (sink, dead);`,
+    `CREATE TABLE blocks (
+        domain  TEXT PRIMARY KEY,
+        mode    TEXT NOT NULL,
+        addrs   TEXT NOT NULL, -- Comma separated
+        url     TEXT NOT NULL,
+        time    INTEGER NOT NULL,
+        expires INTEGER NOT NULL -- Unix milliseconds, or 0 for never
+    );
+    CREATE INDEX blocks_expires ON blocks (expires);`,
+    `CREATE TABLE audit (
+        id      INTEGER PRIMARY KEY AUTOINCREMENT,
+        time    INTEGER NOT NULL, -- Unix milliseconds, UTC
+        actor   TEXT NOT NULL,
+        action  TEXT NOT NULL,
+        target  TEXT NOT NULL,
+        reason  TEXT NOT NULL,
+        profile TEXT NOT NULL
+    );
+    CREATE INDEX audit_time ON audit (time);
+    CREATE TRIGGER audit_no_update BEFORE UPDATE ON audit BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END;
+    CREATE TRIGGER audit_no_delete BEFORE DELETE ON audit BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END;`,
+    `ALTER TABLE alerts ADD COLUMN duration INTEGER NOT NULL DEFAULT 0; -- Of the scan, in milliseconds
+    CREATE TABLE runs (
+        alert    INTEGER NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+        analyzer TEXT NOT NULL,
+        duration INTEGER NOT NULL, -- Milliseconds
+        failed   INTEGER NOT NULL,
+        cached   INTEGER NOT NULL
+    );
+    CREATE INDEX runs_alert ON runs (alert);`,
+    `CREATE TABLE tickets (
+        tracker   TEXT NOT NULL,
+        key       TEXT NOT NULL, -- The link, or its domain
+        issue     TEXT NOT NULL,
+        opened    INTEGER NOT NULL, -- Unix milliseconds
+        seen      INTEGER NOT NULL, -- Of the last sighting
+        sightings INTEGER NOT NULL,
+        PRIMARY KEY (tracker, key)
+    );`,
+    `CREATE TABLE seen (
+        kind TEXT NOT NULL, -- message or link
+        hash TEXT NOT NULL, -- SHA-256 of the key, so no message content is kept
+        time INTEGER NOT NULL, -- Unix milliseconds it was last claimed
+        PRIMARY KEY (kind, hash)
+    );
+    CREATE INDEX seen_time ON seen (kind, time);`,
+}
+
+// History is the alert database, an SQLite file that other processes (the
+// history command, say) can read while the monitor writes to it.
+type History struct {
+    db *sql.DB
+}
+
+// OpenHistory opens or creates the database at path and brings its schema
+// up to date. An empty path disables history.
+func OpenHistory(path string) (*History, error) {
+    if path == "" {
+        return &History{}, nil
+    }
+    db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+    if err != nil {
+        return nil, fmt.Errorf("failed to open history: %v", err)
+    }
+    h := &History{db: db}
+    if err := h.migrate(); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+    }
+    return h, nil
+}
+
+// Enabled reports whether entries are kept, which an empty path turns off.
+func (h *History) Enabled() bool {
+    return h.db != nil
+}
+
+func (h *History) migrate() error {
+    var version int
+    if err := h.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+        return err
+    }
+    for ; version < len(historySchema); version++ {
+        tx, err := h.db.Begin()
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(historySchema[version]); err != nil {
+            tx.Rollback()
+            return fmt.Errorf("migration %d: %v", version+1, err)
+        }
+        // PRAGMA doesn't take parameters
+        if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+            tx.Rollback()
+            return err
+        }
+        if err := tx.Commit(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Close closes the database.
+func (h *History) Close() error {
+    if h.db == nil {
+        return nil
+    }
+    return h.db.Close()
+}
+
+// Record stores entry and returns its ID. A zero Time means now. Actions
+// with the status notify.ActionQueued are put in the delivery queue.
+func (h *History) Record(entry HistoryEntry) (int64, error) {
+    if h.db == nil {
+        return 0, nil
+    }
+    if entry.Time.IsZero() {
+        entry.Time = time.Now()
+    }
+    a := entry.Alert
+
+    tx, err := h.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+    res, err := tx.Exec(`INSERT INTO alerts (time, alert_id, update_id, message_id, chat_id, chat_type, text, url, title, message, severity, screenshot, profile, rescan_of, sender, duration)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        entry.Time.UnixMilli(), a.ID, entry.UpdateID, entry.MessageID, a.ChatID, a.ChatType, entry.Text,
+        a.URL, a.Title, a.Message, int(a.Verdict.Severity), a.Screenshot, a.Profile, entry.RescanOf, a.Sender, a.Verdict.Duration.Milliseconds())
+    if err != nil {
+        return 0, fmt.Errorf("failed to record alert: %v", err)
+    }
+    id, err := res.LastInsertId()
+    if err != nil {
+        return 0, err
+    }
+    for _, f := range a.Verdict.Findings {
+        if _, err := tx.Exec(`INSERT INTO findings (alert, analyzer, severity, description) VALUES (?, ?, ?, ?)`,
+            id, f.Analyzer, int(f.Severity), f.Description); err != nil {
+            return 0, fmt.Errorf("failed to record finding: %v", err)
+        }
+    }
+    for _, r := range a.Verdict.Runs {
+        if _, err := tx.Exec(`INSERT INTO runs (alert, analyzer, duration, failed, cached) VALUES (?, ?, ?, ?, ?)`,
+            id, r.Analyzer, r.Duration.Milliseconds(), r.Failed, r.Cached); err != nil {
+            return 0, fmt.Errorf("failed to record analyzer run: %v", err)
+        }
+    }
+    for _, act := range entry.Actions {
+        if _, err := tx.Exec(`INSERT INTO actions (alert, time, sink, status, error) VALUES (?, ?, ?, ?, ?)`,
+            id, act.Time.UnixMilli(), act.Sink, act.Status, act.Error); err != nil {
+            return 0, fmt.Errorf("failed to record action: %v", err)
+        }
+        // Queued in the same transaction, so a recorded alert is never
+        // left without its deliveries
+        if act.Status == notify.ActionQueued {
+            if _, err := tx.Exec(`INSERT INTO deliveries (alert, sink, next) VALUES (?, ?, ?)`, id, act.Sink, entry.Time.UnixMilli()); err != nil {
+                return 0, fmt.Errorf("failed to queue delivery: %v", err)
+            }
+        }
+    }
+    return id, tx.Commit()
+}
+
+// Recent returns up to limit of the newest entries, oldest first.
+func (h *History) Recent(limit int) ([]HistoryEntry, error) {
+    entries, err := h.list(`ORDER BY id DESC LIMIT ?`, limit)
+    for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+        entries[i], entries[j] = entries[j], entries[i]
+    }
+    return entries, err
+}
+
+// list returns the entries selected by the SQL that follows FROM alerts,
+// with their findings and actions.
+func (h *History) list(where string, args ...interface{}) ([]HistoryEntry, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    rows, err := h.db.Query(`SELECT id, time, alert_id, update_id, message_id, chat_id, chat_type, text, url, title, message, severity, screenshot, profile, rescan_of, sender, duration
+        FROM alerts `+where, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query history: %v", err)
+    }
+    defer rows.Close()
+
+    var entries []HistoryEntry
+    index := map[int64]int{}
+    for rows.Next() {
+        var e HistoryEntry
+        var millis, duration int64
+        var severity int
+        if err := rows.Scan(&e.ID, &millis, &e.Alert.ID, &e.UpdateID, &e.MessageID, &e.Alert.ChatID, &e.Alert.ChatType,
+            &e.Text, &e.Alert.URL, &e.Alert.Title, &e.Alert.Message, &severity, &e.Alert.Screenshot, &e.Alert.Profile, &e.RescanOf, &e.Alert.Sender, &duration); err != nil {
+            return nil, err
+        }
+        e.Time = time.UnixMilli(millis).UTC()
+        e.Alert.Verdict = analysis.Verdict{URL: e.Alert.URL, Severity: analysis.Severity(severity), Duration: time.Duration(duration) * time.Millisecond}
+        index[e.ID] = len(entries)
+        entries = append(entries, e)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    if len(entries) == 0 {
+        return entries, nil
+    }
+
+    ids := make([]string, 0, len(entries))
+    for _, e := range entries {
+        ids = append(ids, fmt.Sprint(e.ID))
+    }
+    in := "(" + strings.Join(ids, ",") + ")"
+
+    findings, err := h.db.Query(`SELECT alert, analyzer, severity, description FROM findings WHERE alert IN ` + in + ` ORDER BY rowid`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query findings: %v", err)
+    }
+    defer findings.Close()
+    for findings.Next() {
+        var id int64
+        var f analysis.Finding
+        var severity int
+        if err := findings.Scan(&id, &f.Analyzer, &severity, &f.Description); err != nil {
+            return nil, err
+        }
+        f.Severity = analysis.Severity(severity)
+        v := &entries[index[id]].Alert.Verdict
+        v.Findings = append(v.Findings, f)
+    }
+    if err := findings.Err(); err != nil {
+        return nil, err
+    }
+
+    actions, err := h.db.Query(`SELECT alert, time, sink, status, error FROM actions WHERE alert IN ` + in + ` ORDER BY rowid`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query actions: %v", err)
+    }
+    defer actions.Close()
+    for actions.Next() {
+        var id, millis int64
+        var act notify.Action
+        if err := actions.Scan(&id, &millis, &act.Sink, &act.Status, &act.Error); err != nil {
+            return nil, err
+        }
+        act.Time = time.UnixMilli(millis).UTC()
+        e := &entries[index[id]]
+        e.Actions = append(e.Actions, act)
+    }
+    return entries, actions.Err()
+}
+
+// Get returns the entry with the given ID, or nil if there is none.
+func (h *History) Get(id int64) (*HistoryEntry, error) {
+    entries, err := h.list(`WHERE id = ?`, id)
+    if err != nil || len(entries) == 0 {
+        return nil, err
+    }
+    return &entries[0], nil
+}
+
+// Flagged reports whether link was recorded with a verdict at least as
+// severe as severity. Without the database nothing was.
+func (h *History) Flagged(link string, severity analysis.Severity) (bool, error) {
+    if h.db == nil {
+        return false, nil
+    }
+    var n int
+    err := h.db.QueryRow(`SELECT COUNT(*) FROM alerts WHERE url = ? AND severity >= ?`, link, int(severity)).Scan(&n)
+    if err != nil {
+        return false, fmt.Errorf("failed to read history: %v", err)
+    }
+    return n > 0, nil
+}
+
+// TrendPoint counts the alerts of one severity on one day.
+type TrendPoint struct {
+    Day      time.Time         `json:"day"` // Midnight UTC
+    Severity analysis.Severity `json:"severity"`
+    Count    int               `json:"count"`
+}
+
+// Trend counts alerts per day and severity since the given time.
+func (h *History) Trend(since time.Time) ([]TrendPoint, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    rows, err := h.db.Query(`SELECT time / 86400000 AS day, severity, COUNT(*) FROM alerts
+        WHERE time >= ? GROUP BY day, severity ORDER BY day`, since.UnixMilli())
+    if err != nil {
+        return nil, fmt.Errorf("failed to query history: %v", err)
+    }
+    defer rows.Close()
+    var points []TrendPoint
+    for rows.Next() {
+        var day int64
+        var severity int
+        var p TrendPoint
+        if err := rows.Scan(&day, &severity, &p.Count); err != nil {
+            return nil, err
+        }
+        p.Day, p.Severity = time.UnixMilli(day*86400000).UTC(), analysis.Severity(severity)
+        points = append(points, p)
+    }
+    return points, rows.Err()
+}
+
+// Rescannable returns up to limit of the newest entries received by
+// profile's bot at or after since, with a verdict below severity, that
+// haven't been rescanned into an alert yet.
+func (h *History) Rescannable(profile string, since time.Time, severity analysis.Severity, limit int) ([]HistoryEntry, error) {
+    return h.list(`WHERE time >= ? AND severity < ? AND profile = ? AND rescan_of = 0
+        AND NOT EXISTS (SELECT 1 FROM alerts r WHERE r.rescan_of = alerts.id)
+        ORDER BY id DESC LIMIT ?`, since.UnixMilli(), int(severity), profile, limit)
+}
+
+// Since returns up to limit entries recorded at or after since, oldest
+// first.
+func (h *History) Since(since time.Time, limit int) ([]HistoryEntry, error) {
+    return h.list(`WHERE time >= ? ORDER BY id LIMIT ?`, since.UnixMilli(), limit)
+}
+
+// HistoryFilter selects history entries; its zero value selects all of
+// them.
+type HistoryFilter struct {
+    Since    time.Time           // Recorded at or after, if set
+    Until    time.Time           // Recorded before, if set
+    Chats    []int64             // Received in one of these chats, if any
+    Senders  []string            // Sent by one of these @usernames or user IDs, if any
+    Domains  []string            // Linking to one of these domains, if any; see MatchDomain
+    Severity analysis.Severity   // With a verdict at least this severe
+    Verdicts []analysis.Severity // With one of these verdicts, if any
+}
+
+// likeEscaper escapes SQL LIKE wildcards, with \ as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// where returns the SQL condition selecting filter's entries, and its
+// arguments. Domains are only narrowed down to links containing them;
+// Matches checks their hosts.
+func (f HistoryFilter) where() (string, []interface{}) {
+    conds := []string{"severity >= ?"}
+    args := []interface{}{int(f.Severity)}
+    in := func(column string, values []interface{}) {
+        if len(values) > 0 {
+            conds = append(conds, column+" IN ("+strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")+")")
+            args = append(args, values...)
+        }
+    }
+    if !f.Since.IsZero() {
+        conds, args = append(conds, "time >= ?"), append(args, f.Since.UnixMilli())
+    }
+    if !f.Until.IsZero() {
+        conds, args = append(conds, "time < ?"), append(args, f.Until.UnixMilli())
+    }
+    var chats, senders, verdicts []interface{}
+    for _, chat := range f.Chats {
+        chats = append(chats, chat)
+    }
+    for _, sender := range f.Senders {
+        senders = append(senders, sender)
+    }
+    for _, v := range f.Verdicts {
+        verdicts = append(verdicts, int(v))
+    }
+    in("chat_id", chats)
+    in("sender COLLATE NOCASE", senders)
+    in("severity", verdicts)
+    if len(f.Domains) > 0 {
+        var likes []string
+        for _, d := range f.Domains {
+            likes = append(likes, `url LIKE ? ESCAPE '\'`)
+            fragment := likeEscaper.Replace(strings.TrimPrefix(d, "*."))
+            args = append(args, "%"+strings.ReplaceAll(fragment, "*", "%")+"%")
+        }
+        conds = append(conds, "("+strings.Join(likes, " OR ")+")")
+    }
+    return strings.Join(conds, " AND "), args
+}
+
+// Matches reports whether entry's link is on one of filter's domains, or
+// true if it has none. The rest of the filter is left to the database.
+func (f HistoryFilter) Matches(entry HistoryEntry) bool {
+    if len(f.Domains) == 0 {
+        return true
+    }
+    u, err := url.Parse(entry.Alert.URL)
+    if err != nil {
+        return false
+    }
+    host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+    for _, d := range f.Domains {
+        if MatchDomain(d, host) {
+            return true
+        }
+    }
+    return false
+}
+
+// MatchDomain reports whether host is on domain. As on the allowlist and
+// blocklist, a domain covers its subdomains, with or without a leading
+// "*.", so *.example-bank.top and example-bank.top both match
+// login.example-bank.top; other wildcards match as in path.Match, so
+// paypa1-*.top matches paypa1-secure.top.
+func MatchDomain(domain, host string) bool {
+    domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+    if d := strings.TrimPrefix(domain, "*."); !strings.Contains(d, "*") {
+        return host == d || strings.HasSuffix(host, "."+d)
+    }
+    ok, _ := path.Match(domain, host)
+    return ok
+}
+
+// exportBatch is how many entries Each reads at a time.
+const exportBatch = 500
+
+// Each calls fn with every entry filter selects, oldest first, reading
+// them in batches so the whole history never has to fit in memory. It
+// stops at the first error fn returns.
+func (h *History) Each(filter HistoryFilter, fn func(HistoryEntry) error) error {
+    where, args := filter.where()
+    var after int64
+    for {
+        entries, err := h.list(`WHERE id > ? AND `+where+` ORDER BY id LIMIT ?`, append(append([]interface{}{after}, args...), exportBatch)...)
+        if err != nil {
+            return err
+        }
+        for _, e := range entries {
+            if !filter.Matches(e) {
+                continue
+            }
+            if err := fn(e); err != nil {
+                return err
+            }
+        }
+        if len(entries) < exportBatch {
+            return nil
+        }
+        after = entries[len(entries)-1].ID
+    }
+}
+
+// Prune deletes the entries recorded before before, along with their
+// findings, actions and deliveries, and returns how many there were and
+// the screenshots they referred to, for the caller to delete.
+func (h *History) Prune(before time.Time) (int, []string, error) {
+    if h.db == nil {
+        return 0, nil, nil
+    }
+    tx, err := h.db.Begin()
+    if err != nil {
+        return 0, nil, err
+    }
+    defer tx.Rollback()
+    screenshots, err := screenshotsBefore(tx, before)
+    if err != nil {
+        return 0, nil, err
+    }
+    res, err := tx.Exec(`DELETE FROM alerts WHERE time < ?`, before.UnixMilli())
+    if err != nil {
+        return 0, nil, fmt.Errorf("failed to prune history: %v", err)
+    }
+    n, _ := res.RowsAffected()
+    return int(n), screenshots, tx.Commit()
+}
+
+// DropScreenshots forgets the screenshots of the entries recorded before
+// before, and returns them for the caller to delete.
+func (h *History) DropScreenshots(before time.Time) ([]string, error) {
+    if h.db == nil {
+        return nil, nil
+    }
+    tx, err := h.db.Begin()
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+    screenshots, err := screenshotsBefore(tx, before)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := tx.Exec(`UPDATE alerts SET screenshot = '' WHERE time < ? AND screenshot != ''`, before.UnixMilli()); err != nil {
+        return nil, fmt.Errorf("failed to drop screenshots: %v", err)
+    }
+    return screenshots, tx.Commit()
+}
+
+// screenshotsBefore returns the screenshots of the entries recorded before
+// before. Screenshots still referred to by a later entry are left out.
+func screenshotsBefore(tx *sql.Tx, before time.Time) ([]string, error) {
+    rows, err := tx.Query(`SELECT DISTINCT screenshot FROM alerts WHERE time < ? AND screenshot != ''
+        AND screenshot NOT IN (SELECT screenshot FROM alerts WHERE time >= ?)`, before.UnixMilli(), before.UnixMilli())
+    if err != nil {
+        return nil, fmt.Errorf("failed to query screenshots: %v", err)
+    }
+    defer rows.Close()
+    var screenshots []string
+    for rows.Next() {
+        var path string
+        if err := rows.Scan(&path); err != nil {
+            return nil, err
+        }
+        screenshots = append(screenshots, path)
+    }
+    return screenshots, rows.Err()
+}
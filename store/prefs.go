@@ -0,0 +1,134 @@
+package store
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sync"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/internal/fsutil"
+)
+
+// ChatPreference controls which alerts a chat produces.
+type ChatPreference struct {
+    Muted       bool              `json:"muted,omitempty"`
+    MinSeverity analysis.Severity `json:"min_severity"`
+    Warn        bool              `json:"warn,omitempty"`     // Reply in the chat to flagged links with a warning
+    Language    string            `json:"language,omitempty"` // Assumed for messages too short to tell their language
+}
+
+// Allows reports whether an alert of severity should be delivered.
+func (p ChatPreference) Allows(severity analysis.Severity) bool {
+    return !p.Muted && severity >= p.MinSeverity
+}
+
+// DefaultChatPreferences are used for chat types without explicit
+// defaults: everything from DMs, flagged links from groups, and only
+// malicious ones from channels.
+var DefaultChatPreferences = map[string]ChatPreference{
+    "private":    {MinSeverity: analysis.SeverityClean},
+    "group":      {MinSeverity: analysis.SeveritySuspicious},
+    "supergroup": {MinSeverity: analysis.SeveritySuspicious},
+    "channel":    {MinSeverity: analysis.SeverityMalicious},
+}
+
+// ChatPreferences holds per-chat overrides on top of per-chat-type
+// defaults, and the roles granted with the /role command, persisted as
+// JSON so changes made with bot commands survive restarts.
+type ChatPreferences struct {
+    Defaults map[string]ChatPreference `json:"defaults"` // Keyed by chat type
+    Chats    map[int64]ChatPreference  `json:"chats"`
+    Roles    map[int64]string          `json:"roles,omitempty"` // Keyed by Telegram user ID
+
+    path string
+    mu   sync.Mutex
+}
+
+// LoadChatPreferences reads preferences from path. A missing file yields
+// the built-in defaults.
+func LoadChatPreferences(path string) (*ChatPreferences, error) {
+    prefs := &ChatPreferences{path: path}
+    data, err := os.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, fmt.Errorf("failed to read chat preferences: %v", err)
+    }
+    if err == nil {
+        if err := json.Unmarshal(data, prefs); err != nil {
+            return nil, fmt.Errorf("failed to parse chat preferences %s: %v", path, err)
+        }
+    }
+    if prefs.Defaults == nil {
+        prefs.Defaults = map[string]ChatPreference{}
+    }
+    for chatType, pref := range DefaultChatPreferences {
+        if _, ok := prefs.Defaults[chatType]; !ok {
+            prefs.Defaults[chatType] = pref
+        }
+    }
+    if prefs.Chats == nil {
+        prefs.Chats = map[int64]ChatPreference{}
+    }
+    return prefs, nil
+}
+
+// Replace takes over the preferences and file of fresh, for a reload.
+func (p *ChatPreferences) Replace(fresh *ChatPreferences) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.Defaults, p.Chats, p.Roles, p.path = fresh.Defaults, fresh.Chats, fresh.Roles, fresh.path
+}
+
+// For returns the effective preference for a chat.
+func (p *ChatPreferences) For(chatID int64, chatType string) ChatPreference {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if pref, ok := p.Chats[chatID]; ok {
+        return pref
+    }
+    return p.Defaults[chatType]
+}
+
+// Set overrides the preference for a chat and saves the file.
+func (p *ChatPreferences) Set(chatID int64, pref ChatPreference) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.Chats[chatID] = pref
+    return p.save()
+}
+
+// Role returns the role granted to a Telegram user, if any.
+func (p *ChatPreferences) Role(userID int64) (string, bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    role, ok := p.Roles[userID]
+    return role, ok
+}
+
+// SetRole grants role to a Telegram user, or takes their role away if it
+// is empty, and saves the file.
+func (p *ChatPreferences) SetRole(userID int64, role string) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if role == "" {
+        delete(p.Roles, userID)
+    } else {
+        if p.Roles == nil {
+            p.Roles = map[int64]string{}
+        }
+        p.Roles[userID] = role
+    }
+    return p.save()
+}
+
+// save writes the preferences to their file. p.mu is held.
+func (p *ChatPreferences) save() error {
+    data, err := json.MarshalIndent(p, "", "    ")
+    if err != nil {
+        return err
+    }
+    if err := fsutil.WriteFile(p.path, data, 0o600); err != nil {
+        return fmt.Errorf("failed to save chat preferences: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "time"
+)
+
+// Kinds of keys Claim deduplicates.
+const (
+    SeenMessage = "message" // A chat's message, so a replayed update isn't handled twice
+    SeenLink    = "link"    // A canonical link in a chat, so a repeat isn't alerted on
+)
+
+// Claim reports whether key of kind is new: not claimed within window
+// before at. A new key is recorded as claimed at at, so whoever claims it
+// next, in this process or after a restart, finds it taken. Only a hash
+// of the key is stored. Keys claimed before the window are forgotten.
+// Without the database every key is new.
+func (h *History) Claim(kind, key string, at time.Time, window time.Duration) (bool, error) {
+    if h.db == nil {
+        return true, nil
+    }
+    sum := sha256.Sum256([]byte(key))
+    since := at.Add(-window).UnixMilli()
+    // The update only happens, and counts as a change, if the claim has
+    // lapsed
+    res, err := h.db.Exec(`INSERT INTO seen (kind, hash, time) VALUES (?, ?, ?)
+        ON CONFLICT (kind, hash) DO UPDATE SET time = excluded.time WHERE seen.time < ?`,
+        kind, hex.EncodeToString(sum[:]), at.UnixMilli(), since)
+    if err != nil {
+        return false, fmt.Errorf("failed to record %s: %v", kind, err)
+    }
+    n, err := res.RowsAffected()
+    if err != nil {
+        return false, err
+    }
+    if _, err := h.db.Exec(`DELETE FROM seen WHERE kind = ? AND time < ?`, kind, since); err != nil {
+        return false, fmt.Errorf("failed to forget old %ss: %v", kind, err)
+    }
+    return n > 0, nil
+}
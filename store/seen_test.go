@@ -0,0 +1,97 @@
+package store
+
+import (
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestClaim(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "history.db")
+    h, err := OpenHistory(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer func() { h.Close() }()
+
+    start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+    window := time.Hour
+    steps := []struct {
+        name      string
+        kind, key string
+        at        time.Time
+        want      bool
+    }{
+        {"first claim", SeenMessage, "1:10", start, true},
+        {"same key again", SeenMessage, "1:10", start.Add(time.Minute), false},
+        {"another key", SeenMessage, "1:11", start.Add(time.Minute), true},
+        {"same key, another kind", SeenLink, "1:10", start.Add(time.Minute), true},
+        {"just inside the window", SeenMessage, "1:10", start.Add(window - time.Second), false},
+        {"after the window", SeenMessage, "1:10", start.Add(window + time.Second), true},
+        {"renewed by the lapsed claim", SeenMessage, "1:10", start.Add(window + time.Minute), false},
+    }
+    for _, s := range steps {
+        got, err := h.Claim(s.kind, s.key, s.at, window)
+        if err != nil {
+            t.Fatalf("%s: %v", s.name, err)
+        }
+        if got != s.want {
+            t.Errorf("%s: Claim = %v, want %v", s.name, got, s.want)
+        }
+    }
+
+    // A restart finds the claims still taken
+    h.Close()
+    if h, err = OpenHistory(path); err != nil {
+        t.Fatal(err)
+    }
+    if ok, err := h.Claim(SeenMessage, "1:11", start.Add(2*time.Minute), window); err != nil || ok {
+        t.Errorf("Claim after reopening = %v, %v; want taken", ok, err)
+    }
+}
+
+func TestClaimConcurrent(t *testing.T) {
+    h, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer h.Close()
+
+    now := time.Now()
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    won := 0
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            ok, err := h.Claim(SeenMessage, "1:10", now, time.Hour)
+            if err != nil {
+                t.Error(err)
+                return
+            }
+            if ok {
+                mu.Lock()
+                won++
+                mu.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    if won != 1 {
+        t.Errorf("%d claims won, want 1", won)
+    }
+}
+
+func TestClaimWithoutDatabase(t *testing.T) {
+    h, err := OpenHistory("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 2; i++ {
+        if ok, err := h.Claim(SeenMessage, "1:10", time.Now(), time.Hour); err != nil || !ok {
+            t.Errorf("Claim = %v, %v; want every key new", ok, err)
+        }
+    }
+}
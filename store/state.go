@@ -0,0 +1,122 @@
+package store
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+    "sync"
+
+    "github.com/hacker1337itme/telephish/internal/fsutil"
+)
+
+// State is what the poller remembers between runs.
+type State struct {
+    // Offset is the update_id after the last update taken from Telegram,
+    // which the next getUpdates confirms.
+    Offset int64 `json:"offset"`
+
+    // Pending are the updates taken from Telegram that aren't handled
+    // yet, by update_id, so that a restart finishes them even though
+    // Telegram won't send them again.
+    Pending map[int64]json.RawMessage `json:"pending,omitempty"`
+}
+
+// StateStore keeps State in a small JSON file, rewritten atomically so a
+// crash mid-write can't lose the offset.
+type StateStore struct {
+    path  string
+    mu    sync.Mutex
+    state State
+}
+
+// OpenStateStore reads the state file at path. A missing file starts from
+// scratch, and an empty path keeps the state in memory only.
+func OpenStateStore(path string) (*StateStore, error) {
+    s := &StateStore{path: path}
+    if path == "" {
+        return s, nil
+    }
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read state: %v", err)
+    }
+    if err := json.Unmarshal(data, &s.state); err != nil {
+        return nil, fmt.Errorf("failed to parse state %s: %v", path, err)
+    }
+    return s, nil
+}
+
+// Offset returns the saved update offset, 0 if none.
+func (s *StateStore) Offset() int64 {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.state.Offset
+}
+
+// SetOffset records the offset and saves the file.
+func (s *StateStore) SetOffset(offset int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.state.Offset = offset
+    return s.save()
+}
+
+// Hold records an update taken from Telegram as pending, moves the offset
+// past it and saves the file, before the next getUpdates confirms it.
+func (s *StateStore) Hold(updateID int64, update json.RawMessage) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.state.Pending == nil {
+        s.state.Pending = map[int64]json.RawMessage{}
+    }
+    s.state.Pending[updateID] = update
+    s.state.Offset = max(s.state.Offset, updateID+1)
+    return s.save()
+}
+
+// Release forgets a pending update once it is handled, and saves the file.
+func (s *StateStore) Release(updateID int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if _, ok := s.state.Pending[updateID]; !ok {
+        return nil
+    }
+    delete(s.state.Pending, updateID)
+    return s.save()
+}
+
+// PendingUpdate is an update held by Hold.
+type PendingUpdate struct {
+    ID     int64
+    Update json.RawMessage
+}
+
+// Pending returns the pending updates, oldest first.
+func (s *StateStore) Pending() []PendingUpdate {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    pending := make([]PendingUpdate, 0, len(s.state.Pending))
+    for id, update := range s.state.Pending {
+        pending = append(pending, PendingUpdate{ID: id, Update: update})
+    }
+    sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+    return pending
+}
+
+func (s *StateStore) save() error {
+    if s.path == "" {
+        return nil
+    }
+    data, err := json.Marshal(s.state)
+    if err != nil {
+        return err
+    }
+    if err := fsutil.WriteFile(s.path, data, 0o600); err != nil {
+        return fmt.Errorf("failed to save state: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+    "encoding/json"
+    "fmt"
+    "path/filepath"
+    "testing"
+)
+
+func TestStateStoreKeepsPendingUpdates(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "state.json")
+    s, err := OpenStateStore(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, id := range []int64{12, 10, 11} {
+        if err := s.Hold(id, json.RawMessage(fmt.Sprintf(`{"update_id":%d}`, id))); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := s.Release(10); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Release(99); err != nil {
+        t.Errorf("Release of an update that isn't pending: %v", err)
+    }
+
+    // A crash now leaves 11 and 12 unfinished, though Telegram has been
+    // told of everything before 13
+    s, err = OpenStateStore(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := s.Offset(); got != 13 {
+        t.Errorf("Offset = %d, want 13", got)
+    }
+    pending := s.Pending()
+    if len(pending) != 2 || pending[0].ID != 11 || pending[1].ID != 12 {
+        t.Fatalf("Pending = %+v, want 11 and 12", pending)
+    }
+    if string(pending[0].Update) != `{"update_id":11}` {
+        t.Errorf("pending update 11 = %s", pending[0].Update)
+    }
+
+    // Resuming an old update doesn't move the offset back
+    if err := s.Hold(11, pending[0].Update); err != nil {
+        t.Fatal(err)
+    }
+    if got := s.Offset(); got != 13 {
+        t.Errorf("Offset after resuming = %d, want 13", got)
+    }
+}
+
+func TestStateStoreInMemory(t *testing.T) {
+    s, err := OpenStateStore("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := s.Hold(5, json.RawMessage(`{}`)); err != nil {
+        t.Fatal(err)
+    }
+    if s.Offset() != 6 || len(s.Pending()) != 1 {
+        t.Errorf("offset %d, %d pending; want 6 and 1", s.Offset(), len(s.Pending()))
+    }
+}
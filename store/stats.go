@@ -0,0 +1,97 @@
+package store
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// Stats are operational totals over the history since a time.
+type Stats struct {
+    Since     time.Time       `json:"since"`
+    Scans     int             `json:"scans"`
+    Verdicts  map[string]int  `json:"verdicts"` // Scans by verdict
+    Days      []DayStats      `json:"days"`
+    MeanMS    float64         `json:"mean_ms"` // Mean scan time, of scans that ran the analyzers
+    MaxMS     int64           `json:"max_ms"`
+    Analyzers []AnalyzerStats `json:"analyzers"`
+}
+
+// DayStats counts one UTC day's scans.
+type DayStats struct {
+    Day      time.Time      `json:"day"`
+    Scans    int            `json:"scans"`
+    Verdicts map[string]int `json:"verdicts"`
+}
+
+// AnalyzerStats totals one analyzer's runs. For a reputation plugin, Calls
+// is what counts against the service's quota.
+type AnalyzerStats struct {
+    Analyzer string  `json:"analyzer"`
+    Runs     int     `json:"runs"`
+    Calls    int     `json:"calls"`  // Runs not answered from the cache
+    Cached   int     `json:"cached"` // Runs answered from the cache
+    Failed   int     `json:"failed"`
+    MeanMS   float64 `json:"mean_ms"`
+}
+
+// Stats totals the scans recorded at or after since.
+func (h *History) Stats(since time.Time) (*Stats, error) {
+    s := &Stats{Since: since, Verdicts: map[string]int{}, Days: []DayStats{}, Analyzers: []AnalyzerStats{}}
+    if h.db == nil {
+        return s, nil
+    }
+    rows, err := h.db.Query(`SELECT time / 86400000 AS day, severity, COUNT(*) FROM alerts
+        WHERE time >= ? GROUP BY day, severity ORDER BY day`, since.UnixMilli())
+    if err != nil {
+        return nil, fmt.Errorf("failed to query history: %v", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var day int64
+        var severity, count int
+        if err := rows.Scan(&day, &severity, &count); err != nil {
+            return nil, err
+        }
+        t := time.UnixMilli(day * 86400000).UTC()
+        if len(s.Days) == 0 || !s.Days[len(s.Days)-1].Day.Equal(t) {
+            s.Days = append(s.Days, DayStats{Day: t, Verdicts: map[string]int{}})
+        }
+        d := &s.Days[len(s.Days)-1]
+        name := analysis.Severity(severity).String()
+        d.Scans += count
+        d.Verdicts[name] += count
+        s.Scans += count
+        s.Verdicts[name] += count
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    var mean *float64
+    var max *int64
+    if err := h.db.QueryRow(`SELECT AVG(duration), MAX(duration) FROM alerts WHERE time >= ? AND duration > 0`,
+        since.UnixMilli()).Scan(&mean, &max); err != nil {
+        return nil, fmt.Errorf("failed to query history: %v", err)
+    }
+    if mean != nil {
+        s.MeanMS, s.MaxMS = *mean, *max
+    }
+
+    runs, err := h.db.Query(`SELECT analyzer, COUNT(*), SUM(cached), SUM(failed), AVG(duration) FROM runs
+        WHERE alert IN (SELECT id FROM alerts WHERE time >= ?) GROUP BY analyzer ORDER BY analyzer`, since.UnixMilli())
+    if err != nil {
+        return nil, fmt.Errorf("failed to query analyzer runs: %v", err)
+    }
+    defer runs.Close()
+    for runs.Next() {
+        var a AnalyzerStats
+        if err := runs.Scan(&a.Analyzer, &a.Runs, &a.Cached, &a.Failed, &a.MeanMS); err != nil {
+            return nil, err
+        }
+        a.Calls = a.Runs - a.Cached
+        s.Analyzers = append(s.Analyzers, a)
+    }
+    return s, runs.Err()
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+    "database/sql"
+    "fmt"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+)
+
+// Summary totals the alerts recorded over a period, for reports.
+type Summary struct {
+    Since    time.Time      `json:"since"`
+    Until    time.Time      `json:"until"`
+    Total    int            `json:"total"`
+    Verdicts map[string]int `json:"verdicts"` // Alerts by verdict
+    Domains  []Tally        `json:"domains"`  // Domains most often linked to by suspicious or malicious links
+    Chats    []Tally        `json:"chats"`    // Chats most often sent them, by chat ID
+    Days     []TrendPoint   `json:"days"`
+}
+
+// Tally counts the alerts for one domain or chat.
+type Tally struct {
+    Name  string `json:"name"`
+    Count int    `json:"count"`
+}
+
+// Summarize totals the alerts recorded at or after since and before until,
+// listing at most top domains and chats.
+func (h *History) Summarize(since, until time.Time, top int) (*Summary, error) {
+    s := &Summary{Since: since, Until: until, Verdicts: map[string]int{}, Domains: []Tally{}, Chats: []Tally{}, Days: []TrendPoint{}}
+    if h.db == nil {
+        return s, nil
+    }
+    period := []interface{}{since.UnixMilli(), until.UnixMilli()}
+    query := func(q string, args []interface{}, scan func(*sql.Rows) error) error {
+        rows, err := h.db.Query(q, args...)
+        if err != nil {
+            return fmt.Errorf("failed to query history: %v", err)
+        }
+        defer rows.Close()
+        for rows.Next() {
+            if err := scan(rows); err != nil {
+                return err
+            }
+        }
+        return rows.Err()
+    }
+
+    err := query(`SELECT time / 86400000 AS day, severity, COUNT(*) FROM alerts
+        WHERE time >= ? AND time < ? GROUP BY day, severity ORDER BY day`, period, func(r *sql.Rows) error {
+        var day int64
+        var severity int
+        var p TrendPoint
+        if err := r.Scan(&day, &severity, &p.Count); err != nil {
+            return err
+        }
+        p.Day, p.Severity = time.UnixMilli(day*86400000).UTC(), analysis.Severity(severity)
+        s.Days = append(s.Days, p)
+        s.Verdicts[p.Severity.String()] += p.Count
+        s.Total += p.Count
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    targeted := append(period, int(analysis.SeveritySuspicious))
+    err = query(`SELECT chat_id, COUNT(*) AS n FROM alerts WHERE time >= ? AND time < ? AND severity >= ?
+        GROUP BY chat_id ORDER BY n DESC, chat_id LIMIT ?`, append(targeted, top), func(r *sql.Rows) error {
+        var chat int64
+        var t Tally
+        if err := r.Scan(&chat, &t.Count); err != nil {
+            return err
+        }
+        t.Name = strconv.FormatInt(chat, 10)
+        s.Chats = append(s.Chats, t)
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    // Hosts aren't stored apart from their links, so they are counted here
+    domains := map[string]int{}
+    err = query(`SELECT url FROM alerts WHERE time >= ? AND time < ? AND severity >= ?`, targeted, func(r *sql.Rows) error {
+        var link string
+        if err := r.Scan(&link); err != nil {
+            return err
+        }
+        if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+            domains[strings.ToLower(u.Hostname())]++
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    for name, count := range domains {
+        s.Domains = append(s.Domains, Tally{Name: name, Count: count})
+    }
+    sort.Slice(s.Domains, func(i, j int) bool {
+        if s.Domains[i].Count != s.Domains[j].Count {
+            return s.Domains[i].Count > s.Domains[j].Count
+        }
+        return s.Domains[i].Name < s.Domains[j].Name
+    })
+    if len(s.Domains) > top {
+        s.Domains = s.Domains[:top]
+    }
+    return s, nil
+}
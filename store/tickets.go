@@ -0,0 +1,43 @@
+package store
+
+import (
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// OpenTicket returns the issue opened in tracker for key since then, if
+// there is one. Without the database no tickets are found, so each
+// sighting opens one.
+func (h *History) OpenTicket(tracker, key string, since time.Time) (string, bool, error) {
+    if h.db == nil {
+        return "", false, nil
+    }
+    var issue string
+    err := h.db.QueryRow(`SELECT issue FROM tickets WHERE tracker = ? AND key = ? AND opened >= ?`, tracker, key, since.UnixMilli()).Scan(&issue)
+    if err == sql.ErrNoRows {
+        return "", false, nil
+    } else if err != nil {
+        return "", false, fmt.Errorf("failed to query tickets: %v", err)
+    }
+    return issue, true, nil
+}
+
+// SaveTicket records a sighting of key filed under issue: a new ticket
+// if issue isn't the one recorded for key, or another sighting if it is.
+func (h *History) SaveTicket(tracker, key, issue string, at time.Time) error {
+    if h.db == nil {
+        return nil
+    }
+    _, err := h.db.Exec(`INSERT INTO tickets (tracker, key, issue, opened, seen, sightings) VALUES (?, ?, ?, ?, ?, 1)
+        ON CONFLICT (tracker, key) DO UPDATE SET
+            opened = CASE WHEN issue = excluded.issue THEN opened ELSE excluded.opened END,
+            sightings = CASE WHEN issue = excluded.issue THEN sightings + 1 ELSE 1 END,
+            issue = excluded.issue,
+            seen = excluded.seen`,
+        tracker, key, issue, at.UnixMilli(), at.UnixMilli())
+    if err != nil {
+        return fmt.Errorf("failed to record ticket: %v", err)
+    }
+    return nil
+}
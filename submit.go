@@ -0,0 +1,151 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "time"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/i18n"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// submitTimeout bounds a submission made by hand or from a toast button.
+const submitTimeout = time.Minute
+
+// newDefender builds the Defender sink from its settings.
+func newDefender(d DefenderConfig) *notify.DefenderNotifier {
+    defender := notify.NewDefenderNotifier(d.TenantID, d.ClientID, d.ClientSecret)
+    defender.Category, defender.Indicators, defender.Expiry = d.Category, d.Indicators, d.Expiry
+    return defender
+}
+
+// SubmitURI returns the protocol link behind the toast button that
+// reports link to Microsoft.
+func SubmitURI(link string) string {
+    return ProtocolURI("submit", link)
+}
+
+// submitLink reports link to Microsoft with the sinks.defender settings,
+// whatever its verdict, and records who did in the audit log.
+func submitLink(ctx context.Context, cfg *Config, actor, link string) error {
+    d := cfg.Sinks.Defender
+    if d.TenantID == "" {
+        return fmt.Errorf("sinks.defender: set tenant_id, client_id and client_secret to submit links")
+    }
+    ctx, cancel := context.WithTimeout(ctx, submitTimeout)
+    defer cancel()
+    if err := newDefender(d).Submit(ctx, link); err != nil {
+        return err
+    }
+    appLog.Info("submitted link to Microsoft", "url", link, "indicator", d.Indicators)
+    auditConfigured(cfg, store.AuditEvent{Actor: actor, Action: "submit", Target: link})
+    return nil
+}
+
+// checkFlagged refuses link unless the monitor found it malicious. Any
+// web page or document can open a telephish: link, not only the toast
+// button, so the link it carries can't be taken on trust.
+func checkFlagged(cfg *Config, link string) error {
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        return err
+    }
+    defer history.Close()
+    if !history.Enabled() {
+        return fmt.Errorf("history: needed to check that the link was found malicious")
+    }
+    flagged, err := history.Flagged(link, analysis.SeverityMalicious)
+    if err != nil {
+        return err
+    }
+    if !flagged {
+        return fmt.Errorf("%s has no malicious alert; submit it with the submit command if it should be", notify.Defang(link))
+    }
+    return nil
+}
+
+// submitCommand reports a link to Microsoft by hand, such as one a person
+// has confirmed that the analyzers only found suspicious.
+func submitCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("submit")
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        return fmt.Errorf("usage: %s submit <url>", os.Args[0])
+    }
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    if err := submitLink(ctx, cfg, cliActor(), fs.Arg(0)); err != nil {
+        return err
+    }
+    fmt.Printf("submitted %s to Microsoft\n", notify.Defang(fs.Arg(0)))
+    return nil
+}
+
+// protocolCommand runs the action behind a toast button's telephish:
+// link. Windows starts it without a console, so failures, and how a
+// submission went, are shown as a toast.
+func protocolCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("protocol")
+    fs.Parse(args)
+    if fs.NArg() != 1 {
+        return fmt.Errorf("usage: %s protocol <%s: link>", os.Args[0], ProtocolScheme)
+    }
+    action, link, err := ParseProtocolURI(fs.Arg(0))
+    if err != nil {
+        return err
+    }
+    if action == "sandbox" {
+        return OpenInSandbox(link)
+    }
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    switch action {
+    case "open":
+        if err := SafeOpen(cfg.SafeOpen, link); err != nil {
+            showResult(ctx, cfg, "open.title", "open.failed", link, err)
+            return err
+        }
+        return nil
+    case "submit":
+        err = checkFlagged(cfg, link)
+        if err == nil {
+            err = submitLink(ctx, cfg, ActorToast, link)
+        }
+        done := "submit.done"
+        if err != nil {
+            done = "submit.failed"
+        }
+        showResult(ctx, cfg, "submit.title", done, link, err)
+        return err
+    }
+    return fmt.Errorf("unknown %s: action %q", ProtocolScheme, action)
+}
+
+// showResult tells whoever pressed a toast button how it went, with the
+// translated title and message, given err if there is one.
+func showResult(ctx context.Context, cfg *Config, title, message, link string, err error) {
+    if !ToastsSupported() {
+        return
+    }
+    loc, lerr := i18n.NewLocalizer(cfg.Locale)
+    if lerr != nil {
+        return
+    }
+    if err != nil {
+        message = loc.T(message, err)
+    } else {
+        message = loc.T(message)
+    }
+    toastXML := fmt.Sprintf(`<toast><visual><binding template='ToastGeneric'><text>%s</text><text>%s</text><text>%s</text></binding></visual></toast>`,
+        notify.EscapeXML(loc.T(title)), notify.EscapeXML(message), notify.EscapeXML(notify.Defang(link)))
+    if err := ShowNotification(ctx, toastXML, "", nil); err != nil {
+        appLog.Warn("failed to show the result of a toast button", "err", err)
+    }
+}
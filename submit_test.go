@@ -0,0 +1,50 @@
+package telephish
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+func TestCheckFlagged(t *testing.T) {
+    cfg := DefaultConfig()
+    cfg.History = filepath.Join(t.TempDir(), "history.db")
+    history, err := store.OpenHistory(cfg.History)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for link, severity := range map[string]analysis.Severity{
+        "http://phish.example/login": analysis.SeverityMalicious,
+        "http://odd.example/":        analysis.SeveritySuspicious,
+    } {
+        alert := notify.Alert{URL: link}
+        alert.Verdict.Severity = severity
+        if _, err := history.Record(store.HistoryEntry{Alert: alert}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    history.Close()
+
+    tests := []struct {
+        link string
+        ok   bool
+    }{
+        {"http://phish.example/login", true},
+        {"http://odd.example/", false},
+        {"http://phish.example/login?x", false},
+        {"https://anything.example/", false},
+    }
+    for _, tt := range tests {
+        if err := checkFlagged(&cfg, tt.link); (err == nil) != tt.ok {
+            t.Errorf("checkFlagged(%q) = %v, want ok %v", tt.link, err, tt.ok)
+        }
+    }
+
+    cfg.History = ""
+    if err := checkFlagged(&cfg, "http://phish.example/login"); err == nil {
+        t.Errorf("checkFlagged without history succeeded")
+    }
+}
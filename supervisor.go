@@ -0,0 +1,163 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "runtime/debug"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// Backoff between restarts of a failed component: it doubles from
+// minRestartDelay up to maxRestartDelay, and starts over once the
+// component has kept running for stableAfter.
+const (
+    minRestartDelay = time.Second
+    maxRestartDelay = time.Minute
+    stableAfter     = 5 * time.Minute
+)
+
+// ComponentStatus is what the supervisor knows about one component, such
+// as the poller, the workers or a sink.
+type ComponentStatus struct {
+    // State is running, restarting or stopped for a component the
+    // supervisor restarts, and empty for one it only recovers panics in.
+    State       string    `json:"state,omitempty"`
+    Restarts    int       `json:"restarts"`
+    Panics      int       `json:"panics"`
+    LastError   string    `json:"last_error,omitempty"`
+    LastFailure time.Time `json:"last_failure,omitempty"`
+}
+
+// supervisor keeps the monitor's components going: it recovers their
+// panics, so a nil pointer in one sink or message doesn't kill the whole
+// monitor, restarts long-running ones with backoff, and keeps their status
+// for the health checks. The zero value is ready to use.
+type supervisor struct {
+    mu         sync.Mutex
+    components map[string]*ComponentStatus
+}
+
+// component returns name's status, creating it. s.mu must be held.
+func (s *supervisor) component(name string) *ComponentStatus {
+    if s.components == nil {
+        s.components = map[string]*ComponentStatus{}
+    }
+    c, ok := s.components[name]
+    if !ok {
+        c = &ComponentStatus{}
+        s.components[name] = c
+    }
+    return c
+}
+
+// failed records that name failed with err, counting a panic if it was
+// one.
+func (s *supervisor) failed(name string, err error, panicked bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    c := s.component(name)
+    c.LastError, c.LastFailure = err.Error(), time.Now()
+    if panicked {
+        c.Panics++
+    }
+}
+
+// setState records the state of a restarted component.
+func (s *supervisor) setState(name, state string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    c := s.component(name)
+    if state == "running" && c.State == "restarting" {
+        c.Restarts++
+    }
+    c.State = state
+}
+
+// protect calls fn, recovering a panic in it as an error that is logged
+// with its stack and counted against component.
+func (s *supervisor) protect(component string, fn func()) (err error) {
+    defer func() {
+        if v := recover(); v != nil {
+            err = fmt.Errorf("panic: %v", v)
+            appLog.Error("recovered from panic", "component", component, "panic", v, "stack", string(debug.Stack()))
+            s.failed(component, err, true)
+        }
+    }()
+    fn()
+    return nil
+}
+
+// run calls fn until it returns nil or ctx is done, restarting it with
+// backoff whenever it fails or panics.
+func (s *supervisor) run(ctx context.Context, component string, fn func(context.Context) error) error {
+    delay := minRestartDelay
+    for {
+        started := time.Now()
+        s.setState(component, "running")
+        var err error
+        if perr := s.protect(component, func() { err = fn(ctx) }); perr != nil {
+            err = perr
+        } else if err != nil {
+            s.failed(component, err, false)
+        }
+        if err == nil || ctx.Err() != nil {
+            s.setState(component, "stopped")
+            return err
+        }
+        s.setState(component, "restarting")
+
+        if time.Since(started) > stableAfter {
+            delay = minRestartDelay
+        }
+        appLog.Error("component failed; restarting", "component", component, "err", err, "delay", delay)
+        select {
+        case <-ctx.Done():
+            return nil
+        case <-time.After(delay):
+        }
+        if delay *= 2; delay > maxRestartDelay {
+            delay = maxRestartDelay
+        }
+    }
+}
+
+// status returns a copy of every component's status, and a problem for
+// each one waiting to restart, which leaves what failed to its status.
+func (s *supervisor) status() (map[string]ComponentStatus, []string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if len(s.components) == 0 {
+        return nil, nil
+    }
+    statuses := make(map[string]ComponentStatus, len(s.components))
+    var down []string
+    for name, c := range s.components {
+        statuses[name] = *c
+        if c.State == "restarting" {
+            down = append(down, name+" is restarting")
+        }
+    }
+    sort.Strings(down)
+    return statuses, down
+}
+
+// supervisedSink delivers alerts to a sink, turning a panic in it into a
+// failed delivery.
+type supervisedSink struct {
+    name string
+    next notify.Notifier
+    sup  *supervisor
+}
+
+// Notify delivers the alert to the sink.
+func (s supervisedSink) Notify(ctx context.Context, alert notify.Alert) error {
+    var err error
+    if perr := s.sup.protect("sink "+s.name, func() { err = s.next.Notify(ctx, alert) }); perr != nil {
+        return perr
+    }
+    return err
+}
@@ -0,0 +1,119 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "text/template"
+    "time"
+)
+
+// SdNotify sends a state such as "READY=1" to systemd. It does nothing
+// when the process was not started by a Type=notify unit.
+func SdNotify(state string) error {
+    socket := os.Getenv("NOTIFY_SOCKET")
+    if socket == "" {
+        return nil
+    }
+    // A leading @ means an abstract socket
+    if strings.HasPrefix(socket, "@") {
+        socket = "\x00" + socket[1:]
+    }
+    conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+    if err != nil {
+        return fmt.Errorf("failed to reach systemd: %v", err)
+    }
+    defer conn.Close()
+    _, err = conn.Write([]byte(state))
+    return err
+}
+
+// WatchdogInterval returns how often systemd expects a watchdog ping, or 0
+// if the unit has no WatchdogSec.
+func WatchdogInterval() time.Duration {
+    usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+    if err != nil || usec <= 0 {
+        return 0
+    }
+    if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+        return 0
+    }
+    return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog pings the systemd watchdog at half its interval for as long
+// as healthy reports true, so a wedged poller gets the unit restarted.
+func StartWatchdog(healthy func() bool) {
+    interval := WatchdogInterval()
+    if interval == 0 {
+        return
+    }
+    go func() {
+        ticker := time.NewTicker(interval / 2)
+        defer ticker.Stop()
+        for range ticker.C {
+            if !healthy() {
+                systemdLog.Warn("monitor is not making progress; withholding watchdog ping")
+                continue
+            }
+            if err := SdNotify("WATCHDOG=1"); err != nil {
+                systemdLog.Error("failed to ping watchdog", "err", err)
+            }
+        }
+    }()
+}
+
+// unitTemplate is the systemd unit written by `systemd-unit`.
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description={{.AppName}} phishing link monitor
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStart={{.Exe}} {{.Command}}{{if .Config}} --config {{.Config}}{{end}}
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5
+WatchdogSec={{.Watchdog}}
+User={{.User}}
+Environment=TELEPHISH_HEADLESS=1
+StateDirectory=telephish
+RuntimeDirectory=telephish
+WorkingDirectory=/var/lib/telephish
+NoNewPrivileges=yes
+ProtectSystem=strict
+ProtectHome=yes
+PrivateTmp=yes
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+func systemdUnitCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("systemd-unit")
+    webhook := fs.Bool("webhook", false, "run in webhook mode instead of polling")
+    user := fs.String("user", "telephish", "user the service runs as")
+    fs.Parse(args)
+
+    exe, err := os.Executable()
+    if err != nil {
+        return fmt.Errorf("failed to locate executable: %v", err)
+    }
+    command := "run"
+    if *webhook {
+        command = "webhook"
+    }
+    // Long polls last 30s, so leave the watchdog comfortable headroom
+    return unitTemplate.Execute(os.Stdout, map[string]interface{}{
+        "AppName":  AppName,
+        "Exe":      exe,
+        "Command":  command,
+        "Config":   *configPath,
+        "Watchdog": "120",
+        "User":     *user,
+    })
+}
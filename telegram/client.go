@@ -0,0 +1,56 @@
+package telegram
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "path"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+// timeout bounds each Bot API call. It allows for 30s long polls.
+const timeout = 60 * time.Second
+
+// newClient returns the client for Bot API calls, going through proxy if
+// it isn't nil.
+func newClient(proxy *url.URL) *http.Client {
+    transport := netutil.NewTransport(nil)
+    if proxy != nil {
+        transport.Proxy = http.ProxyURL(proxy)
+    }
+    return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// newRequest builds a request for a Bot API method, with query appended
+// if it isn't empty. The URL holds the bot token, so an error names only
+// the method.
+func newRequest(ctx context.Context, httpMethod, token, method, query string, body io.Reader) (*http.Request, error) {
+    endpoint := fmt.Sprintf("%s/bot%s/%s", APIURL, token, method)
+    if query != "" {
+        endpoint += "?" + query
+    }
+    req, err := http.NewRequestWithContext(ctx, httpMethod, endpoint, body)
+    return req, scrubURL(err, method)
+}
+
+// do sends a request built by newRequest. Its errors, which end up in logs,
+// /healthz and heartbeats, name the method instead of the URL.
+func do(req *http.Request) (*http.Response, error) {
+    resp, err := client.Do(req)
+    return resp, scrubURL(err, path.Base(req.URL.Path))
+}
+
+// scrubURL replaces the URL of a *url.Error in err with the Bot API
+// method, so the token in it goes no further.
+func scrubURL(err error, method string) error {
+    var uerr *url.Error
+    if errors.As(err, &uerr) {
+        uerr.URL = method
+    }
+    return err
+}
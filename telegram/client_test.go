@@ -0,0 +1,36 @@
+package telegram
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestErrorsLeaveOutToken(t *testing.T) {
+    const token = "123456:SECRET-TOKEN"
+    closed := httptest.NewServer(http.NotFoundHandler())
+    closed.Close()
+    defer func(u string) { APIURL = u }(APIURL)
+
+    for _, base := range []string{closed.URL, "http://bad host"} {
+        APIURL = base
+        calls := map[string]error{}
+        _, calls["getUpdates"] = GetUpdates(context.Background(), token, 0, 0)
+        calls["sendMessage"] = SendMessage(context.Background(), token, 1, "hi", "")
+        calls["sendDocument"] = SendDocument(context.Background(), token, 1, "a.txt", []byte("a"), "")
+        calls["setWebhook"] = SetWebhook(context.Background(), token, "https://example.com/hook", "")
+        for method, err := range calls {
+            if err == nil {
+                t.Fatalf("%s at %s: no error", method, base)
+            }
+            if strings.Contains(err.Error(), "SECRET") {
+                t.Errorf("%s at %s: error has the token: %v", method, base, err)
+            }
+            if !strings.Contains(err.Error(), method) {
+                t.Errorf("%s at %s: error doesn't name the method: %v", method, base, err)
+            }
+        }
+    }
+}
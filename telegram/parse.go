@@ -0,0 +1,244 @@
+package telegram
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// Kinds of ParseError.
+const (
+    ParseSyntax       = "syntax"        // Not JSON
+    ParseTruncated    = "truncated"     // JSON cut off before its end
+    ParseTypeMismatch = "type_mismatch" // A field of a type other than the Bot API's
+    ParseMissing      = "missing"       // A field every update of its kind has
+    ParseUnknownField = "unknown_field" // A field the monitor would want but doesn't read
+    ParseUnknownValue = "unknown_value" // A type of update, chat or entity the monitor doesn't know
+)
+
+// snippetLen is how much of a payload a ParseError keeps.
+const snippetLen = 200
+
+// ParseError is a Bot API payload that doesn't match what the monitor
+// knows of the Bot API, saying what didn't and where.
+type ParseError struct {
+    Kind    string
+    Path    string // Where in the update, e.g. "message.entities[2].type"; empty for the whole
+    Detail  string
+    Snippet string // The payload around the problem, cut to snippetLen bytes
+}
+
+func (e *ParseError) Error() string {
+    if e.Path == "" {
+        return fmt.Sprintf("update: %s: %s", e.Kind, e.Detail)
+    }
+    return fmt.Sprintf("update: %s at %s: %s", e.Kind, e.Path, e.Detail)
+}
+
+// LogAttrs returns the error as log attributes, with the snippet as the
+// payload attribute, which log redaction masks as message text.
+func (e *ParseError) LogAttrs() []interface{} {
+    return []interface{}{"kind", e.Kind, "path", e.Path, "detail", e.Detail, "payload", e.Snippet}
+}
+
+// updateKinds are the fields an update may carry besides update_id, as of
+// Bot API 7.x. Only message is read; the others are known and ignored.
+var updateKinds = map[string]bool{
+    "message": true, "edited_message": true, "channel_post": true, "edited_channel_post": true,
+    "business_connection": true, "business_message": true, "edited_business_message": true, "deleted_business_messages": true,
+    "message_reaction": true, "message_reaction_count": true, "inline_query": true, "chosen_inline_result": true,
+    "callback_query": true, "shipping_query": true, "pre_checkout_query": true, "purchased_paid_media": true,
+    "poll": true, "poll_answer": true, "my_chat_member": true, "chat_member": true, "chat_join_request": true,
+    "chat_boost": true, "removed_chat_boost": true,
+}
+
+// unreadMessageFields are fields of a message that can carry links the
+// monitor doesn't scan: captions of media, and the buttons under a bot's
+// message.
+var unreadMessageFields = []string{"caption", "caption_entities", "reply_markup"}
+
+// chatTypes are the types of chat the Bot API has.
+var chatTypes = map[string]bool{"private": true, "group": true, "supergroup": true, "channel": true}
+
+// entityTypes are the types of message entity the Bot API has.
+var entityTypes = map[string]bool{
+    "mention": true, "hashtag": true, "cashtag": true, "bot_command": true, "url": true, "email": true,
+    "phone_number": true, "bold": true, "italic": true, "underline": true, "strikethrough": true, "spoiler": true,
+    "blockquote": true, "expandable_blockquote": true, "code": true, "pre": true, "text_link": true,
+    "text_mention": true, "custom_emoji": true,
+}
+
+// ParseUpdate decodes one update as the Bot API sends it. It fails with a
+// *ParseError if raw isn't JSON, is cut short or has no positive
+// update_id. Other problems don't stop it, so that a new Bot API version
+// loses as little as it can: a field of the wrong type is left zero, and
+// it, anything the monitor would want but doesn't read and any unknown
+// type of update, chat or entity are listed in the update's Problems for
+// the caller to report.
+func ParseUpdate(raw []byte) (Update, error) {
+    var update Update
+    if err := json.Unmarshal(raw, &update); err != nil {
+        var syntax *json.SyntaxError
+        var mismatch *json.UnmarshalTypeError
+        switch {
+        case errors.As(err, &syntax) && strings.HasPrefix(syntax.Error(), "unexpected end of JSON"):
+            return update, &ParseError{Kind: ParseTruncated, Detail: fmt.Sprintf("payload ends after %d bytes", len(raw)), Snippet: snippet(raw, int64(len(raw)))}
+        case errors.As(err, &syntax):
+            return update, &ParseError{Kind: ParseSyntax, Detail: syntax.Error(), Snippet: snippet(raw, syntax.Offset)}
+        case errors.As(err, &mismatch) && mismatch.Field == "update_id":
+            return update, &ParseError{Kind: ParseTypeMismatch, Path: "update_id", Detail: fmt.Sprintf("want a number, got a JSON %s", mismatch.Value), Snippet: snippet(raw, mismatch.Offset)}
+        case errors.As(err, &mismatch):
+            // The rest was still decoded
+            update.Problems = append(update.Problems, &ParseError{
+                Kind:    ParseTypeMismatch,
+                Path:    mismatch.Field,
+                Detail:  fmt.Sprintf("want %s, got a JSON %s", mismatch.Type, mismatch.Value),
+                Snippet: snippet(raw, mismatch.Offset),
+            })
+        default:
+            return update, &ParseError{Kind: ParseSyntax, Detail: err.Error(), Snippet: snippet(raw, 0)}
+        }
+    }
+
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(raw, &fields); err != nil {
+        return update, &ParseError{Kind: ParseTypeMismatch, Detail: "want an object", Snippet: snippet(raw, 0)}
+    }
+    if _, ok := fields["update_id"]; !ok {
+        return update, &ParseError{Kind: ParseMissing, Path: "update_id", Detail: "every update has one", Snippet: snippet(raw, 0)}
+    }
+    if update.UpdateID <= 0 {
+        // null decodes as 0 without complaint, and would reset the offset
+        return update, &ParseError{Kind: ParseTypeMismatch, Path: "update_id", Detail: fmt.Sprintf("want a positive number, got %s", fields["update_id"]), Snippet: snippet(raw, 0)}
+    }
+    for name, value := range fields {
+        if name != "update_id" && !updateKinds[name] {
+            update.problem(ParseUnknownValue, name, "a type of update this version doesn't know", value)
+        }
+    }
+    if update.Message != nil {
+        update.checkMessage(fields["message"])
+    }
+    return update, nil
+}
+
+// checkMessage lists the problems with the message of u, raw as sent.
+func (u *Update) checkMessage(raw json.RawMessage) {
+    msg := u.Message
+    if msg.Chat == nil {
+        u.problem(ParseMissing, "message.chat", "every message has one", raw)
+    } else if !chatTypes[msg.Chat.Type] {
+        u.problem(ParseUnknownValue, "message.chat.type", fmt.Sprintf("unknown chat type %q", msg.Chat.Type), raw)
+    }
+    for i, e := range msg.Entities {
+        if !entityTypes[e.Type] {
+            u.problem(ParseUnknownValue, fmt.Sprintf("message.entities[%d].type", i), fmt.Sprintf("unknown entity type %q", e.Type), raw)
+        }
+    }
+
+    var fields map[string]json.RawMessage
+    if json.Unmarshal(raw, &fields) != nil {
+        return
+    }
+    for _, name := range unreadMessageFields {
+        if value, ok := fields[name]; ok {
+            u.problem(ParseUnknownField, "message."+name, "may carry links, which aren't scanned", value)
+        }
+    }
+}
+
+func (u *Update) problem(kind, path, detail string, raw json.RawMessage) {
+    u.Problems = append(u.Problems, &ParseError{Kind: kind, Path: path, Detail: detail, Snippet: snippet(raw, 0)})
+}
+
+// updateID reads the update_id of an update ParseUpdate refused, if it is
+// a number or a string of one. Telegram numbers updates one after another,
+// so failing that it is taken to be next, the ID after the update before
+// it, and is 0 if that isn't known either.
+func updateID(raw json.RawMessage, next int64) int64 {
+    var fields struct {
+        UpdateID json.RawMessage `json:"update_id"`
+    }
+    if json.Unmarshal(raw, &fields) == nil {
+        id, err := strconv.ParseInt(strings.Trim(string(fields.UpdateID), `"`), 10, 64)
+        if err == nil && id > 0 && id >= next {
+            return id
+        }
+    }
+    return max(next, 0)
+}
+
+// streamError returns err, from decoding a getUpdates response, as a
+// *ParseError if the response is cut short or isn't JSON. Others, such as
+// a dropped connection, are returned as they are.
+func streamError(err error, read *tail) error {
+    var syntax *json.SyntaxError
+    switch {
+    case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+        errors.As(err, &syntax) && strings.HasPrefix(syntax.Error(), "unexpected end of JSON"):
+        return &ParseError{Kind: ParseTruncated, Detail: fmt.Sprintf("response ends after %d bytes", read.n), Snippet: read.snippet()}
+    case errors.As(err, &syntax):
+        return &ParseError{Kind: ParseSyntax, Detail: syntax.Error(), Snippet: read.snippet()}
+    }
+    return err
+}
+
+// tail keeps the last snippetLen bytes read through it, which is what a
+// ParseError shows of a response that stops decoding.
+type tail struct {
+    r   io.Reader
+    n   int64
+    buf []byte
+}
+
+func (t *tail) Read(p []byte) (int, error) {
+    n, err := t.r.Read(p)
+    t.n += int64(n)
+    t.buf = append(t.buf, p[:n]...)
+    if over := len(t.buf) - snippetLen; over > 0 {
+        t.buf = append(t.buf[:0], t.buf[over:]...)
+    }
+    return n, err
+}
+
+func (t *tail) snippet() string {
+    b := t.buf
+    for len(b) > 0 && !utf8.RuneStart(b[0]) {
+        b = b[1:]
+    }
+    if t.n > int64(len(b)) {
+        return "…" + string(b)
+    }
+    return string(b)
+}
+
+// snippet returns up to snippetLen bytes of raw around offset, cut on
+// character boundaries.
+func snippet(raw []byte, offset int64) string {
+    start := int(offset) - snippetLen/2
+    if start < 0 || len(raw) <= snippetLen {
+        start = 0
+    }
+    end := start + snippetLen
+    if end > len(raw) {
+        end, start = len(raw), max(0, len(raw)-snippetLen)
+    }
+    for start > 0 && !utf8.RuneStart(raw[start]) {
+        start++
+    }
+    for end < len(raw) && !utf8.RuneStart(raw[end]) {
+        end--
+    }
+    s := string(raw[start:end])
+    if start > 0 {
+        s = "…" + s
+    }
+    if end < len(raw) {
+        s += "…"
+    }
+    return s
+}
@@ -0,0 +1,69 @@
+package telegram
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestParseUpdate(t *testing.T) {
+    tests := []struct {
+        name     string
+        raw      string
+        kind     string   // Of the error, if it fails
+        problems []string // Paths of the problems found, if it doesn't
+    }{
+        {"message", `{"update_id":7,"message":{"message_id":1,"chat":{"id":1,"type":"private"},"text":"hi","entities":[{"type":"url","offset":0,"length":2}]}}`, "", nil},
+        {"known kind of update", `{"update_id":7,"edited_message":{"message_id":1}}`, "", nil},
+        {"unknown kind of update", `{"update_id":7,"space_launch":{}}`, "", []string{"space_launch"}},
+        {"wrong type of field", `{"update_id":7,"message":{"message_id":"one","chat":{"id":1,"type":"private"}}}`, "", []string{"message.message_id"}},
+        {"no chat", `{"update_id":7,"message":{"message_id":1}}`, "", []string{"message.chat"}},
+        {"unknown chat type", `{"update_id":7,"message":{"message_id":1,"chat":{"id":1,"type":"forum"}}}`, "", []string{"message.chat.type"}},
+        {"unknown entity type", `{"update_id":7,"message":{"message_id":1,"chat":{"id":1,"type":"group"},"entities":[{"type":"hologram"}]}}`, "", []string{"message.entities[0].type"}},
+        {"caption", `{"update_id":7,"message":{"message_id":1,"chat":{"id":1,"type":"private"},"caption":"see http://a.example"}}`, "", []string{"message.caption"}},
+        {"no update_id", `{"message":{"message_id":1}}`, ParseMissing, nil},
+        {"update_id a string", `{"update_id":"7"}`, ParseTypeMismatch, nil},
+        {"update_id null", `{"update_id":null}`, ParseTypeMismatch, nil},
+        {"update_id zero", `{"update_id":0}`, ParseTypeMismatch, nil},
+        {"not an object", `[1,2]`, ParseTypeMismatch, nil},
+        {"cut short", `{"update_id":7,"mess`, ParseTruncated, nil},
+        {"not JSON", `update 7`, ParseSyntax, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            update, err := ParseUpdate([]byte(tt.raw))
+            if tt.kind != "" {
+                var perr *ParseError
+                if !errors.As(err, &perr) || perr.Kind != tt.kind {
+                    t.Fatalf("err = %v, want a %s ParseError", err, tt.kind)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("err = %v", err)
+            }
+            if update.UpdateID != 7 {
+                t.Errorf("UpdateID = %d, want 7", update.UpdateID)
+            }
+            var paths []string
+            for _, p := range update.Problems {
+                paths = append(paths, p.Path)
+            }
+            if len(paths) != len(tt.problems) || (len(paths) > 0 && paths[0] != tt.problems[0]) {
+                t.Errorf("problems at %q, want %q", paths, tt.problems)
+            }
+        })
+    }
+}
+
+func TestParseErrorSnippet(t *testing.T) {
+    raw := `{"update_id":7,"message":{"message_id":1,"text":"` + strings.Repeat("a", 1000)
+    _, err := ParseUpdate([]byte(raw))
+    var perr *ParseError
+    if !errors.As(err, &perr) {
+        t.Fatalf("err = %v, want a *ParseError", err)
+    }
+    if !strings.HasPrefix(perr.Snippet, "…") || len(strings.TrimPrefix(perr.Snippet, "…")) > snippetLen {
+        t.Errorf("snippet %q isn't the end of the payload, cut to %d bytes", perr.Snippet, snippetLen)
+    }
+}
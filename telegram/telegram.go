@@ -0,0 +1,357 @@
+// Package telegram is a small client for the parts of the Telegram Bot API
+// the monitor uses: receiving updates and sending replies.
+package telegram
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "mime/multipart"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "github.com/hacker1337itme/telephish/capture"
+)
+
+// Update represents an update from the Telegram API.
+type Update struct {
+    UpdateID int64    `json:"update_id"`
+    Message  *Message `json:"message"`
+
+    // Problems are what ParseUpdate found in the update that doesn't match
+    // the Bot API as the monitor knows it, but didn't stop it decoding.
+    Problems []*ParseError `json:"-"`
+
+    // Err is why StreamUpdates couldn't decode the update, which it passes
+    // on only to be logged and confirmed: nothing else is set, and UpdateID
+    // is as updateID reads or guesses it, or 0 if it can't.
+    Err *ParseError `json:"-"`
+}
+
+// Message represents a message in Telegram.
+type Message struct {
+    MessageID int64    `json:"message_id"`
+    From      *User    `json:"from,omitempty"` // nil in channels
+    Chat      *Chat    `json:"chat"`
+    Date      int64    `json:"date"` // Unix time it was sent
+    Text      string   `json:"text"`
+    Entities  []Entity `json:"entities"` // Entities might contain URL links
+}
+
+// User represents the sender of a message.
+type User struct {
+    ID        int64  `json:"id"`
+    IsBot     bool   `json:"is_bot"`
+    FirstName string `json:"first_name"`
+    Username  string `json:"username,omitempty"`
+}
+
+// Handle names the user for alerts and logs: @username if they have one,
+// or else their numeric ID.
+func (u *User) Handle() string {
+    if u.Username != "" {
+        return "@" + u.Username
+    }
+    return strconv.FormatInt(u.ID, 10)
+}
+
+// Chat represents the chat a message was sent in.
+type Chat struct {
+    ID    int64  `json:"id"`
+    Type  string `json:"type"`
+    Title string `json:"title,omitempty"`
+}
+
+// Entity represents the different entities in a message (e.g., URLs).
+type Entity struct {
+    Type   string `json:"type"`
+    Offset int    `json:"offset"` // In UTF-16 code units; see extract.Slice
+    Length int    `json:"length"`
+    URL    string `json:"url,omitempty"` // Only for "text_link"; a "url" entity's link is its text
+}
+
+// APIURL is where Bot API calls go. Tests point it at a fake server such
+// as telephishtest.BotAPI.
+var APIURL = "https://api.telegram.org"
+
+// client is used for all Bot API calls.
+var client = newClient(nil)
+
+// SetProxy routes Bot API calls through an HTTP proxy.
+func SetProxy(proxy string) error {
+    u, err := url.Parse(proxy)
+    if err != nil {
+        return fmt.Errorf("invalid Telegram proxy: %v", err)
+    }
+    client = newClient(u)
+    return nil
+}
+
+// UpdatesPage is the most updates one getUpdates call returns.
+const UpdatesPage = 100
+
+// GetUpdates fetches updates from the Telegram bot, starting at offset
+// (0 for all unconfirmed updates, or -n for only the last n). A positive
+// timeout long-polls for that many seconds when no updates are waiting.
+// Fetching with an offset confirms every earlier update, so Telegram won't
+// deliver them again.
+func GetUpdates(ctx context.Context, token string, offset int64, timeout int) ([]Update, error) {
+    var updates []Update
+    _, err := StreamUpdates(ctx, token, offset, 0, timeout, func(update Update) error {
+        updates = append(updates, update)
+        return nil
+    })
+    return updates, err
+}
+
+// StreamUpdates is GetUpdates for large backlogs: it asks for at most
+// limit updates (UpdatesPage if limit is 0) and decodes the response as it
+// arrives, calling fn with each update in turn, so a page never has to be
+// held in memory. It stops at the first error fn returns, and reports how
+// many updates fn was called with. An update that doesn't parse is passed
+// to fn with its Err set instead of failing the page, since Telegram would
+// otherwise send it again on every call; a response that is cut short or
+// isn't JSON fails with a *ParseError.
+func StreamUpdates(ctx context.Context, token string, offset int64, limit, timeout int, fn func(Update) error) (int, error) {
+    query := url.Values{}
+    if offset != 0 {
+        query.Set("offset", strconv.FormatInt(offset, 10))
+    }
+    if limit > 0 {
+        query.Set("limit", strconv.Itoa(limit))
+    }
+    if timeout > 0 {
+        query.Set("timeout", strconv.Itoa(timeout))
+    }
+    req, err := newRequest(ctx, http.MethodGet, token, "getUpdates", query.Encode(), nil)
+    if err != nil {
+        return 0, err
+    }
+    resp, err := do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+
+    // Each update is decoded on its own, after it is captured, so one that
+    // doesn't parse can be reproduced from the capture.
+    rec := capture.FromContext(ctx)
+    n, next := 0, offset
+    ok, okSeen, description := false, false, ""
+    read := &tail{r: resp.Body}
+    dec := json.NewDecoder(read)
+    if err := expectDelim(dec, '{', ""); err != nil {
+        return 0, streamError(err, read)
+    }
+    for dec.More() {
+        key, err := dec.Token()
+        if err != nil {
+            return n, streamError(err, read)
+        }
+        switch key {
+        case "ok":
+            err, okSeen = dec.Decode(&ok), true
+        case "description":
+            err = dec.Decode(&description)
+        case "result":
+            if okSeen && !ok {
+                // An error's result isn't a list of updates
+                err = dec.Decode(new(json.RawMessage))
+                break
+            }
+            if err := expectDelim(dec, '[', "result"); err != nil {
+                return n, streamError(err, read)
+            }
+            for dec.More() {
+                var raw json.RawMessage
+                if err := dec.Decode(&raw); err != nil {
+                    return n, streamError(err, read)
+                }
+                rec.RecordRaw("update", raw)
+                update, err := ParseUpdate(raw)
+                var perr *ParseError
+                if errors.As(err, &perr) {
+                    update = Update{UpdateID: updateID(raw, next), Err: perr}
+                }
+                if update.UpdateID > 0 {
+                    next = update.UpdateID + 1
+                }
+                if err := fn(update); err != nil {
+                    return n, err
+                }
+                n++
+            }
+            err = expectDelim(dec, ']', "result")
+        default:
+            err = dec.Decode(new(json.RawMessage))
+        }
+        if err != nil {
+            return n, streamError(err, read)
+        }
+    }
+    if !ok {
+        return n, fmt.Errorf("failed to get updates: %s", description)
+    }
+    return n, nil
+}
+
+// expectDelim reads the next JSON token, which must be delim, at path in
+// the getUpdates response.
+func expectDelim(dec *json.Decoder, delim json.Delim, path string) error {
+    tok, err := dec.Token()
+    if err != nil {
+        return err
+    }
+    if tok != delim {
+        return &ParseError{Kind: ParseTypeMismatch, Path: path, Detail: fmt.Sprintf("want %v, got %v", delim, tok)}
+    }
+    return nil
+}
+
+// SendMessage sends a text message to a chat through the Telegram bot.
+// parseMode may be empty for plain text, or "MarkdownV2".
+func SendMessage(ctx context.Context, token string, chatID int64, text, parseMode string) error {
+    return sendMessage(ctx, token, chatID, text, parseMode, nil)
+}
+
+// SendReply sends a text message to a chat in reply to one of its
+// messages, which Telegram quotes above it. If that message has been
+// deleted in the meantime, the text is sent on its own.
+func SendReply(ctx context.Context, token string, chatID, messageID int64, text, parseMode string) error {
+    reply, err := json.Marshal(map[string]interface{}{"message_id": messageID, "allow_sending_without_reply": true})
+    if err != nil {
+        return err
+    }
+    return sendMessage(ctx, token, chatID, text, parseMode, url.Values{"reply_parameters": {string(reply)}})
+}
+
+// sendMessage calls sendMessage with extra set as well.
+func sendMessage(ctx context.Context, token string, chatID int64, text, parseMode string, extra url.Values) error {
+    form := url.Values{}
+    form.Set("chat_id", strconv.FormatInt(chatID, 10))
+    form.Set("text", text)
+    if parseMode != "" {
+        form.Set("parse_mode", parseMode)
+    }
+    for k, v := range extra {
+        form[k] = v
+    }
+
+    resp, err := postForm(ctx, token, "sendMessage", form)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        Ok          bool   `json:"ok"`
+        Description string `json:"description"`
+    }
+
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return err
+    }
+
+    if !result.Ok {
+        return fmt.Errorf("failed to send message: %s", result.Description)
+    }
+
+    return nil
+}
+
+// SendDocument sends data to a chat as a file named name, with a plain
+// text caption.
+func SendDocument(ctx context.Context, token string, chatID int64, name string, data []byte, caption string) error {
+    var body bytes.Buffer
+    mw := multipart.NewWriter(&body)
+    mw.WriteField("chat_id", strconv.FormatInt(chatID, 10))
+    if caption != "" {
+        mw.WriteField("caption", caption)
+    }
+    part, err := mw.CreateFormFile("document", name)
+    if err != nil {
+        return err
+    }
+    part.Write(data)
+    if err := mw.Close(); err != nil {
+        return err
+    }
+
+    req, err := newRequest(ctx, http.MethodPost, token, "sendDocument", "", &body)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", mw.FormDataContentType())
+    resp, err := do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        Ok          bool   `json:"ok"`
+        Description string `json:"description"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return err
+    }
+    if !result.Ok {
+        return fmt.Errorf("failed to send document: %s", result.Description)
+    }
+    return nil
+}
+
+// SetWebhook asks Telegram to push updates to hookURL instead of waiting
+// for getUpdates. Telegram sends secret back in each request's
+// X-Telegram-Bot-Api-Secret-Token header.
+func SetWebhook(ctx context.Context, token, hookURL, secret string) error {
+    form := url.Values{}
+    form.Set("url", hookURL)
+    if secret != "" {
+        form.Set("secret_token", secret)
+    }
+    return callBotAPI(ctx, token, "setWebhook", form)
+}
+
+// DeleteWebhook switches the bot back to getUpdates polling.
+func DeleteWebhook(ctx context.Context, token string) error {
+    return callBotAPI(ctx, token, "deleteWebhook", url.Values{})
+}
+
+// callBotAPI calls a Bot API method that returns only ok/description.
+func callBotAPI(ctx context.Context, token, method string, form url.Values) error {
+    resp, err := postForm(ctx, token, method, form)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    var result struct {
+        Ok          bool   `json:"ok"`
+        Description string `json:"description"`
+    }
+
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return err
+    }
+
+    if !result.Ok {
+        return fmt.Errorf("%s failed: %s", method, result.Description)
+    }
+
+    return nil
+}
+
+// postForm posts form to a Bot API method.
+func postForm(ctx context.Context, token, method string, form url.Values) (*http.Response, error) {
+    req, err := newRequest(ctx, http.MethodPost, token, method, "", strings.NewReader(form.Encode()))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    return do(req)
+}
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+// serveUpdates points APIURL at a server answering getUpdates with body
+// until the test ends.
+func serveUpdates(t *testing.T, body string) {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, body)
+    }))
+    old := APIURL
+    APIURL = srv.URL
+    t.Cleanup(func() {
+        APIURL = old
+        srv.Close()
+    })
+}
+
+func TestStreamUpdatesSkipsBadUpdates(t *testing.T) {
+    serveUpdates(t, `{"ok":true,"result":[
+        {"update_id":5,"message":{"message_id":1,"chat":{"id":1,"type":"private"},"text":"hi"}},
+        {"update_id":"abc"},
+        {"message":{"message_id":2}},
+        {"update_id":"9"},
+        [],
+        {"update_id":11,"message":{"message_id":3,"chat":{"id":1,"type":"private"},"text":"hi"}}
+    ]}`)
+    var got []Update
+    n, err := StreamUpdates(context.Background(), "1:x", 5, 0, 0, func(u Update) error {
+        got = append(got, u)
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("StreamUpdates: %v", err)
+    }
+    want := []struct {
+        id  int64
+        bad bool
+    }{{5, false}, {6, true}, {7, true}, {9, true}, {10, true}, {11, false}}
+    if n != len(want) || len(got) != len(want) {
+        t.Fatalf("got %d updates (n = %d), want %d", len(got), n, len(want))
+    }
+    for i, w := range want {
+        if got[i].UpdateID != w.id || (got[i].Err != nil) != w.bad {
+            t.Errorf("update %d: ID %d, Err %v; want ID %d, bad %v", i, got[i].UpdateID, got[i].Err, w.id, w.bad)
+        }
+    }
+    if got[0].Message == nil || got[5].Message == nil {
+        t.Errorf("good updates lost their messages")
+    }
+}
+
+func TestStreamUpdatesUnknownFirstID(t *testing.T) {
+    serveUpdates(t, `{"ok":true,"result":[{"update_id":null},{"update_id":3}]}`)
+    var got []Update
+    if _, err := StreamUpdates(context.Background(), "1:x", 0, 0, 0, func(u Update) error {
+        got = append(got, u)
+        return nil
+    }); err != nil {
+        t.Fatal(err)
+    }
+    if len(got) != 2 || got[0].UpdateID != 0 || got[0].Err == nil || got[1].UpdateID != 3 || got[1].Err != nil {
+        t.Errorf("got %+v, want a bad update with ID 0 and then update 3", got)
+    }
+}
+
+func TestStreamUpdatesResponseErrors(t *testing.T) {
+    tests := []struct {
+        name, body string
+        kind       string
+        n          int
+    }{
+        {"empty", ``, ParseTruncated, 0},
+        {"cut in an update", `{"ok":true,"result":[{"update_id":1},{"upd`, ParseTruncated, 1},
+        {"cut between updates", `{"ok":true,"result":[{"update_id":1},`, ParseTruncated, 1},
+        {"cut in a key", `{"ok":true,"re`, ParseTruncated, 0},
+        {"html", `<html>Bad Gateway</html>`, ParseSyntax, 0},
+        {"garbage after an update", `{"ok":true,"result":[{"update_id":1}x]}`, ParseSyntax, 1},
+        {"result not a list", `{"ok":true,"result":{}}`, ParseTypeMismatch, 0},
+        {"not an object", `[]`, ParseTypeMismatch, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            serveUpdates(t, tt.body)
+            n, err := StreamUpdates(context.Background(), "1:x", 0, 0, 0, func(Update) error { return nil })
+            var perr *ParseError
+            if !errors.As(err, &perr) {
+                t.Fatalf("err = %v (%T), want a *ParseError", err, err)
+            }
+            if perr.Kind != tt.kind || n != tt.n {
+                t.Errorf("kind %s after %d updates, want %s after %d: %v", perr.Kind, n, tt.kind, tt.n, err)
+            }
+        })
+    }
+}
+
+func TestStreamUpdatesNotOK(t *testing.T) {
+    serveUpdates(t, `{"ok":false,"error_code":409,"description":"Conflict: can't use getUpdates method while webhook is active"}`)
+    _, err := StreamUpdates(context.Background(), "1:x", 0, 0, 0, func(Update) error { return nil })
+    var perr *ParseError
+    if err == nil || errors.As(err, &perr) {
+        t.Errorf("err = %v, want the Bot API's description", err)
+    }
+}
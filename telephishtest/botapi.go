@@ -0,0 +1,357 @@
+// Package telephishtest helps test code built on telephish without a real
+// bot token or network access: BotAPI is a fake Telegram Bot API server, and
+// the fixtures build the updates Telegram would send.
+package telephishtest
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// Token is the bot token BotAPI accepts unless another one is set.
+const Token = "123456:TEST-TOKEN"
+
+// SentMessage is a sendMessage call received by BotAPI.
+type SentMessage struct {
+    ChatID    int64
+    Text      string
+    ParseMode string
+    ReplyTo   int64 // The message it replies to, if any
+}
+
+// SentDocument is a sendDocument call received by BotAPI, such as a
+// report.
+type SentDocument struct {
+    ChatID  int64
+    Name    string
+    Caption string
+    Data    []byte
+}
+
+// BotAPI is an httptest server speaking enough of the Bot API for the
+// monitor: getUpdates serves the updates queued with Push, long-polling
+// like Telegram when none are waiting, sendMessage and sendDocument calls
+// are captured, and setWebhook and deleteWebhook are recorded. Calls with another token get
+// 401 Unauthorized, as they would from Telegram.
+type BotAPI struct {
+    Server *httptest.Server
+    Token  string
+
+    mu        sync.Mutex
+    updates   []telegram.Update // Not yet confirmed by an offset
+    nextID    int64
+    pushed    chan struct{} // Closed and replaced by Push, to wake long polls
+    sent      []SentMessage
+    sentCh    chan struct{}
+    documents []SentDocument
+    webhook   string
+    failures  map[string][]string
+    confirmed int64
+}
+
+// NewBotAPI starts a fake Bot API server. Close it when done.
+func NewBotAPI() *BotAPI {
+    b := &BotAPI{
+        Token:    Token,
+        nextID:   1,
+        pushed:   make(chan struct{}),
+        sentCh:   make(chan struct{}),
+        failures: map[string][]string{},
+    }
+    b.Server = httptest.NewServer(http.HandlerFunc(b.serve))
+    return b
+}
+
+// Use points the telegram package at the fake server, returning a function
+// that points it back.
+func (b *BotAPI) Use() (restore func()) {
+    old := telegram.APIURL
+    telegram.APIURL = b.Server.URL
+    return func() { telegram.APIURL = old }
+}
+
+// Close shuts the server down.
+func (b *BotAPI) Close() {
+    b.Server.Close()
+}
+
+// Push queues updates for getUpdates. Updates without an UpdateID are
+// numbered after the last one pushed. It returns the updates as queued.
+func (b *BotAPI) Push(updates ...telegram.Update) []telegram.Update {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for i := range updates {
+        if updates[i].UpdateID == 0 {
+            updates[i].UpdateID = b.nextID
+        }
+        if updates[i].UpdateID >= b.nextID {
+            b.nextID = updates[i].UpdateID + 1
+        }
+        b.updates = append(b.updates, updates[i])
+    }
+    close(b.pushed)
+    b.pushed = make(chan struct{})
+    return updates
+}
+
+// Fail makes the next call to method fail with description, as Telegram
+// reports errors with ok set to false. Calling it again queues more
+// failures.
+func (b *BotAPI) Fail(method, description string) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.failures[method] = append(b.failures[method], description)
+}
+
+// Pending returns the updates not yet confirmed by a getUpdates offset.
+func (b *BotAPI) Pending() []telegram.Update {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return append([]telegram.Update(nil), b.updates...)
+}
+
+// Confirmed returns the highest offset getUpdates has been called with.
+func (b *BotAPI) Confirmed() int64 {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.confirmed
+}
+
+// Sent returns the messages sent through the bot so far.
+func (b *BotAPI) Sent() []SentMessage {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return append([]SentMessage(nil), b.sent...)
+}
+
+// WaitSent waits until at least n messages have been sent, or timeout
+// passes, and returns them.
+func (b *BotAPI) WaitSent(n int, timeout time.Duration) ([]SentMessage, error) {
+    deadline := time.After(timeout)
+    for {
+        b.mu.Lock()
+        sent, wait := append([]SentMessage(nil), b.sent...), b.sentCh
+        b.mu.Unlock()
+        if len(sent) >= n {
+            return sent, nil
+        }
+        select {
+        case <-wait:
+        case <-deadline:
+            return sent, fmt.Errorf("got %d sent messages after %s, want %d", len(sent), timeout, n)
+        }
+    }
+}
+
+// Documents returns the documents sent through the bot so far.
+func (b *BotAPI) Documents() []SentDocument {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return append([]SentDocument(nil), b.documents...)
+}
+
+// Webhook returns the URL set with setWebhook, or "" if none is set.
+func (b *BotAPI) Webhook() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.webhook
+}
+
+// response is the envelope every Bot API method replies with.
+type response struct {
+    Ok          bool        `json:"ok"`
+    Result      interface{} `json:"result,omitempty"`
+    ErrorCode   int         `json:"error_code,omitempty"`
+    Description string      `json:"description,omitempty"`
+}
+
+func (b *BotAPI) serve(w http.ResponseWriter, r *http.Request) {
+    token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+    if !ok || !strings.HasPrefix(r.URL.Path, "/bot") {
+        reply(w, http.StatusNotFound, response{ErrorCode: http.StatusNotFound, Description: "Not Found"})
+        return
+    }
+    if token != b.Token {
+        reply(w, http.StatusUnauthorized, response{ErrorCode: http.StatusUnauthorized, Description: "Unauthorized"})
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+        return
+    }
+    if description, failed := b.takeFailure(method); failed {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: description})
+        return
+    }
+
+    switch method {
+    case "getUpdates":
+        b.getUpdates(w, r)
+    case "sendMessage":
+        b.sendMessage(w, r)
+    case "sendDocument":
+        b.sendDocument(w, r)
+    case "setWebhook":
+        b.mu.Lock()
+        b.webhook = r.Form.Get("url")
+        b.mu.Unlock()
+        reply(w, http.StatusOK, response{Ok: true, Result: true, Description: "Webhook was set"})
+    case "deleteWebhook":
+        b.mu.Lock()
+        b.webhook = ""
+        b.mu.Unlock()
+        reply(w, http.StatusOK, response{Ok: true, Result: true, Description: "Webhook was deleted"})
+    default:
+        reply(w, http.StatusNotFound, response{ErrorCode: http.StatusNotFound, Description: "Not Found: method not found"})
+    }
+}
+
+func (b *BotAPI) takeFailure(method string) (string, bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    queued := b.failures[method]
+    if len(queued) == 0 {
+        return "", false
+    }
+    b.failures[method] = queued[1:]
+    return queued[0], true
+}
+
+// getUpdates drops the updates confirmed by offset and returns up to limit
+// of the rest (100 by default, as Telegram does), waiting up to timeout
+// seconds for one to be pushed if there are none. A negative offset, as
+// with Telegram, confirms all but the last -offset updates.
+func (b *BotAPI) getUpdates(w http.ResponseWriter, r *http.Request) {
+    offset, _ := strconv.ParseInt(r.Form.Get("offset"), 10, 64)
+    limit, _ := strconv.Atoi(r.Form.Get("limit"))
+    if limit <= 0 || limit > telegram.UpdatesPage {
+        limit = telegram.UpdatesPage
+    }
+    timeout, _ := strconv.Atoi(r.Form.Get("timeout"))
+    deadline := time.After(time.Duration(timeout) * time.Second)
+    for {
+        b.mu.Lock()
+        if offset < 0 && len(b.updates) > 0 {
+            last := len(b.updates) + int(offset)
+            if last < 0 {
+                last = 0
+            }
+            offset = b.updates[last].UpdateID
+        }
+        if offset > b.confirmed {
+            b.confirmed = offset
+        }
+        kept := b.updates[:0]
+        for _, u := range b.updates {
+            if u.UpdateID >= b.confirmed {
+                kept = append(kept, u)
+            }
+        }
+        b.updates = kept
+        updates, wait := append([]telegram.Update{}, b.updates...), b.pushed
+        b.mu.Unlock()
+
+        if len(updates) > limit {
+            updates = updates[:limit]
+        }
+        if len(updates) > 0 || timeout <= 0 {
+            reply(w, http.StatusOK, response{Ok: true, Result: updates})
+            return
+        }
+        select {
+        case <-wait:
+        case <-deadline:
+            timeout = 0
+        case <-r.Context().Done():
+            return
+        }
+    }
+}
+
+func (b *BotAPI) sendMessage(w http.ResponseWriter, r *http.Request) {
+    chatID, err := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+    if err != nil {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: chat not found"})
+        return
+    }
+    text := r.Form.Get("text")
+    if text == "" {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: message text is empty"})
+        return
+    }
+    msg := SentMessage{ChatID: chatID, Text: text, ParseMode: r.Form.Get("parse_mode")}
+    if params := r.Form.Get("reply_parameters"); params != "" {
+        var replyTo struct {
+            MessageID int64 `json:"message_id"`
+        }
+        if err := json.Unmarshal([]byte(params), &replyTo); err != nil {
+            reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: can't parse reply parameters JSON object"})
+            return
+        }
+        msg.ReplyTo = replyTo.MessageID
+    }
+
+    b.mu.Lock()
+    b.sent = append(b.sent, msg)
+    id := int64(len(b.sent) + len(b.documents))
+    close(b.sentCh)
+    b.sentCh = make(chan struct{})
+    b.mu.Unlock()
+
+    reply(w, http.StatusOK, response{Ok: true, Result: telegram.Message{
+        MessageID: id,
+        Chat:      &telegram.Chat{ID: chatID},
+        Text:      text,
+    }})
+}
+
+// sendDocument takes the document as a multipart upload, the way the
+// monitor sends reports; file_id and URL documents aren't supported.
+func (b *BotAPI) sendDocument(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseMultipartForm(32 << 20); err != nil {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+        return
+    }
+    chatID, err := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+    if err != nil {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: chat not found"})
+        return
+    }
+    file, header, err := r.FormFile("document")
+    if err != nil {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: there is no document in the request"})
+        return
+    }
+    defer file.Close()
+    data, err := io.ReadAll(file)
+    if err != nil {
+        reply(w, http.StatusBadRequest, response{ErrorCode: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+        return
+    }
+    doc := SentDocument{ChatID: chatID, Name: header.Filename, Caption: r.FormValue("caption"), Data: data}
+
+    b.mu.Lock()
+    b.documents = append(b.documents, doc)
+    id := int64(len(b.sent) + len(b.documents))
+    b.mu.Unlock()
+
+    reply(w, http.StatusOK, response{Ok: true, Result: telegram.Message{
+        MessageID: id,
+        Chat:      &telegram.Chat{ID: chatID},
+    }})
+}
+
+func reply(w http.ResponseWriter, status int, resp response) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,98 @@
+package telephishtest
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+func TestBotAPIUpdates(t *testing.T) {
+    api := NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    ctx := context.Background()
+
+    pushed := api.Push(Phishing(), Clean(), NoLink())
+    if pushed[0].UpdateID != 1 || pushed[2].UpdateID != 3 {
+        t.Fatalf("pushed update IDs %d..%d, want 1..3", pushed[0].UpdateID, pushed[2].UpdateID)
+    }
+    if pushed[0].Message.MessageID == pushed[1].Message.MessageID {
+        t.Errorf("two fixtures have message_id %d", pushed[0].Message.MessageID)
+    }
+
+    updates, err := telegram.GetUpdates(ctx, Token, 0, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(updates) != 3 {
+        t.Fatalf("got %d updates, want 3", len(updates))
+    }
+    if e := updates[0].Message.Entities; len(e) != 1 || extract.Slice(updates[0].Message.Text, e[0].Offset, e[0].Length) != PhishingLink {
+        t.Errorf("Phishing entities = %+v, want one for %s", e, PhishingLink)
+    }
+
+    // An offset confirms the updates before it
+    if updates, err = telegram.GetUpdates(ctx, Token, 3, 0); err != nil || len(updates) != 1 {
+        t.Fatalf("after confirming 2 updates: %d updates, %v; want 1", len(updates), err)
+    }
+    if api.Confirmed() != 3 || len(api.Pending()) != 1 {
+        t.Errorf("confirmed %d with %d pending, want 3 with 1", api.Confirmed(), len(api.Pending()))
+    }
+
+    // A long poll returns when an update is pushed
+    go func() {
+        time.Sleep(50 * time.Millisecond)
+        api.Push(GroupMessage("hello"))
+    }()
+    if updates, err = telegram.GetUpdates(ctx, Token, 4, 5); err != nil || len(updates) != 1 || updates[0].UpdateID != 4 {
+        t.Fatalf("long poll got %+v, %v; want update 4", updates, err)
+    }
+
+    api.Fail("getUpdates", "Conflict: terminated by other getUpdates request")
+    if _, err := telegram.GetUpdates(ctx, Token, 5, 0); err == nil {
+        t.Errorf("GetUpdates succeeded after Fail")
+    }
+    if _, err := telegram.GetUpdates(ctx, "1:wrong", 5, 0); err == nil {
+        t.Errorf("GetUpdates succeeded with the wrong token")
+    }
+}
+
+func TestBotAPISends(t *testing.T) {
+    api := NewBotAPI()
+    defer api.Close()
+    defer api.Use()()
+    ctx := context.Background()
+
+    if err := telegram.SendMessage(ctx, Token, GroupChatID, "*alert*", "MarkdownV2"); err != nil {
+        t.Fatal(err)
+    }
+    if err := telegram.SendReply(ctx, Token, GroupChatID, 7, "careful", ""); err != nil {
+        t.Fatal(err)
+    }
+    sent, err := api.WaitSent(2, time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sent[0] != (SentMessage{ChatID: GroupChatID, Text: "*alert*", ParseMode: "MarkdownV2"}) {
+        t.Errorf("sent %+v", sent[0])
+    }
+    if sent[1].ReplyTo != 7 {
+        t.Errorf("reply sent to message %d, want 7", sent[1].ReplyTo)
+    }
+
+    if err := telegram.SendDocument(ctx, Token, PrivateChatID, "report.html", []byte("<html></html>"), "Weekly report"); err != nil {
+        t.Fatal(err)
+    }
+    docs := api.Documents()
+    if len(docs) != 1 || docs[0].ChatID != PrivateChatID || docs[0].Name != "report.html" || docs[0].Caption != "Weekly report" || string(docs[0].Data) != "<html></html>" {
+        t.Errorf("documents = %+v", docs)
+    }
+
+    api.Fail("sendDocument", "Bad Request: chat not found")
+    if err := telegram.SendDocument(ctx, Token, PrivateChatID, "report.html", nil, ""); err == nil {
+        t.Errorf("SendDocument succeeded after Fail")
+    }
+}
@@ -0,0 +1,119 @@
+package telephishtest
+
+import (
+    "regexp"
+    "sync/atomic"
+    "time"
+    "unicode/utf16"
+
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// Chat IDs used by the fixtures. Group and channel IDs are negative, as
+// Telegram's are.
+const (
+    PrivateChatID int64 = 1001
+    GroupChatID   int64 = -1002
+    ChannelChatID int64 = -1003
+)
+
+// Sender is who sends the fixtures' messages, except channel posts, which
+// have no sender.
+var Sender = telegram.User{ID: PrivateChatID, FirstName: "Test", Username: "tester"}
+
+// Canned links for the built-in url and text analyzers: CleanLink scores
+// clean, SuspiciousLink suspicious, and PhishingLink malicious in the
+// message Phishing sends it with. They use reserved names and documentation
+// addresses, so they never reach a real site; leave the page analyzer off
+// to keep tests from waiting on a fetch.
+const (
+    CleanLink      = "https://www.example.com/docs"
+    SuspiciousLink = "http://example.zip/account"
+    PhishingLink   = "http://account@203.0.113.7/login"
+)
+
+// linkPattern finds the links the fixtures mark up as url entities.
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// lastMessageID numbers the fixtures' messages, which the monitor would
+// otherwise take for one message sent again.
+var lastMessageID atomic.Int64
+
+// Message returns an update carrying text in a chat of chatType
+// ("private", "group", "supergroup" or "channel"), with a url entity for
+// every link in text, sent now. Its message_id is new to the process, and
+// Push numbers it if its UpdateID is left at zero.
+func Message(chatID int64, chatType, text string) telegram.Update {
+    msg := &telegram.Message{
+        MessageID: lastMessageID.Add(1),
+        Chat:      &telegram.Chat{ID: chatID, Type: chatType},
+        Date:      time.Now().Unix(),
+        Text:      text,
+    }
+    for _, loc := range linkPattern.FindAllStringIndex(text, -1) {
+        link := text[loc[0]:loc[1]]
+        msg.Entities = append(msg.Entities, telegram.Entity{
+            Type:   "url",
+            Offset: utf16Len(text[:loc[0]]),
+            Length: utf16Len(link),
+        })
+    }
+    if chatType != "private" {
+        msg.Chat.Title = "Test " + chatType
+    }
+    if chatType != "channel" {
+        from := Sender
+        msg.From = &from
+    }
+    return telegram.Update{Message: msg}
+}
+
+// PrivateMessage is text sent to the bot in a direct message.
+func PrivateMessage(text string) telegram.Update {
+    return Message(PrivateChatID, "private", text)
+}
+
+// GroupMessage is text posted in a group the bot is a member of.
+func GroupMessage(text string) telegram.Update {
+    return Message(GroupChatID, "group", text)
+}
+
+// ChannelPost is text posted in a channel the bot administers.
+func ChannelPost(text string) telegram.Update {
+    return Message(ChannelChatID, "channel", text)
+}
+
+// Command is a bot command such as "/mute" sent in a direct message.
+func Command(command string) telegram.Update {
+    u := PrivateMessage(command)
+    u.Message.Entities = []telegram.Entity{{Type: "bot_command", Offset: 0, Length: utf16Len(command)}}
+    return u
+}
+
+// Phishing is a direct message urging the reader to log in at
+// PhishingLink.
+func Phishing() telegram.Update {
+    return PrivateMessage("URGENT: your account will be suspended, verify your password now " + PhishingLink)
+}
+
+// Clean is a direct message with an ordinary link.
+func Clean() telegram.Update {
+    return PrivateMessage("Here are the docs: " + CleanLink)
+}
+
+// NoLink is a direct message without any link.
+func NoLink() telegram.Update {
+    return PrivateMessage("see you tomorrow")
+}
+
+// NonMessage is an update Telegram sends for something other than a new
+// message, such as an edited one, which the monitor ignores.
+func NonMessage() telegram.Update {
+    return telegram.Update{}
+}
+
+// utf16Len is the length of s in UTF-16 code units, which is how Telegram
+// measures entity offsets and lengths.
+func utf16Len(s string) int {
+    return len(utf16.Encode([]rune(s)))
+}
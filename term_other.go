@@ -0,0 +1,56 @@
+//go:build !windows
+
+package telephish
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+// disableEcho stops the terminal on f echoing input, returning a function
+// that turns it back on.
+func disableEcho(f *os.File) (restore func(), err error) {
+    stty := func(arg string) error {
+        cmd := exec.Command("stty", arg)
+        cmd.Stdin = f
+        return cmd.Run()
+    }
+    if err := stty("-echo"); err != nil {
+        return nil, err
+    }
+    return func() { stty("echo") }, nil
+}
+
+// rawTerminal makes the terminal on in pass each key through as it is
+// pressed, without echoing it, returning a function that restores it.
+// Terminals here draw the ANSI escape codes written to out as they are.
+func rawTerminal(in, out *os.File) (restore func(), err error) {
+    stty := func(args ...string) ([]byte, error) {
+        cmd := exec.Command("stty", args...)
+        cmd.Stdin = in
+        return cmd.Output()
+    }
+    saved, err := stty("-g")
+    if err != nil {
+        return nil, err
+    }
+    if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+        return nil, err
+    }
+    return func() { stty(strings.TrimSpace(string(saved))) }, nil
+}
+
+// terminalSize returns the columns and rows of the terminal on f, or zero
+// if they aren't known.
+func terminalSize(f *os.File) (cols, rows int) {
+    cmd := exec.Command("stty", "size")
+    cmd.Stdin = f
+    out, err := cmd.Output()
+    if err != nil {
+        return 0, 0
+    }
+    fmt.Sscan(string(out), &rows, &cols)
+    return cols, rows
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package telephish
+
+import (
+    "os"
+
+    "golang.org/x/sys/windows"
+)
+
+// disableEcho stops the console on f echoing input, returning a function
+// that turns it back on.
+func disableEcho(f *os.File) (restore func(), err error) {
+    h := windows.Handle(f.Fd())
+    var mode uint32
+    if err := windows.GetConsoleMode(h, &mode); err != nil {
+        return nil, err
+    }
+    if err := windows.SetConsoleMode(h, mode&^windows.ENABLE_ECHO_INPUT); err != nil {
+        return nil, err
+    }
+    return func() { windows.SetConsoleMode(h, mode) }, nil
+}
+
+// rawTerminal makes the console on in pass each key through as it is
+// pressed, without echoing it, and the one on out draw ANSI escape codes,
+// returning a function that restores both.
+func rawTerminal(in, out *os.File) (restore func(), err error) {
+    hin, hout := windows.Handle(in.Fd()), windows.Handle(out.Fd())
+    var inMode, outMode uint32
+    if err := windows.GetConsoleMode(hin, &inMode); err != nil {
+        return nil, err
+    }
+    if err := windows.GetConsoleMode(hout, &outMode); err != nil {
+        return nil, err
+    }
+    raw := inMode&^(windows.ENABLE_LINE_INPUT|windows.ENABLE_ECHO_INPUT) | windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+    if err := windows.SetConsoleMode(hin, raw); err != nil {
+        return nil, err
+    }
+    if err := windows.SetConsoleMode(hout, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+        windows.SetConsoleMode(hin, inMode)
+        return nil, err
+    }
+    return func() {
+        windows.SetConsoleMode(hin, inMode)
+        windows.SetConsoleMode(hout, outMode)
+    }, nil
+}
+
+// terminalSize returns the columns and rows of the console window on f,
+// or zero if they aren't known.
+func terminalSize(f *os.File) (cols, rows int) {
+    var info windows.ConsoleScreenBufferInfo
+    if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+        return 0, 0
+    }
+    return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1
+}
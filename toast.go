@@ -0,0 +1,289 @@
+package telephish
+
+import (
+    "context"
+    "fmt"
+    "runtime"
+    "sync"
+    "sync/atomic"
+
+    "github.com/go-ole/go-ole"
+    "github.com/go-ole/go-ole/oleutil"
+
+    "github.com/hacker1337itme/telephish/notify"
+)
+
+// AppName is the display name toasts are shown under.
+const AppName = notify.AppName
+
+// AppID is the AppUserModelID registered by the install step.
+const AppID = "Telephish.Monitor"
+
+// ToastNotifier shows alerts as Windows toast notifications.
+type ToastNotifier struct {
+    Templates *notify.Templates
+}
+
+// Notify displays the alert as a toast, replacing its progress toast if
+// one is showing.
+func (n ToastNotifier) Notify(ctx context.Context, alert notify.Alert) error {
+    if !ToastsSupported() {
+        return fmt.Errorf("toasts are not supported on this system")
+    }
+    toastXML, err := n.Templates.RenderToast(alert)
+    if err != nil {
+        return err
+    }
+    return ShowNotification(ctx, toastXML, alert.ID, nil)
+}
+
+// Progress shows or advances the progress toast for an alert whose link is
+// still being scanned.
+func (n ToastNotifier) Progress(ctx context.Context, alert notify.Alert, done, total int, stage string) error {
+    if !ToastsSupported() {
+        return nil
+    }
+    data := map[string]string{
+        "progressValue":       fmt.Sprintf("%.2f", float64(done)/float64(total)),
+        "progressValueString": fmt.Sprintf("%d/%d", done, total),
+        "progressStatus":      stage,
+    }
+    if done > 0 {
+        return UpdateNotification(ctx, alert.ID, data)
+    }
+    toastXML, err := n.Templates.RenderProgress(alert)
+    if err != nil {
+        return err
+    }
+    return ShowNotification(ctx, toastXML, alert.ID, data)
+}
+
+// BalloonNotifier shows alerts as tray balloon tips, for Windows versions
+// that predate desktop toasts (Server 2012, old LTSB builds).
+type BalloonNotifier struct{}
+
+// Notify displays the alert as a balloon tip.
+func (BalloonNotifier) Notify(ctx context.Context, alert notify.Alert) error {
+    return ShowBalloon(alert.Title, fmt.Sprintf("%s\n%s", alert.Message, alert.URL), alert.Verdict.Severity)
+}
+
+// toastSequence orders data-binding updates; Windows drops updates whose
+// sequence number is not newer than the one it already shows.
+var toastSequence uint32
+
+// ShowNotification displays a toast notification from its XML content.
+// A non-empty tag replaces any toast already shown with the same tag, and
+// data fills the toast's {binding} placeholders.
+func ShowNotification(ctx context.Context, toastXML, tag string, data map[string]string) error {
+    return withToastNotifier(ctx, func(managerDispatch, notifier *ole.IDispatch) error {
+        // Create a Toast Notification content
+        content, err := oleutil.CallMethod(managerDispatch, "GetTemplateContent", 2) // 2 for ToastGeneric
+        if err != nil {
+            return fmt.Errorf("failed to get template content: %v", err)
+        }
+        defer content.Clear() // Release content after use
+
+        // Set the InnerXml property of the Toast Notification
+        if _, err := oleutil.PutProperty(content.ToIDispatch(), "InnerXml", toastXML); err != nil {
+            return fmt.Errorf("failed to set inner XML: %v", err)
+        }
+
+        // Wrap the content in a notification we can tag and bind data to
+        toast, err := oleutil.CreateObject("Windows.UI.Notifications.ToastNotification")
+        if err != nil {
+            return fmt.Errorf("failed to create ToastNotification: %v", err)
+        }
+        defer toast.Release()
+
+        toastDispatch, err := toast.QueryInterface(ole.IID_IDispatch)
+        if err != nil {
+            return fmt.Errorf("failed to query IDispatch: %v", err)
+        }
+        defer toastDispatch.Release()
+
+        if _, err := oleutil.PutProperty(toastDispatch, "Content", content.ToIDispatch()); err != nil {
+            return fmt.Errorf("failed to set toast content: %v", err)
+        }
+        if tag != "" {
+            if _, err := oleutil.PutProperty(toastDispatch, "Tag", tag); err != nil {
+                return fmt.Errorf("failed to set toast tag: %v", err)
+            }
+            if _, err := oleutil.PutProperty(toastDispatch, "Group", AppName); err != nil {
+                return fmt.Errorf("failed to set toast group: %v", err)
+            }
+        }
+        if data != nil {
+            notificationData, err := newNotificationData(data)
+            if err != nil {
+                return err
+            }
+            defer notificationData.Release()
+            if _, err := oleutil.PutProperty(toastDispatch, "Data", notificationData); err != nil {
+                return fmt.Errorf("failed to set toast data: %v", err)
+            }
+        }
+
+        // Display the notification
+        if _, err := oleutil.CallMethod(notifier, "Show", toastDispatch); err != nil {
+            return fmt.Errorf("failed to show notification: %v", err)
+        }
+        return nil
+    })
+}
+
+// UpdateNotification replaces the bound data of the toast shown with tag,
+// e.g. to move a progress bar, without re-showing the toast.
+func UpdateNotification(ctx context.Context, tag string, data map[string]string) error {
+    return withToastNotifier(ctx, func(managerDispatch, notifier *ole.IDispatch) error {
+        notificationData, err := newNotificationData(data)
+        if err != nil {
+            return err
+        }
+        defer notificationData.Release()
+
+        if _, err := oleutil.CallMethod(notifier, "Update", notificationData, tag, AppName); err != nil {
+            return fmt.Errorf("failed to update notification: %v", err)
+        }
+        return nil
+    })
+}
+
+// toastThread runs every toast call on one OS thread. COM objects belong
+// to the thread that created them, and the toast manager is slow to make,
+// so rather than initializing COM and creating it anew for each alert, on
+// whichever thread the calling goroutine happened to be, the thread does
+// that once and keeps them for the life of the process.
+type toastThread struct {
+    once  sync.Once
+    calls chan toastCall
+}
+
+type toastCall struct {
+    fn   func(managerDispatch, notifier *ole.IDispatch) error
+    done chan error
+}
+
+// toastCOM are the objects the toast thread keeps.
+type toastCOM struct {
+    manager         *ole.IUnknown
+    managerDispatch *ole.IDispatch
+    notifier        *ole.VARIANT
+}
+
+var toasts toastThread
+
+// withToastNotifier hands fn the toast manager and a notifier for our
+// AppUserModelID, on the toast thread, and waits for it, or for ctx to be
+// done if the thread is stuck on an earlier call.
+func withToastNotifier(ctx context.Context, fn func(managerDispatch, notifier *ole.IDispatch) error) error {
+    toasts.once.Do(func() {
+        toasts.calls = make(chan toastCall)
+        go toasts.run()
+    })
+    call := toastCall{fn: fn, done: make(chan error, 1)}
+    select {
+    case toasts.calls <- call:
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+    select {
+    case err := <-call.done:
+        return err
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// run initializes COM on a thread of its own and serves toast calls on it.
+// The objects are created on the first call, and again after a call fails,
+// since a notifier whose Explorer has restarted fails every call.
+func (t *toastThread) run() {
+    // Never unlocked, so the thread ends with the process and COM stays
+    // initialized on it until then
+    runtime.LockOSThread()
+    comErr := ole.CoInitialize(0)
+    if oleErr, ok := comErr.(*ole.OleError); ok && oleErr.Code() == 1 {
+        comErr = nil // S_FALSE: already initialized on this thread
+    }
+    var com *toastCOM
+    for call := range t.calls {
+        if comErr != nil {
+            call.done <- fmt.Errorf("failed to initialize OLE: %v", comErr)
+            continue
+        }
+        if com == nil {
+            var err error
+            if com, err = newToastCOM(); err != nil {
+                call.done <- err
+                continue
+            }
+        }
+        err := call.fn(com.managerDispatch, com.notifier.ToIDispatch())
+        if err != nil {
+            com.release()
+            com = nil
+        }
+        call.done <- err
+    }
+}
+
+// newToastCOM creates the toast manager and a notifier for our
+// AppUserModelID.
+func newToastCOM() (*toastCOM, error) {
+    manager, err := oleutil.CreateObject("Windows.UI.Notifications.ToastNotificationManager")
+    if err != nil {
+        return nil, fmt.Errorf("failed to create ToastNotificationManager: %v", err)
+    }
+    managerDispatch, err := manager.QueryInterface(ole.IID_IDispatch)
+    if err != nil {
+        manager.Release()
+        return nil, fmt.Errorf("failed to query IDispatch: %v", err)
+    }
+    notifier, err := oleutil.CallMethod(managerDispatch, "CreateToastNotifier", AppID)
+    if err != nil {
+        managerDispatch.Release()
+        manager.Release()
+        return nil, fmt.Errorf("failed to get ToastNotifier: %v", err)
+    }
+    return &toastCOM{manager: manager, managerDispatch: managerDispatch, notifier: notifier}, nil
+}
+
+func (c *toastCOM) release() {
+    c.notifier.Clear()
+    c.managerDispatch.Release()
+    c.manager.Release()
+}
+
+// newNotificationData builds a NotificationData holding values under the
+// next sequence number.
+func newNotificationData(values map[string]string) (*ole.IDispatch, error) {
+    data, err := oleutil.CreateObject("Windows.UI.Notifications.NotificationData")
+    if err != nil {
+        return nil, fmt.Errorf("failed to create NotificationData: %v", err)
+    }
+    defer data.Release()
+
+    dataDispatch, err := data.QueryInterface(ole.IID_IDispatch)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query IDispatch: %v", err)
+    }
+
+    valueMap, err := oleutil.GetProperty(dataDispatch, "Values")
+    if err != nil {
+        dataDispatch.Release()
+        return nil, fmt.Errorf("failed to get notification values: %v", err)
+    }
+    defer valueMap.Clear()
+
+    for k, v := range values {
+        if _, err := oleutil.CallMethod(valueMap.ToIDispatch(), "Insert", k, v); err != nil {
+            dataDispatch.Release()
+            return nil, fmt.Errorf("failed to set notification value %s: %v", k, err)
+        }
+    }
+    if _, err := oleutil.PutProperty(dataDispatch, "SequenceNumber", atomic.AddUint32(&toastSequence, 1)); err != nil {
+        dataDispatch.Release()
+        return nil, fmt.Errorf("failed to set sequence number: %v", err)
+    }
+    return dataDispatch, nil
+}
@@ -0,0 +1,422 @@
+package telephish
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "net/url"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf8"
+
+    "github.com/hacker1337itme/telephish/analysis"
+    "github.com/hacker1337itme/telephish/extract"
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+)
+
+// tuiMaxEntries is how many alerts the terminal UI keeps in its feed.
+const tuiMaxEntries = 500
+
+// tuiMaxChats is how many chats the counters show, busiest first.
+const tuiMaxChats = 5
+
+// tuiKeys is the key help in the terminal UI's title bar.
+const tuiKeys = "↑↓ select  enter details  s mark safe  b block  q quit"
+
+// tui is the terminal dashboard run --tui draws in place of the alert
+// lines: a live feed of what every profile processes, counters per chat,
+// and keys to allowlist or block the selected alert's domain.
+type tui struct {
+    app     *App // The first; the lists, history and broker are shared
+    in, out *os.File
+    color   bool
+
+    mu       sync.Mutex
+    entries  []store.HistoryEntry // Newest first
+    chats    map[int64]*tuiChat
+    selected int
+    top      int // The first entry on screen
+    detail   bool
+    status   string
+    lastLog  string
+    logBuf   []byte
+}
+
+// tuiChat counts the alerts from one chat.
+type tuiChat struct {
+    id                           int64
+    total, suspicious, malicious int
+    last                         time.Time
+}
+
+func newTUI(app *App, in, out *os.File) *tui {
+    return &tui{app: app, in: in, out: out, color: os.Getenv("NO_COLOR") == "", chats: map[int64]*tuiChat{}}
+}
+
+// Write takes the logs while the UI is up, keeping the last line for its
+// status bar.
+func (t *tui) Write(p []byte) (int, error) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.logBuf = append(t.logBuf, p...)
+    for {
+        i := bytes.IndexByte(t.logBuf, '\n')
+        if i < 0 {
+            break
+        }
+        t.lastLog = string(t.logBuf[:i])
+        t.logBuf = t.logBuf[i+1:]
+    }
+    return len(p), nil
+}
+
+// run draws the UI until ctx is done or q is pressed, which calls quit.
+func (t *tui) run(ctx context.Context, quit func()) error {
+    restore, err := rawTerminal(t.in, t.out)
+    if err != nil {
+        return fmt.Errorf("--tui: %v", err)
+    }
+    // The alternate screen, without a cursor, leaves the shell's as it was
+    fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+    defer func() {
+        fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+        restore()
+    }()
+
+    alerts, unsubscribe := t.app.Alerts.Subscribe()
+    defer unsubscribe()
+    recent, err := t.app.History.Recent(50)
+    if err != nil {
+        appLog.Warn("failed to load recent alerts", "err", err)
+    }
+    for _, e := range recent {
+        t.add(e)
+    }
+
+    done := make(chan struct{})
+    defer close(done)
+    keys := make(chan string)
+    go t.readKeys(keys, done)
+    ticker := time.NewTicker(time.Second)
+    defer ticker.Stop()
+    for {
+        t.draw()
+        select {
+        case <-ctx.Done():
+            return nil
+        case e, ok := <-alerts:
+            if !ok {
+                return nil
+            }
+            t.add(e)
+        case k := <-keys:
+            if !t.key(k) {
+                quit()
+                return nil
+            }
+        case <-ticker.C:
+        }
+    }
+}
+
+// readKeys sends the keys pressed, naming the arrows, enter and escape,
+// until done is closed.
+func (t *tui) readKeys(keys chan<- string, done <-chan struct{}) {
+    buf := make([]byte, 32)
+    for {
+        n, err := t.in.Read(buf)
+        if err != nil {
+            return
+        }
+        for in := buf[:n]; len(in) > 0; {
+            var k string
+            switch {
+            case bytes.HasPrefix(in, []byte("\x1b[A")), bytes.HasPrefix(in, []byte("\x1bOA")):
+                k, in = "up", in[3:]
+            case bytes.HasPrefix(in, []byte("\x1b[B")), bytes.HasPrefix(in, []byte("\x1bOB")):
+                k, in = "down", in[3:]
+            case in[0] == '\x1b' && len(in) > 2 && in[1] == '[':
+                // Another key's sequence; skip it whole
+                i := 2
+                for i < len(in) && (in[i] < 0x40 || in[i] > 0x7e) {
+                    i++
+                }
+                in = in[min(i+1, len(in)):]
+                continue
+            case in[0] == '\x1b':
+                k, in = "esc", in[1:]
+            case in[0] == '\r' || in[0] == '\n':
+                k, in = "enter", in[1:]
+            default:
+                k, in = string(in[0]), in[1:]
+            }
+            select {
+            case keys <- k:
+            case <-done:
+                return
+            }
+        }
+    }
+}
+
+// add puts e at the top of the feed and counts it for its chat.
+func (t *tui) add(e store.HistoryEntry) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.entries = append([]store.HistoryEntry{e}, t.entries...)
+    if len(t.entries) > tuiMaxEntries {
+        t.entries = t.entries[:tuiMaxEntries]
+    }
+    // Keep the selection on the same alert as the feed moves under it
+    if t.selected > 0 || t.detail {
+        t.selected = min(t.selected+1, len(t.entries)-1)
+        t.top++
+    }
+
+    c := t.chats[e.Alert.ChatID]
+    if c == nil {
+        c = &tuiChat{id: e.Alert.ChatID}
+        t.chats[e.Alert.ChatID] = c
+    }
+    c.total++
+    switch e.Alert.Verdict.Severity {
+    case analysis.SeveritySuspicious:
+        c.suspicious++
+    case analysis.SeverityMalicious:
+        c.malicious++
+    }
+    if e.Time.After(c.last) {
+        c.last = e.Time
+    }
+}
+
+// key acts on a key press, reporting false for the one that quits.
+func (t *tui) key(k string) bool {
+    switch k {
+    case "s":
+        t.list(analysis.ListAllow, "marked safe")
+        return true
+    case "b":
+        t.list(analysis.ListBlock, "blocked")
+        return true
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.status = ""
+    switch k {
+    case "q", "\x03":
+        return false
+    case "up", "k":
+        if t.selected > 0 {
+            t.selected--
+        }
+    case "down", "j":
+        if t.selected < len(t.entries)-1 {
+            t.selected++
+        }
+    case "enter":
+        t.detail = !t.detail && len(t.entries) > 0
+    case "esc":
+        t.detail = false
+    }
+    return true
+}
+
+// list puts the selected alert's domain on list, as the dashboard's list
+// form does, and says so in the status bar. It logs, so it must not be
+// called holding t.mu.
+func (t *tui) list(list, done string) {
+    t.mu.Lock()
+    var link string
+    if t.selected < len(t.entries) {
+        link = t.entries[t.selected].Alert.URL
+    }
+    t.mu.Unlock()
+
+    status := func(s string) {
+        t.mu.Lock()
+        t.status = s
+        t.mu.Unlock()
+    }
+    u, err := url.Parse(link)
+    if err != nil || u.Hostname() == "" {
+        status("the selected alert has no domain")
+        return
+    }
+    host := u.Hostname()
+    if err := t.app.Lists.Add(list, host, "added from the terminal UI"); err != nil {
+        status(err.Error())
+        return
+    }
+    appLog.Info("list updated from terminal UI", "list", list, "domain", host, "action", "add")
+    t.app.audit(cliActor(), list+"list.add", host, "")
+    status(done + ": " + extract.DisplayHost(host))
+}
+
+// draw redraws the whole screen.
+func (t *tui) draw() {
+    cols, rows := terminalSize(t.out)
+    if cols <= 0 || rows <= 0 {
+        cols, rows = 80, 24
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    var lines []string
+    title := fmt.Sprintf(" %s — %d alerts from %d chats", notify.AppName, len(t.entries), len(t.chats))
+    pad := cols - utf8.RuneCountInString(title) - utf8.RuneCountInString(tuiKeys) - 1
+    if pad > 0 {
+        title += strings.Repeat(" ", pad) + tuiKeys + " "
+    }
+    lines = append(lines, "\x1b[7m"+fit(title, cols)+"\x1b[0m", "")
+    lines = append(lines, t.chatLines(cols)...)
+    lines = append(lines, "")
+
+    body := rows - len(lines) - 1
+    if t.detail && t.selected < len(t.entries) {
+        lines = append(lines, t.detailLines(t.entries[t.selected], cols)...)
+    } else {
+        lines = append(lines, t.feedLines(cols, body)...)
+    }
+    if len(lines) > rows-1 {
+        lines = lines[:rows-1]
+    }
+    for len(lines) < rows-1 {
+        lines = append(lines, "")
+    }
+    status := t.status
+    if status == "" {
+        status = t.lastLog
+    }
+    lines = append(lines, "\x1b[2m"+fit(status, cols)+"\x1b[0m")
+
+    var b strings.Builder
+    b.WriteString("\x1b[H")
+    for i, line := range lines {
+        if i > 0 {
+            b.WriteString("\r\n")
+        }
+        b.WriteString(line)
+        b.WriteString("\x1b[K")
+    }
+    fmt.Fprint(t.out, b.String())
+}
+
+// chatLines counts the alerts of the busiest chats.
+func (t *tui) chatLines(cols int) []string {
+    chats := make([]*tuiChat, 0, len(t.chats))
+    for _, c := range t.chats {
+        chats = append(chats, c)
+    }
+    sort.Slice(chats, func(i, j int) bool {
+        if chats[i].total != chats[j].total {
+            return chats[i].total > chats[j].total
+        }
+        return chats[i].id < chats[j].id
+    })
+    lines := []string{"\x1b[1m" + fit(fmt.Sprintf("%-16s %7s %11s %10s  %s", "CHAT", "ALERTS", "SUSPICIOUS", "MALICIOUS", "LAST"), cols) + "\x1b[0m"}
+    for i, c := range chats {
+        if i == tuiMaxChats {
+            lines = append(lines, fit(fmt.Sprintf("and %d more", len(chats)-i), cols))
+            break
+        }
+        lines = append(lines, fit(fmt.Sprintf("%-16d %7d %11d %10d  %s", c.id, c.total, c.suspicious, c.malicious, c.last.Local().Format("Jan 02 15:04:05")), cols))
+    }
+    return lines
+}
+
+// feedLines lists as many alerts as fit in rows, scrolled to keep the
+// selected one on screen.
+func (t *tui) feedLines(cols, rows int) []string {
+    rows-- // For the header
+    if rows < 1 {
+        return nil
+    }
+    if t.selected < t.top {
+        t.top = t.selected
+    }
+    if t.selected >= t.top+rows {
+        t.top = t.selected - rows + 1
+    }
+    t.top = max(0, min(t.top, len(t.entries)-rows))
+
+    lines := []string{"\x1b[1m" + fit(fmt.Sprintf("%-8s  %-10s  %-16s  %s", "TIME", "SEVERITY", "CHAT", "DOMAIN · URL"), cols) + "\x1b[0m"}
+    if len(t.entries) == 0 {
+        return append(lines, "waiting for alerts…")
+    }
+    for i := t.top; i < len(t.entries) && i < t.top+rows; i++ {
+        e := t.entries[i]
+        severity := strings.ToUpper(e.Alert.Verdict.Severity.String())
+        line := fmt.Sprintf("%-8s  %-10s  %-16d  %s · %s", e.Time.Local().Format("15:04:05"), severity, e.Alert.ChatID, extract.DisplayDomain(e.Alert.URL), e.Alert.URL)
+        line = fit(line, cols)
+        switch {
+        case i == t.selected:
+            line = "\x1b[7m" + line + strings.Repeat(" ", max(0, cols-utf8.RuneCountInString(line))) + "\x1b[0m"
+        case t.color && len(line) >= 20:
+            // The severity is the second column, ten characters in
+            line = line[:10] + notify.SeverityColor(e.Alert.Verdict.Severity) + line[10:20] + "\x1b[0m" + line[20:]
+        }
+        lines = append(lines, line)
+    }
+    return lines
+}
+
+// detailLines describes e in full: its link, where it came from, the
+// findings behind its verdict, what each sink did and the message.
+func (t *tui) detailLines(e store.HistoryEntry, cols int) []string {
+    a := e.Alert
+    severity := strings.ToUpper(a.Verdict.Severity.String())
+    if t.color {
+        severity = notify.SeverityColor(a.Verdict.Severity) + severity + "\x1b[0m"
+    }
+    lines := []string{
+        fit("URL       "+a.URL, cols),
+        fit("Domain    "+extract.DisplayDomain(a.URL), cols),
+        fit("From      "+a.Origin(), cols),
+        fit("Sender    "+a.Sender, cols),
+        fit("Time      "+e.Time.Local().Format(time.RFC1123), cols),
+        "Verdict   " + severity + fmt.Sprintf(", score %d, in %s", notify.Score(a), a.Verdict.Duration.Round(time.Millisecond)),
+        "",
+        "\x1b[1mFINDINGS\x1b[0m",
+    }
+    for _, f := range a.Verdict.Findings {
+        lines = append(lines, fit(fmt.Sprintf("  %-10s  %-12s  %s", f.Severity, f.Analyzer, f.Description), cols))
+    }
+    lines = append(lines, "", "\x1b[1mACTIONS\x1b[0m")
+    for _, act := range e.Actions {
+        line := fmt.Sprintf("  %-8s  %-12s  %-10s", act.Time.Local().Format("15:04:05"), act.Sink, act.Status)
+        if act.Error != "" {
+            line += "  " + act.Error
+        }
+        lines = append(lines, fit(line, cols))
+    }
+    if e.Text != "" {
+        lines = append(lines, "", "\x1b[1mMESSAGE\x1b[0m")
+        for _, line := range strings.Split(e.Text, "\n") {
+            lines = append(lines, fit("  "+line, cols))
+        }
+    }
+    return lines
+}
+
+// fit cuts s to cols characters, marking the cut with an ellipsis, and
+// drops control characters that would move the cursor.
+func fit(s string, cols int) string {
+    s = strings.Map(func(r rune) rune {
+        if r < ' ' || r == 0x7f {
+            return ' '
+        }
+        return r
+    }, s)
+    if utf8.RuneCountInString(s) <= cols {
+        return s
+    }
+    if cols <= 0 {
+        return ""
+    }
+    return string([]rune(s)[:cols-1]) + "…"
+}
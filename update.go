@@ -0,0 +1,318 @@
+package telephish
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hacker1337itme/telephish/internal/netutil"
+)
+
+// updateKey is the base64 Ed25519 public key release checksums are signed
+// with, set at build time with
+// -ldflags "-X github.com/hacker1337itme/telephish.updateKey=...".
+// update.public_key overrides it.
+var updateKey = ""
+
+// maxUpdateSize bounds each file downloaded by `update`.
+const maxUpdateSize = 200 << 20
+
+// Release assets other than the binaries: sha256sum output for every
+// asset, and its Ed25519 signature.
+const (
+    checksumsAsset = "checksums.txt"
+    signatureAsset = "checksums.txt.sig"
+)
+
+// release is the part of a GitHub release `update` reads.
+type release struct {
+    Tag    string         `json:"tag_name"`
+    Assets []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+    Name string `json:"name"`
+    URL  string `json:"browser_download_url"`
+}
+
+// updateCommand replaces the running binary with the latest release, or
+// the one named by --version, once its checksum and signature check out.
+func updateCommand(ctx context.Context, args []string) error {
+    fs, configPath := newFlagSet("update")
+    check := fs.Bool("check", false, "only report whether a newer release is available")
+    tag := fs.String("version", "", "install this release tag instead of the latest, even if it is older")
+    force := fs.Bool("force", false, "reinstall the release even if it is the running version")
+    unsigned := fs.Bool("unsigned", false, "trust the checksum alone if no signing key is built in or configured")
+    restart := fs.Bool("restart", true, "restart the service afterwards if it is running")
+    fs.Parse(args)
+
+    cfg, err := loadConfig(*configPath)
+    if err != nil {
+        return err
+    }
+    key := updateKey
+    if cfg.Update.PublicKey != "" {
+        key = cfg.Update.PublicKey
+    }
+    var pub ed25519.PublicKey
+    if key != "" {
+        if pub, err = parsePublicKey(key); err != nil {
+            return fmt.Errorf("invalid update signing key: %v", err)
+        }
+    } else if !*unsigned && !*check {
+        return fmt.Errorf("no update signing key is built in or set in update.public_key; pass --unsigned to trust the checksum alone")
+    }
+
+    client := &http.Client{Transport: netutil.NewTransport(nil), Timeout: 5 * time.Minute}
+    rel, err := fetchRelease(ctx, client, cfg.Update, *tag)
+    if err != nil {
+        return err
+    }
+    newer := compareVersions(rel.Tag, version) > 0
+    if *check {
+        if newer {
+            fmt.Printf("%s %s is available (running %s)\n", AppName, rel.Tag, version)
+        } else {
+            fmt.Printf("%s %s is up to date\n", AppName, version)
+        }
+        return nil
+    }
+    if !*force && (compareVersions(rel.Tag, version) == 0 || *tag == "" && !newer) {
+        fmt.Printf("%s %s is up to date\n", AppName, version)
+        return nil
+    }
+
+    name := binaryAsset()
+    bin, err := downloadAsset(ctx, client, rel, name)
+    if err != nil {
+        return err
+    }
+    sums, err := downloadAsset(ctx, client, rel, checksumsAsset)
+    if err != nil {
+        return err
+    }
+    if pub != nil {
+        sig, err := downloadAsset(ctx, client, rel, signatureAsset)
+        if err != nil {
+            return fmt.Errorf("release %s is not signed: %v", rel.Tag, err)
+        }
+        if err := verifySignature(pub, sums, sig); err != nil {
+            return fmt.Errorf("release %s: %v", rel.Tag, err)
+        }
+    } else {
+        commandsLog.Warn("no update signing key; trusting the checksum alone")
+    }
+    if err := verifyChecksum(sums, name, bin); err != nil {
+        return fmt.Errorf("release %s: %v", rel.Tag, err)
+    }
+
+    exe, err := os.Executable()
+    if err == nil {
+        exe, err = filepath.EvalSymlinks(exe)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to locate executable: %v", err)
+    }
+    if err := replaceExecutable(exe, bin); err != nil {
+        return fmt.Errorf("failed to replace %s: %v", exe, err)
+    }
+    fmt.Printf("updated %s from %s to %s\n", AppName, version, rel.Tag)
+
+    if *restart {
+        restarted, err := restartService()
+        if err != nil {
+            return fmt.Errorf("updated, but failed to restart the service: %v", err)
+        }
+        if restarted {
+            fmt.Println("restarted the service")
+        }
+    }
+    return nil
+}
+
+// fetchRelease asks the GitHub API for the release tagged tag, or the
+// latest one if tag is empty. GITHUB_TOKEN, if set, raises the API's
+// rate limit.
+func fetchRelease(ctx context.Context, client *http.Client, cfg UpdateConfig, tag string) (release, error) {
+    var rel release
+    endpoint := strings.TrimSuffix(cfg.API, "/") + "/repos/" + cfg.Repo + "/releases/latest"
+    if tag != "" {
+        endpoint = strings.TrimSuffix(cfg.API, "/") + "/repos/" + cfg.Repo + "/releases/tags/" + url.PathEscape(tag)
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+    if err != nil {
+        return rel, err
+    }
+    req.Header.Set("Accept", "application/vnd.github+json")
+    req.Header.Set("User-Agent", AppName+"/"+version)
+    if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return rel, fmt.Errorf("failed to check for releases: %v", err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return rel, fmt.Errorf("failed to check for releases of %s: %s", cfg.Repo, resp.Status)
+    }
+    if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&rel); err != nil {
+        return rel, fmt.Errorf("failed to decode release: %v", err)
+    }
+    return rel, nil
+}
+
+// binaryAsset names the release asset built for this platform, such as
+// telephish_linux_amd64 or telephish_windows_amd64.exe.
+func binaryAsset() string {
+    name := "telephish_" + runtime.GOOS + "_" + runtime.GOARCH
+    if runtime.GOOS == "windows" {
+        name += ".exe"
+    }
+    return name
+}
+
+// downloadAsset fetches the asset called name from rel.
+func downloadAsset(ctx context.Context, client *http.Client, rel release, name string) ([]byte, error) {
+    var link string
+    for _, asset := range rel.Assets {
+        if asset.Name == name {
+            link = asset.URL
+        }
+    }
+    if link == "" {
+        return nil, fmt.Errorf("release %s has no %s", rel.Tag, name)
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("User-Agent", AppName+"/"+version)
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to download %s: %v", name, err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("failed to download %s: %s", name, resp.Status)
+    }
+    data, err := io.ReadAll(io.LimitReader(resp.Body, maxUpdateSize+1))
+    if err != nil {
+        return nil, fmt.Errorf("failed to download %s: %v", name, err)
+    }
+    if len(data) > maxUpdateSize {
+        return nil, fmt.Errorf("failed to download %s: larger than %d MB", name, maxUpdateSize>>20)
+    }
+    return data, nil
+}
+
+// parsePublicKey decodes a base64 Ed25519 public key.
+func parsePublicKey(s string) (ed25519.PublicKey, error) {
+    key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+    if err != nil {
+        return nil, fmt.Errorf("want base64: %v", err)
+    }
+    if len(key) != ed25519.PublicKeySize {
+        return nil, fmt.Errorf("want a %d-byte Ed25519 key, got %d bytes", ed25519.PublicKeySize, len(key))
+    }
+    return ed25519.PublicKey(key), nil
+}
+
+// verifySignature checks sig, raw or base64, is pub's signature of sums.
+func verifySignature(pub ed25519.PublicKey, sums, sig []byte) error {
+    if len(sig) != ed25519.SignatureSize {
+        decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+        if err != nil {
+            return fmt.Errorf("malformed %s: %v", signatureAsset, err)
+        }
+        sig = decoded
+    }
+    if !ed25519.Verify(pub, sums, sig) {
+        return fmt.Errorf("%s does not match its signature", checksumsAsset)
+    }
+    return nil
+}
+
+// verifyChecksum checks data against name's SHA-256 in sums, which is
+// sha256sum output.
+func verifyChecksum(sums []byte, name string, data []byte) error {
+    scanner := bufio.NewScanner(bytes.NewReader(sums))
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+            continue
+        }
+        want, err := hex.DecodeString(fields[0])
+        if err != nil {
+            return fmt.Errorf("malformed checksum for %s: %v", name, err)
+        }
+        got := sha256.Sum256(data)
+        if !bytes.Equal(got[:], want) {
+            return fmt.Errorf("%s does not match its checksum", name)
+        }
+        return nil
+    }
+    return fmt.Errorf("%s lists no checksum for %s", checksumsAsset, name)
+}
+
+// compareVersions compares release versions such as v1.4.0, ignoring any
+// pre-release or build suffix. A version that isn't one, like a "dev"
+// build, is older than every release.
+func compareVersions(a, b string) int {
+    pa, pb := versionParts(a), versionParts(b)
+    switch {
+    case pa == nil && pb == nil:
+        return strings.Compare(a, b)
+    case pa == nil:
+        return -1
+    case pb == nil:
+        return 1
+    }
+    for i := 0; i < len(pa) || i < len(pb); i++ {
+        var x, y int
+        if i < len(pa) {
+            x = pa[i]
+        }
+        if i < len(pb) {
+            y = pb[i]
+        }
+        if x != y {
+            if x < y {
+                return -1
+            }
+            return 1
+        }
+    }
+    return 0
+}
+
+func versionParts(v string) []int {
+    v = strings.TrimPrefix(v, "v")
+    if i := strings.IndexAny(v, "-+"); i >= 0 {
+        v = v[:i]
+    }
+    var parts []int
+    for _, field := range strings.Split(v, ".") {
+        n, err := strconv.Atoi(field)
+        if err != nil {
+            return nil
+        }
+        parts = append(parts, n)
+    }
+    return parts
+}
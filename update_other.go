@@ -0,0 +1,36 @@
+//go:build !windows
+
+package telephish
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+
+    "github.com/hacker1337itme/telephish/internal/fsutil"
+)
+
+// systemdUnit is the unit the README has `systemd-unit` installed as.
+const systemdUnit = "telephish.service"
+
+// replaceExecutable writes data over exe by renaming a new file into place,
+// which leaves a running copy on the old one until it restarts.
+func replaceExecutable(exe string, data []byte) error {
+    info, err := os.Stat(exe)
+    if err != nil {
+        return err
+    }
+    return fsutil.WriteFile(exe, data, info.Mode().Perm())
+}
+
+// restartService restarts the systemd unit if it is active, reporting
+// whether it was.
+func restartService() (bool, error) {
+    if exec.Command("systemctl", "is-active", "--quiet", systemdUnit).Run() != nil {
+        return false, nil
+    }
+    if out, err := exec.Command("systemctl", "restart", systemdUnit).CombinedOutput(); err != nil {
+        return true, fmt.Errorf("%v: %s", err, out)
+    }
+    return true, nil
+}
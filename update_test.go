@@ -0,0 +1,61 @@
+package telephish
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "testing"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+    data := []byte("telephish binary")
+    sum := sha256.Sum256(data)
+    good := hex.EncodeToString(sum[:])
+    tests := []struct {
+        name string
+        sums string
+        ok   bool
+    }{
+        {"text mode", good + "  telephish.exe\n", true},
+        {"binary mode", good + " *telephish.exe\n", true},
+        {"among others", "00ff  telephish-linux\n" + good + "  telephish.exe\n", true},
+        {"wrong sum", "00" + good[2:] + "  telephish.exe\n", false},
+        {"not hex", "zz  telephish.exe\n", false},
+        {"not listed", good + "  telephish-linux\n", false},
+        {"name as a prefix", good + "  telephish.exe.sig\n", false},
+        {"empty", "", false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := verifyChecksum([]byte(tt.sums), "telephish.exe", data)
+            if (err == nil) != tt.ok {
+                t.Errorf("verifyChecksum = %v, want ok %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestCompareVersions(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want int
+    }{
+        {"v1.4.0", "v1.4.0", 0},
+        {"v1.4.0", "1.4.0", 0},
+        {"v1.4", "v1.4.0", 0},
+        {"v1.4.0", "v1.5.0", -1},
+        {"v1.10.0", "v1.9.0", 1},
+        {"v2.0.0", "v1.99.99", 1},
+        {"v1.4.1", "v1.4", 1},
+        {"v1.4.0-rc1", "v1.4.0", 0},
+        {"v1.4.0+build.7", "v1.3.9", 1},
+        {"dev", "v0.0.1", -1},
+        {"v0.0.1", "dev", 1},
+        {"dev", "dev", 0},
+        {"v1.x.0", "v1.0.0", -1},
+    }
+    for _, tt := range tests {
+        if got := compareVersions(tt.a, tt.b); got != tt.want {
+            t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,66 @@
+package telephish
+
+import (
+    "fmt"
+    "os"
+    "time"
+
+    "golang.org/x/sys/windows/svc"
+    "golang.org/x/sys/windows/svc/mgr"
+
+    "github.com/hacker1337itme/telephish/internal/fsutil"
+)
+
+// replaceExecutable moves the running exe aside, since Windows won't
+// overwrite it but will rename it, and writes data in its place. The old
+// copy is removed by the next update.
+func replaceExecutable(exe string, data []byte) error {
+    old := exe + ".old"
+    os.Remove(old)
+    if err := os.Rename(exe, old); err != nil {
+        return err
+    }
+    if err := fsutil.WriteFile(exe, data, 0o755); err != nil {
+        os.Rename(old, exe)
+        return err
+    }
+    return nil
+}
+
+// restartService stops the service, waiting for it to finish the scans it
+// has started, and starts it again on the new binary. It reports false if
+// the service isn't installed or running.
+func restartService() (bool, error) {
+    m, err := mgr.Connect()
+    if err != nil {
+        return false, nil
+    }
+    defer m.Disconnect()
+    s, err := m.OpenService(ServiceName)
+    if err != nil {
+        return false, nil
+    }
+    defer s.Close()
+    status, err := s.Query()
+    if err != nil || status.State != svc.Running {
+        return false, nil
+    }
+
+    if status, err = s.Control(svc.Stop); err != nil {
+        return true, fmt.Errorf("failed to stop service: %v", err)
+    }
+    deadline := time.Now().Add(2 * time.Minute)
+    for status.State != svc.Stopped {
+        if time.Now().After(deadline) {
+            return true, fmt.Errorf("service did not stop within 2 minutes")
+        }
+        time.Sleep(500 * time.Millisecond)
+        if status, err = s.Query(); err != nil {
+            return true, fmt.Errorf("failed to query service: %v", err)
+        }
+    }
+    if err := s.Start(); err != nil {
+        return true, fmt.Errorf("failed to start service: %v", err)
+    }
+    return true, nil
+}
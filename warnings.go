@@ -0,0 +1,34 @@
+package telephish
+
+import (
+    "context"
+    "log/slog"
+
+    "github.com/hacker1337itme/telephish/notify"
+    "github.com/hacker1337itme/telephish/store"
+    "github.com/hacker1337itme/telephish/telegram"
+)
+
+// warn replies to the message of entry in its group with a warning about
+// its link, if the group has warnings on and the verdict is at least
+// warnings.severity, so that members see it whether or not anyone watches
+// the alerts. It reports false if there was nothing to warn about.
+func (a *App) warn(ctx context.Context, logger *slog.Logger, entry store.HistoryEntry) (notify.Action, bool) {
+    alert := entry.Alert
+    if alert.ChatType != "group" && alert.ChatType != "supergroup" || entry.MessageID == 0 {
+        return notify.Action{}, false
+    }
+    if alert.Verdict.Severity < a.Config.Warnings.Severity || !a.Prefs.For(alert.ChatID, alert.ChatType).Warn {
+        return notify.Action{}, false
+    }
+    text, err := a.Templates.RenderWarning(alert)
+    if err == nil {
+        err = telegram.SendReply(ctx, a.Config.Telegram.Token, alert.ChatID, entry.MessageID, text, "MarkdownV2")
+    }
+    if err != nil {
+        logger.Error("failed to post warning", "message_id", entry.MessageID, "err", err)
+    } else {
+        logger.Info("posted warning", "message_id", entry.MessageID)
+    }
+    return notify.NewAction("warning", err), true
+}
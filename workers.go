@@ -0,0 +1,240 @@
+package telephish
+
+import (
+    "net/url"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Overflow behaviors for a full work queue.
+const (
+    OverflowBlock = "block" // Wait for room, holding up the poller
+    OverflowDrop  = "drop"  // Skip the update
+)
+
+// workQueue runs jobs on a fixed number of workers. Jobs wait in a
+// bounded queue; when it is full, Submit either waits or gives up. A job
+// that panics is recovered by sup, so the worker goes on to the next one.
+type workQueue struct {
+    jobs chan func()
+    drop bool
+    sup  *supervisor
+    wg   sync.WaitGroup
+    once sync.Once
+}
+
+func newWorkQueue(cfg WorkersConfig, sup *supervisor) *workQueue {
+    q := &workQueue{jobs: make(chan func(), cfg.Queue), drop: cfg.Overflow == OverflowDrop, sup: sup}
+    for i := 0; i < cfg.Count; i++ {
+        q.wg.Add(1)
+        go func() {
+            defer q.wg.Done()
+            for job := range q.jobs {
+                q.sup.protect("workers", job)
+            }
+        }()
+    }
+    return q
+}
+
+// Submit queues job, reporting false if the queue was full and overflow
+// is set to drop.
+func (q *workQueue) Submit(job func()) bool {
+    if !q.drop {
+        q.jobs <- job
+        return true
+    }
+    return q.TrySubmit(job)
+}
+
+// TrySubmit queues job if there is room, whatever overflow is set to,
+// and reports whether there was.
+func (q *workQueue) TrySubmit(job func()) bool {
+    select {
+    case q.jobs <- job:
+        return true
+    default:
+        return false
+    }
+}
+
+// Waiting returns how many jobs are queued for a worker.
+func (q *workQueue) Waiting() int {
+    return len(q.jobs)
+}
+
+// Close runs the jobs still queued and waits for the workers to finish.
+// Nothing may be submitted afterwards.
+func (q *workQueue) Close() {
+    q.once.Do(func() { close(q.jobs) })
+    q.wg.Wait()
+}
+
+// laneIdle is how long a chat's lane stays up with nothing to do.
+const laneIdle = 10 * time.Minute
+
+// chatLanes gives each chat its own workers and queue, so a flood of links
+// in a busy group can't delay the scan of a phishing link sent in a DM: a
+// full lane blocks or drops only as its own overflow says. A lane starts
+// with its chat's first update and stops once the chat has been quiet for
+// laneIdle.
+type chatLanes struct {
+    cfg WorkersConfig
+    sup *supervisor
+
+    mu    sync.Mutex
+    lanes map[int64]*chatLane
+}
+
+type chatLane struct {
+    queue   *workQueue
+    pending int // Jobs submitted and not yet finished
+    used    time.Time
+}
+
+// ChatQueueStatus is the state of one chat's lane in the health status.
+type ChatQueueStatus struct {
+    ChatID  int64 `json:"chat_id"`
+    Workers int   `json:"workers"`
+    Waiting int   `json:"waiting"` // Updates queued for one of the lane's workers
+    Pending int   `json:"pending"` // Updates queued or being scanned
+}
+
+func newChatLanes(cfg WorkersConfig, sup *supervisor) *chatLanes {
+    return &chatLanes{cfg: cfg, sup: sup, lanes: map[int64]*chatLane{}}
+}
+
+// Submit queues job in chatID's lane, starting the lane if need be. Like
+// workQueue.Submit, it reports false if the lane was full and its overflow
+// is set to drop.
+func (l *chatLanes) Submit(chatID int64, job func()) bool {
+    now := time.Now()
+    l.mu.Lock()
+    l.retire(now)
+    lane := l.lanes[chatID]
+    if lane == nil {
+        lane = &chatLane{queue: newWorkQueue(l.cfg.forChat(chatID), l.sup)}
+        l.lanes[chatID] = lane
+    }
+    lane.pending++
+    lane.used = now
+    l.mu.Unlock()
+
+    if lane.queue.Submit(func() {
+        defer l.finished(lane)
+        job()
+    }) {
+        return true
+    }
+    l.finished(lane)
+    return false
+}
+
+func (l *chatLanes) finished(lane *chatLane) {
+    l.mu.Lock()
+    lane.pending--
+    lane.used = time.Now()
+    l.mu.Unlock()
+}
+
+// retire stops the lanes idle since laneIdle before now. l.mu is held, so
+// no job can be submitted to a lane while it stops.
+func (l *chatLanes) retire(now time.Time) {
+    for id, lane := range l.lanes {
+        if lane.pending == 0 && now.Sub(lane.used) > laneIdle {
+            delete(l.lanes, id)
+            lane.queue.Close()
+        }
+    }
+}
+
+// Waiting returns how many jobs are queued in all the lanes.
+func (l *chatLanes) Waiting() int {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    n := 0
+    for _, lane := range l.lanes {
+        n += lane.queue.Waiting()
+    }
+    return n
+}
+
+// Status returns the state of the lanes with work to do, by chat ID.
+func (l *chatLanes) Status() []ChatQueueStatus {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    var status []ChatQueueStatus
+    for id, lane := range l.lanes {
+        if lane.pending > 0 {
+            status = append(status, ChatQueueStatus{ChatID: id, Workers: l.cfg.forChat(id).Count, Waiting: lane.queue.Waiting(), Pending: lane.pending})
+        }
+    }
+    sort.Slice(status, func(i, j int) bool { return status[i].ChatID < status[j].ChatID })
+    return status
+}
+
+// Close runs the jobs still queued in every lane and waits for them to
+// finish. Nothing may be submitted afterwards.
+func (l *chatLanes) Close() {
+    l.mu.Lock()
+    lanes := l.lanes
+    l.lanes = map[int64]*chatLane{}
+    l.mu.Unlock()
+    var wg sync.WaitGroup
+    for _, lane := range lanes {
+        wg.Add(1)
+        go func(q *workQueue) {
+            defer wg.Done()
+            q.Close()
+        }(lane.queue)
+    }
+    wg.Wait()
+}
+
+// domainLimiter caps how many scans of one host run at once, so a burst
+// of links to a single slow site can't take every worker.
+type domainLimiter struct {
+    limit int // Zero means no limit
+
+    mu    sync.Mutex
+    slots map[string]*domainSlot
+}
+
+type domainSlot struct {
+    sem   chan struct{}
+    users int
+}
+
+// acquire waits for a slot for link's host and returns the function that
+// releases it.
+func (l *domainLimiter) acquire(link string) (release func()) {
+    u, err := url.Parse(link)
+    if l.limit <= 0 || err != nil || u.Hostname() == "" {
+        return func() {}
+    }
+    host := strings.ToLower(u.Hostname())
+
+    l.mu.Lock()
+    if l.slots == nil {
+        l.slots = map[string]*domainSlot{}
+    }
+    slot := l.slots[host]
+    if slot == nil {
+        slot = &domainSlot{sem: make(chan struct{}, l.limit)}
+        l.slots[host] = slot
+    }
+    slot.users++
+    l.mu.Unlock()
+
+    slot.sem <- struct{}{}
+    return func() {
+        <-slot.sem
+        l.mu.Lock()
+        if slot.users--; slot.users == 0 {
+            delete(l.slots, host)
+        }
+        l.mu.Unlock()
+    }
+}
@@ -0,0 +1,37 @@
+package telephish
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/hacker1337itme/telephish/i18n"
+)
+
+func TestLaterDoesNotBlock(t *testing.T) {
+    loc, err := i18n.NewLocalizer("en")
+    if err != nil {
+        t.Fatal(err)
+    }
+    a := &App{}
+    a.Loc = loc
+    // No workers, so the one place in the queue stays taken
+    a.queue = newWorkQueue(WorkersConfig{Queue: 1, Overflow: OverflowBlock}, &a.sup)
+    if !a.queue.Submit(func() {}) {
+        t.Fatal("Submit refused a job with room in the queue")
+    }
+
+    // Commands run with a.mu held, so later must not wait for room
+    a.mu.RLock()
+    reply := make(chan string, 1)
+    go func() { reply <- a.later(context.Background(), 1, "/scan", func() string { return "" }) }()
+    select {
+    case got := <-reply:
+        if want := loc.T("commands.busy"); got != want {
+            t.Errorf("later = %q, want %q", got, want)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("later blocked on a full queue with overflow: block")
+    }
+    a.mu.RUnlock()
+}